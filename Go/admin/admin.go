@@ -0,0 +1,324 @@
+// Package admin serves an optional HTML UI for operators: it lists the
+// registered models, pages through their latest rows, shows the version
+// history of an entity with the columns each version changed, and reverts an
+// entity to an earlier version after confirmation.
+package admin
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+const pageSize = 50
+
+// UI holds the dependencies of the admin pages.
+type UI struct {
+	DB *gorm.DB
+	// Models maps the table names shown in the UI to their versioned models.
+	Models map[string]any
+	// Prefix is the path the UI is mounted under, e.g. "/admin".
+	Prefix string
+	// Actor returns the operator a request acts for, recorded on the
+	// versions reverts write; reverts without one are refused. The default is
+	// the X-Actor header, set by the authenticating proxy as for the API.
+	Actor func(*http.Request) string
+}
+
+// csrfCookie holds the token a revert form must echo, so a form posted
+// from another site, which can send the cookie but not read it, is refused.
+const csrfCookie = "scd_admin_csrf"
+
+// Handler returns the admin routes below Prefix. Keep it behind the same
+// authentication as psql access: reverts write to the tables. A revert is
+// only accepted from the form of its confirmation page, and is recorded with
+// the actor of the request and "admin" as its source.
+func (u *UI) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", u.listModels)
+	mux.HandleFunc("GET /{table}", u.listLatest)
+	mux.HandleFunc("GET /{table}/{id}", u.history)
+	mux.HandleFunc("GET /{table}/{id}/revert", u.confirmRevert)
+	mux.HandleFunc("POST /{table}/{id}/revert", u.revert)
+	return http.StripPrefix(u.Prefix, mux)
+}
+
+// modelView is a registered model together with the columns shown for it.
+type modelView struct {
+	scd.ModelDescriptor
+	Columns []string
+}
+
+func (u *UI) listModels(w http.ResponseWriter, r *http.Request) {
+	var views []modelView
+	for _, d := range scd.RegisteredModels() {
+		if _, ok := u.Models[d.Table]; ok {
+			views = append(views, newModelView(d))
+		}
+	}
+	u.render(w, "models.html", map[string]any{"Models": views})
+}
+
+func (u *UI) listLatest(w http.ResponseWriter, r *http.Request) {
+	view, model, ok := u.model(w, r)
+	if !ok {
+		return
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	offset = max(offset, 0)
+	var rows []map[string]any
+	q := u.DB.WithContext(r.Context()).Model(model).Select("?.*", scd.TableRef(view.Table))
+	err := scd.JoinVersions(q, view.Table, "latest", scd.LatestSubquery(r.Context(), u.DB, model)).
+		Order(clause.OrderByColumn{Column: scd.Column(view.Table, "id")}).Offset(offset).Limit(pageSize + 1).Find(&rows).Error
+	if err != nil {
+		u.renderError(w, http.StatusInternalServerError, err)
+		return
+	}
+	data := map[string]any{"Model": view, "Offset": offset}
+	if len(rows) > pageSize {
+		rows = rows[:pageSize]
+		data["Next"] = offset + pageSize
+	}
+	if offset > 0 {
+		data["Prev"] = max(offset-pageSize, 0)
+	}
+	data["Rows"] = cells(append([]string{"id"}, view.Columns...), rows)
+	u.render(w, "latest.html", data)
+}
+
+// versionView is one version of an entity with the columns it changed.
+type versionView struct {
+	Version int
+	Cells   []string
+	Changed map[string]bool
+	Deleted bool
+	Latest  bool
+}
+
+func (u *UI) history(w http.ResponseWriter, r *http.Request) {
+	view, model, ok := u.model(w, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	rows, err := u.versions(r, model, id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, scd.ErrHistoryTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		u.renderError(w, status, err)
+		return
+	}
+	if len(rows) == 0 {
+		u.renderError(w, http.StatusNotFound, fmt.Errorf("%s %q not found", view.Table, id))
+		return
+	}
+	all := append([]string{"version", "uid", "created_at", "is_deleted"}, view.Columns...)
+	versions := make([]versionView, len(rows))
+	for i, row := range rows {
+		v := versionView{Version: toInt(row["version"]), Cells: cells(all, rows[i:i+1])[0], Latest: i == len(rows)-1}
+		v.Deleted = fmt.Sprint(row["is_deleted"]) == "true"
+		if i > 0 {
+			v.Changed = changed(rows[i-1], row, view.Columns)
+		}
+		versions[i] = v
+	}
+	u.render(w, "history.html", map[string]any{"Model": view, "ID": id, "Columns": all, "Versions": versions})
+}
+
+func (u *UI) confirmRevert(w http.ResponseWriter, r *http.Request) {
+	view, model, ok := u.model(w, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	version, err := strconv.Atoi(r.URL.Query().Get("version"))
+	if err != nil {
+		u.renderError(w, http.StatusBadRequest, errors.New("version must be a number"))
+		return
+	}
+	rows, err := u.versions(r, model, id)
+	if err != nil {
+		u.renderError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var target, latest map[string]any
+	for _, row := range rows {
+		if toInt(row["version"]) == version {
+			target = row
+		}
+		latest = row
+	}
+	if target == nil {
+		u.renderError(w, http.StatusNotFound, fmt.Errorf("%s %q has no version %d", view.Table, id, version))
+		return
+	}
+	type change struct{ Column, From, To string }
+	var changes []change
+	for _, c := range view.Columns {
+		if from, to := fmt.Sprint(latest[c]), fmt.Sprint(target[c]); from != to {
+			changes = append(changes, change{c, from, to})
+		}
+	}
+	token, err := u.csrfToken(w, r)
+	if err != nil {
+		u.renderError(w, http.StatusInternalServerError, err)
+		return
+	}
+	u.render(w, "revert.html", map[string]any{
+		"Model": view, "ID": id, "Version": version, "Latest": toInt(latest["version"]), "Next": toInt(latest["version"]) + 1, "Changes": changes,
+		"CSRF": token,
+	})
+}
+
+func (u *UI) revert(w http.ResponseWriter, r *http.Request) {
+	_, model, ok := u.model(w, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	version, err := strconv.Atoi(r.FormValue("version"))
+	if err != nil {
+		u.renderError(w, http.StatusBadRequest, errors.New("version must be a number"))
+		return
+	}
+	if r.FormValue("confirm") != "yes" {
+		u.renderError(w, http.StatusBadRequest, errors.New("revert was not confirmed"))
+		return
+	}
+	if c, err := r.Cookie(csrfCookie); err != nil || c.Value == "" || subtle.ConstantTimeCompare([]byte(c.Value), []byte(r.FormValue("csrf"))) != 1 {
+		u.renderError(w, http.StatusForbidden, errors.New("missing or invalid CSRF token; reload the revert page"))
+		return
+	}
+	actor := u.actor(r)
+	if actor == "" {
+		u.renderError(w, http.StatusForbidden, errors.New("revert has no actor to record"))
+		return
+	}
+	ctx := scd.WithSource(scd.WithActor(r.Context(), actor), "admin")
+	if err := scd.Revert(ctx, u.DB, model, id, version); err != nil {
+		u.renderError(w, http.StatusInternalServerError, err)
+		return
+	}
+	http.Redirect(w, r, u.Prefix+"/"+r.PathValue("table")+"/"+url.PathEscape(id), http.StatusSeeOther)
+}
+
+func (u *UI) actor(r *http.Request) string {
+	if u.Actor != nil {
+		return u.Actor(r)
+	}
+	return r.Header.Get("X-Actor")
+}
+
+// csrfToken returns the CSRF token of the browser sending r, issuing one
+// in csrfCookie when it has none.
+func (u *UI) csrfToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if c, err := r.Cookie(csrfCookie); err == nil && c.Value != "" {
+		return c.Value, nil
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("issuing CSRF token failed: %w", err)
+	}
+	token := hex.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{Name: csrfCookie, Value: token, Path: u.Prefix + "/",
+		HttpOnly: true, Secure: r.TLS != nil, SameSite: http.SameSiteStrictMode})
+	return token, nil
+}
+
+// versions loads the full history of id, refusing histories over scd's limit.
+func (u *UI) versions(r *http.Request, model any, id string) ([]map[string]any, error) {
+	if _, err := scd.CheckHistorySize(r.Context(), u.DB, model, id, false); err != nil {
+		return nil, err
+	}
+	var rows []map[string]any
+	err := scd.AllVersions(r.Context(), u.DB, model).Where("id = ?", id).Order("version").Find(&rows).Error
+	return rows, err
+}
+
+// model resolves the {table} path segment to a served model.
+func (u *UI) model(w http.ResponseWriter, r *http.Request) (modelView, any, bool) {
+	table := r.PathValue("table")
+	model, ok := u.Models[table]
+	if !ok {
+		u.renderError(w, http.StatusNotFound, fmt.Errorf("unknown table %q", table))
+		return modelView{}, nil, false
+	}
+	d, ok := scd.LookupModel(table)
+	if !ok {
+		u.renderError(w, http.StatusNotFound, fmt.Errorf("table %q is not registered", table))
+		return modelView{}, nil, false
+	}
+	return newModelView(d), model, true
+}
+
+func newModelView(d scd.ModelDescriptor) modelView {
+	v := modelView{ModelDescriptor: d}
+	for _, f := range d.Fields {
+		if !f.Meta {
+			v.Columns = append(v.Columns, f.Column)
+		}
+	}
+	return v
+}
+
+// changed reports the columns whose value differs between two versions.
+func changed(prev, cur map[string]any, columns []string) map[string]bool {
+	out := map[string]bool{}
+	for _, c := range columns {
+		if fmt.Sprint(prev[c]) != fmt.Sprint(cur[c]) {
+			out[c] = true
+		}
+	}
+	return out
+}
+
+// cells formats rows as strings in column order.
+func cells(columns []string, rows []map[string]any) [][]string {
+	out := make([][]string, len(rows))
+	for i, row := range rows {
+		out[i] = make([]string, len(columns))
+		for j, c := range columns {
+			if v := row[c]; v != nil {
+				out[i][j] = fmt.Sprint(v)
+			}
+		}
+	}
+	return out
+}
+
+func toInt(v any) int {
+	n, _ := strconv.Atoi(fmt.Sprint(v))
+	return n
+}
+
+func (u *UI) render(w http.ResponseWriter, name string, data map[string]any) {
+	data["Base"] = u.Prefix
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := templates.ExecuteTemplate(w, name, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (u *UI) renderError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	templates.ExecuteTemplate(w, "error.html", map[string]any{"Base": u.Prefix, "Status": status, "Error": err.Error()})
+}
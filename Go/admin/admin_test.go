@@ -0,0 +1,71 @@
+package admin_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/yourorg/Go/admin"
+	"github.com/yourorg/Go/models"
+)
+
+func newUI() http.Handler {
+	models.Register()
+	ui := &admin.UI{Models: map[string]any{"jobs": models.Job{}}, Prefix: "/admin"}
+	return ui.Handler()
+}
+
+func TestListModelsShowsServedModels(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newUI().ServeHTTP(rec, httptest.NewRequest("GET", "/admin/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `href="/admin/jobs"`) || strings.Contains(body, "timelogs") {
+		t.Errorf("unexpected model list:\n%s", body)
+	}
+}
+
+func TestRevertRequiresConfirmation(t *testing.T) {
+	req := httptest.NewRequest("POST", "/admin/jobs/j1/revert", strings.NewReader(url.Values{"version": {"1"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	newUI().ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400", rec.Code)
+	}
+}
+
+func postRevert(form url.Values, cookie *http.Cookie, actor string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/admin/jobs/j1/revert", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	if actor != "" {
+		req.Header.Set("X-Actor", actor)
+	}
+	rec := httptest.NewRecorder()
+	newUI().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRevertRequiresCSRFTokenAndActor(t *testing.T) {
+	form := url.Values{"version": {"1"}, "confirm": {"yes"}, "csrf": {"token1"}}
+	for _, tc := range []struct {
+		name   string
+		cookie *http.Cookie
+		actor  string
+	}{
+		{"forged without cookie", nil, "alice"},
+		{"token mismatch", &http.Cookie{Name: "scd_admin_csrf", Value: "token2"}, "alice"},
+		{"no actor", &http.Cookie{Name: "scd_admin_csrf", Value: "token1"}, ""},
+	} {
+		if rec := postRevert(form, tc.cookie, tc.actor); rec.Code != http.StatusForbidden {
+			t.Errorf("%s: status %d, want 403", tc.name, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Package adoption helps teams move an existing, non-versioned table onto the
+// SCD layout: backfill current rows as version 1, mirror legacy writes while
+// both tables are live, and verify the two agree before cutting reads over.
+package adoption
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+// Spec pairs a legacy table with the versioned model that replaces it.
+// The legacy table must have an id column plus the model's business columns.
+type Spec struct {
+	LegacyTable string
+	Model       any
+	// Columns restricts the mirrored columns; defaults to all business columns of Model.
+	Columns []string
+}
+
+func (s Spec) resolve(db *gorm.DB) (scd.TableInfo, error) {
+	info, err := scd.Describe(db, s.Model)
+	if err != nil {
+		return info, err
+	}
+	if len(s.Columns) > 0 {
+		info.Columns = s.Columns
+	}
+	return info, nil
+}
+
+// Backfill copies legacy rows that have no versioned counterpart yet into the
+// versioned table as version 1 with a fresh UID. It runs in batches of batchSize
+// and is safe to re-run; it returns the number of rows inserted.
+func Backfill(ctx context.Context, db *gorm.DB, spec Spec, batchSize int) (int64, error) {
+	info, err := spec.resolve(db)
+	if err != nil {
+		return 0, err
+	}
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	query := fmt.Sprintf(
+		`INSERT INTO %[1]s (id, version, uid, %[2]s)
+		SELECT l.id::text, 1, gen_random_uuid()::text, %[3]s FROM %[4]s l
+		WHERE NOT EXISTS (SELECT 1 FROM %[1]s v WHERE v.id = l.id::text)
+		LIMIT ?`,
+		db.Statement.Quote(info.Table), info.QuotedColumnList(db, ""), info.QuotedColumnList(db, "l"), db.Statement.Quote(spec.LegacyTable))
+
+	var total int64
+	for {
+		res := db.WithContext(ctx).Exec(query, batchSize)
+		if res.Error != nil {
+			return total, fmt.Errorf("backfilling %s failed: %w", info.Table, res.Error)
+		}
+		total += res.RowsAffected
+		if res.RowsAffected < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
+
+// InstallDualWrite installs a trigger on the legacy table that mirrors every
+// insert and effective update into the versioned table as a new version.
+// Deletes are not mirrored.
+func InstallDualWrite(ctx context.Context, db *gorm.DB, spec Spec) error {
+	info, err := spec.resolve(db)
+	if err != nil {
+		return err
+	}
+	fn, legacy := triggerFunc(db, spec.LegacyTable), db.Statement.Quote(spec.LegacyTable)
+	body := fmt.Sprintf(
+		`CREATE OR REPLACE FUNCTION %[1]s() RETURNS trigger AS $$
+		BEGIN
+			IF TG_OP = 'UPDATE' AND OLD IS NOT DISTINCT FROM NEW THEN
+				RETURN NEW;
+			END IF;
+			INSERT INTO %[2]s (id, version, uid, %[3]s)
+			SELECT NEW.id::text, COALESCE(MAX(v.version), 0) + 1, gen_random_uuid()::text, %[4]s
+			FROM %[2]s v WHERE v.id = NEW.id::text;
+			RETURN NEW;
+		END
+		$$ LANGUAGE plpgsql`,
+		fn, db.Statement.Quote(info.Table), info.QuotedColumnList(db, ""), info.QuotedColumnList(db, "NEW"))
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(body).Error; err != nil {
+			return fmt.Errorf("creating dual-write function failed: %w", err)
+		}
+		if err := tx.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS scd_dual_write ON %s", legacy)).Error; err != nil {
+			return err
+		}
+		stmt := fmt.Sprintf("CREATE TRIGGER scd_dual_write AFTER INSERT OR UPDATE ON %s FOR EACH ROW EXECUTE FUNCTION %s()", legacy, fn)
+		if err := tx.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("creating dual-write trigger failed: %w", err)
+		}
+		return nil
+	})
+}
+
+// RemoveDualWrite drops the trigger and function created by InstallDualWrite.
+func RemoveDualWrite(ctx context.Context, db *gorm.DB, spec Spec) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS scd_dual_write ON %s", tx.Statement.Quote(spec.LegacyTable))).Error; err != nil {
+			return err
+		}
+		return tx.Exec(fmt.Sprintf("DROP FUNCTION IF EXISTS %s()", triggerFunc(tx, spec.LegacyTable))).Error
+	})
+}
+
+// CutoverReport compares a legacy table with the latest versions of its replacement.
+// The ID slices hold at most the sample size passed to Verify.
+type CutoverReport struct {
+	LegacyRows         int64
+	LatestRows         int64
+	MissingInVersioned []string
+	MissingInLegacy    []string
+	Mismatched         []string
+}
+
+// Ready reports whether reads can be switched to the versioned table.
+func (r CutoverReport) Ready() bool {
+	return r.LegacyRows == r.LatestRows &&
+		len(r.MissingInVersioned) == 0 && len(r.MissingInLegacy) == 0 && len(r.Mismatched) == 0
+}
+
+// Verify builds a CutoverReport, collecting up to sample offending ids per category.
+func Verify(ctx context.Context, db *gorm.DB, spec Spec, sample int) (CutoverReport, error) {
+	var report CutoverReport
+	info, err := spec.resolve(db)
+	if err != nil {
+		return report, err
+	}
+	if sample <= 0 {
+		sample = 100
+	}
+	db = db.WithContext(ctx)
+	legacy := db.Statement.Quote(spec.LegacyTable)
+	latest := fmt.Sprintf("(SELECT v.* FROM %[1]s v JOIN (?) AS latest ON v.id = latest.id AND v.version = latest.max_version)", db.Statement.Quote(info.Table))
+	subq := scd.LatestSubquery(ctx, db, spec.Model)
+
+	if err := db.Table(spec.LegacyTable).Count(&report.LegacyRows).Error; err != nil {
+		return report, fmt.Errorf("counting legacy rows failed: %w", err)
+	}
+	if err := db.Raw("SELECT COUNT(*) FROM "+latest+" cur", subq).Scan(&report.LatestRows).Error; err != nil {
+		return report, fmt.Errorf("counting latest rows failed: %w", err)
+	}
+
+	checks := []struct {
+		dest  *[]string
+		query string
+		args  []any
+	}{
+		{&report.MissingInVersioned, fmt.Sprintf(
+			"SELECT l.id::text FROM %s l WHERE NOT EXISTS (SELECT 1 FROM %s v WHERE v.id = l.id::text) LIMIT ?",
+			legacy, db.Statement.Quote(info.Table)), []any{sample}},
+		{&report.MissingInLegacy, fmt.Sprintf(
+			"SELECT cur.id FROM %s cur WHERE NOT EXISTS (SELECT 1 FROM %s l WHERE l.id::text = cur.id) LIMIT ?",
+			latest, legacy), []any{subq, sample}},
+		{&report.Mismatched, fmt.Sprintf(
+			"SELECT cur.id FROM %s cur JOIN %s l ON l.id::text = cur.id WHERE (%s) IS DISTINCT FROM (%s) LIMIT ?",
+			latest, legacy, info.QuotedColumnList(db, "cur"), info.QuotedColumnList(db, "l")), []any{subq, sample}},
+	}
+	for _, c := range checks {
+		if err := db.Raw(c.query, c.args...).Scan(c.dest).Error; err != nil {
+			return report, fmt.Errorf("verifying %s failed: %w", info.Table, err)
+		}
+	}
+	return report, nil
+}
+
+// triggerFunc is the dual-write function of legacyTable, quoted.
+func triggerFunc(db *gorm.DB, legacyTable string) string {
+	return db.Statement.Quote("scd_dual_write_" + strings.ReplaceAll(legacyTable, ".", "_"))
+}
@@ -1,14 +1,18 @@
 package benchmark
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/yourorg/Go/factory"
 	"github.com/yourorg/Go/models"
 	"github.com/yourorg/Go/repos"
 	"github.com/yourorg/Go/scd"
+	"github.com/yourorg/Go/scdtest"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -26,41 +30,31 @@ func setupDB(b *testing.B) *gorm.DB {
 	return db
 }
 
-func seedMillion(db *gorm.DB) {
-	db.Exec("TRUNCATE TABLE payment_line_items, timelogs, jobs RESTART IDENTITY CASCADE")
+// resetDB empties the benchmark tables, skipping the benchmark when resets
+// are not allowed against this database.
+func resetDB(b *testing.B, db *gorm.DB) {
+	models.Register()
+	err := scdtest.ResetAll(context.Background(), db)
+	if errors.Is(err, scdtest.ErrResetNotAllowed) {
+		b.Skip(err)
+	}
+	if err != nil {
+		b.Fatalf("resetting tables: %v", err)
+	}
+}
+
+func seedMillion(b *testing.B, db *gorm.DB) {
+	resetDB(b, db)
 	for i := 0; i < 10000; i++ {
-		job := models.Job{
-			Versioned:    models.Versioned{ID: fmt.Sprintf("job%d", i), Version: 1, UID: fmt.Sprintf("job-uid-%d", i)},
-			Status:       "active",
-			Rate:         100,
-			Title:        "Engineer",
-			CompanyID:    "comp1",
-			ContractorID: "cont1",
-		}
-		db.Create(&job)
-		timelog := models.Timelog{
-			Versioned: models.Versioned{ID: fmt.Sprintf("tl%d", i), Version: 1, UID: fmt.Sprintf("tl-uid-%d", i)},
-			Duration:  8,
-			TimeStart: time.Now().Add(-2 * time.Hour),
-			TimeEnd:   time.Now().Add(-1 * time.Hour),
-			Type:      "work",
-			JobUID:    fmt.Sprintf("job-uid-%d", i),
-		}
-		db.Create(&timelog)
-		pli := models.PaymentLineItem{
-			Versioned:  models.Versioned{ID: fmt.Sprintf("pli%d", i), Version: 1, UID: fmt.Sprintf("pli-uid-%d", i)},
-			JobUID:     fmt.Sprintf("job-uid-%d", i),
-			TimelogUID: fmt.Sprintf("tl-uid-%d", i),
-			Amount:     800,
-			Status:     "pending",
-		}
-		db.Create(&pli)
+		job, _ := factory.Job().WithID(fmt.Sprintf("job%d", i)).Create(db)
+		timelog, _ := factory.Timelog().WithID(fmt.Sprintf("tl%d", i)).ForJob(job).Create(db)
+		factory.LineItem().WithID(fmt.Sprintf("pli%d", i)).For(job, timelog).Create(db)
 	}
 }
 
 func BenchmarkRepoQueries(b *testing.B) {
 	db := setupDB(b)
-	seedMillion(db)
+	seedMillion(b, db)
 	jobRepo := repos.JobRepo{DB: db}
 	timelogRepo := repos.TimelogRepo{DB: db}
 	pliRepo := repos.PaymentLineItemRepo{DB: db}
@@ -69,22 +63,22 @@ func BenchmarkRepoQueries(b *testing.B) {
 
 	b.Run("FindActiveJobsByCompany", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			jobRepo.FindActiveJobsByCompany("comp1")
+			jobRepo.FindActiveJobsByCompany(context.Background(), "comp1")
 		}
 	})
 	b.Run("FindActiveJobsByContractor", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			jobRepo.FindActiveJobsByContractor("cont1")
+			jobRepo.FindActiveJobsByContractor(context.Background(), "cont1")
 		}
 	})
 	b.Run("FindTimelogsByContractorAndPeriod", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			timelogRepo.FindTimelogsByContractorAndPeriod("cont1", from, to)
+			timelogRepo.FindTimelogsByContractorAndPeriod(context.Background(), "cont1", from, to)
 		}
 	})
 	b.Run("FindLineItemsByContractorAndPeriod", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			pliRepo.FindLineItemsByContractorAndPeriod("cont1", from, to)
+			pliRepo.FindLineItemsByContractorAndPeriod(context.Background(), "cont1", from, to)
 		}
 	})
 }
@@ -92,7 +86,7 @@ func BenchmarkRepoQueries(b *testing.B) {
 // BenchmarkSCDvsRaw compares SCD abstraction vs raw SQL queries
 func BenchmarkSCDvsRaw(b *testing.B) {
 	db := setupDB(b)
-	seedMillion(db)
+	seedMillion(b, db)
 	jobRepo := repos.JobRepo{DB: db}
 	from := time.Now().Add(-24 * time.Hour)
 	to := time.Now().Add(24 * time.Hour)
@@ -100,7 +94,7 @@ func BenchmarkSCDvsRaw(b *testing.B) {
 	// Test 1: Find Active Jobs by Company - SCD vs Raw
 	b.Run("FindActiveJobsByCompany_SCD", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			jobRepo.FindActiveJobsByCompany("comp1")
+			jobRepo.FindActiveJobsByCompany(context.Background(), "comp1")
 		}
 	})
 
@@ -118,7 +112,7 @@ func BenchmarkSCDvsRaw(b *testing.B) {
 	// Test 2: Find Active Jobs by Contractor - SCD vs Raw
 	b.Run("FindActiveJobsByContractor_SCD", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			jobRepo.FindActiveJobsByContractor("cont1")
+			jobRepo.FindActiveJobsByContractor(context.Background(), "cont1")
 		}
 	})
 
@@ -137,7 +131,7 @@ func BenchmarkSCDvsRaw(b *testing.B) {
 	b.Run("FindTimelogsByContractorAndPeriod_SCD", func(b *testing.B) {
 		timelogRepo := repos.TimelogRepo{DB: db}
 		for i := 0; i < b.N; i++ {
-			timelogRepo.FindTimelogsByContractorAndPeriod("cont1", from, to)
+			timelogRepo.FindTimelogsByContractorAndPeriod(context.Background(), "cont1", from, to)
 		}
 	})
 
@@ -156,7 +150,7 @@ func BenchmarkSCDvsRaw(b *testing.B) {
 	b.Run("FindLineItemsByContractorAndPeriod_SCD", func(b *testing.B) {
 		pliRepo := repos.PaymentLineItemRepo{DB: db}
 		for i := 0; i < b.N; i++ {
-			pliRepo.FindLineItemsByContractorAndPeriod("cont1", from, to)
+			pliRepo.FindLineItemsByContractorAndPeriod(context.Background(), "cont1", from, to)
 		}
 	})
 
@@ -175,11 +169,11 @@ func BenchmarkSCDvsRaw(b *testing.B) {
 // BenchmarkSCDAbstraction tests the SCD helper functions directly
 func BenchmarkSCDAbstraction(b *testing.B) {
 	db := setupDB(b)
-	seedMillion(db)
+	seedMillion(b, db)
 
 	b.Run("LatestSubquery", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			scd.LatestSubquery(db, models.Job{})
+			scd.LatestSubquery(context.Background(), db, models.Job{})
 		}
 	})
 
@@ -187,20 +181,12 @@ func BenchmarkSCDAbstraction(b *testing.B) {
 		for i := 0; i < b.N; i++ {
 			// Create a test job with unique ID for each iteration
 			jobID := fmt.Sprintf("test-job-%d", i)
-			jobUID := fmt.Sprintf("test-job-uid-%d", i)
-			job := models.Job{
-				Versioned:    models.Versioned{ID: jobID, Version: 1, UID: jobUID},
-				Status:       "active",
-				Rate:         100,
-				Title:        "Engineer",
-				CompanyID:    "comp1",
-				ContractorID: "cont1",
-			}
-			db.Create(&job)
+			factory.Job().WithID(jobID).Create(db)
 
 			// Create new version
-			scd.CreateNewSCDVersion(db, jobID, func(j *models.Job) {
+			scd.CreateNewSCDVersion(context.Background(), db, jobID, func(j *models.Job) error {
 				j.Status = "completed"
+				return nil
 			})
 		}
 	})
@@ -1,11 +1,13 @@
 package benchmark
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/yourorg/Go/factory"
 	"github.com/yourorg/Go/models"
 	"github.com/yourorg/Go/repos"
 	"github.com/yourorg/Go/scd"
@@ -26,27 +28,19 @@ func setupSimpleDB(b *testing.B) *gorm.DB {
 	return db
 }
 
-func seedSimpleData(db *gorm.DB) {
-	db.Exec("TRUNCATE TABLE payment_line_items, timelogs, jobs RESTART IDENTITY CASCADE")
+func seedSimpleData(b *testing.B, db *gorm.DB) {
+	resetDB(b, db)
 
 	// Create a smaller dataset for faster benchmarking
 	for i := 0; i < 1000; i++ {
-		job := models.Job{
-			Versioned:    models.Versioned{ID: fmt.Sprintf("job%d", i), Version: 1, UID: fmt.Sprintf("job-uid-%d", i)},
-			Status:       "active",
-			Rate:         100,
-			Title:        "Engineer",
-			CompanyID:    "comp1",
-			ContractorID: "cont1",
-		}
-		db.Create(&job)
+		factory.Job().WithID(fmt.Sprintf("job%d", i)).Create(db)
 	}
 }
 
 // BenchmarkSimpleSCDImpact measures the basic SCD abstraction overhead
 func BenchmarkSimpleSCDImpact(b *testing.B) {
 	db := setupSimpleDB(b)
-	seedSimpleData(db)
+	seedSimpleData(b, db)
 
 	fmt.Printf("\n=== Simple SCD Impact Analysis ===\n")
 	fmt.Printf("Testing with 1,000 records\n\n")
@@ -58,7 +52,7 @@ func BenchmarkSimpleSCDImpact(b *testing.B) {
 		// Test SCD abstraction
 		b.Run("SCD_Abstraction", func(b *testing.B) {
 			for i := 0; i < b.N; i++ {
-				jobRepo.FindActiveJobsByCompany("comp1")
+				jobRepo.FindActiveJobsByCompany(context.Background(), "comp1")
 			}
 		})
 
@@ -86,7 +80,7 @@ func BenchmarkSimpleSCDImpact(b *testing.B) {
 		// Test SCD abstraction
 		b.Run("SCD_Abstraction", func(b *testing.B) {
 			for i := 0; i < b.N; i++ {
-				jobRepo.FindActiveJobsByContractor("cont1")
+				jobRepo.FindActiveJobsByContractor(context.Background(), "cont1")
 			}
 		})
 
@@ -111,7 +105,7 @@ func BenchmarkSimpleSCDImpact(b *testing.B) {
 // BenchmarkSimpleSCDCoreOperations measures the core SCD operations
 func BenchmarkSimpleSCDCoreOperations(b *testing.B) {
 	db := setupSimpleDB(b)
-	seedSimpleData(db)
+	seedSimpleData(b, db)
 
 	fmt.Printf("\n=== SCD Core Operations Performance ===\n\n")
 
@@ -120,7 +114,7 @@ func BenchmarkSimpleSCDCoreOperations(b *testing.B) {
 		// Test SCD abstraction
 		b.Run("SCD_Abstraction", func(b *testing.B) {
 			for i := 0; i < b.N; i++ {
-				scd.LatestSubquery(db, models.Job{})
+				scd.LatestSubquery(context.Background(), db, models.Job{})
 			}
 		})
 
@@ -138,23 +132,15 @@ func BenchmarkSimpleSCDCoreOperations(b *testing.B) {
 		b.Run("SCD_Abstraction", func(b *testing.B) {
 			for i := 0; i < b.N; i++ {
 				jobID := fmt.Sprintf("simple-test-job-%d", i)
-				jobUID := fmt.Sprintf("simple-test-job-uid-%d", i)
 
 				// Create initial version
-				job := models.Job{
-					Versioned:    models.Versioned{ID: jobID, Version: 1, UID: jobUID},
-					Status:       "active",
-					Rate:         100,
-					Title:        "Engineer",
-					CompanyID:    "comp1",
-					ContractorID: "cont1",
-				}
-				db.Create(&job)
+				factory.Job().WithID(jobID).Create(db)
 
 				// Create new version using SCD abstraction
-				scd.CreateNewSCDVersion(db, jobID, func(j *models.Job) {
+				scd.CreateNewSCDVersion(context.Background(), db, jobID, func(j *models.Job) error {
 					j.Status = "completed"
 					j.Rate = 150
+					return nil
 				})
 			}
 		})
@@ -189,7 +175,7 @@ func BenchmarkSimpleSCDCoreOperations(b *testing.B) {
 // BenchmarkSimpleSCDOverhead calculates the actual overhead percentage
 func BenchmarkSimpleSCDOverhead(b *testing.B) {
 	db := setupSimpleDB(b)
-	seedSimpleData(db)
+	seedSimpleData(b, db)
 
 	fmt.Printf("\n=== SCD Overhead Calculation ===\n\n")
 
@@ -203,7 +189,7 @@ func BenchmarkSimpleSCDOverhead(b *testing.B) {
 			name: "Job_By_Company",
 			scdQuery: func() {
 				jobRepo := repos.JobRepo{DB: db}
-				jobRepo.FindActiveJobsByCompany("comp1")
+				jobRepo.FindActiveJobsByCompany(context.Background(), "comp1")
 			},
 			rawQuery: func() {
 				var jobs []models.Job
@@ -222,7 +208,7 @@ func BenchmarkSimpleSCDOverhead(b *testing.B) {
 			name: "Job_By_Contractor",
 			scdQuery: func() {
 				jobRepo := repos.JobRepo{DB: db}
-				jobRepo.FindActiveJobsByContractor("cont1")
+				jobRepo.FindActiveJobsByContractor(context.Background(), "cont1")
 			},
 			rawQuery: func() {
 				var jobs []models.Job
@@ -0,0 +1,167 @@
+package benchmark
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// dataShape describes a synthetic versioned table: entities × versions rows.
+type dataShape struct {
+	name     string
+	entities int
+	versions int
+}
+
+var strategyShapes = []dataShape{
+	{"few_entities_many_versions", 100, 1000},
+	{"balanced", 10000, 20},
+	{"many_entities_few_versions", 100000, 2},
+}
+
+// latestStrategy renders the latest-version query for a strategy. The where
+// argument is an extra predicate on the latest rows (aliased t), or "TRUE".
+type latestStrategy struct {
+	name  string
+	query func(where string) string
+}
+
+var latestStrategies = []latestStrategy{
+	{"max_join", func(where string) string {
+		return `SELECT t.* FROM scd_strategy_bench t
+			JOIN (SELECT id, MAX(version) AS max_version FROM scd_strategy_bench GROUP BY id) latest
+			ON t.id = latest.id AND t.version = latest.max_version WHERE ` + where
+	}},
+	{"window", func(where string) string {
+		return `SELECT * FROM (SELECT *, row_number() OVER (PARTITION BY id ORDER BY version DESC) AS rn FROM scd_strategy_bench) t
+			WHERE t.rn = 1 AND ` + where
+	}},
+	{"lateral", func(where string) string {
+		return `SELECT t.* FROM (SELECT DISTINCT id FROM scd_strategy_bench) e
+			CROSS JOIN LATERAL (SELECT * FROM scd_strategy_bench v WHERE v.id = e.id ORDER BY v.version DESC LIMIT 1) t
+			WHERE ` + where
+	}},
+	{"distinct_on", func(where string) string {
+		return `SELECT * FROM (SELECT DISTINCT ON (id) * FROM scd_strategy_bench ORDER BY id, version DESC) t WHERE ` + where
+	}},
+	{"is_latest", func(where string) string {
+		return `SELECT t.* FROM scd_strategy_bench t WHERE t.is_latest AND ` + where
+	}},
+	{"pointer_table", func(where string) string {
+		return `SELECT t.* FROM scd_strategy_bench t
+			JOIN scd_strategy_bench_latest p ON t.id = p.id AND t.version = p.version WHERE ` + where
+	}},
+}
+
+// strategyQueries are the access patterns each strategy is measured on.
+var strategyQueries = []struct {
+	name  string
+	where string
+}{
+	{"full_scan", "TRUE"},
+	{"point_lookup", "t.id = 'e42'"},
+}
+
+func seedStrategyShape(b *testing.B, db *gorm.DB, shape dataShape) {
+	stmts := []string{
+		"DROP TABLE IF EXISTS scd_strategy_bench, scd_strategy_bench_latest",
+		`CREATE TABLE scd_strategy_bench (
+			id text NOT NULL, version int NOT NULL, is_latest boolean NOT NULL DEFAULT false, rate numeric,
+			PRIMARY KEY (id, version))`,
+		fmt.Sprintf(`INSERT INTO scd_strategy_bench (id, version, rate)
+			SELECT 'e' || e, v, random() * 100 FROM generate_series(1, %d) e, generate_series(1, %d) v`,
+			shape.entities, shape.versions),
+		fmt.Sprintf("UPDATE scd_strategy_bench SET is_latest = true WHERE version = %d", shape.versions),
+		"CREATE INDEX idx_scd_strategy_bench_latest ON scd_strategy_bench (id) WHERE is_latest",
+		"CREATE TABLE scd_strategy_bench_latest AS SELECT id, MAX(version) AS version FROM scd_strategy_bench GROUP BY id",
+		"ALTER TABLE scd_strategy_bench_latest ADD PRIMARY KEY (id)",
+		"ANALYZE scd_strategy_bench",
+		"ANALYZE scd_strategy_bench_latest",
+	}
+	for _, s := range stmts {
+		if err := db.Exec(s).Error; err != nil {
+			b.Fatalf("seeding %s: %v", shape.name, err)
+		}
+	}
+}
+
+// planCost returns the planner's total cost estimate for query.
+func planCost(b *testing.B, db *gorm.DB, query string) float64 {
+	var raw string
+	if err := db.Raw("EXPLAIN (FORMAT JSON) " + query).Row().Scan(&raw); err != nil {
+		b.Fatalf("explaining %s: %v", query, err)
+	}
+	var plan []struct {
+		Plan struct {
+			TotalCost float64 `json:"Total Cost"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal([]byte(raw), &plan); err != nil || len(plan) == 0 {
+		b.Fatalf("decoding plan of %s: %v", query, err)
+	}
+	return plan[0].Plan.TotalCost
+}
+
+// BenchmarkLatestStrategyMatrix seeds each data shape, measures every
+// latest-version strategy on each access pattern and prints a recommendation
+// matrix naming the fastest strategy per cell.
+func BenchmarkLatestStrategyMatrix(b *testing.B) {
+	db := setupDB(b)
+	defer db.Exec("DROP TABLE IF EXISTS scd_strategy_bench, scd_strategy_bench_latest")
+
+	type cell struct {
+		strategy string
+		perOp    time.Duration
+		cost     float64
+	}
+	matrix := map[string]map[string][]cell{}
+
+	for _, shape := range strategyShapes {
+		seedStrategyShape(b, db, shape)
+		matrix[shape.name] = map[string][]cell{}
+		for _, q := range strategyQueries {
+			for _, s := range latestStrategies {
+				query := s.query(q.where)
+				// A failure inside testing.Benchmark only zeroes its result,
+				// which would rank the failing strategy fastest.
+				var runErr error
+				res := testing.Benchmark(func(b *testing.B) {
+					for i := 0; i < b.N && runErr == nil; i++ {
+						var n int64
+						runErr = db.Raw("SELECT COUNT(*) FROM (" + query + ") q").Scan(&n).Error
+					}
+				})
+				if runErr != nil {
+					b.Fatalf("%s on %s, %s: %v", s.name, shape.name, q.name, runErr)
+				}
+				matrix[shape.name][q.name] = append(matrix[shape.name][q.name], cell{
+					strategy: s.name,
+					perOp:    time.Duration(res.NsPerOp()),
+					cost:     planCost(b, db, query),
+				})
+			}
+		}
+	}
+
+	fmt.Printf("\n=== Latest-Version Strategy Recommendation Matrix ===\n\n")
+	for _, shape := range strategyShapes {
+		fmt.Printf("%s (%d entities x %d versions):\n", shape.name, shape.entities, shape.versions)
+		for _, q := range strategyQueries {
+			cells := matrix[shape.name][q.name]
+			best := cells[0]
+			for _, c := range cells[1:] {
+				if c.perOp < best.perOp {
+					best = c
+				}
+			}
+			fmt.Printf("  %-13s recommended: %s\n", q.name, best.strategy)
+			for _, c := range cells {
+				fmt.Printf("    %-14s %12v/op  plan cost %10.1f\n", c.strategy, c.perOp, c.cost)
+			}
+		}
+		fmt.Printf("\n")
+	}
+}
@@ -0,0 +1,146 @@
+// Package changefeed pushes change log entries to subscribers over Postgres
+// LISTEN/NOTIFY, so consumers get new versions as they commit without polling
+// scd_change_log. It requires scd.Config.ChangeLog.
+package changefeed
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/yourorg/Go/pgnotify"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+// Channel is the NOTIFY channel carrying change log entries.
+const Channel = "scd_changes"
+
+// QuarantineChannel is the NOTIFY channel carrying scd.QuarantineEvents, for
+// the owners of quarantined entities to be told.
+const QuarantineChannel = "scd_quarantine"
+
+// PublishQuarantines NOTIFYs every entity quarantined or released, on commit.
+func PublishQuarantines() {
+	scd.OnQuarantine(func(tx *gorm.DB, event scd.QuarantineEvent) error {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		return pgnotify.Notify(tx, QuarantineChannel, string(payload))
+	})
+}
+
+// Publish makes the write path NOTIFY every change log entry it records. The
+// notification is part of the creating transaction and only sent on commit.
+func Publish() {
+	scd.OnAnyVersionCreated(func(tx *gorm.DB, event scd.VersionEvent) error {
+		if event.Seq == 0 {
+			return nil
+		}
+		payload, err := json.Marshal(scd.ChangeLogEntry{
+			Seq: event.Seq, Table: event.Table, EntityID: event.ID, Version: event.Version,
+			UID: event.UID, Kind: event.Kind, ChangeSet: event.ChangeSet,
+		})
+		if err != nil {
+			return err
+		}
+		return pgnotify.Notify(tx, Channel, string(payload))
+	})
+}
+
+// Options tune a subscription.
+type Options struct {
+	// Table restricts the feed to one table; empty means all tables.
+	Table string
+	// Lookback is how many sequence numbers below the cursor are re-read on
+	// every (re)connect. Sequence numbers are assigned at insert but become
+	// visible at commit, so concurrent writers can commit out of order; the
+	// lookback catches entries that committed late (default 100).
+	Lookback int64
+}
+
+// Subscribe delivers every change log entry after cursor to fn, first by
+// reading the change log, then live from notifications, until ctx is cancelled
+// or fn returns an error. After a dropped connection it reconnects and catches
+// up from the highest sequence delivered so far.
+//
+// Delivery is at least once: entries inside the lookback window may be
+// delivered again after a restart, so fn must be idempotent (e.g. keyed on Seq).
+func Subscribe(ctx context.Context, db *gorm.DB, cursor int64, opts Options, fn func(scd.ChangeLogEntry) error) error {
+	if opts.Lookback <= 0 {
+		opts.Lookback = 100
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	s := &subscription{opts: opts, fn: fn, cancel: cancel, cursor: cursor, seen: map[int64]bool{},
+		read: func(ctx context.Context, after int64, limit int) ([]scd.ChangeLogEntry, error) {
+			return scd.ChangesSince(ctx, db, opts.Table, after, limit)
+		}}
+
+	err := pgnotify.Listen(ctx, db, Channel, s.catchUp, func(payload string) {
+		var e scd.ChangeLogEntry
+		if json.Unmarshal([]byte(payload), &e) != nil {
+			return
+		}
+		if err := s.deliver(e); err != nil {
+			cancel(err)
+		}
+	})
+	if cause := context.Cause(ctx); cause != nil && cause != ctx.Err() {
+		return cause
+	}
+	return err
+}
+
+type subscription struct {
+	opts Options
+	fn   func(scd.ChangeLogEntry) error
+	// read reads the change log after a sequence number, and cancel ends the
+	// subscription with the error of fn.
+	read   func(ctx context.Context, after int64, limit int) ([]scd.ChangeLogEntry, error)
+	cancel context.CancelCauseFunc
+	cursor int64
+	seen   map[int64]bool
+}
+
+// catchUp delivers the change log from the lookback window below the cursor
+// on. Errors reading it make pgnotify.Listen reconnect and retry, while an
+// error of fn ends the subscription, as it does for live entries.
+func (s *subscription) catchUp(ctx context.Context) error {
+	const batch = 1000
+	from := max(s.cursor-s.opts.Lookback, 0)
+	for {
+		entries, err := s.read(ctx, from, batch)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := s.deliver(e); err != nil {
+				s.cancel(err)
+				return err
+			}
+		}
+		if len(entries) < batch {
+			return nil
+		}
+		from = entries[len(entries)-1].Seq
+	}
+}
+
+func (s *subscription) deliver(e scd.ChangeLogEntry) error {
+	if s.seen[e.Seq] || (s.opts.Table != "" && e.Table != s.opts.Table) {
+		return nil
+	}
+	if err := s.fn(e); err != nil {
+		return err
+	}
+	s.seen[e.Seq] = true
+	s.cursor = max(s.cursor, e.Seq)
+	// Only the lookback window can be redelivered, so forget anything older.
+	for seq := range s.seen {
+		if seq < s.cursor-s.opts.Lookback {
+			delete(s.seen, seq)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package changefeed
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/yourorg/Go/scd"
+)
+
+func TestCatchUpErrorEndsSubscription(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	failed := errors.New("consumer failed")
+	var delivered []int64
+	s := &subscription{
+		opts:   Options{Lookback: 100},
+		cancel: cancel,
+		seen:   map[int64]bool{},
+		read: func(ctx context.Context, after int64, limit int) ([]scd.ChangeLogEntry, error) {
+			return []scd.ChangeLogEntry{{Seq: 1}, {Seq: 2}, {Seq: 3}}, nil
+		},
+		fn: func(e scd.ChangeLogEntry) error {
+			delivered = append(delivered, e.Seq)
+			if e.Seq == 2 {
+				return failed
+			}
+			return nil
+		},
+	}
+	if err := s.catchUp(ctx); !errors.Is(err, failed) {
+		t.Errorf("catchUp = %v, want %v", err, failed)
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, failed) {
+		t.Errorf("subscription not cancelled with the error of fn: cause = %v", cause)
+	}
+	if len(delivered) != 2 || s.cursor != 1 {
+		t.Errorf("delivered %v with cursor %d, want [1 2] and 1", delivered, s.cursor)
+	}
+}
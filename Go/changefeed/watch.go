@@ -0,0 +1,127 @@
+package changefeed
+
+import (
+	"context"
+	"sync"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+// Watcher serves watches of single entities off one change feed
+// subscription, so any number of open detail pages share one connection:
+//
+//	w := &changefeed.Watcher{DB: db}
+//	go w.Run(ctx)
+//	jobs, err := changefeed.WatchEntity[models.Job](ctx, w, "job1")
+//	for job := range jobs { ... }
+type Watcher struct {
+	DB *gorm.DB
+
+	mu      sync.Mutex
+	watches map[watchKey]map[chan scd.ChangeLogEntry]struct{}
+}
+
+type watchKey struct{ table, id string }
+
+// Run subscribes to the change feed from its current head and hands entries
+// to the open watches until ctx is cancelled.
+func (w *Watcher) Run(ctx context.Context) error {
+	head, err := scd.LatestSeq(ctx, w.DB)
+	if err != nil {
+		return err
+	}
+	return Subscribe(ctx, w.DB, head, Options{}, func(e scd.ChangeLogEntry) error {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		for ch := range w.watches[watchKey{e.Table, e.EntityID}] {
+			// Watchers only care about the newest version, so a pending
+			// entry the consumer has not picked up yet is replaced.
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- e
+		}
+		return nil
+	})
+}
+
+// Watch returns the change log entries of entity id in table written from
+// now on, until ctx is cancelled, when the channel is closed. A consumer that
+// falls behind skips to the newest entry, so versions may be missed but the
+// last one never is. Entries arrive once Run has delivered them.
+func (w *Watcher) Watch(ctx context.Context, table, id string) <-chan scd.ChangeLogEntry {
+	key := watchKey{table, id}
+	ch := make(chan scd.ChangeLogEntry, 1)
+	w.mu.Lock()
+	if w.watches == nil {
+		w.watches = map[watchKey]map[chan scd.ChangeLogEntry]struct{}{}
+	}
+	if w.watches[key] == nil {
+		w.watches[key] = map[chan scd.ChangeLogEntry]struct{}{}
+	}
+	w.watches[key][ch] = struct{}{}
+	w.mu.Unlock()
+
+	out := make(chan scd.ChangeLogEntry)
+	go func() {
+		defer close(out)
+		defer func() {
+			w.mu.Lock()
+			delete(w.watches[key], ch)
+			if len(w.watches[key]) == 0 {
+				delete(w.watches, key)
+			}
+			w.mu.Unlock()
+		}()
+		last := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-ch:
+				// The lookback can redeliver entries; versions only move forward.
+				if e.Version <= last {
+					continue
+				}
+				last = e.Version
+				select {
+				case out <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// WatchEntity is Watch for a model type: it streams the new versions of
+// entity id of T, loaded as they are announced, until ctx is cancelled. A
+// version that can no longer be loaded, e.g. because retention removed it in
+// the meantime, is skipped.
+func WatchEntity[T any](ctx context.Context, w *Watcher, id string) (<-chan T, error) {
+	var model T
+	info, err := scd.Describe(w.DB, &model)
+	if err != nil {
+		return nil, err
+	}
+	entries := w.Watch(ctx, info.Table, id)
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for e := range entries {
+			v, err := scd.GetVersion[T](ctx, w.DB, id, e.Version)
+			if err != nil {
+				continue
+			}
+			select {
+			case out <- v:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+// runAggregate prints an entity and its dependents as in effect at one time;
+// see scd.AggregateAsOf.
+func runAggregate(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("aggregate")
+	modelName := fs.String("model", "jobs", "versioned table, e.g. jobs")
+	id := fs.String("id", "", "entity id")
+	at := fs.String("at", "", "RFC 3339 time to read it at; now when empty")
+	deleted := fs.Bool("include-deleted", false, "include tombstoned entities")
+	fs.Parse(args)
+
+	if *id == "" {
+		return errors.New("-id is required")
+	}
+	if _, err := lookupModel(*modelName); err != nil {
+		return err
+	}
+	t := time.Now().UTC()
+	if *at != "" {
+		var err error
+		if t, err = time.Parse(time.RFC3339, *at); err != nil {
+			return fmt.Errorf("invalid -at: %w", err)
+		}
+	}
+	if *deleted {
+		ctx = scd.IncludeDeleted(ctx)
+	}
+	agg, err := scd.AggregateAsOf(ctx, db, *modelName, *id, t)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(agg)
+}
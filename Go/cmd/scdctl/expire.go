@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func runExpire(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("expire")
+	modelName := fs.String("model", "", "versioned table, e.g. jobs")
+	where := fs.String("where", "", "SQL predicate over latest rows, e.g. \"status <> 'active'\"")
+	olderThan := fs.Duration("older-than", 0, "only entities whose latest version is older than this, e.g. 17520h")
+	batch := fs.Int("batch", 500, "entities tombstoned per transaction")
+	dryRun := fs.Bool("dry-run", false, "report matches without deleting")
+	maxDuration := fs.Duration("max-duration", 0, "stop after this long and print a resume token")
+	resume := fs.String("resume", "", "continue a run from its resume token")
+	changeSet := fs.String("change-set", "", "change set of the run being resumed")
+	fs.Parse(args)
+
+	e, err := lookupModel(*modelName)
+	if err != nil {
+		return err
+	}
+	if *olderThan <= 0 {
+		return fmt.Errorf("-older-than is required")
+	}
+	res, err := e.expire(ctx, db, scd.ExpireOptions{
+		Where:     *where,
+		Before:    time.Now().Add(-*olderThan),
+		BatchSize: *batch,
+		DryRun:    *dryRun,
+		ChangeSet: *changeSet,
+		Limits:    scd.Limits{MaxDuration: *maxDuration, Resume: scd.ResumeToken(*resume)},
+	})
+	fmt.Printf("change set %s: matched %d, deleted %d\n", res.ChangeSet, res.Matched, res.Deleted)
+	for _, id := range res.Sample {
+		fmt.Printf("  %s\n", id)
+	}
+	if res.Resume != "" {
+		fmt.Printf("stopped early; continue with -change-set %s -resume %s\n", res.ChangeSet, res.Resume)
+	}
+	return err
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func runGraph(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("graph")
+	modelName := fs.String("model", "", "versioned table, e.g. jobs")
+	id := fs.String("id", "", "entity id")
+	format := fs.String("format", "dot", "output format: dot or json")
+	fs.Parse(args)
+
+	if *id == "" {
+		return errors.New("-id is required")
+	}
+	if _, err := lookupModel(*modelName); err != nil {
+		return err
+	}
+	g, err := scd.EntityGraph(ctx, db, *modelName, *id)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "dot":
+		return g.WriteDOT(os.Stdout)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(g)
+	}
+	return fmt.Errorf("unknown format %q", *format)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/yourorg/Go/jobsvc"
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+// runJob applies a lifecycle event to a job; see jobsvc.Transition.
+func runJob(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("job")
+	id := fs.String("id", "", "job id")
+	event := fs.String("event", "", "pause, resume, complete or cancel")
+	reason := fs.String("reason", "", "why, recorded with the event as the change reason")
+	actor := fs.String("actor", "", "who applies the event")
+	fs.Parse(args)
+
+	if *id == "" || *event == "" {
+		return errors.New("usage: scdctl job -id ID -event pause|resume|complete|cancel [-reason TEXT] [-actor NAME]")
+	}
+	if *reason != "" {
+		ctx = scd.WithChangeReason(ctx, *reason)
+	}
+	if *actor != "" {
+		ctx = scd.WithActor(ctx, *actor)
+	}
+	job, err := jobsvc.Transition(ctx, db, *id, models.JobEvent(*event))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("job %s is %s at version %d\n", job.ID, job.Status, job.Version)
+	return nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func runLayout(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("layout")
+	modelName := fs.String("model", "", "versioned table, e.g. jobs")
+	fs.Parse(args)
+
+	e, err := lookupModel(*modelName)
+	if err != nil {
+		return err
+	}
+	if err := scd.PrepareLayout(ctx, db, e.model); err != nil {
+		return err
+	}
+	fmt.Printf("%s: storage layout prepared\n", *modelName)
+	return nil
+}
@@ -0,0 +1,121 @@
+// Command scdctl runs operational tasks against the SCD tables.
+//
+//	scdctl <command> [flags]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type command struct {
+	summary string
+	run     func(ctx context.Context, db *gorm.DB, args []string) error
+}
+
+var commands = map[string]command{
+	"snapshot":          {"materialize a non-versioned table from latest versions", runSnapshot},
+	"expire":            {"soft-delete stale entities matching a filter", runExpire},
+	"maintain":          {"run one pass of registered retention policies", runMaintain},
+	"backfill-validity": {"populate valid_from/valid_to from existing versions", runBackfillValidity},
+	"models":            {"describe the registered versioned models", runModels},
+	"switch-strategy":   {"switch a table's latest-version strategy online", runSwitchStrategy},
+	"rls":               {"install or drop the row-level security policies of a model", runRLS},
+	"layout":            {"move a model's tables to its registered storage layout", runLayout},
+	"revert":            {"re-publish an earlier version of an entity as its latest", runRevert},
+	"graph":             {"print the version and provenance graph of an entity", runGraph},
+	"write-amp":         {"report the write amplification of recently written versions", runWriteAmp},
+	"payments":          {"compute or recompute payment line items from timelogs", runPayments},
+	"reconcile":         {"report and fix discrepancies between timelogs and line items", runReconcile},
+	"periods":           {"list, close or reopen payroll periods", runPeriods},
+	"job":               {"move a job through its status lifecycle", runJob},
+	"quarantine":        {"list, quarantine or release entities with suspect history", runQuarantine},
+	"aggregate":         {"print an entity with its dependents as in effect at a time", runAggregate},
+}
+
+// modelEntry binds the generic scd operations to one concrete model type.
+type modelEntry struct {
+	model  any
+	expire func(context.Context, *gorm.DB, scd.ExpireOptions) (scd.ExpireResult, error)
+}
+
+func entry[T any, P scd.EntityPtr[T]]() modelEntry {
+	var model T
+	return modelEntry{model: model, expire: scd.ExpireStale[T, P]}
+}
+
+// versionedModels maps table names accepted by -model flags to their models.
+var versionedModels = map[string]modelEntry{
+	"jobs":               entry[models.Job](),
+	"timelogs":           entry[models.Timelog](),
+	"payment_line_items": entry[models.PaymentLineItem](),
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	models.Register()
+
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dsn == "" {
+		dsn = "host=localhost user=postgres password=postgres dbname=scd port=5432 sslmode=disable"
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("failed to connect database: %v", err)
+	}
+	if err := db.Use(scd.NewPlugin()); err != nil {
+		log.Fatalf("failed to install scd plugin: %v", err)
+	}
+	if err := scd.LoadStrategies(context.Background(), db); err != nil {
+		log.Fatalf("failed to load strategies: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := cmd.run(ctx, db, os.Args[2:]); err != nil {
+		log.Fatalf("%s: %v", os.Args[1], err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: scdctl <command> [flags]")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-18s %s\n", name, commands[name].summary)
+	}
+}
+
+func lookupModel(name string) (modelEntry, error) {
+	e, ok := versionedModels[name]
+	if !ok {
+		return e, fmt.Errorf("unknown model %q", name)
+	}
+	return e, nil
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet("scdctl "+name, flag.ExitOnError)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yourorg/Go/maintenance"
+	"gorm.io/gorm"
+)
+
+func runMaintain(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("maintain")
+	batch := fs.Int("batch", 5000, "rows removed per statement")
+	workers := fs.Int("workers", 1, "tables processed concurrently")
+	maxDuration := fs.Duration("max-duration", 0, "stop starting new batches after this long")
+	fs.Parse(args)
+
+	scheduler := &maintenance.Scheduler{DB: db, BatchSize: *batch, MaxRunTime: *maxDuration}
+	if *workers > 1 {
+		scheduler.Scaler = maintenance.ScalerFunc(func(load maintenance.Load) int { return min(*workers, load.QueueDepth) })
+	}
+	stats := scheduler.RunOnce(ctx)
+	for _, t := range stats.Tables {
+		status := ""
+		if t.Resume != "" {
+			status = "  (incomplete)"
+		}
+		fmt.Printf("%-20s pruned %8d  archived %8d%s\n", t.Table, t.Pruned, t.Archived, status)
+	}
+	for _, e := range stats.Errors {
+		fmt.Printf("error: %s\n", e)
+	}
+	if len(stats.Errors) > 0 {
+		return fmt.Errorf("%d table(s) failed", len(stats.Errors))
+	}
+	return nil
+}
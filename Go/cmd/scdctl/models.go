@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func runModels(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("models")
+	asJSON := fs.Bool("json", false, "print descriptors as JSON")
+	fs.Parse(args)
+
+	descriptors := scd.RegisteredModels()
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(descriptors)
+	}
+	for _, d := range descriptors {
+		var columns []string
+		for _, f := range d.Fields {
+			if !f.Meta {
+				columns = append(columns, f.Column)
+			}
+		}
+		fmt.Printf("%-20s %-16s layout=%s latest=%s soft_delete=%t validity=%t\n",
+			d.Table, d.Name, d.Strategies.Layout, d.Strategies.Latest, d.Strategies.SoftDelete, d.Strategies.Validity)
+		fmt.Printf("  columns: %s\n", strings.Join(columns, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/payroll"
+	"github.com/yourorg/Go/periods"
+	"github.com/yourorg/Go/repos"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func runPayments(ctx context.Context, db *gorm.DB, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "recompute":
+			return runRecompute(ctx, db, args[1:])
+		case "compute":
+			return runCompute(ctx, db, args[1:])
+		case "correct":
+			return runCorrect(ctx, db, args[1:])
+		}
+	}
+	return errors.New("usage: scdctl payments recompute -company ID -from DATE -to DATE\n       scdctl payments compute -contractor ID -from DATE -to DATE\n       scdctl payments correct -timelog ID -hours H -reason TEXT")
+}
+
+// runCompute derives the line items of a contractor's timelogs; see
+// payroll.ComputeLineItems.
+func runCompute(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("payments compute")
+	contractor := fs.String("contractor", "", "contractor whose timelogs are priced")
+	from := fs.String("from", "", "first day of the window, e.g. 2024-01-01")
+	to := fs.String("to", "", "last day of the window, inclusive, e.g. 2024-01-31")
+	fs.Parse(args)
+
+	if *contractor == "" {
+		return errors.New("-contractor is required")
+	}
+	period, err := parseWindow(*from, *to)
+	if err != nil {
+		return err
+	}
+	res, err := payroll.ComputeLineItems(ctx, db, *contractor, period)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("change set %s: %d timelogs, %d line items created, %d updated, %d unchanged\n",
+		res.ChangeSet, res.Timelogs, res.Created, res.Updated, res.Unchanged)
+	for _, id := range res.Held {
+		fmt.Printf("  %s is out of date but no longer pending\n", id)
+	}
+	return nil
+}
+
+// runCorrect changes the hours of a timelog, billing the difference with an
+// adjustment when its line item can no longer change; see payroll.Correct.
+func runCorrect(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("payments correct")
+	timelog := fs.String("timelog", "", "timelog to correct")
+	hours := fs.Float64("hours", -1, "corrected duration of the timelog, in hours")
+	reason := fs.String("reason", "", "why the timelog is corrected")
+	fs.Parse(args)
+
+	if *timelog == "" || *hours < 0 || *reason == "" {
+		return errors.New("-timelog, -hours and -reason are required")
+	}
+	if err := periods.Migrate(db); err != nil {
+		return fmt.Errorf("migrating periods failed: %w", err)
+	}
+	c, err := payroll.Correct(scd.WithChangeReason(ctx, *reason), db, *timelog, func(t *models.Timelog) error {
+		t.Duration = *hours
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	switch {
+	case c.Adjustment != nil:
+		fmt.Printf("change set %s: %s v%d, adjustment %s bills %+.2f\n", c.ChangeSet, c.Timelog.ID, c.Timelog.Version, c.Adjustment.ID, c.Adjustment.Amount)
+	case c.Repriced:
+		fmt.Printf("change set %s: %s v%d, its pending line item was repriced\n", c.ChangeSet, c.Timelog.ID, c.Timelog.Version)
+	default:
+		fmt.Printf("change set %s: %s v%d, nothing billed changed\n", c.ChangeSet, c.Timelog.ID, c.Timelog.Version)
+	}
+	return nil
+}
+
+func runRecompute(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("payments recompute")
+	company := fs.String("company", "", "company whose line items are recomputed")
+	from := fs.String("from", "", "first day of the window, e.g. 2024-01-01")
+	to := fs.String("to", "", "last day of the window, inclusive, e.g. 2024-01-31")
+	dryRun := fs.Bool("dry-run", false, "print the corrections without writing them")
+	yes := fs.Bool("yes", false, "write the corrections without asking")
+	maxDuration := fs.Duration("max-duration", 0, "give up, writing nothing, after this long")
+	fs.Parse(args)
+
+	if *company == "" {
+		return errors.New("-company is required")
+	}
+	period, err := parseWindow(*from, *to)
+	if err != nil {
+		return err
+	}
+	if *maxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *maxDuration)
+		defer cancel()
+	}
+
+	repo := &repos.PaymentLineItemRepo{DB: db}
+	corrections, err := repo.PlanRecompute(ctx, *company, period)
+	if err != nil {
+		return err
+	}
+	if len(corrections) == 0 {
+		fmt.Printf("%s %s..%s: no amounts changed\n", *company, *from, *to)
+		return nil
+	}
+	var delta float64
+	for _, c := range corrections {
+		fmt.Printf("  %s v%d: %.2f -> %.2f (%.2fh at %.2f)\n", c.LineItemID, c.Version, c.OldAmount, c.NewAmount, c.Hours, c.Rate)
+		delta += c.NewAmount - c.OldAmount
+	}
+	fmt.Printf("%s %s..%s: %d line items change, total %+.2f\n", *company, *from, *to, len(corrections), delta)
+	if *dryRun {
+		return nil
+	}
+	if !*yes && !confirm("write the corrective versions?") {
+		return errors.New("aborted")
+	}
+	changeSet, err := repo.ApplyCorrections(ctx, corrections)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("change set %s: corrected %d line items\n", changeSet, len(corrections))
+	return nil
+}
+
+// parseWindow parses the -from and -to days of a window, both inclusive,
+// into a half-open period.
+func parseWindow(from, to string) (repos.Period, error) {
+	if from == "" || to == "" {
+		return repos.Period{}, errors.New("-from and -to are required")
+	}
+	start, err := time.Parse(time.DateOnly, from)
+	if err != nil {
+		return repos.Period{}, fmt.Errorf("invalid -from: %w", err)
+	}
+	end, err := time.Parse(time.DateOnly, to)
+	if err != nil {
+		return repos.Period{}, fmt.Errorf("invalid -to: %w", err)
+	}
+	return repos.Period{From: start, To: end.AddDate(0, 0, 1)}, nil
+}
+
+// confirm asks question on stdout and reports whether the answer read from
+// stdin is yes.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
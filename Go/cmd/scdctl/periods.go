@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yourorg/Go/periods"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+// runPeriods lists, closes or reopens payroll periods; see periods.Close.
+func runPeriods(ctx context.Context, db *gorm.DB, args []string) error {
+	if err := periods.Migrate(db); err != nil {
+		return fmt.Errorf("migrating periods failed: %w", err)
+	}
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			return listPeriods(ctx, db)
+		case "close":
+			return closePeriod(ctx, db, args[1:])
+		case "reopen":
+			return reopenPeriod(ctx, db, args[1:])
+		}
+	}
+	return errors.New("usage: scdctl periods list\n       scdctl periods close -id ID -from DATE -to DATE [-actor NAME]\n       scdctl periods reopen -id ID")
+}
+
+func listPeriods(ctx context.Context, db *gorm.DB) error {
+	list, err := periods.List(ctx, db)
+	if err != nil {
+		return err
+	}
+	for _, p := range list {
+		fmt.Printf("%-10s %s..%s  %s", p.ID, p.From.Format(time.DateOnly), p.To.AddDate(0, 0, -1).Format(time.DateOnly), p.Status)
+		if p.ClosedAt != nil {
+			fmt.Printf(" at %s by %s", p.ClosedAt.Format(time.RFC3339), p.ClosedBy)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func closePeriod(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("periods close")
+	id := fs.String("id", "", "period id, e.g. 2024-01")
+	from := fs.String("from", "", "first day of the period, e.g. 2024-01-01")
+	to := fs.String("to", "", "last day of the period, inclusive, e.g. 2024-01-31")
+	actor := fs.String("actor", "", "who closes the period")
+	fs.Parse(args)
+
+	if *id == "" {
+		return errors.New("-id is required")
+	}
+	window, err := parseWindow(*from, *to)
+	if err != nil {
+		return err
+	}
+	if *actor != "" {
+		ctx = scd.WithActor(ctx, *actor)
+	}
+	p, err := periods.Close(ctx, db, *id, window.From, window.To)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("period %s is %s\n", p.ID, p.Status)
+	return nil
+}
+
+func reopenPeriod(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("periods reopen")
+	id := fs.String("id", "", "period id, e.g. 2024-01")
+	fs.Parse(args)
+
+	if *id == "" {
+		return errors.New("-id is required")
+	}
+	p, err := periods.Reopen(ctx, db, *id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("period %s is %s\n", p.ID, p.Status)
+	return nil
+}
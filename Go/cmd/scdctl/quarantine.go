@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+// runQuarantine lists, quarantines or releases entities; see
+// scd.QuarantineEntity.
+func runQuarantine(ctx context.Context, db *gorm.DB, args []string) error {
+	if err := scd.MigrateQuarantine(db); err != nil {
+		return fmt.Errorf("migrating quarantine failed: %w", err)
+	}
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			return listQuarantined(ctx, db, args[1:])
+		case "add":
+			return quarantineEntity(ctx, db, args[1:])
+		case "release":
+			return releaseQuarantine(ctx, db, args[1:])
+		}
+	}
+	return errors.New("usage: scdctl quarantine list [-model TABLE]\n       scdctl quarantine add -model TABLE -id ID -reason TEXT [-check NAME] [-actor NAME]\n       scdctl quarantine release -model TABLE -id ID [-actor NAME]")
+}
+
+func listQuarantined(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("quarantine list")
+	modelName := fs.String("model", "", "versioned table, e.g. jobs; every table when empty")
+	fs.Parse(args)
+
+	list, err := scd.ListQuarantined(ctx, db, *modelName)
+	if err != nil {
+		return err
+	}
+	for _, q := range list {
+		fmt.Printf("%-20s %-24s %s", q.Table, q.EntityID, q.CreatedAt.Format(time.RFC3339))
+		if q.CreatedBy != "" {
+			fmt.Printf(" by %s", q.CreatedBy)
+		}
+		if q.Check != "" {
+			fmt.Printf(" [%s]", q.Check)
+		}
+		fmt.Printf("  %s\n", q.Reason)
+	}
+	return nil
+}
+
+func quarantineEntity(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("quarantine add")
+	modelName := fs.String("model", "", "versioned table, e.g. jobs")
+	id := fs.String("id", "", "entity id")
+	reason := fs.String("reason", "", "what is suspected to be wrong with its history")
+	check := fs.String("check", "", "the automated check that found it, if any")
+	actor := fs.String("actor", "", "who quarantines the entity")
+	fs.Parse(args)
+
+	e, err := lookupModel(*modelName)
+	if err != nil {
+		return err
+	}
+	if *id == "" || *reason == "" {
+		return errors.New("-id and -reason are required")
+	}
+	if *actor != "" {
+		ctx = scd.WithActor(ctx, *actor)
+	}
+	q, err := scd.QuarantineEntity(ctx, db, e.model, *id, *reason, *check)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s %s: quarantined since %s\n", q.Table, q.EntityID, q.CreatedAt.Format(time.RFC3339))
+	return nil
+}
+
+func releaseQuarantine(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("quarantine release")
+	modelName := fs.String("model", "", "versioned table, e.g. jobs")
+	id := fs.String("id", "", "entity id")
+	actor := fs.String("actor", "", "who releases the entity")
+	fs.Parse(args)
+
+	e, err := lookupModel(*modelName)
+	if err != nil {
+		return err
+	}
+	if *id == "" {
+		return errors.New("-id is required")
+	}
+	if *actor != "" {
+		ctx = scd.WithActor(ctx, *actor)
+	}
+	if err := scd.ReleaseQuarantine(ctx, db, e.model, *id); err != nil {
+		return err
+	}
+	fmt.Printf("%s %s: released\n", *modelName, *id)
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/yourorg/Go/reconcile"
+	"gorm.io/gorm"
+)
+
+// runReconcile reports the discrepancies between timelogs and payment line
+// items; see reconcile.Run.
+func runReconcile(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("reconcile")
+	contractor := fs.String("contractor", "", "contractor to reconcile (default all)")
+	from := fs.String("from", "", "first day of the window, e.g. 2024-01-01")
+	to := fs.String("to", "", "last day of the window, inclusive, e.g. 2024-01-31")
+	fix := fs.Bool("fix", false, "fix the discrepancies of pending line items with new versions")
+	yes := fs.Bool("yes", false, "fix without asking")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Parse(args)
+
+	period, err := parseWindow(*from, *to)
+	if err != nil {
+		return err
+	}
+	opts := reconcile.Options{ContractorID: *contractor, Period: period}
+	rep, err := reconcile.Run(ctx, db, opts)
+	if err != nil {
+		return err
+	}
+	if *fix && len(rep.Discrepancies) > 0 {
+		if !*asJSON {
+			printReconcile(rep)
+		}
+		if !*yes && !confirm("fix the discrepancies of pending line items?") {
+			return errors.New("aborted")
+		}
+		opts.Fix = true
+		if rep, err = reconcile.Run(ctx, db, opts); err != nil {
+			return err
+		}
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	}
+	printReconcile(rep)
+	return nil
+}
+
+func printReconcile(rep reconcile.Report) {
+	for _, d := range rep.Discrepancies {
+		state := ""
+		if d.Fixed {
+			state = " (fixed)"
+		}
+		if d.LineItemID == "" {
+			fmt.Printf("  %-18s timelog %s: expected %.2f%s\n", d.Kind, d.TimelogID, d.Expected, state)
+			continue
+		}
+		fmt.Printf("  %-18s timelog %s, %s v%d (%s): billed %.2f, expected %.2f%s\n",
+			d.Kind, d.TimelogID, d.LineItemID, d.LineItemVersion, d.Status, d.Actual, d.Expected, state)
+	}
+	fmt.Printf("%s..%s: %d timelogs, %d reconciled, %d discrepancies; billed %.2f, expected %.2f\n",
+		rep.From.Format("2006-01-02"), rep.To.AddDate(0, 0, -1).Format("2006-01-02"),
+		rep.Timelogs, rep.Reconciled, len(rep.Discrepancies), rep.Billed, rep.Expected)
+	if rep.ChangeSet != "" {
+		fmt.Printf("change set %s: fixed %d timelogs\n", rep.ChangeSet, rep.Fixed)
+	}
+	for _, id := range rep.Held {
+		fmt.Printf("  %s is out of date but no longer pending\n", id)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func runRevert(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("revert")
+	modelName := fs.String("model", "", "versioned table, e.g. jobs")
+	id := fs.String("id", "", "entity id")
+	version := fs.Int("version", 0, "version to restore as the new latest version")
+	fs.Parse(args)
+
+	e, err := lookupModel(*modelName)
+	if err != nil {
+		return err
+	}
+	if *id == "" || *version <= 0 {
+		return errors.New("-id and -version are required")
+	}
+	if err := scd.Revert(ctx, db, e.model, *id, *version); err != nil {
+		return err
+	}
+	fmt.Printf("%s %s: reverted to version %d\n", *modelName, *id, *version)
+	return nil
+}
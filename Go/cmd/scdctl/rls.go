@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func runRLS(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("rls")
+	modelName := fs.String("model", "", "versioned table, e.g. jobs")
+	disable := fs.Bool("disable", false, "drop the policies and disable row-level security")
+	fs.Parse(args)
+
+	e, err := lookupModel(*modelName)
+	if err != nil {
+		return err
+	}
+	if *disable {
+		if err := scd.DisableRLS(ctx, db, e.model); err != nil {
+			return err
+		}
+		fmt.Printf("%s: row-level security disabled\n", *modelName)
+		return nil
+	}
+	if err := scd.EnableRLS(ctx, db, e.model); err != nil {
+		return err
+	}
+	fmt.Printf("%s: row-level security enabled\n", *modelName)
+	return nil
+}
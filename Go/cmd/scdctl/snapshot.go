@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yourorg/Go/snapshot"
+	"gorm.io/gorm"
+)
+
+func runSnapshot(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("snapshot")
+	modelName := fs.String("model", "", "versioned table to export, e.g. jobs")
+	target := fs.String("target", "", "name of the plain table to create (default <model>_snapshot)")
+	follow := fs.Bool("follow", false, "keep the table in sync by following the change log")
+	interval := fs.Duration("interval", 5*time.Second, "change log polling interval with -follow")
+	fs.Parse(args)
+
+	e, err := lookupModel(*modelName)
+	if err != nil {
+		return err
+	}
+	model := e.model
+	if *target == "" {
+		*target = *modelName + "_snapshot"
+	}
+	if err := snapshot.Materialize(ctx, db, model, *target); err != nil {
+		return err
+	}
+	fmt.Printf("materialized %s from %s\n", *target, *modelName)
+	if !*follow {
+		return nil
+	}
+	err = snapshot.Follow(ctx, db, model, *target, *interval)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func runSwitchStrategy(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("switch-strategy")
+	modelName := fs.String("model", "", "versioned table, e.g. jobs")
+	to := fs.String("to", "", "target strategy: max_version, window, distinct_on, lateral, is_latest or pointer_table")
+	batch := fs.Int("batch", 1000, "entities backfilled per statement")
+	cleanupAfter := fs.Duration("cleanup-after", 0, "drop the previous strategy's storage after this grace period; 0 skips cleanup")
+	cleanupOnly := fs.Bool("cleanup", false, "only drop storage of strategies other than the active one")
+	fs.Parse(args)
+
+	e, err := lookupModel(*modelName)
+	if err != nil {
+		return err
+	}
+	if *cleanupOnly {
+		return scd.CleanupStrategies(ctx, db, e.model)
+	}
+	s := scd.Strategy(*to)
+	switch s {
+	case scd.StrategyMaxVersion, scd.StrategyWindow, scd.StrategyDistinctOn, scd.StrategyLateral,
+		scd.StrategyIsLatest, scd.StrategyPointer:
+	default:
+		return fmt.Errorf("unknown strategy %q", *to)
+	}
+	err = scd.SwitchStrategy(ctx, db, e.model, s, scd.SwitchOptions{
+		BatchSize:    *batch,
+		CleanupAfter: *cleanupAfter,
+		Progress:     func(step string) { fmt.Printf("%s: %s\n", *modelName, step) },
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s now reads with %s\n", *modelName, s)
+	if *cleanupAfter <= 0 {
+		fmt.Printf("once every server has reloaded, run: scdctl switch-strategy -model %s -cleanup\n", *modelName)
+	}
+	return nil
+}
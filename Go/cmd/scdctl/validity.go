@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func runBackfillValidity(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("backfill-validity")
+	modelName := fs.String("model", "", "versioned table, e.g. jobs")
+	batch := fs.Int("batch", 1000, "entities updated per statement")
+	verify := fs.Bool("verify", false, "only report validity-window inconsistencies")
+	fs.Parse(args)
+
+	e, err := lookupModel(*modelName)
+	if err != nil {
+		return err
+	}
+	if !*verify {
+		n, err := scd.BackfillValidity(ctx, db, e.model, *batch)
+		fmt.Printf("%s: backfilled %d versions\n", *modelName, n)
+		if err != nil {
+			return err
+		}
+	}
+	report, err := scd.VerifyValidity(ctx, db, e.model)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: missing %d, discontinuous %d, closed latest %d, inverted %d\n",
+		report.Table, report.Missing, report.Discontinuous, report.ClosedLatest, report.Inverted)
+	if !report.OK() {
+		return fmt.Errorf("validity windows of %s are inconsistent", report.Table)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/yourorg/Go/writeamp"
+	"gorm.io/gorm"
+)
+
+func runWriteAmp(ctx context.Context, db *gorm.DB, args []string) error {
+	fs := newFlagSet("write-amp")
+	modelName := fs.String("model", "", "versioned table, e.g. jobs")
+	since := fs.Duration("since", 24*time.Hour, "analyze versions written within this long ago")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Parse(args)
+
+	e, err := lookupModel(*modelName)
+	if err != nil {
+		return err
+	}
+	to := time.Now()
+	report, err := writeamp.Analyze(ctx, db, e.model, to.Add(-*since), to)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	fmt.Printf("%s: %d versions in the last %s\n", report.Table, report.Versions, *since)
+	fmt.Printf("  changed bytes:    %d\n", report.ChangedBytes)
+	fmt.Printf("  row bytes:        %d (%.0f per update)\n", report.RowBytes, report.BytesPerUpdate())
+	fmt.Printf("  index entries:    %d\n", report.IndexEntries)
+	fmt.Printf("  amplification:    %.1fx\n", report.Amplification())
+	return nil
+}
@@ -0,0 +1,237 @@
+// Command scdserver serves the SCD HTTP API.
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourorg/Go/admin"
+	"github.com/yourorg/Go/changefeed"
+	"github.com/yourorg/Go/exportjob"
+	"github.com/yourorg/Go/faults"
+	"github.com/yourorg/Go/jobsvc"
+	"github.com/yourorg/Go/maintenance"
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/overhead"
+	"github.com/yourorg/Go/payroll"
+	"github.com/yourorg/Go/periods"
+	"github.com/yourorg/Go/scd"
+	"github.com/yourorg/Go/server"
+	"github.com/yourorg/Go/slo"
+	"github.com/yourorg/Go/usage"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func main() {
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dsn == "" {
+		dsn = "host=localhost user=postgres password=postgres dbname=scd port=5432 sslmode=disable"
+	}
+	addr := os.Getenv("SCD_ADDR")
+	if addr == "" {
+		addr = ":8080"
+	}
+	exportDir := os.Getenv("SCD_EXPORT_DIR")
+	if exportDir == "" {
+		exportDir = os.TempDir()
+	}
+
+	models.Register()
+	// SCD_DEFAULT_STRATEGY picks the latest-version read strategy of tables
+	// without one activated, e.g. "distinct_on".
+	cfg := scd.Config{DefaultStrategy: scd.Strategy(os.Getenv("SCD_DEFAULT_STRATEGY"))}
+	// SCD_COLD_STORAGE maps tables to relations of offloaded history, e.g. "jobs=jobs_cold,timelogs=timelogs_cold".
+	if v := os.Getenv("SCD_COLD_STORAGE"); v != "" {
+		cfg.ColdStorage = map[string]string{}
+		for _, pair := range strings.Split(v, ",") {
+			if table, relation, ok := strings.Cut(pair, "="); ok {
+				cfg.ColdStorage[strings.TrimSpace(table)] = strings.TrimSpace(relation)
+			}
+		}
+	}
+	// SCD_WATCH=true records the change log and publishes it, so entities can
+	// be watched under /{table}/{id}/watch.
+	watch := os.Getenv("SCD_WATCH") == "true"
+	if watch {
+		cfg.ChangeLog = true
+		changefeed.Publish()
+	}
+	// SCD_PAYROLL_RECOMPUTE=true reprices pending payment line items whenever
+	// a job's rate changes.
+	if os.Getenv("SCD_PAYROLL_RECOMPUTE") == "true" {
+		payroll.RecomputeOnRateChange()
+	}
+	// SCD_PERIOD_CLOSE=true serves the payroll periods under /periods and
+	// rejects new versions of work within closed ones.
+	periodClose := os.Getenv("SCD_PERIOD_CLOSE") == "true"
+	if periodClose {
+		periods.Enforce()
+	}
+	// SCD_JOB_TRANSITIONS=true rejects job versions changing the status along
+	// no transition of models.JobTransitions.
+	if os.Getenv("SCD_JOB_TRANSITIONS") == "true" {
+		jobsvc.Enforce()
+	}
+	// SCD_QUARANTINE=true freezes quarantined entities (see scdctl quarantine)
+	// and NOTIFYs owners on changefeed.QuarantineChannel.
+	quarantine := os.Getenv("SCD_QUARANTINE") == "true"
+	if quarantine {
+		cfg.Quarantine = true
+		changefeed.PublishQuarantines()
+		scd.OnQuarantine(func(tx *gorm.DB, event scd.QuarantineEvent) error {
+			if event.Released {
+				log.Printf("%s %s released from quarantine by %s", event.Table, event.EntityID, event.Actor)
+			} else {
+				log.Printf("%s %s quarantined by %s: %s", event.Table, event.EntityID, event.Actor, event.Reason)
+			}
+			return nil
+		})
+	}
+	scd.Configure(cfg)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("failed to connect database: %v", err)
+	}
+	if err := db.Use(scd.NewPlugin()); err != nil {
+		log.Fatalf("failed to install scd plugin: %v", err)
+	}
+	// SCD_FAULTS injects failures into every database operation, e.g.
+	// "errors=0.01,latency=20ms,conflicts=0.05", for resilience testing in
+	// staging (see faults.ParseConfig). Never set it in production.
+	if v := os.Getenv("SCD_FAULTS"); v != "" {
+		fc, err := faults.ParseConfig(v)
+		if err != nil {
+			log.Fatalf("invalid SCD_FAULTS: %v", err)
+		}
+		if err := db.Use(faults.New(fc)); err != nil {
+			log.Fatalf("failed to install fault injection: %v", err)
+		}
+		log.Printf("fault injection enabled: %s", v)
+	}
+	if err := scd.LoadStrategies(context.Background(), db); err != nil {
+		log.Fatalf("failed to load strategies: %v", err)
+	}
+
+	versionedModels := map[string]any{}
+	var tables []string
+	for _, d := range scd.RegisteredModels() {
+		versionedModels[d.Table] = d.Model
+		tables = append(tables, d.Table)
+	}
+	exports := &exportjob.Manager{DB: db, Dir: exportDir, Tables: tables}
+	// SCD_EXPORT_LINEAGE=true adds lineage columns to exported rows;
+	// SCD_OPENLINEAGE_URL also posts a run event per export to that endpoint,
+	// under SCD_OPENLINEAGE_NAMESPACE with tables in SCD_OPENLINEAGE_DATASOURCE.
+	if url := os.Getenv("SCD_OPENLINEAGE_URL"); url != "" || os.Getenv("SCD_EXPORT_LINEAGE") == "true" {
+		exports.Lineage = &exportjob.Lineage{Namespace: os.Getenv("SCD_OPENLINEAGE_NAMESPACE"),
+			Datasource: os.Getenv("SCD_OPENLINEAGE_DATASOURCE")}
+		if url != "" {
+			exports.Lineage.Emitter = &exportjob.HTTPEmitter{URL: url, APIKey: os.Getenv("SCD_OPENLINEAGE_API_KEY")}
+		}
+	}
+	if err := exports.Migrate(); err != nil {
+		log.Fatalf("failed to migrate export jobs: %v", err)
+	}
+	if err := exports.Resume(context.Background()); err != nil {
+		log.Printf("resuming export jobs: %v", err)
+	}
+
+	if interval, err := time.ParseDuration(os.Getenv("SCD_MAINTENANCE_INTERVAL")); err == nil && interval > 0 {
+		scheduler := &maintenance.Scheduler{DB: db, Interval: interval}
+		if n, err := strconv.Atoi(os.Getenv("SCD_MAINTENANCE_MAX_WORKERS")); err == nil && n > 1 {
+			scheduler.Scaler = maintenance.RateScaler{Min: 1, Max: n, VersionsPerWorker: 500}
+		}
+		go scheduler.Run(context.Background())
+	}
+
+	// Scheduled changes are activated every SCD_ACTIVATION_INTERVAL, default one minute.
+	if err := scd.MigrateScheduledChanges(db); err != nil {
+		log.Fatalf("failed to migrate scheduled changes: %v", err)
+	}
+	activationInterval, _ := time.ParseDuration(os.Getenv("SCD_ACTIVATION_INTERVAL"))
+	go (&maintenance.Activator{DB: db, Interval: activationInterval}).Run(context.Background())
+
+	srv := &server.Server{DB: db, Models: versionedModels, Exports: exports, Periods: periodClose}
+	if periodClose {
+		if err := periods.Migrate(db); err != nil {
+			log.Fatalf("failed to migrate periods: %v", err)
+		}
+		if err := periods.InstallGuard(db); err != nil {
+			log.Fatalf("failed to install period guard: %v", err)
+		}
+	}
+	if quarantine {
+		if err := scd.MigrateQuarantine(db); err != nil {
+			log.Fatalf("failed to migrate quarantine: %v", err)
+		}
+	}
+	// SCD_RESERVATIONS=true lets offline clients reserve the next version of
+	// an entity and commit their edit later.
+	if os.Getenv("SCD_RESERVATIONS") == "true" {
+		if err := scd.MigrateReservations(db); err != nil {
+			log.Fatalf("failed to migrate version reservations: %v", err)
+		}
+		srv.Reservations = true
+	}
+	if watch {
+		if err := scd.MigrateChangeLog(db); err != nil {
+			log.Fatalf("failed to migrate change log: %v", err)
+		}
+		srv.Watcher = &changefeed.Watcher{DB: db}
+		go func() {
+			if err := srv.Watcher.Run(context.Background()); err != nil {
+				log.Printf("entity watches stopped: %v", err)
+			}
+		}()
+	}
+	// SCD_SLO_CREATE_P99 and SCD_SLO_READ_P99 set p99 latency targets, e.g. "50ms".
+	createP99, _ := time.ParseDuration(os.Getenv("SCD_SLO_CREATE_P99"))
+	readP99, _ := time.ParseDuration(os.Getenv("SCD_SLO_READ_P99"))
+	if createP99 > 0 || readP99 > 0 {
+		tracker := &slo.Tracker{
+			Create: slo.Objective{Percentile: 0.99, Threshold: createP99},
+			Read:   slo.Objective{Percentile: 0.99, Threshold: readP99},
+		}
+		if err := tracker.Install(db); err != nil {
+			log.Fatalf("failed to install SLO tracking: %v", err)
+		}
+		tracker.Publish()
+		srv.SLO = tracker
+	}
+	// SCD_OVERHEAD_SAMPLE_RATE compares that fraction of latest-version reads
+	// with their non-versioned baseline, e.g. "0.01", published as scd_overhead.
+	if rate, err := strconv.ParseFloat(os.Getenv("SCD_OVERHEAD_SAMPLE_RATE"), 64); err == nil && rate > 0 {
+		monitor := &overhead.Monitor{SampleRate: rate}
+		if err := monitor.Install(db); err != nil {
+			log.Fatalf("failed to install overhead sampling: %v", err)
+		}
+		monitor.Publish()
+	}
+	// SCD_USAGE=true records which repository methods run which queries,
+	// published as scd_usage and served under /usage.
+	if os.Getenv("SCD_USAGE") == "true" {
+		tracker := &usage.Tracker{}
+		if err := tracker.Install(db); err != nil {
+			log.Fatalf("failed to install usage tracking: %v", err)
+		}
+		tracker.Publish()
+		srv.Usage = tracker
+	}
+	handler := srv.Handler()
+	if os.Getenv("SCD_ADMIN") == "true" {
+		ui := &admin.UI{DB: db, Models: versionedModels, Prefix: "/admin"}
+		mux := http.NewServeMux()
+		mux.Handle("/admin/", ui.Handler())
+		mux.Handle("/", handler)
+		handler = mux
+	}
+	log.Printf("listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, handler))
+}
@@ -0,0 +1,304 @@
+// Package dedup finds likely duplicates of an imported record among the
+// entities already stored, so an import can merge into an existing entity
+// instead of creating a second one:
+//
+//	d, err := dedup.New(db, dedup.Config{
+//		Model:   models.Job{},
+//		Fields:  []dedup.Field{{Column: "title", Weight: 2}, {Column: "company_id", Exact: true}},
+//		History: 90 * 24 * time.Hour,
+//	})
+//	candidates, err := d.Find(ctx, map[string]string{"title": row.Title, "company_id": row.CompanyID})
+//	if len(candidates) > 0 && candidates[0].Score >= 0.9 { ... merge into candidates[0].ID ... }
+//
+// Fuzzy fields are compared by trigram similarity, which needs the pg_trgm
+// extension; Migrate creates it along with an index per fuzzy field.
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+// Field is a column compared when looking for duplicates.
+type Field struct {
+	Column string
+	// Weight is the field's share of the score relative to the other fields
+	// (default 1).
+	Weight float64
+	// Exact compares case-insensitively for equality instead of by
+	// similarity, for identifiers such as tax numbers or foreign keys.
+	Exact bool
+}
+
+// Config describes what counts as a duplicate of a record.
+type Config struct {
+	// Model is the versioned model searched, e.g. models.Job{}.
+	Model  any
+	Fields []Field
+	// Threshold is the lowest score of a candidate, between 0 and 1
+	// (default 0.6).
+	Threshold float64
+	// Limit caps the candidates returned (default 10).
+	Limit int
+	// History also matches versions written within this window, so a record
+	// still matches an entity that was renamed since the data was exported.
+	// Zero matches latest versions only.
+	History time.Duration
+}
+
+// Candidate is an entity that may be a duplicate of the record searched for.
+type Candidate struct {
+	ID string `json:"id"`
+	// Version and UID identify the version that matched best.
+	Version int    `json:"version"`
+	UID     string `json:"uid"`
+	// Score is the weighted similarity of the matched fields, from 0 to 1.
+	Score float64 `json:"score"`
+	// Fields holds the similarity of each field searched.
+	Fields map[string]float64 `json:"fields"`
+	// Historical is set when an older version matched better than the latest.
+	Historical bool `json:"historical,omitempty"`
+}
+
+// Detector searches for duplicates as its Config describes.
+type Detector struct {
+	db   *gorm.DB
+	cfg  Config
+	info scd.TableInfo
+}
+
+// New checks cfg against the model's table and returns a Detector for it.
+func New(db *gorm.DB, cfg Config) (*Detector, error) {
+	info, err := scd.Describe(db, cfg.Model)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Fields) == 0 {
+		return nil, fmt.Errorf("dedup: no fields configured for %s", info.Table)
+	}
+	cfg.Fields = slices.Clone(cfg.Fields)
+	for i, f := range cfg.Fields {
+		if !slices.Contains(info.Columns, f.Column) {
+			return nil, fmt.Errorf("dedup: %s has no column %s", info.Table, f.Column)
+		}
+		if f.Weight < 0 {
+			return nil, fmt.Errorf("dedup: negative weight for %s", f.Column)
+		}
+		if f.Weight == 0 {
+			cfg.Fields[i].Weight = 1
+		}
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = 0.6
+	}
+	if cfg.Limit <= 0 {
+		cfg.Limit = 10
+	}
+	return &Detector{db: db, cfg: cfg, info: info}, nil
+}
+
+// Migrate creates the pg_trgm extension and a trigram index on each fuzzy
+// field of cfg, which the similarity search uses to avoid a full scan.
+func Migrate(ctx context.Context, db *gorm.DB, cfg Config) error {
+	info, err := scd.Describe(db, cfg.Model)
+	if err != nil {
+		return err
+	}
+	db = db.WithContext(ctx)
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		return fmt.Errorf("creating pg_trgm extension failed: %w", err)
+	}
+	for _, f := range cfg.Fields {
+		if f.Exact {
+			continue
+		}
+		index := strings.ReplaceAll(info.Table, ".", "_") + "_" + f.Column + "_trgm"
+		stmt := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING gin (lower(%s::text) gin_trgm_ops)",
+			db.Statement.Quote(index), db.Statement.Quote(info.Table), db.Statement.Quote(f.Column))
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("creating trigram index on %s.%s failed: %w", info.Table, f.Column, err)
+		}
+	}
+	return nil
+}
+
+// Find returns the entities likely to duplicate record, a map from column to
+// the imported value, best match first. Fields the record leaves empty are
+// not compared and the score is weighted over the rest. Deleted entities are
+// not matched.
+//
+// Only rows that match at least one field are scored: exact fields by
+// equality, fuzzy fields with pg_trgm's % operator, i.e. a similarity of at
+// least pg_trgm.similarity_threshold (0.3 unless configured otherwise).
+func (d *Detector) Find(ctx context.Context, record map[string]string) ([]Candidate, error) {
+	var fields []Field
+	for _, f := range d.cfg.Fields {
+		if strings.TrimSpace(record[f.Column]) != "" {
+			fields = append(fields, f)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	latest := scd.RawLatest(ctx, d.db, d.cfg.Model, d.liveOnly())
+	candidates, err := d.search(ctx, latest, fields, record)
+	if err != nil {
+		return nil, err
+	}
+	if d.cfg.History > 0 {
+		since := time.Now().Add(-d.cfg.History)
+		versions := scd.AllVersions(ctx, d.db, d.cfg.Model).Select("*").Where("created_at >= ?", since)
+		if slices.Contains(d.info.Meta, "version_state") {
+			versions = versions.Where("version_state = ?", scd.StateApproved)
+		}
+		older, err := d.search(ctx, versions, fields, record)
+		if err != nil {
+			return nil, err
+		}
+		current, err := d.latestVersions(ctx, older)
+		if err != nil {
+			return nil, err
+		}
+		candidates = merge(candidates, older, current)
+	}
+	if len(candidates) > d.cfg.Limit {
+		candidates = candidates[:d.cfg.Limit]
+	}
+	return candidates, nil
+}
+
+// liveOnly is the RawLatest clause leaving out deleted entities.
+func (d *Detector) liveOnly() string {
+	if slices.Contains(d.info.Meta, "is_deleted") {
+		return "WHERE is_deleted IS NOT TRUE"
+	}
+	return ""
+}
+
+// latestVersions returns the latest version of each live entity among
+// candidates, by id.
+func (d *Detector) latestVersions(ctx context.Context, candidates []Candidate) (map[string]int, error) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.ID
+	}
+	clause := "WHERE id IN ?"
+	if d.liveOnly() != "" {
+		clause += " AND is_deleted IS NOT TRUE"
+	}
+	var rows []struct {
+		ID      string
+		Version int
+	}
+	q := scd.RawLatest(ctx, d.db, d.cfg.Model, clause, ids)
+	if err := d.db.WithContext(ctx).Raw("SELECT id, version FROM (?) AS l", q).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("reading latest versions of %s failed: %w", d.info.Table, err)
+	}
+	out := make(map[string]int, len(rows))
+	for _, r := range rows {
+		out[r.ID] = r.Version
+	}
+	return out, nil
+}
+
+// search scores the rows of source, best first, one row per entity.
+func (d *Detector) search(ctx context.Context, source *gorm.DB, fields []Field, record map[string]string) ([]Candidate, error) {
+	q := d.db.WithContext(ctx)
+	var sims, matches []string
+	var simArgs, matchArgs []any
+	var total float64
+	for _, f := range fields {
+		col := "lower(c." + q.Statement.Quote(f.Column) + "::text)"
+		value := strings.ToLower(strings.TrimSpace(record[f.Column]))
+		if f.Exact {
+			sims = append(sims, fmt.Sprintf("CASE WHEN %s = ? THEN 1.0 ELSE 0.0 END", col))
+			matches = append(matches, col+" = ?")
+		} else {
+			sims = append(sims, fmt.Sprintf("similarity(%s, ?)", col))
+			matches = append(matches, col+" % ?")
+		}
+		simArgs = append(simArgs, value)
+		matchArgs = append(matchArgs, value)
+		total += f.Weight
+	}
+	var score []string
+	for i, f := range fields {
+		score = append(score, fmt.Sprintf("%g * s.s%d", f.Weight/total, i))
+	}
+	var cols []string
+	for i, s := range sims {
+		cols = append(cols, fmt.Sprintf("%s AS s%d", s, i))
+	}
+	sql := fmt.Sprintf(
+		`SELECT * FROM (
+			SELECT DISTINCT ON (s.id) s.*, %[1]s AS score FROM (
+				SELECT c.id, c.version, c.uid, %[2]s FROM (?) AS c WHERE %[3]s
+			) AS s ORDER BY s.id, score DESC, s.version DESC
+		) AS m WHERE score >= ? ORDER BY score DESC, id LIMIT ?`,
+		strings.Join(score, " + "), strings.Join(cols, ", "), strings.Join(matches, " OR "))
+	args := append(append(append(simArgs, source), matchArgs...), d.cfg.Threshold, d.cfg.Limit)
+
+	rows, err := q.Raw(sql, args...).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("searching %s for duplicates failed: %w", d.info.Table, err)
+	}
+	defer rows.Close()
+	var out []Candidate
+	for rows.Next() {
+		c := Candidate{Fields: make(map[string]float64, len(fields))}
+		s := make([]float64, len(fields))
+		dest := []any{&c.ID, &c.Version, &c.UID}
+		for i := range s {
+			dest = append(dest, &s[i])
+		}
+		if err := rows.Scan(append(dest, &c.Score)...); err != nil {
+			return nil, fmt.Errorf("reading duplicate candidates failed: %w", err)
+		}
+		for i, f := range fields {
+			c.Fields[f.Column] = s[i]
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// merge adds the historical matches in older to latest, keeping each
+// entity's best match. current holds the latest version of each live entity
+// in older; matches of other entities are dropped, since deleted entities are
+// never candidates.
+func merge(latest, older []Candidate, current map[string]int) []Candidate {
+	best := make(map[string]int, len(latest))
+	for i, c := range latest {
+		best[c.ID] = i
+	}
+	for _, c := range older {
+		version, live := current[c.ID]
+		if !live {
+			continue
+		}
+		c.Historical = c.Version != version
+		if i, ok := best[c.ID]; !ok {
+			best[c.ID] = len(latest)
+			latest = append(latest, c)
+		} else if c.Score > latest[i].Score {
+			latest[i] = c
+		}
+	}
+	sort.SliceStable(latest, func(i, j int) bool {
+		if latest[i].Score != latest[j].Score {
+			return latest[i].Score > latest[j].Score
+		}
+		return latest[i].ID < latest[j].ID
+	})
+	return latest
+}
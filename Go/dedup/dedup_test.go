@@ -0,0 +1,35 @@
+package dedup_test
+
+import (
+	"testing"
+
+	"github.com/yourorg/Go/dedup"
+	"github.com/yourorg/Go/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestNewChecksFieldsAgainstTheModel(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("opening dry-run db: %v", err)
+	}
+	for name, fields := range map[string][]dedup.Field{
+		"none":        nil,
+		"unknown":     {{Column: "name"}},
+		"meta column": {{Column: "uid"}},
+		"negative":    {{Column: "title", Weight: -1}},
+	} {
+		if _, err := dedup.New(db, dedup.Config{Model: models.Job{}, Fields: fields}); err == nil {
+			t.Errorf("%s: New accepted %+v", name, fields)
+		}
+	}
+	fields := []dedup.Field{{Column: "title"}, {Column: "company_id", Exact: true}}
+	if _, err := dedup.New(db, dedup.Config{Model: models.Job{}, Fields: fields}); err != nil {
+		t.Fatalf("New rejected valid fields: %v", err)
+	}
+	if fields[0].Weight != 0 {
+		t.Errorf("New changed the caller's fields: %+v", fields)
+	}
+}
@@ -0,0 +1,357 @@
+// Package exportjob runs full-history exports of versioned tables as
+// background jobs. Progress is checkpointed after every batch, so a job
+// interrupted by a restart resumes where it stopped instead of starting over.
+// A running job is leased by the Manager running it, so several instances
+// sharing the job table never run the same job at once.
+//
+// An export is not a consistent snapshot: it reads the table in (id, version)
+// order batch by batch, so a version written during the export to an entity
+// the cursor has already passed is left out, on resume as well as in one run.
+// For a consistent copy, export while writes are paused, or read the versions
+// recorded since the export started from the scd change log.
+package exportjob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Job statuses.
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// Job is the persisted state of one export. The checkpoint is the last exported
+// (id, version) key plus the output file size at that point. Owner is the
+// Manager holding the job's lease, which runs out at LeaseUntil unless the
+// Manager checkpoints again.
+type Job struct {
+	ID            string     `gorm:"primaryKey;column:id" json:"id"`
+	Table         string     `gorm:"column:table_name" json:"table"`
+	Status        string     `gorm:"column:status" json:"status"`
+	Anonymize     bool       `gorm:"column:anonymize" json:"anonymize"`
+	Salt          string     `gorm:"column:salt" json:"-"`
+	RowsExported  int64      `gorm:"column:rows_exported" json:"rows_exported"`
+	CursorID      string     `gorm:"column:cursor_id" json:"-"`
+	CursorVersion int        `gorm:"column:cursor_version" json:"-"`
+	Bytes         int64      `gorm:"column:bytes" json:"bytes"`
+	Path          string     `gorm:"column:path" json:"-"`
+	Error         string     `gorm:"column:error" json:"error,omitempty"`
+	Owner         string     `gorm:"column:owner;not null;default:''" json:"-"`
+	LeaseUntil    *time.Time `gorm:"column:lease_until" json:"-"`
+	// LineageRunID is the OpenLineage run ID of the job's latest run, and
+	// LineageError why its run event could not be emitted.
+	LineageRunID string    `gorm:"column:lineage_run_id" json:"lineage_run_id,omitempty"`
+	LineageError string    `gorm:"column:lineage_error" json:"lineage_error,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (Job) TableName() string { return "scd_export_jobs" }
+
+// ErrUnknownTable is returned when an export is requested for a table the Manager does not serve.
+var ErrUnknownTable = errors.New("unknown table")
+
+// errLeaseLost stops a job whose lease another Manager took over.
+var errLeaseLost = errors.New("export job lease lost")
+
+// Manager creates and runs export jobs, writing NDJSON files into Dir.
+type Manager struct {
+	DB  *gorm.DB
+	Dir string
+	// Tables lists the versioned tables that may be exported.
+	Tables []string
+	// BatchSize is the number of rows written between checkpoints (default 5000).
+	BatchSize int
+	// Lineage, when set, adds the lineage columns to every exported row and
+	// emits a run event per export run.
+	Lineage *Lineage
+	// LeaseTTL is how long a job stays claimed without a checkpoint before
+	// another Manager may take it over (default 5 minutes). Keep it well
+	// above the time one batch takes.
+	LeaseTTL time.Duration
+
+	wg        sync.WaitGroup
+	ownerOnce sync.Once
+	owner     string
+}
+
+// Migrate creates the job table.
+func (m *Manager) Migrate() error {
+	return m.DB.AutoMigrate(&Job{})
+}
+
+// Create records a new pending export of table and starts it in the background.
+func (m *Manager) Create(ctx context.Context, table string) (Job, error) {
+	return m.create(ctx, table, false)
+}
+
+// CreateAnonymized is Create for a staging-safe copy: the PII columns declared
+// at scd.RegisterModel are pseudonymized. See pseudonymize.
+func (m *Manager) CreateAnonymized(ctx context.Context, table string) (Job, error) {
+	return m.create(ctx, table, true)
+}
+
+func (m *Manager) create(ctx context.Context, table string, anonymize bool) (Job, error) {
+	if !slices.Contains(m.Tables, table) {
+		return Job{}, fmt.Errorf("%w: %s", ErrUnknownTable, table)
+	}
+	id, err := newID()
+	if err != nil {
+		return Job{}, err
+	}
+	job := Job{ID: id, Table: table, Status: StatusPending, Anonymize: anonymize, Path: filepath.Join(m.Dir, id+".ndjson")}
+	if anonymize {
+		if job.Salt, err = newID(); err != nil {
+			return Job{}, err
+		}
+	}
+	if err := m.DB.WithContext(ctx).Create(&job).Error; err != nil {
+		return Job{}, fmt.Errorf("creating export job failed: %w", err)
+	}
+	m.start(ctx, job.ID)
+	return job, nil
+}
+
+// Get returns the current state of a job.
+func (m *Manager) Get(ctx context.Context, id string) (Job, error) {
+	var job Job
+	err := m.DB.WithContext(ctx).First(&job, "id = ?", id).Error
+	return job, err
+}
+
+// Resume restarts every job left pending or running whose lease has run out,
+// e.g. after a process restart.
+func (m *Manager) Resume(ctx context.Context) error {
+	var ids []string
+	err := m.DB.WithContext(ctx).Model(&Job{}).
+		Where("status IN ? AND (lease_until IS NULL OR lease_until < now())", []string{StatusPending, StatusRunning}).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return fmt.Errorf("listing unfinished export jobs failed: %w", err)
+	}
+	for _, id := range ids {
+		m.start(ctx, id)
+	}
+	return nil
+}
+
+// Wait blocks until all jobs started by this Manager have returned.
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+func (m *Manager) start(ctx context.Context, id string) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		// A job outlives the request that created it, so drop its cancellation.
+		m.run(context.WithoutCancel(ctx), id)
+	}()
+}
+
+// run executes a job from its last checkpoint until it is done or fails,
+// unless another Manager holds it.
+func (m *Manager) run(ctx context.Context, id string) {
+	if claimed, err := m.claim(ctx, id); err != nil || !claimed {
+		return
+	}
+	job, err := m.Get(ctx, id)
+	if err != nil {
+		return
+	}
+	if m.Lineage != nil {
+		job.LineageRunID, job.LineageError = scd.UUIDv4(), ""
+	}
+	if err := m.export(ctx, &job); errors.Is(err, errLeaseLost) {
+		return
+	} else if err != nil {
+		job.Status, job.Error = StatusFailed, err.Error()
+	} else {
+		job.Status = StatusDone
+	}
+	if m.Lineage != nil && m.Lineage.Emitter != nil {
+		eventType := EventComplete
+		if job.Status == StatusFailed {
+			eventType = EventFail
+		}
+		if err := m.Lineage.Emitter.Emit(ctx, m.Lineage.NewRunEvent(job, job.LineageRunID, eventType, time.Now())); err != nil {
+			job.LineageError = err.Error()
+		}
+	}
+	m.checkpoint(ctx, &job)
+}
+
+// claim takes the lease of job id for this Manager, reporting false when
+// the job is finished or another Manager's lease on it is still running.
+// Lease times are the database's, so Managers' clocks need not agree.
+func (m *Manager) claim(ctx context.Context, id string) (bool, error) {
+	res := m.DB.WithContext(ctx).Model(&Job{}).
+		Where("id = ? AND status IN ? AND (lease_until IS NULL OR lease_until < now())", id, []string{StatusPending, StatusRunning}).
+		Updates(map[string]any{"owner": m.ownerID(), "lease_until": m.leaseExpiry()})
+	if res.Error != nil {
+		return false, fmt.Errorf("claiming export job %s failed: %w", id, res.Error)
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// checkpoint saves the state of job and renews its lease, failing with
+// errLeaseLost when another Manager has taken the job over.
+func (m *Manager) checkpoint(ctx context.Context, job *Job) error {
+	res := m.DB.WithContext(ctx).Model(&Job{}).Where("id = ? AND owner = ?", job.ID, m.ownerID()).Updates(map[string]any{
+		"status":         job.Status,
+		"rows_exported":  job.RowsExported,
+		"cursor_id":      job.CursorID,
+		"cursor_version": job.CursorVersion,
+		"bytes":          job.Bytes,
+		"error":          job.Error,
+		"lineage_run_id": job.LineageRunID,
+		"lineage_error":  job.LineageError,
+		"lease_until":    m.leaseExpiry(),
+	})
+	if res.Error != nil {
+		return fmt.Errorf("checkpointing export job failed: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("checkpointing export job %s failed: %w", job.ID, errLeaseLost)
+	}
+	return nil
+}
+
+// ownerID identifies this Manager as the owner of the jobs it leases.
+func (m *Manager) ownerID() string {
+	m.ownerOnce.Do(func() { m.owner = scd.UUIDv4() })
+	return m.owner
+}
+
+func (m *Manager) leaseExpiry() clause.Expr {
+	ttl := m.LeaseTTL
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	return gorm.Expr("now() + make_interval(secs => ?)", ttl.Seconds())
+}
+
+func (m *Manager) export(ctx context.Context, job *Job) error {
+	batch := m.BatchSize
+	if batch <= 0 {
+		batch = 5000
+	}
+	f, err := os.OpenFile(job.Path, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// Anything past the checkpoint was written by an interrupted batch and is rewritten.
+	if err := f.Truncate(job.Bytes); err != nil {
+		return err
+	}
+	if _, err := f.Seek(job.Bytes, 0); err != nil {
+		return err
+	}
+	job.Status = StatusRunning
+	if err := m.checkpoint(ctx, job); err != nil {
+		return err
+	}
+	var pii []string
+	if job.Anonymize {
+		d, ok := scd.LookupModel(job.Table)
+		if !ok {
+			return fmt.Errorf("%w: %s is not registered", ErrUnknownTable, job.Table)
+		}
+		pii = d.PIIColumns()
+	}
+	changeLog := m.Lineage != nil && m.DB.WithContext(ctx).Migrator().HasTable(&scd.ChangeLogEntry{})
+
+	for {
+		var rows []map[string]any
+		q := m.DB.WithContext(ctx).Table(job.Table).Order("id, version").Limit(batch)
+		if job.CursorID != "" {
+			q = q.Where("(id, version) > (?, ?)", job.CursorID, job.CursorVersion)
+		}
+		if err := q.Find(&rows).Error; err != nil {
+			return fmt.Errorf("reading %s failed: %w", job.Table, err)
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		if m.Lineage != nil {
+			if err := addLineage(ctx, m.DB, job, rows, changeLog); err != nil {
+				return err
+			}
+		}
+		enc := json.NewEncoder(f)
+		for _, row := range rows {
+			for _, c := range pii {
+				row[c] = pseudonymize(job.Salt, c, row[c])
+			}
+			if err := enc.Encode(row); err != nil {
+				return err
+			}
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+		last := rows[len(rows)-1]
+		job.CursorID = fmt.Sprint(last["id"])
+		job.CursorVersion = toInt(last["version"])
+		job.RowsExported += int64(len(rows))
+		if job.Bytes, err = f.Seek(0, 1); err != nil {
+			return err
+		}
+		if err := m.checkpoint(ctx, job); err != nil {
+			return err
+		}
+	}
+}
+
+// pseudonymize replaces a PII value with a keyed hash of it. Equal values map
+// to equal pseudonyms within one export, so an entity keeps the same fake
+// value across its versions unless the real value changed, and joins on the
+// column still line up. NULLs stay NULL.
+func pseudonymize(salt, column string, v any) any {
+	if v == nil {
+		return nil
+	}
+	mac := hmac.New(sha256.New, []byte(salt))
+	fmt.Fprintf(mac, "%s\x00%v", column, v)
+	return column + "_" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+func newID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func toInt(v any) int {
+	switch n := v.(type) {
+	case int64:
+		return int(n)
+	case int32:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,186 @@
+package exportjob
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+// Lineage columns added to every exported row when Manager.Lineage is set.
+const (
+	ColumnSourceTable = "_scd_source_table"
+	ColumnEntityID    = "_scd_entity_id"
+	ColumnVersion     = "_scd_version"
+	// ColumnChangeSet is the change set the version was written in, empty
+	// when the change log was off or the write carried none.
+	ColumnChangeSet = "_scd_change_set_id"
+	// ColumnExportID is the ID of the export job that wrote the row.
+	ColumnExportID = "_scd_export_id"
+)
+
+// OpenLineage run event types.
+const (
+	EventComplete = "COMPLETE"
+	EventFail     = "FAIL"
+)
+
+const (
+	// Producer identifies this package as the producer of lineage events.
+	Producer = "https://github.com/yourorg/Go/exportjob"
+	// RunEventSchemaURL is the OpenLineage spec version the events follow.
+	RunEventSchemaURL = "https://openlineage.io/spec/2-0-2/OpenLineage.json#/$defs/RunEvent"
+)
+
+// Lineage configures the provenance an export records, for a data platform
+// tracking datasets derived from versioned tables.
+type Lineage struct {
+	// Namespace is the OpenLineage namespace of the export jobs (default "scd").
+	Namespace string
+	// Datasource is the namespace of the exported tables as input datasets,
+	// such as "postgres://db.internal:5432" (default "postgres").
+	Datasource string
+	// Emitter receives one run event per export run, when it finishes or
+	// fails; nil only adds the lineage columns.
+	Emitter Emitter
+}
+
+// Emitter delivers OpenLineage run events.
+type Emitter interface {
+	Emit(ctx context.Context, ev RunEvent) error
+}
+
+// RunEvent is an OpenLineage run event.
+type RunEvent struct {
+	EventType string    `json:"eventType"`
+	EventTime time.Time `json:"eventTime"`
+	Run       struct {
+		RunID  string         `json:"runId"`
+		Facets map[string]any `json:"facets,omitempty"`
+	} `json:"run"`
+	Job struct {
+		Namespace string `json:"namespace"`
+		Name      string `json:"name"`
+	} `json:"job"`
+	Inputs    []Dataset `json:"inputs"`
+	Outputs   []Dataset `json:"outputs"`
+	Producer  string    `json:"producer"`
+	SchemaURL string    `json:"schemaURL"`
+}
+
+// Dataset is an input or output dataset of a run event.
+type Dataset struct {
+	Namespace    string         `json:"namespace"`
+	Name         string         `json:"name"`
+	Facets       map[string]any `json:"facets,omitempty"`
+	OutputFacets map[string]any `json:"outputFacets,omitempty"`
+}
+
+// HTTPEmitter posts run events to an OpenLineage HTTP endpoint such as
+// Marquez's /api/v1/lineage.
+type HTTPEmitter struct {
+	URL string
+	// APIKey, when set, is sent as a bearer token.
+	APIKey string
+	// Client defaults to a client with a 10 second timeout.
+	Client *http.Client
+}
+
+// Emit posts ev, failing on any status other than 2xx.
+func (e *HTTPEmitter) Emit(ctx context.Context, ev RunEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+	client := e.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("emitting lineage event failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("emitting lineage event failed: %s", resp.Status)
+	}
+	return nil
+}
+
+// NewRunEvent describes the run runID of job, which ended at at as
+// eventType: the table read and the file written, with the rows and bytes
+// the job has exported so far.
+func (l *Lineage) NewRunEvent(job Job, runID, eventType string, at time.Time) RunEvent {
+	namespace, datasource := l.Namespace, l.Datasource
+	if namespace == "" {
+		namespace = "scd"
+	}
+	if datasource == "" {
+		datasource = "postgres"
+	}
+	ev := RunEvent{EventType: eventType, EventTime: at.UTC(), Producer: Producer, SchemaURL: RunEventSchemaURL}
+	ev.Run.RunID = runID
+	ev.Run.Facets = map[string]any{"scd_export": facet(map[string]any{"exportId": job.ID, "anonymized": job.Anonymize})}
+	if job.Error != "" {
+		ev.Run.Facets["errorMessage"] = facet(map[string]any{"message": job.Error, "programmingLanguage": "go"})
+	}
+	ev.Job.Namespace, ev.Job.Name = namespace, "export."+job.Table
+	ev.Inputs = []Dataset{{Namespace: datasource, Name: job.Table}}
+	ev.Outputs = []Dataset{{Namespace: "file", Name: job.Path,
+		OutputFacets: map[string]any{"outputStatistics": facet(map[string]any{"rowCount": job.RowsExported, "size": job.Bytes})}}}
+	return ev
+}
+
+// facet adds the fields every OpenLineage facet carries to fields.
+func facet(fields map[string]any) map[string]any {
+	fields["_producer"] = Producer
+	fields["_schemaURL"] = RunEventSchemaURL
+	return fields
+}
+
+// addLineage adds the lineage columns to rows, a batch read by job, looking
+// their change sets up in the change log when changeLog is set.
+func addLineage(ctx context.Context, db *gorm.DB, job *Job, rows []map[string]any, changeLog bool) error {
+	changeSets := map[string]string{}
+	if changeLog {
+		uids := make([]string, 0, len(rows))
+		for _, row := range rows {
+			if uid, ok := row["uid"].(string); ok {
+				uids = append(uids, uid)
+			}
+		}
+		var entries []scd.ChangeLogEntry
+		err := db.WithContext(ctx).Select("uid", "change_set_id").
+			Where("table_name = ? AND uid IN ? AND change_set_id <> ''", job.Table, uids).Find(&entries).Error
+		if err != nil {
+			return fmt.Errorf("reading change sets of %s failed: %w", job.Table, err)
+		}
+		for _, e := range entries {
+			changeSets[e.UID] = e.ChangeSet
+		}
+	}
+	for _, row := range rows {
+		uid, _ := row["uid"].(string)
+		row[ColumnSourceTable] = job.Table
+		row[ColumnEntityID] = row["id"]
+		row[ColumnVersion] = row["version"]
+		row[ColumnChangeSet] = changeSets[uid]
+		row[ColumnExportID] = job.ID
+	}
+	return nil
+}
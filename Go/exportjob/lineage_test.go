@@ -0,0 +1,64 @@
+package exportjob_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yourorg/Go/exportjob"
+)
+
+func TestHTTPEmitterPostsRunEvent(t *testing.T) {
+	var got map[string]any
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding event: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	l := &exportjob.Lineage{Datasource: "postgres://db:5432"}
+	job := exportjob.Job{ID: "exp1", Table: "jobs", Path: "/exports/exp1.ndjson", RowsExported: 42, Bytes: 4096}
+	ev := l.NewRunEvent(job, "3f0c7a1e-0000-4000-8000-000000000001", exportjob.EventComplete, time.Now())
+	if err := (&exportjob.HTTPEmitter{URL: srv.URL, APIKey: "k"}).Emit(context.Background(), ev); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+	if auth != "Bearer k" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if got["eventType"] != "COMPLETE" || got["producer"] != exportjob.Producer {
+		t.Errorf("event = %v", got)
+	}
+	jobFields := got["job"].(map[string]any)
+	if jobFields["namespace"] != "scd" || jobFields["name"] != "export.jobs" {
+		t.Errorf("job = %v, want the default namespace and export.jobs", jobFields)
+	}
+	input := got["inputs"].([]any)[0].(map[string]any)
+	if input["namespace"] != "postgres://db:5432" || input["name"] != "jobs" {
+		t.Errorf("input = %v", input)
+	}
+	stats := got["outputs"].([]any)[0].(map[string]any)["outputFacets"].(map[string]any)["outputStatistics"].(map[string]any)
+	if stats["rowCount"] != 42.0 || stats["size"] != 4096.0 {
+		t.Errorf("output statistics = %v", stats)
+	}
+}
+
+func TestHTTPEmitterFailsOnErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad event", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	ev := (&exportjob.Lineage{}).NewRunEvent(exportjob.Job{ID: "exp1", Table: "jobs", Error: "boom"}, "run", exportjob.EventFail, time.Now())
+	if _, ok := ev.Run.Facets["errorMessage"]; !ok {
+		t.Errorf("failed run has no errorMessage facet: %v", ev.Run.Facets)
+	}
+	if err := (&exportjob.HTTPEmitter{URL: srv.URL}).Emit(context.Background(), ev); err == nil {
+		t.Fatal("Emit succeeded on a 400")
+	}
+}
@@ -0,0 +1,50 @@
+package factory_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yourorg/Go/factory"
+	"github.com/yourorg/Go/models"
+)
+
+func TestJobHistoryEndsInTheBuiltJob(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	versions := factory.Job().WithID("job1").Completed().WithRate(120).WithVersions(4).At(at).Build()
+	if len(versions) != 4 {
+		t.Fatalf("got %d versions, want 4", len(versions))
+	}
+	for i, v := range versions {
+		if v.ID != "job1" || v.Version != i+1 || v.UID == "" {
+			t.Errorf("version %d: id, version, uid = %s, %d, %q", i, v.ID, v.Version, v.UID)
+		}
+		if i > 0 && (v.UID == versions[i-1].UID || !v.CreatedAt.After(*versions[i-1].CreatedAt) || !versions[i-1].ValidTo.Equal(*v.ValidFrom)) {
+			t.Errorf("version %d does not follow version %d", v.Version, versions[i-1].Version)
+		}
+	}
+	latest, previous := versions[3], versions[2]
+	if latest.Status != models.JobCompleted || latest.Rate != 120 || latest.ChangeReason != "complete" || latest.ValidTo != nil || !latest.CreatedAt.Equal(at) {
+		t.Errorf("latest = %+v", latest)
+	}
+	if previous.Status != models.JobActive || previous.Rate != 120 {
+		t.Errorf("version before completing = %+v", previous)
+	}
+	if first := versions[0]; first.Rate >= versions[1].Rate || first.ChangeReason != "created" {
+		t.Errorf("first = %+v, want a lower rate than version 2", first)
+	}
+}
+
+func TestLineItemPaysTimelogAtJobRate(t *testing.T) {
+	job := factory.Job().WithRate(50).Build()[0]
+	tl := factory.Timelog().ForJob(job).Lasting(90 * time.Minute).WithVersions(2).Build()[1]
+	if tl.JobUID != job.UID || tl.Duration != 1.5 || tl.ChangeReason != "approve" {
+		t.Errorf("timelog = %+v", tl)
+	}
+	items := factory.LineItem().For(job, tl).Paid().WithVersions(2).Build()
+	if got := items[1]; got.Amount != 75 || got.Status != "paid" || got.TimelogUID != tl.UID || got.JobUID != job.UID {
+		t.Errorf("line item = %+v", got)
+	}
+	if items[0].Status != "pending" {
+		t.Errorf("first version status = %q, want pending", items[0].Status)
+	}
+}
@@ -0,0 +1,80 @@
+package factory
+
+import (
+	"math"
+	"time"
+
+	"github.com/yourorg/Go/models"
+	"gorm.io/gorm"
+)
+
+// JobBuilder builds a job; see Job.
+type JobBuilder struct {
+	job models.Job
+	history
+}
+
+// Job returns a builder of an active engineering job of contractor cont1 at
+// company comp1, paying 100.
+func Job() *JobBuilder {
+	return &JobBuilder{job: models.Job{
+		Versioned:    models.Versioned{ID: newID("job")},
+		Status:       models.JobActive,
+		Rate:         100,
+		Title:        "Engineer",
+		CompanyID:    "comp1",
+		ContractorID: "cont1",
+	}}
+}
+
+func (b *JobBuilder) WithID(id string) *JobBuilder { b.job.ID = id; return b }
+
+func (b *JobBuilder) Active() *JobBuilder    { b.job.Status = models.JobActive; return b }
+func (b *JobBuilder) Paused() *JobBuilder    { b.job.Status = models.JobPaused; return b }
+func (b *JobBuilder) Completed() *JobBuilder { b.job.Status = models.JobCompleted; return b }
+func (b *JobBuilder) Cancelled() *JobBuilder { b.job.Status = models.JobCancelled; return b }
+
+func (b *JobBuilder) WithRate(rate float64) *JobBuilder     { b.job.Rate = rate; return b }
+func (b *JobBuilder) WithTitle(title string) *JobBuilder    { b.job.Title = title; return b }
+func (b *JobBuilder) ForCompany(id string) *JobBuilder      { b.job.CompanyID = id; return b }
+func (b *JobBuilder) ForContractor(id string) *JobBuilder   { b.job.ContractorID = id; return b }
+func (b *JobBuilder) Edit(fn func(*models.Job)) *JobBuilder { fn(&b.job); return b }
+
+// WithVersions gives the job n versions. The latest moves the job from
+// active to its status, if it is another one, and the others raise the rate
+// by 5% each up to the job's.
+func (b *JobBuilder) WithVersions(n int) *JobBuilder { b.versions = n; return b }
+
+// At sets when the latest version was written, now by default; Every sets
+// the time between versions, a day by default.
+func (b *JobBuilder) At(t time.Time) *JobBuilder        { b.at = t; return b }
+func (b *JobBuilder) Every(d time.Duration) *JobBuilder { b.every = d; return b }
+
+// Build returns the versions of the job, oldest first, without writing them.
+func (b *JobBuilder) Build() []models.Job {
+	versions, reasons := unwind(b.job, b.versions, func(next models.Job, latest bool) (models.Job, string) {
+		prev := next
+		if latest && next.Status != models.JobActive {
+			prev.Status = models.JobActive
+			for event, to := range models.JobTransitions[models.JobActive] {
+				if to == next.Status {
+					return prev, string(event)
+				}
+			}
+			return prev, "status change"
+		}
+		prev.Rate = math.Round(next.Rate/1.05*100) / 100
+		return prev, "rate change"
+	})
+	meta := make([]*models.Versioned, len(versions))
+	for i := range versions {
+		meta[i] = &versions[i].Versioned
+	}
+	stamp(meta, b.job.ID, reasons, b.history)
+	return versions
+}
+
+// Create writes the versions of the job to db and returns the latest.
+func (b *JobBuilder) Create(db *gorm.DB) (models.Job, error) {
+	return create(db, b.job.ID, b.Build())
+}
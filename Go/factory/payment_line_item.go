@@ -0,0 +1,88 @@
+package factory
+
+import (
+	"math"
+	"time"
+
+	"github.com/yourorg/Go/models"
+	"gorm.io/gorm"
+)
+
+// pendingStatus is payroll.PendingStatus, kept here so that payroll's own
+// tests can build line items.
+const pendingStatus = "pending"
+
+// LineItemBuilder builds a payment line item; see LineItem.
+type LineItemBuilder struct {
+	item models.PaymentLineItem
+	history
+}
+
+// LineItem returns a builder of a pending payment line item, of no timelog
+// until For.
+func LineItem() *LineItemBuilder {
+	return &LineItemBuilder{item: models.PaymentLineItem{
+		Versioned: models.Versioned{ID: newID("pli")},
+		Status:    pendingStatus,
+	}}
+}
+
+func (b *LineItemBuilder) WithID(id string) *LineItemBuilder { b.item.ID = id; return b }
+
+// For pays timelog at the rate of job, the versions given.
+func (b *LineItemBuilder) For(job models.Job, timelog models.Timelog) *LineItemBuilder {
+	b.item.JobUID, b.item.TimelogUID = job.UID, timelog.UID
+	b.item.Amount = math.Round(job.Rate*timelog.Duration*100) / 100
+	return b
+}
+
+func (b *LineItemBuilder) WithAmount(amount float64) *LineItemBuilder {
+	b.item.Amount = amount
+	return b
+}
+func (b *LineItemBuilder) WithStatus(status string) *LineItemBuilder {
+	b.item.Status = status
+	return b
+}
+func (b *LineItemBuilder) Pending() *LineItemBuilder { return b.WithStatus(pendingStatus) }
+func (b *LineItemBuilder) Paid() *LineItemBuilder    { return b.WithStatus("paid") }
+
+func (b *LineItemBuilder) Edit(fn func(*models.PaymentLineItem)) *LineItemBuilder {
+	fn(&b.item)
+	return b
+}
+
+// WithVersions gives the line item n versions. The latest moves the item
+// from pending to its status, if it is another one, and the others
+// recompute the amount, 5% up each time, to the item's.
+func (b *LineItemBuilder) WithVersions(n int) *LineItemBuilder { b.versions = n; return b }
+
+// At sets when the latest version was written, now by default; Every sets
+// the time between versions, a day by default.
+func (b *LineItemBuilder) At(t time.Time) *LineItemBuilder        { b.at = t; return b }
+func (b *LineItemBuilder) Every(d time.Duration) *LineItemBuilder { b.every = d; return b }
+
+// Build returns the versions of the line item, oldest first, without writing
+// them.
+func (b *LineItemBuilder) Build() []models.PaymentLineItem {
+	versions, reasons := unwind(b.item, b.versions, func(next models.PaymentLineItem, latest bool) (models.PaymentLineItem, string) {
+		prev := next
+		if latest && next.Status != pendingStatus {
+			prev.Status = pendingStatus
+			return prev, next.Status
+		}
+		prev.Amount = math.Round(next.Amount/1.05*100) / 100
+		return prev, "recomputed"
+	})
+	meta := make([]*models.Versioned, len(versions))
+	for i := range versions {
+		meta[i] = &versions[i].Versioned
+	}
+	stamp(meta, b.item.ID, reasons, b.history)
+	return versions
+}
+
+// Create writes the versions of the line item to db and returns the latest.
+func (b *LineItemBuilder) Create(db *gorm.DB) (models.PaymentLineItem, error) {
+	return create(db, b.item.ID, b.Build())
+}
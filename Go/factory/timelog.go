@@ -0,0 +1,105 @@
+package factory
+
+import (
+	"time"
+
+	"github.com/yourorg/Go/models"
+	"gorm.io/gorm"
+)
+
+// TimelogBuilder builds a timelog; see Timelog.
+type TimelogBuilder struct {
+	timelog models.Timelog
+	history
+}
+
+// Timelog returns a builder of an approved eight-hour work timelog ending an
+// hour ago, of no job until ForJob.
+func Timelog() *TimelogBuilder {
+	end := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	return &TimelogBuilder{timelog: models.Timelog{
+		Versioned:      models.Versioned{ID: newID("tl")},
+		Duration:       8,
+		TimeStart:      end.Add(-8 * time.Hour),
+		TimeEnd:        end,
+		Type:           "work",
+		ApprovalStatus: models.TimelogApproved,
+	}}
+}
+
+func (b *TimelogBuilder) WithID(id string) *TimelogBuilder { b.timelog.ID = id; return b }
+
+// ForJob logs the time against the given version of job.
+func (b *TimelogBuilder) ForJob(job models.Job) *TimelogBuilder { b.timelog.JobUID = job.UID; return b }
+
+// Between logs the time from start to end, Lasting the time from the start
+// for d; both set the duration in hours to match.
+func (b *TimelogBuilder) Between(start, end time.Time) *TimelogBuilder {
+	b.timelog.TimeStart, b.timelog.TimeEnd = start, end
+	b.timelog.Duration = end.Sub(start).Hours()
+	return b
+}
+
+func (b *TimelogBuilder) Lasting(d time.Duration) *TimelogBuilder {
+	return b.Between(b.timelog.TimeStart, b.timelog.TimeStart.Add(d))
+}
+
+func (b *TimelogBuilder) OfType(typ string) *TimelogBuilder { b.timelog.Type = typ; return b }
+
+func (b *TimelogBuilder) Submitted() *TimelogBuilder {
+	b.timelog.ApprovalStatus = models.TimelogSubmitted
+	return b
+}
+
+func (b *TimelogBuilder) Approved() *TimelogBuilder {
+	b.timelog.ApprovalStatus = models.TimelogApproved
+	return b
+}
+
+func (b *TimelogBuilder) Rejected() *TimelogBuilder {
+	b.timelog.ApprovalStatus = models.TimelogRejected
+	return b
+}
+
+func (b *TimelogBuilder) Edit(fn func(*models.Timelog)) *TimelogBuilder { fn(&b.timelog); return b }
+
+// WithVersions gives the timelog n versions. The latest approves or rejects
+// the submitted timelog, unless it is still submitted, and the others
+// correct its end by 15 minutes each.
+func (b *TimelogBuilder) WithVersions(n int) *TimelogBuilder { b.versions = n; return b }
+
+// At sets when the latest version was written, now by default; Every sets
+// the time between versions, a day by default.
+func (b *TimelogBuilder) At(t time.Time) *TimelogBuilder        { b.at = t; return b }
+func (b *TimelogBuilder) Every(d time.Duration) *TimelogBuilder { b.every = d; return b }
+
+// Build returns the versions of the timelog, oldest first, without writing
+// them.
+func (b *TimelogBuilder) Build() []models.Timelog {
+	versions, reasons := unwind(b.timelog, b.versions, func(next models.Timelog, latest bool) (models.Timelog, string) {
+		prev := next
+		if latest && next.ApprovalStatus != models.TimelogSubmitted {
+			prev.ApprovalStatus = models.TimelogSubmitted
+			if next.ApprovalStatus == models.TimelogRejected {
+				return prev, "reject"
+			}
+			return prev, "approve"
+		}
+		if end := next.TimeEnd.Add(-15 * time.Minute); end.After(next.TimeStart) {
+			prev.TimeEnd = end
+			prev.Duration = end.Sub(next.TimeStart).Hours()
+		}
+		return prev, "time corrected"
+	})
+	meta := make([]*models.Versioned, len(versions))
+	for i := range versions {
+		meta[i] = &versions[i].Versioned
+	}
+	stamp(meta, b.timelog.ID, reasons, b.history)
+	return versions
+}
+
+// Create writes the versions of the timelog to db and returns the latest.
+func (b *TimelogBuilder) Create(db *gorm.DB) (models.Timelog, error) {
+	return create(db, b.timelog.ID, b.Build())
+}
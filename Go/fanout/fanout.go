@@ -0,0 +1,183 @@
+// Package fanout runs one read against many databases or tenants at once,
+// such as the shards of a sharded installation or every tenant of a shared
+// one, and merges the rows. With Options.AllowPartial, targets that fail or
+// exceed their soft timeout are reported beside the rows instead of failing
+// the whole read:
+//
+//	res, err := fanout.Query(ctx, shards, fanout.Options{Timeout: 2 * time.Second, AllowPartial: true},
+//		func(ctx context.Context, tx *gorm.DB) ([]models.Job, error) {
+//			var jobs []models.Job
+//			err := tx.Scopes(scd.Latest[models.Job]()).Where("jobs.status = ?", "active").Find(&jobs).Error
+//			return jobs, err
+//		})
+//	if !res.Complete() && res.Coverage() < 0.9 { ... refuse to report on this ... }
+package fanout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yourorg/Go/scd"
+	"github.com/yourorg/Go/scderr"
+	"gorm.io/gorm"
+)
+
+// ErrIncomplete is returned, wrapping the first failure, when a target fails
+// and Options.AllowPartial is not set.
+var ErrIncomplete = errors.New("fanout: not every target answered")
+
+// Target is one database, or one tenant of a database, to read from.
+type Target struct {
+	// Name identifies the target in Result.Failed, e.g. "shard-3" or a tenant ID.
+	Name string
+	DB   *gorm.DB
+	// Session, when set, is applied to the read with scd.InSession, so row
+	// level security restricts it to one tenant.
+	Session *scd.Session
+}
+
+// Options controls a fan-out read.
+type Options struct {
+	// Timeout bounds each target's read; zero leaves it to ctx.
+	Timeout time.Duration
+	// AllowPartial returns the rows of the targets that answered when others
+	// fail instead of an error.
+	AllowPartial bool
+	// MaxConcurrency bounds the targets read at once (default all of them).
+	MaxConcurrency int
+}
+
+// Failure describes a target that did not answer.
+type Failure struct {
+	Target string
+	// Code classifies Err, so callers can tell e.g. an unreachable shard from
+	// a broken query.
+	Code     scderr.Code
+	TimedOut bool
+	Err      error
+	Elapsed  time.Duration
+}
+
+// Result holds the merged rows of a fan-out read and which targets they
+// came from.
+type Result[T any] struct {
+	// Rows are the rows of the targets that answered, in target order.
+	Rows []T
+	// Succeeded and Failed partition the targets, in target order.
+	Succeeded []string
+	Failed    []Failure
+}
+
+// Complete reports whether every target answered.
+func (r Result[T]) Complete() bool { return len(r.Failed) == 0 }
+
+// Coverage is the fraction of targets that answered, 1 when there were none.
+func (r Result[T]) Coverage() float64 {
+	total := len(r.Succeeded) + len(r.Failed)
+	if total == 0 {
+		return 1
+	}
+	return float64(len(r.Succeeded)) / float64(total)
+}
+
+// TimedOut lists the targets that exceeded Options.Timeout.
+func (r Result[T]) TimedOut() []string {
+	var names []string
+	for _, f := range r.Failed {
+		if f.TimedOut {
+			names = append(names, f.Target)
+		}
+	}
+	return names
+}
+
+// Query runs read against every target concurrently and merges the rows.
+// read gets a context bounded by Options.Timeout and a session on the
+// target's database derived from it. Without Options.AllowPartial any failure
+// makes Query return ErrIncomplete; the Result is returned either way.
+func Query[T any](ctx context.Context, targets []Target, opts Options, read func(ctx context.Context, tx *gorm.DB) ([]T, error)) (Result[T], error) {
+	rows := make([][]T, len(targets))
+	failures := make([]*Failure, len(targets))
+	limit := opts.MaxConcurrency
+	if limit <= 0 || limit > len(targets) {
+		limit = len(targets)
+	}
+	sem := make(chan struct{}, max(limit, 1))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			start := time.Now()
+			got, err := readTarget(ctx, target, opts.Timeout, read)
+			if err != nil {
+				failures[i] = &Failure{
+					Target:   target.Name,
+					Code:     scderr.CodeOf(err),
+					TimedOut: errors.Is(err, context.DeadlineExceeded) || scderr.CodeOf(err) == scderr.Timeout,
+					Err:      err,
+					Elapsed:  time.Since(start),
+				}
+				return
+			}
+			rows[i] = got
+		}()
+	}
+	wg.Wait()
+
+	var res Result[T]
+	for i, target := range targets {
+		if f := failures[i]; f != nil {
+			res.Failed = append(res.Failed, *f)
+			continue
+		}
+		res.Succeeded = append(res.Succeeded, target.Name)
+		res.Rows = append(res.Rows, rows[i]...)
+	}
+	if !res.Complete() && !opts.AllowPartial {
+		f := res.Failed[0]
+		return res, fmt.Errorf("%w: %s: %w", ErrIncomplete, f.Target, f.Err)
+	}
+	return res, nil
+}
+
+// readTarget runs read against one target. It gives up when timeout expires
+// even if read has not returned yet, e.g. because the driver does not notice
+// the cancelled context while waiting for the network; read then finishes in
+// the background and its rows are dropped.
+func readTarget[T any](ctx context.Context, target Target, timeout time.Duration, read func(ctx context.Context, tx *gorm.DB) ([]T, error)) ([]T, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	type outcome struct {
+		rows []T
+		err  error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		var o outcome
+		if target.Session == nil {
+			o.rows, o.err = read(ctx, target.DB.WithContext(ctx))
+		} else {
+			o.err = scd.InSession(scd.WithSession(ctx, *target.Session), target.DB, func(tx *gorm.DB) error {
+				var err error
+				o.rows, err = read(ctx, tx)
+				return err
+			})
+		}
+		done <- o
+	}()
+	select {
+	case o := <-done:
+		return o.rows, o.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,63 @@
+package fanout_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yourorg/Go/fanout"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// readShard answers with the shard's name, fails on shard-2 and hangs on
+// shard-3 past its context, like a driver slow to notice the cancellation.
+func readShard(ctx context.Context, tx *gorm.DB) ([]string, error) {
+	switch name := tx.Statement.Table; name {
+	case "shard-2":
+		return nil, errors.New("relation does not exist")
+	case "shard-3":
+		<-ctx.Done()
+		time.Sleep(time.Second)
+		return []string{name}, nil
+	default:
+		return []string{name}, nil
+	}
+}
+
+func TestQuerySoftTimeout(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("opening dry-run db: %v", err)
+	}
+	var targets []fanout.Target
+	for _, name := range []string{"shard-1", "shard-2", "shard-3"} {
+		targets = append(targets, fanout.Target{Name: name, DB: db.Table(name)})
+	}
+	opts := fanout.Options{Timeout: 20 * time.Millisecond, AllowPartial: true}
+
+	start := time.Now()
+	res, err := fanout.Query(context.Background(), targets, opts, readShard)
+	if err != nil {
+		t.Fatalf("partial query failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("query waited %v for the hung target", elapsed)
+	}
+	if len(res.Rows) != 1 || res.Rows[0] != "shard-1" {
+		t.Errorf("rows = %v, want [shard-1]", res.Rows)
+	}
+	if len(res.Failed) != 2 || res.Failed[0].TimedOut || !res.Failed[1].TimedOut {
+		t.Errorf("failed = %+v, want shard-2 failed and shard-3 timed out", res.Failed)
+	}
+	if got := res.TimedOut(); len(got) != 1 || got[0] != "shard-3" {
+		t.Errorf("timed out = %v, want [shard-3]", got)
+	}
+
+	opts.AllowPartial = false
+	if _, err := fanout.Query(context.Background(), targets, opts, readShard); !errors.Is(err, fanout.ErrIncomplete) {
+		t.Errorf("strict query error = %v, want ErrIncomplete", err)
+	}
+}
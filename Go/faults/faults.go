@@ -0,0 +1,279 @@
+// Package faults injects failures into database operations, so retry
+// policies, sagas and callers' error handling can be exercised against the
+// failures they are written for instead of only the happy path. An Injector
+// is a GORM plugin; every repository and scd write using the database it is
+// installed on is affected:
+//
+//	inj := faults.New(faults.Config{ErrorRate: 0.01, Latency: 20 * time.Millisecond, ConflictRate: 0.05})
+//	if err := db.Use(inj); err != nil { ... }
+//
+// Injected version conflicts and serialization failures look exactly like
+// the driver's, so they go through scd's retries and scderr's
+// classification. Install it only in test and staging builds.
+package faults
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/yourorg/Go/scd"
+	"github.com/yourorg/Go/scderr"
+	"gorm.io/gorm"
+)
+
+// ErrInjected is the default error of Config.ErrorRate. It is classified as
+// scderr.Internal, so nothing retries it.
+var ErrInjected = scderr.New(scderr.Internal, "faults: injected failure")
+
+// Operations an Injector can target, named after GORM's callback processors.
+const (
+	OpCreate = "create"
+	OpQuery  = "query"
+	OpUpdate = "update"
+	OpDelete = "delete"
+	OpRow    = "row"
+	OpRaw    = "raw"
+)
+
+var allOps = []string{OpCreate, OpQuery, OpUpdate, OpDelete, OpRow, OpRaw}
+
+// Config says which faults to inject how often. Rates are probabilities
+// per operation, from 0 to 1.
+type Config struct {
+	// ErrorRate fails operations with Err.
+	ErrorRate float64
+	// Err is the error of ErrorRate (default ErrInjected).
+	Err error
+	// Latency delays operations by Latency plus up to Jitter, with
+	// probability LatencyRate (default 1 when Latency or Jitter is set). A
+	// delay cut short by the operation's context fails it with the
+	// context's error.
+	Latency     time.Duration
+	Jitter      time.Duration
+	LatencyRate float64
+	// ConflictRate fails creates of versioned rows as if another writer had
+	// created the same version first, which scd retries.
+	ConflictRate float64
+	// SerializationRate fails operations with a serialization failure
+	// (SQLSTATE 40001), which callers are expected to retry.
+	SerializationRate float64
+	// Tables restricts the faults to these tables (default every table).
+	Tables []string
+	// Operations restricts the faults to these operations (default all).
+	Operations []string
+	// Seed makes the faults reproducible when non-zero.
+	Seed uint64
+}
+
+// Stats counts the faults injected so far.
+type Stats struct {
+	Operations     int64 `json:"operations"`
+	Errors         int64 `json:"errors"`
+	Delays         int64 `json:"delays"`
+	Conflicts      int64 `json:"conflicts"`
+	Serializations int64 `json:"serializations"`
+}
+
+// Injector injects the faults of its Config into the operations of the
+// databases it is installed on. It is safe for concurrent use.
+type Injector struct {
+	cfg      Config
+	disabled atomic.Bool
+
+	mu    sync.Mutex
+	rng   *rand.Rand
+	stats Stats
+}
+
+// New returns an Injector for cfg.
+func New(cfg Config) *Injector {
+	if cfg.Err == nil {
+		cfg.Err = ErrInjected
+	}
+	if cfg.LatencyRate <= 0 && (cfg.Latency > 0 || cfg.Jitter > 0) {
+		cfg.LatencyRate = 1
+	}
+	if len(cfg.Operations) == 0 {
+		cfg.Operations = allOps
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return &Injector{cfg: cfg, rng: rand.New(rand.NewPCG(seed, seed))}
+}
+
+// Name implements gorm.Plugin.
+func (i *Injector) Name() string { return "faults" }
+
+// Initialize implements gorm.Plugin, registering the injecting callbacks
+// before each of the configured operations.
+func (i *Injector) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, op := range i.cfg.Operations {
+		var err error
+		name, fn := "faults:"+op, i.inject(op)
+		switch op {
+		case OpCreate:
+			err = cb.Create().Before("gorm:create").Register(name, fn)
+		case OpQuery:
+			err = cb.Query().Before("gorm:query").Register(name, fn)
+		case OpUpdate:
+			err = cb.Update().Before("gorm:update").Register(name, fn)
+		case OpDelete:
+			err = cb.Delete().Before("gorm:delete").Register(name, fn)
+		case OpRow:
+			err = cb.Row().Before("gorm:row").Register(name, fn)
+		case OpRaw:
+			err = cb.Raw().Before("gorm:raw").Register(name, fn)
+		default:
+			err = fmt.Errorf("faults: unknown operation %q", op)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Disable stops injecting faults until Enable, e.g. while a test sets up
+// its fixtures.
+func (i *Injector) Disable() { i.disabled.Store(true) }
+
+// Enable resumes injecting faults after Disable.
+func (i *Injector) Enable() { i.disabled.Store(false) }
+
+// Stats returns the faults injected so far.
+func (i *Injector) Stats() Stats {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.stats
+}
+
+func (i *Injector) inject(op string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		if tx.Error != nil || i.disabled.Load() {
+			return
+		}
+		table := tableOf(tx.Statement)
+		if len(i.cfg.Tables) > 0 && !slices.Contains(i.cfg.Tables, table) {
+			return
+		}
+		delay, fault := i.draw(op, table)
+		if delay > 0 {
+			if err := sleep(tx.Statement.Context, delay); err != nil {
+				tx.AddError(err)
+				return
+			}
+		}
+		if fault != nil {
+			tx.AddError(fault)
+		}
+	}
+}
+
+// draw decides the delay and failure of one operation on table.
+func (i *Injector) draw(op, table string) (time.Duration, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.stats.Operations++
+	var delay time.Duration
+	if i.cfg.LatencyRate > 0 && i.rng.Float64() < i.cfg.LatencyRate {
+		delay = i.cfg.Latency
+		if i.cfg.Jitter > 0 {
+			delay += time.Duration(i.rng.Int64N(int64(i.cfg.Jitter)))
+		}
+		i.stats.Delays++
+	}
+	switch {
+	case op == OpCreate && i.cfg.ConflictRate > 0 && isVersioned(table) && i.rng.Float64() < i.cfg.ConflictRate:
+		i.stats.Conflicts++
+		return delay, &pgconn.PgError{Severity: "ERROR", Code: "23505", ConstraintName: table + "_pkey",
+			Message: fmt.Sprintf("duplicate key value violates unique constraint %q (injected)", table+"_pkey")}
+	case i.cfg.SerializationRate > 0 && i.rng.Float64() < i.cfg.SerializationRate:
+		i.stats.Serializations++
+		return delay, &pgconn.PgError{Severity: "ERROR", Code: "40001",
+			Message: "could not serialize access due to concurrent update (injected)"}
+	case i.cfg.ErrorRate > 0 && i.rng.Float64() < i.cfg.ErrorRate:
+		i.stats.Errors++
+		return delay, i.cfg.Err
+	}
+	return delay, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func tableOf(stmt *gorm.Statement) string {
+	if stmt.Table != "" {
+		return stmt.Table
+	}
+	if stmt.Schema != nil {
+		return stmt.Schema.Table
+	}
+	return ""
+}
+
+func isVersioned(table string) bool {
+	_, ok := scd.LookupModel(table)
+	return ok
+}
+
+// ParseConfig parses a comma-separated list of faults, as set in an
+// environment variable of a staging deployment, e.g.
+// "errors=0.01,latency=20ms,jitter=30ms,conflicts=0.05,serialization=0.01,tables=jobs|timelogs,seed=42".
+func ParseConfig(s string) (Config, error) {
+	var cfg Config
+	for _, part := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return cfg, fmt.Errorf("faults: %q is not key=value", part)
+		}
+		var err error
+		switch key {
+		case "errors":
+			cfg.ErrorRate, err = strconv.ParseFloat(value, 64)
+		case "latency":
+			cfg.Latency, err = time.ParseDuration(value)
+		case "jitter":
+			cfg.Jitter, err = time.ParseDuration(value)
+		case "latency_rate":
+			cfg.LatencyRate, err = strconv.ParseFloat(value, 64)
+		case "conflicts":
+			cfg.ConflictRate, err = strconv.ParseFloat(value, 64)
+		case "serialization":
+			cfg.SerializationRate, err = strconv.ParseFloat(value, 64)
+		case "tables":
+			cfg.Tables = strings.Split(value, "|")
+		case "operations":
+			cfg.Operations = strings.Split(value, "|")
+		case "seed":
+			cfg.Seed, err = strconv.ParseUint(value, 10, 64)
+		default:
+			return cfg, fmt.Errorf("faults: unknown setting %q", key)
+		}
+		if err != nil {
+			return cfg, fmt.Errorf("faults: %s: %w", key, err)
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,108 @@
+package faults_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yourorg/Go/faults"
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"github.com/yourorg/Go/scderr"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func dryRunDB(t *testing.T, cfg faults.Config) (*gorm.DB, *faults.Injector) {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatalf("opening dry-run db: %v", err)
+	}
+	inj := faults.New(cfg)
+	if err := db.Use(inj); err != nil {
+		t.Fatalf("installing injector: %v", err)
+	}
+	return db, inj
+}
+
+func TestInjectsErrors(t *testing.T) {
+	db, inj := dryRunDB(t, faults.Config{ErrorRate: 1, Seed: 1})
+	var jobs []models.Job
+	if err := db.Find(&jobs).Error; !errors.Is(err, faults.ErrInjected) {
+		t.Fatalf("Find error = %v, want ErrInjected", err)
+	}
+	if code := scderr.CodeOf(faults.ErrInjected); code != scderr.Internal {
+		t.Errorf("ErrInjected code = %s, want %s", code, scderr.Internal)
+	}
+	inj.Disable()
+	if err := db.Find(&jobs).Error; err != nil {
+		t.Fatalf("Find while disabled: %v", err)
+	}
+	inj.Enable()
+	if got := inj.Stats(); got.Operations != 1 || got.Errors != 1 {
+		t.Errorf("stats = %+v, want one failed operation", got)
+	}
+}
+
+func TestInjectsVersionConflictsOnVersionedCreates(t *testing.T) {
+	models.Register()
+	db, _ := dryRunDB(t, faults.Config{ConflictRate: 1, Seed: 1})
+	err := db.Create(&models.Job{Versioned: models.Versioned{ID: "job1", Version: 2, UID: "u"}}).Error
+	if !errors.Is(scd.Translate(err), scd.ErrVersionConflict) {
+		t.Fatalf("Create error = %v, want a version conflict", err)
+	}
+	var jobs []models.Job
+	if err := db.Find(&jobs).Error; err != nil {
+		t.Fatalf("conflicts must only affect creates, Find: %v", err)
+	}
+}
+
+func TestInjectsSerializationFailures(t *testing.T) {
+	db, _ := dryRunDB(t, faults.Config{SerializationRate: 1, Seed: 1})
+	var jobs []models.Job
+	err := db.Find(&jobs).Error
+	if code := scderr.CodeOf(scd.Translate(err)); !code.Retryable() {
+		t.Fatalf("Find error = %v (%s), want a retryable failure", err, code)
+	}
+}
+
+func TestTablesFilter(t *testing.T) {
+	db, _ := dryRunDB(t, faults.Config{ErrorRate: 1, Tables: []string{"timelogs"}})
+	var jobs []models.Job
+	if err := db.Find(&jobs).Error; err != nil {
+		t.Fatalf("jobs are not targeted, Find: %v", err)
+	}
+	var timelogs []models.Timelog
+	if err := db.Find(&timelogs).Error; !errors.Is(err, faults.ErrInjected) {
+		t.Fatalf("Find timelogs error = %v, want ErrInjected", err)
+	}
+}
+
+func TestLatencyHonoursContext(t *testing.T) {
+	db, _ := dryRunDB(t, faults.Config{Latency: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var jobs []models.Job
+	if err := db.WithContext(ctx).Find(&jobs).Error; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Find error = %v, want the context's deadline", err)
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := faults.ParseConfig("errors=0.01, latency=20ms,jitter=5ms,conflicts=0.5,tables=jobs|timelogs,seed=7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ErrorRate != 0.01 || cfg.Latency != 20*time.Millisecond || cfg.Jitter != 5*time.Millisecond ||
+		cfg.ConflictRate != 0.5 || len(cfg.Tables) != 2 || cfg.Seed != 7 {
+		t.Errorf("ParseConfig = %+v", cfg)
+	}
+	for _, bad := range []string{"errors", "errors=x", "bogus=1"} {
+		if _, err := faults.ParseConfig(bad); err == nil {
+			t.Errorf("ParseConfig(%q) succeeded", bad)
+		}
+	}
+}
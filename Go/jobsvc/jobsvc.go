@@ -0,0 +1,87 @@
+// Package jobsvc moves jobs through their lifecycle. A job's status changes
+// only through the events its current status accepts (see
+// models.JobTransitions), and every transition is written as a new version
+// whose change reason names the event:
+//
+//	job, err := jobsvc.Transition(ctx, db, "job1", models.JobPause)
+//	if errors.Is(err, jobsvc.ErrInvalidTransition) { ... the job is completed, say ... }
+//
+// Enforce extends the check to versions written by any other means.
+package jobsvc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"github.com/yourorg/Go/scderr"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidTransition is returned when a job's status does not accept
+	// the event, or a version would move it to a status it cannot reach.
+	ErrInvalidTransition = scderr.New(scderr.Conflict, "jobsvc: invalid status transition")
+	// ErrUnknownStatus is returned when a version would give a job a status
+	// that is not a models.JobStatus.
+	ErrUnknownStatus = scderr.New(scderr.Validation, "jobsvc: unknown job status")
+)
+
+// Transition applies event to job jobID, writing the version with its new
+// status. The version's change reason is the event, followed by the change
+// reason of ctx when it carries one, e.g. "pause: contractor on leave".
+func Transition(ctx context.Context, db *gorm.DB, jobID string, event models.JobEvent) (models.Job, error) {
+	reason := string(event)
+	if r := scd.ChangeReasonFrom(ctx); r != "" {
+		reason += ": " + r
+	}
+	ctx = scd.WithChangeReason(ctx, reason)
+	_, created, err := scd.CreateNewSCDVersionReturning[models.Job](ctx, db, jobID, func(j *models.Job) error {
+		next, ok := j.Status.Next(event)
+		if !ok {
+			return fmt.Errorf("%w: %s job %s does not accept %s", ErrInvalidTransition, j.Status, jobID, event)
+		}
+		j.Status = next
+		return nil
+	})
+	if err != nil {
+		return created, fmt.Errorf("transitioning job %s failed: %w", jobID, err)
+	}
+	return created, nil
+}
+
+// Enforce rejects new job versions that change the status to an unknown one
+// or to one the previous status cannot become, e.g. edits reactivating a
+// completed job. Versions keeping the status are left alone, so jobs
+// predating the statuses can still be edited.
+func Enforce() {
+	scd.OnVersionCreated(models.Job{}, func(tx *gorm.DB, event scd.VersionEvent) error {
+		var j models.Job
+		switch e := event.Entity.(type) {
+		case *models.Job:
+			j = *e
+		case models.Job:
+			j = e
+		default:
+			return nil
+		}
+		var previous []models.JobStatus
+		err := scd.AllVersions(tx.Statement.Context, tx, &models.Job{}).
+			Where("id = ? AND version < ?", event.ID, event.Version).
+			Order("version DESC").Limit(1).Pluck("status", &previous).Error
+		if err != nil {
+			return fmt.Errorf("reading version before %s v%d failed: %w", event.ID, event.Version, err)
+		}
+		if len(previous) == 0 || previous[0] == j.Status {
+			return nil
+		}
+		if !j.Status.Valid() {
+			return fmt.Errorf("%w: %q on job %s", ErrUnknownStatus, j.Status, event.ID)
+		}
+		if !previous[0].CanBecome(j.Status) {
+			return fmt.Errorf("%w: job %s cannot go from %s to %s", ErrInvalidTransition, event.ID, previous[0], j.Status)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,46 @@
+package jobsvc_test
+
+import (
+	"testing"
+
+	"github.com/yourorg/Go/models"
+)
+
+func TestJobTransitions(t *testing.T) {
+	for _, tc := range []struct {
+		from  models.JobStatus
+		event models.JobEvent
+		want  models.JobStatus
+		ok    bool
+	}{
+		{models.JobActive, models.JobPause, models.JobPaused, true},
+		{models.JobPaused, models.JobResume, models.JobActive, true},
+		{models.JobActive, models.JobComplete, models.JobCompleted, true},
+		{models.JobPaused, models.JobCancel, models.JobCancelled, true},
+		{models.JobActive, models.JobResume, "", false},
+		{models.JobPaused, models.JobPause, "", false},
+		{models.JobCompleted, models.JobResume, "", false},
+		{models.JobCancelled, models.JobComplete, "", false},
+		{"archived", models.JobPause, "", false},
+	} {
+		got, ok := tc.from.Next(tc.event)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%s.Next(%s) = %q, %v; want %q, %v", tc.from, tc.event, got, ok, tc.want, tc.ok)
+		}
+		if ok && !tc.from.CanBecome(got) {
+			t.Errorf("%s.CanBecome(%s) = false for a transition", tc.from, got)
+		}
+	}
+}
+
+func TestJobStatusCanBecome(t *testing.T) {
+	if !models.JobCompleted.CanBecome(models.JobCompleted) {
+		t.Error("a status cannot stay as it is")
+	}
+	if models.JobCompleted.CanBecome(models.JobActive) {
+		t.Error("a completed job can be reactivated")
+	}
+	if models.JobStatus("archived").Valid() || !models.JobPaused.Valid() {
+		t.Error("Valid disagrees with JobTransitions")
+	}
+}
@@ -1,18 +1,24 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"time"
 
+	"github.com/yourorg/Go/dedup"
+	"github.com/yourorg/Go/factory"
 	"github.com/yourorg/Go/models"
 	"github.com/yourorg/Go/repos"
+	"github.com/yourorg/Go/scd"
+	"github.com/yourorg/Go/scdtest"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 func main() {
+	ctx := context.Background()
 	dsn := os.Getenv("POSTGRES_DSN")
 	if dsn == "" {
 		dsn = "host=localhost user=postgres password=postgres dbname=scd port=5432 sslmode=disable"
@@ -22,12 +28,52 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to connect database: %v", err)
 	}
+	if err := db.Use(scd.NewPlugin()); err != nil {
+		log.Fatalf("failed to install scd plugin: %v", err)
+	}
+
+	// Register models
+	models.Register()
+
+	// AutoMigrate, keeping timelogs predating their approval workflow approved
+	if err := models.MigrateTimelogApproval(db); err != nil {
+		log.Fatalf("failed to migrate timelog approval: %v", err)
+	}
+	db.AutoMigrate(&models.Job{}, &models.Timelog{}, &models.PaymentLineItem{}, &models.Setting{})
 
-	// AutoMigrate
-	db.AutoMigrate(&models.Job{}, &models.Timelog{}, &models.PaymentLineItem{})
+	// Refuse payment line items computed from superseded timelogs
+	scd.GuardReferences(models.PaymentLineItem{}, scd.Reference{Field: "TimelogUID", Target: models.Timelog{}})
+	if err := scd.InstallGuards(db); err != nil {
+		log.Fatalf("failed to install reference guards: %v", err)
+	}
+
+	// Trace payments back to the job and timelog versions they were computed from
+	scd.TrackProvenance(models.Timelog{}, scd.Reference{Field: "JobUID", Target: models.Job{}})
+	scd.TrackProvenance(models.PaymentLineItem{},
+		scd.Reference{Field: "JobUID", Target: models.Job{}},
+		scd.Reference{Field: "TimelogUID", Target: models.Timelog{}})
+	if err := scd.MigrateProvenance(db); err != nil {
+		log.Fatalf("failed to migrate provenance: %v", err)
+	}
+	if err := scd.InstallProvenance(db); err != nil {
+		log.Fatalf("failed to install provenance tracking: %v", err)
+	}
+
+	// Keep timelogs on their job's latest version; flag payments for review instead
+	scd.FollowLatest[models.Timelog](scd.Reference{Field: "JobUID", Target: models.Job{}}, scd.FollowRepoint)
+	scd.FollowLatest[models.PaymentLineItem](scd.Reference{Field: "TimelogUID", Target: models.Timelog{}}, scd.FollowFlag)
+	if err := scd.MigrateStaleReferences(db); err != nil {
+		log.Fatalf("failed to migrate stale references: %v", err)
+	}
+	if err := scd.MigrateAnnotations(db); err != nil {
+		log.Fatalf("failed to migrate version annotations: %v", err)
+	}
+	if err := dedup.Migrate(ctx, db, repos.JobDuplicates); err != nil {
+		log.Fatalf("failed to migrate duplicate detection: %v", err)
+	}
 
 	// Seed sample data
-	seedData(db)
+	pli := seedData(ctx, db)
 
 	// Repos
 	jobRepo := repos.JobRepo{DB: db}
@@ -36,13 +82,13 @@ func main() {
 
 	// Demo queries
 	fmt.Println("Active jobs for company comp1:")
-	jobs, _ := jobRepo.FindActiveJobsByCompany("comp1")
+	jobs, _ := jobRepo.FindActiveJobsByCompany(ctx, "comp1")
 	for _, j := range jobs {
 		fmt.Printf("%+v\n", j)
 	}
 
 	fmt.Println("Active jobs for contractor cont1:")
-	jobs, _ = jobRepo.FindActiveJobsByContractor("cont1")
+	jobs, _ = jobRepo.FindActiveJobsByContractor(ctx, "cont1")
 	for _, j := range jobs {
 		fmt.Printf("%+v\n", j)
 	}
@@ -51,47 +97,39 @@ func main() {
 	to := time.Now().Add(24 * time.Hour)
 
 	fmt.Println("Timelogs for contractor cont1 in period:")
-	timelogs, _ := timelogRepo.FindTimelogsByContractorAndPeriod("cont1", from, to)
+	timelogs, _ := timelogRepo.FindTimelogsByContractorAndPeriod(ctx, "cont1", from, to)
 	for _, t := range timelogs {
 		fmt.Printf("%+v\n", t)
 	}
 
 	fmt.Println("Payment line items for contractor cont1 in period:")
-	items, _ := pliRepo.FindLineItemsByContractorAndPeriod("cont1", from, to)
+	items, _ := pliRepo.FindLineItemsByContractorAndPeriod(ctx, "cont1", from, to)
 	for _, i := range items {
 		fmt.Printf("%+v\n", i)
 	}
+
+	fmt.Printf("Provenance of payment line item %s:\n", pli.UID)
+	edges, _ := scd.Provenance(ctx, db, pli.UID)
+	for _, e := range edges {
+		fmt.Printf("%s %s -> %s %s (%s)\n", e.Table, e.UID, e.SourceTable, e.SourceUID, e.Field)
+	}
 }
 
-func seedData(db *gorm.DB) {
-	db.Exec("TRUNCATE TABLE payment_line_items, timelogs, jobs RESTART IDENTITY CASCADE")
-	// Seed jobs
-	job := models.Job{
-		Versioned:    models.Versioned{ID: "job1", Version: 1, UID: "job-uid-1"},
-		Status:       "active",
-		Rate:         100,
-		Title:        "Engineer",
-		CompanyID:    "comp1",
-		ContractorID: "cont1",
-	}
-	db.Create(&job)
-	// Seed timelog
-	timelog := models.Timelog{
-		Versioned: models.Versioned{ID: "tl1", Version: 1, UID: "tl-uid-1"},
-		Duration:  8,
-		TimeStart: time.Now().Add(-2 * time.Hour),
-		TimeEnd:   time.Now().Add(-1 * time.Hour),
-		Type:      "work",
-		JobUID:    "job-uid-1",
-	}
-	db.Create(&timelog)
-	// Seed payment line item
-	pli := models.PaymentLineItem{
-		Versioned:  models.Versioned{ID: "pli1", Version: 1, UID: "pli-uid-1"},
-		JobUID:     "job-uid-1",
-		TimelogUID: "tl-uid-1",
-		Amount:     800,
-		Status:     "pending",
-	}
-	db.Create(&pli)
+func seedData(ctx context.Context, db *gorm.DB) models.PaymentLineItem {
+	if err := scdtest.ResetAll(ctx, db); err != nil {
+		log.Fatalf("failed to reset demo tables: %v", err)
+	}
+	job, err := factory.Job().WithID("job1").WithVersions(3).Create(db)
+	if err != nil {
+		log.Fatalf("failed to seed job: %v", err)
+	}
+	timelog, err := factory.Timelog().WithID("tl1").ForJob(job).Create(db)
+	if err != nil {
+		log.Fatalf("failed to seed timelog: %v", err)
+	}
+	pli, err := factory.LineItem().WithID("pli1").For(job, timelog).Create(db)
+	if err != nil {
+		log.Fatalf("failed to seed payment line item: %v", err)
+	}
+	return pli
 }
@@ -0,0 +1,42 @@
+package maintenance
+
+import (
+	"context"
+	"time"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+// Activator applies scheduled changes (see scd.ScheduleChange) once they
+// fall due, checking every Interval.
+type Activator struct {
+	DB *gorm.DB
+	// Interval bounds how late a change is activated (default one minute).
+	Interval time.Duration
+}
+
+// Run loops until ctx is cancelled, starting with an immediate pass. A pass
+// that fails is simply repeated on the next tick; changes that cannot be
+// applied at all are marked with their error in the scheduled changes table.
+func (a *Activator) Run(ctx context.Context) error {
+	interval := a.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		a.RunOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce activates the changes due now.
+func (a *Activator) RunOnce(ctx context.Context) (scd.ActivationResult, error) {
+	return scd.ActivateDueChanges(ctx, a.DB, time.Now())
+}
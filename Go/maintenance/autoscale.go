@@ -0,0 +1,78 @@
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Load is what the scheduler observed before a pass. A Scaler turns it into
+// the number of tables processed concurrently during the pass.
+type Load struct {
+	// VersionRate is the rate of rows inserted into the registered tables, in
+	// rows per second, since the previous pass. It is 0 on the first pass.
+	VersionRate float64
+	// QueueDepth is the number of tables with retention work to do.
+	QueueDepth int
+	// Workers is the concurrency of the previous pass.
+	Workers int
+	// LastDuration is how long the previous pass took.
+	LastDuration time.Duration
+}
+
+// Scaler chooses the worker concurrency of the next pass.
+type Scaler interface {
+	Workers(Load) int
+}
+
+// ScalerFunc adapts a function to Scaler.
+type ScalerFunc func(Load) int
+
+func (f ScalerFunc) Workers(l Load) int { return f(l) }
+
+// RateScaler adds a worker for every VersionsPerWorker rows per second being
+// written, between Min and Max and never more than there are tables to work on.
+type RateScaler struct {
+	Min, Max          int
+	VersionsPerWorker float64
+}
+
+func (s RateScaler) Workers(l Load) int {
+	n := s.Min
+	if s.VersionsPerWorker > 0 {
+		n = max(n, int(math.Ceil(l.VersionRate/s.VersionsPerWorker)))
+	}
+	if s.Max > 0 {
+		n = min(n, s.Max)
+	}
+	return max(min(n, l.QueueDepth), 1)
+}
+
+// rateSampler estimates the row insert rate of a set of tables from
+// PostgreSQL's cumulative statistics, which count inserts whether or not the
+// scd change log is enabled.
+type rateSampler struct {
+	inserts int64
+	at      time.Time
+}
+
+func (r *rateSampler) sample(ctx context.Context, db *gorm.DB, tables []string) (float64, error) {
+	var inserts int64
+	err := db.WithContext(ctx).
+		Raw("SELECT COALESCE(SUM(n_tup_ins), 0) FROM pg_stat_user_tables WHERE relname IN ?", tables).
+		Scan(&inserts).Error
+	if err != nil {
+		return 0, fmt.Errorf("sampling insert rate failed: %w", err)
+	}
+	now := time.Now()
+	var rate float64
+	// Statistics resets make the counter go backwards; skip that sample.
+	if !r.at.IsZero() && inserts >= r.inserts {
+		rate = float64(inserts-r.inserts) / now.Sub(r.at).Seconds()
+	}
+	r.inserts, r.at = inserts, now
+	return rate, nil
+}
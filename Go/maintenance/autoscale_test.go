@@ -0,0 +1,26 @@
+package maintenance_test
+
+import (
+	"testing"
+
+	"github.com/yourorg/Go/maintenance"
+)
+
+func TestRateScalerWorkers(t *testing.T) {
+	s := maintenance.RateScaler{Min: 1, Max: 4, VersionsPerWorker: 100}
+	cases := []struct {
+		load maintenance.Load
+		want int
+	}{
+		{maintenance.Load{VersionRate: 0, QueueDepth: 3}, 1},
+		{maintenance.Load{VersionRate: 250, QueueDepth: 5}, 3},
+		{maintenance.Load{VersionRate: 10000, QueueDepth: 5}, 4},
+		{maintenance.Load{VersionRate: 10000, QueueDepth: 2}, 2},
+		{maintenance.Load{VersionRate: 10000, QueueDepth: 0}, 1},
+	}
+	for _, c := range cases {
+		if got := s.Workers(c.load); got != c.want {
+			t.Errorf("Workers(%+v) = %d, want %d", c.load, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Package maintenance runs periodic housekeeping over the registered SCD
+// models, starting with enforcement of their declared retention policies.
+package maintenance
+
+import (
+	"context"
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+// RunStats describes one pass of the scheduler.
+type RunStats struct {
+	Started  time.Time
+	Duration time.Duration
+	// Load is what the Scaler was given and Workers what it chose.
+	Load    Load
+	Workers int
+	Tables  []scd.RetentionResult
+	Errors  []string
+}
+
+// Totals are the cumulative rows removed from each table across runs.
+type Totals struct {
+	Runs     int64
+	Pruned   map[string]int64
+	Archived map[string]int64
+	LastRun  RunStats
+}
+
+// Metrics accumulates Totals. It is published through expvar under
+// "scd_maintenance" by the first Scheduler started in the process.
+type Metrics struct {
+	mu     sync.Mutex
+	totals Totals
+}
+
+func (m *Metrics) record(stats RunStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.totals.Pruned == nil {
+		m.totals.Pruned, m.totals.Archived = map[string]int64{}, map[string]int64{}
+	}
+	m.totals.Runs++
+	for _, t := range stats.Tables {
+		m.totals.Pruned[t.Table] += t.Pruned
+		m.totals.Archived[t.Table] += t.Archived
+	}
+	m.totals.LastRun = stats
+}
+
+// Snapshot returns a copy of the current totals.
+func (m *Metrics) Snapshot() Totals {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := Totals{Runs: m.totals.Runs, LastRun: m.totals.LastRun, Pruned: map[string]int64{}, Archived: map[string]int64{}}
+	for k, v := range m.totals.Pruned {
+		t.Pruned[k] = v
+	}
+	for k, v := range m.totals.Archived {
+		t.Archived[k] = v
+	}
+	return t
+}
+
+// Scheduler enforces the retention policy of every model registered with
+// scd.RegisterModel once per Interval.
+type Scheduler struct {
+	DB *gorm.DB
+	// Interval is the time between passes (default one hour).
+	Interval time.Duration
+	// BatchSize bounds the rows removed per statement (default 5000).
+	BatchSize int
+	// Scaler sets how many tables are processed concurrently; nil processes
+	// them one at a time.
+	Scaler Scaler
+	// MaxRunTime bounds each pass. Tables not finished in time continue where
+	// they stopped on the next pass.
+	MaxRunTime time.Duration
+	Metrics    Metrics
+
+	rates  rateSampler
+	last   RunStats
+	resume map[string]scd.ResumeToken
+}
+
+var publishOnce sync.Once
+
+// Run loops until ctx is cancelled, starting with an immediate pass.
+func (s *Scheduler) Run(ctx context.Context) error {
+	publishOnce.Do(func() {
+		expvar.Publish("scd_maintenance", expvar.Func(func() any { return s.Metrics.Snapshot() }))
+	})
+	interval := s.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.RunOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce applies every registered retention policy and records the outcome in Metrics.
+func (s *Scheduler) RunOnce(ctx context.Context) RunStats {
+	stats := RunStats{Started: time.Now()}
+	// Pick up strategy switches made by other processes.
+	if err := scd.LoadStrategies(ctx, s.DB); err != nil {
+		stats.Errors = append(stats.Errors, err.Error())
+	}
+	regs := scd.Registrations()
+	stats.Load, stats.Workers = s.plan(ctx, regs, &stats)
+
+	results := make([]scd.RetentionResult, len(regs))
+	errs := make([]error, len(regs))
+	limits := scd.Limits{}
+	if s.MaxRunTime > 0 {
+		limits.Deadline = stats.Started.Add(s.MaxRunTime)
+	}
+	sem := make(chan struct{}, stats.Workers)
+	var wg sync.WaitGroup
+	for i, reg := range regs {
+		wg.Add(1)
+		sem <- struct{}{}
+		l := limits
+		if info, err := scd.Describe(s.DB, reg.Model); err == nil {
+			l.Resume = s.resume[info.Table]
+		}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			results[i], errs[i] = scd.ApplyRetentionWithin(ctx, s.DB, reg.Model, reg.Options.Retention, s.BatchSize, l)
+		}()
+	}
+	wg.Wait()
+	if s.resume == nil {
+		s.resume = map[string]scd.ResumeToken{}
+	}
+	for i, err := range errs {
+		if err != nil {
+			stats.Errors = append(stats.Errors, err.Error())
+		}
+		if results[i].Resume == "" {
+			delete(s.resume, results[i].Table)
+		} else {
+			s.resume[results[i].Table] = results[i].Resume
+		}
+		stats.Tables = append(stats.Tables, results[i])
+	}
+	stats.Duration = time.Since(stats.Started)
+	s.last = stats
+	s.Metrics.record(stats)
+	return stats
+}
+
+// plan observes the load on the registered tables and asks the Scaler for
+// the concurrency of this pass. Sampling failures are recorded in stats and
+// leave the rate at 0.
+func (s *Scheduler) plan(ctx context.Context, regs []scd.Registration, stats *RunStats) (Load, int) {
+	load := Load{Workers: s.last.Workers, LastDuration: s.last.Duration}
+	var tables []string
+	for _, reg := range regs {
+		if reg.Options.Retention.Kind == scd.RetainAll {
+			continue
+		}
+		load.QueueDepth++
+		if info, err := scd.Describe(s.DB, reg.Model); err == nil {
+			tables = append(tables, info.Table)
+		}
+	}
+	if s.Scaler == nil {
+		return load, 1
+	}
+	if len(tables) > 0 {
+		rate, err := s.rates.sample(ctx, s.DB, tables)
+		if err != nil {
+			stats.Errors = append(stats.Errors, err.Error())
+		}
+		load.VersionRate = rate
+	}
+	return load, max(s.Scaler.Workers(load), 1)
+}
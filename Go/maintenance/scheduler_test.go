@@ -0,0 +1,25 @@
+package maintenance_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yourorg/Go/maintenance"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestSchedulerRunDefaultsNonPositiveInterval(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("opening dry-run db: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := (&maintenance.Scheduler{DB: db}).Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run = %v, want the context deadline", err)
+	}
+}
@@ -0,0 +1,61 @@
+package models
+
+// JobStatus is the lifecycle state of a job. Jobs move between states only
+// along the transitions of JobTransitions, driven by a JobEvent (see
+// jobsvc.Transition).
+type JobStatus string
+
+// Job statuses.
+const (
+	JobActive    JobStatus = "active"
+	JobPaused    JobStatus = "paused"
+	JobCompleted JobStatus = "completed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// JobEvent is what moves a job from one status to another.
+type JobEvent string
+
+// Job events.
+const (
+	JobPause    JobEvent = "pause"
+	JobResume   JobEvent = "resume"
+	JobComplete JobEvent = "complete"
+	JobCancel   JobEvent = "cancel"
+)
+
+// JobTransitions maps each status to the events it accepts and the status
+// each of them leads to. Completed and cancelled jobs are final.
+var JobTransitions = map[JobStatus]map[JobEvent]JobStatus{
+	JobActive:    {JobPause: JobPaused, JobComplete: JobCompleted, JobCancel: JobCancelled},
+	JobPaused:    {JobResume: JobActive, JobComplete: JobCompleted, JobCancel: JobCancelled},
+	JobCompleted: {},
+	JobCancelled: {},
+}
+
+// Valid reports whether s is a known status.
+func (s JobStatus) Valid() bool {
+	_, ok := JobTransitions[s]
+	return ok
+}
+
+// Next returns the status event moves s to; ok is false when s does not
+// accept event.
+func (s JobStatus) Next(event JobEvent) (next JobStatus, ok bool) {
+	next, ok = JobTransitions[s][event]
+	return next, ok
+}
+
+// CanBecome reports whether some event moves s to next. Every status can
+// stay as it is.
+func (s JobStatus) CanBecome(next JobStatus) bool {
+	if s == next {
+		return true
+	}
+	for _, to := range JobTransitions[s] {
+		if to == next {
+			return true
+		}
+	}
+	return false
+}
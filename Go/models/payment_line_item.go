@@ -2,8 +2,11 @@ package models
 
 type PaymentLineItem struct {
 	Versioned
-	JobUID     string  `gorm:"column:job_uid"`
-	TimelogUID string  `gorm:"column:timelog_uid"`
-	Amount     float64 `gorm:"column:amount"`
-	Status     string  `gorm:"column:status"`
-} 
\ No newline at end of file
+	JobUID      string  `gorm:"column:job_uid;index:idx_payment_line_items_status_job,priority:2"`
+	TimelogUID  string  `gorm:"column:timelog_uid"`
+	Amount      float64 `gorm:"column:amount"`
+	Status      string  `gorm:"column:status;index:idx_payment_line_items_status_job,priority:1"`
+	// CorrectsUID is set on adjustments: the UID of the line item version
+	// whose amount they correct, e.g. after its period was closed.
+	CorrectsUID string  `gorm:"column:corrects_uid;index"`
+}
\ No newline at end of file
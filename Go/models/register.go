@@ -0,0 +1,19 @@
+package models
+
+import "github.com/yourorg/Go/scd"
+
+// Register declares the versioned models and their options with the scd
+// package. Financial history and settings are kept in full. Timelogs depend
+// on jobs, and payment line items on both.
+func Register() {
+	scd.RegisterModel(Job{}, scd.ModelOptions{Retention: scd.KeepAll(), PII: []string{"title", "contractor_id"}})
+	scd.RegisterModel(Timelog{}, scd.ModelOptions{
+		Retention:  scd.KeepAll(),
+		References: []scd.Reference{{Field: "JobUID", Target: Job{}}},
+	})
+	scd.RegisterModel(PaymentLineItem{}, scd.ModelOptions{
+		Retention:  scd.KeepAll(),
+		References: []scd.Reference{{Field: "JobUID", Target: Job{}}, {Field: "TimelogUID", Target: Timelog{}}},
+	})
+	scd.RegisterModel(Setting{}, scd.ModelOptions{Retention: scd.KeepAll()})
+}
@@ -0,0 +1,9 @@
+package models
+
+// Setting is one system setting. Its ID is the setting's key, e.g.
+// "payment.period_days", and Value its value encoded as JSON, so settings get
+// history, effective dating and watches like any other versioned entity.
+type Setting struct {
+	Versioned
+	Value string `gorm:"column:value;not null"`
+}
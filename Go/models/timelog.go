@@ -2,6 +2,17 @@ package models
 
 import "time"
 
+// TimelogApproval is the approval state of a timelog.
+type TimelogApproval string
+
+// Timelog approval states. A submitted timelog is approved or rejected; a
+// rejected one is corrected and submitted again.
+const (
+	TimelogSubmitted TimelogApproval = "submitted"
+	TimelogApproved  TimelogApproval = "approved"
+	TimelogRejected  TimelogApproval = "rejected"
+)
+
 type Timelog struct {
 	Versioned
 	Duration   float64   `gorm:"column:duration"`
@@ -9,4 +20,10 @@ type Timelog struct {
 	TimeEnd    time.Time `gorm:"column:time_end"`
 	Type       string    `gorm:"column:type"`
 	JobUID     string    `gorm:"column:job_uid"`
+	// ApprovalStatus is where the timelog is in its approval (see
+	// repos.TimelogRepo.SubmitTimelog); only approved timelogs are billed.
+	// Timelogs created without a status are submitted, and so are edits of
+	// approved ones (see BeforeCreate); timelogs predating the workflow are
+	// approved by MigrateTimelogApproval.
+	ApprovalStatus TimelogApproval `gorm:"column:approval_status;not null;default:'submitted'"`
 } 
\ No newline at end of file
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+// BeforeCreate submits timelogs created without an approval status, and
+// sends a new version of an approved timelog back to review when it changes
+// what is billed: the duration, the times or the type. Approving a
+// submitted timelog, and versions changing nothing billed, such as a JobUID
+// repointed to the job's latest version by scd.FollowLatest, stay approved.
+func (t *Timelog) BeforeCreate(tx *gorm.DB) error {
+	if t.ApprovalStatus == "" {
+		t.ApprovalStatus = TimelogSubmitted
+	}
+	if t.ApprovalStatus != TimelogApproved || t.Version <= 1 {
+		return nil
+	}
+	var previous Timelog
+	err := scd.AllVersions(tx.Statement.Context, tx.Session(&gorm.Session{NewDB: true}), &Timelog{}).
+		Where("id = ? AND version < ? AND version_state = ?", t.ID, t.Version, scd.StateApproved).
+		Order("version DESC").Limit(1).Find(&previous).Error
+	if err != nil {
+		return fmt.Errorf("reading previous version of timelog %s failed: %w", t.ID, err)
+	}
+	if previous.ApprovalStatus == TimelogApproved && billedChanged(previous, *t) {
+		t.ApprovalStatus = TimelogSubmitted
+	}
+	return nil
+}
+
+func billedChanged(a, b Timelog) bool {
+	return a.Duration != b.Duration || !a.TimeStart.Equal(b.TimeStart) || !a.TimeEnd.Equal(b.TimeEnd) || a.Type != b.Type
+}
+
+// MigrateTimelogApproval adds the approval_status column to an existing
+// timelogs table with the timelogs in it approved, as they were billed
+// before the approval workflow, while new timelogs default to submitted.
+// Run it before migrating Timelog, which would add the column with every
+// existing timelog submitted.
+func MigrateTimelogApproval(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable(&Timelog{}) || m.HasColumn(&Timelog{}, "ApprovalStatus") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("ALTER TABLE timelogs ADD COLUMN approval_status text NOT NULL DEFAULT '" + string(TimelogApproved) + "'").Error; err != nil {
+			return fmt.Errorf("adding timelog approval status failed: %w", err)
+		}
+		if err := tx.Exec("ALTER TABLE timelogs ALTER COLUMN approval_status SET DEFAULT '" + string(TimelogSubmitted) + "'").Error; err != nil {
+			return fmt.Errorf("defaulting timelogs to submitted failed: %w", err)
+		}
+		return nil
+	})
+}
@@ -1,28 +1,43 @@
 package models
 
 import (
-	"gorm.io/gorm"
+	"time"
+
+	"github.com/yourorg/Go/scd"
 )
 
+var _ scd.Entity = (*Versioned)(nil)
+
 type Versioned struct {
 	ID      string `gorm:"primaryKey;column:id"`
 	Version int    `gorm:"primaryKey;column:version"`
 	UID     string `gorm:"uniqueIndex;column:uid"`
+	// CreatedAt is when this version was written; rows predating the column are NULL.
+	CreatedAt *time.Time `gorm:"column:created_at"`
+	// CreatedBy, ChangeReason and Source record who wrote this version, why,
+	// and through which channel. They are filled in from the context of the
+	// write (see scd.WithActor) and empty where it carried none.
+	CreatedBy    string `gorm:"column:created_by;not null;default:''"`
+	ChangeReason string `gorm:"column:change_reason;not null;default:''"`
+	Source       string `gorm:"column:source;not null;default:''"`
+	// ValidFrom and ValidTo bound the period this version is in effect in
+	// business time, which may start after CreatedAt (see scd.ScheduleVersion
+	// and scd.EffectiveAt). ValidTo is NULL until a later-effective version
+	// exists. See scd.BackfillValidity.
+	ValidFrom *time.Time `gorm:"column:valid_from"`
+	ValidTo   *time.Time `gorm:"column:valid_to"`
+	// IsDeleted marks a tombstone version written by scd.DeleteAsNewVersion;
+	// DeletedAt is when it was written and NULL on live versions.
+	IsDeleted bool       `gorm:"column:is_deleted;not null;default:false"`
+	DeletedAt *time.Time `gorm:"column:deleted_at"`
+	// VersionState is scd.StateApproved for versions in effect; drafts and
+	// versions awaiting approval are invisible to latest-version reads (see
+	// scd.SaveDraft). Rows predating the column are approved.
+	VersionState string `gorm:"column:version_state;not null;default:'approved'"`
 }
 
-func (v *Versioned) BeforeUpdate(tx *gorm.DB) (err error) {
-	var maxVersion int
-	err = tx.Model(v).Where("id = ?", v.ID).Select("MAX(version)").Scan(&maxVersion).Error
-	if err != nil {
-		return err
-	}
-	v.Version = maxVersion + 1
-	// Instead of updating, create a new record
-	tx.Statement.Model = v
-	err = tx.Create(v).Error
-	if err != nil {
-		return err
-	}
-	// Cancel the update
-	return gorm.ErrInvalidData
-} 
\ No newline at end of file
+func (v *Versioned) GetID() string     { return v.ID }
+func (v *Versioned) GetVersion() int   { return v.Version }
+func (v *Versioned) SetVersion(n int)  { v.Version = n }
+func (v *Versioned) GetUID() string    { return v.UID }
+func (v *Versioned) SetUID(uid string) { v.UID = uid }
@@ -0,0 +1,282 @@
+// Package overhead tracks what versioning costs reads in production, the
+// way the benchmarks do offline. A Monitor installed on a DB samples a small
+// fraction of the queries that join the latest versions of a registered
+// table, derives the baseline query a non-versioned table would have run by
+// leaving the version join out, and compares the two with EXPLAIN. The moving
+// average of the ratio per table is published through expvar:
+//
+//	m := &overhead.Monitor{SampleRate: 0.01}
+//	if err := m.Install(db); err != nil { ... }
+//	m.Publish() // "scd_overhead": [{"table": "jobs", "ratio": 1.8, ...}]
+package overhead
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+)
+
+// Monitor samples versioned reads and compares them with their baseline.
+type Monitor struct {
+	// SampleRate is the fraction of versioned reads measured (default 0.01).
+	SampleRate float64
+	// Execute compares execution times from EXPLAIN ANALYZE instead of the
+	// planner's cost estimates. Both queries then run once more per sample,
+	// so keep SampleRate low.
+	Execute bool
+	// Smoothing is the weight of a new sample in the moving averages
+	// (default 0.05).
+	Smoothing float64
+	// MaxInFlight bounds the samples measured at once; reads sampled while
+	// that many are running are skipped (default 2).
+	MaxInFlight int
+	// Timeout bounds the EXPLAIN of one sample (default 5s).
+	Timeout time.Duration
+
+	db       *gorm.DB
+	inFlight chan struct{}
+	mu       sync.Mutex
+	tables   map[string]*TableReport
+}
+
+// TableReport is the measured overhead of versioning on reads of one table.
+type TableReport struct {
+	Table   string `json:"table"`
+	Samples int64  `json:"samples"`
+	// Ratio is the moving average of the versioned query's cost over its
+	// baseline's: 1.8 means reads cost 80% more than without versioning.
+	Ratio float64 `json:"ratio"`
+	// Cost and BaselineCost are the moving averages of the two queries'
+	// costs, in planner cost units, or milliseconds with Execute.
+	Cost         float64 `json:"cost"`
+	BaselineCost float64 `json:"baseline_cost"`
+	// Errors counts samples whose EXPLAIN failed.
+	Errors int64 `json:"errors"`
+}
+
+const baselineKey = "overhead:baseline"
+
+type baselineQuery struct {
+	sql  string
+	vars []any
+}
+
+// Install registers the sampling callbacks on db. EXPLAINs run in the
+// background on db's connection pool, outside the sampled read's
+// transaction.
+func (m *Monitor) Install(db *gorm.DB) error {
+	m.db = db
+	m.inFlight = make(chan struct{}, m.maxInFlight())
+	cb := db.Callback().Query()
+	if err := cb.Before("gorm:query").Register("overhead:sample", m.sample); err != nil {
+		return err
+	}
+	return cb.After("gorm:query").Register("overhead:measure", m.measure)
+}
+
+// Baseline returns the SQL and bound variables the query of tx would have
+// run without versioning, i.e. without the joins added by scd.JoinVersions
+// such as the scd.Latest scope's. ok is false when the query has no such
+// join. Call it before the query is built, e.g. from a callback registered
+// before "gorm:query".
+func Baseline(tx *gorm.DB) (sql string, vars []any, ok bool) {
+	joins := tx.Statement.Joins
+	kept := joins[:0:0]
+	for _, j := range joins {
+		if !scd.IsVersionJoin(j.Name) {
+			kept = append(kept, j)
+		}
+	}
+	if len(kept) == len(joins) {
+		return "", nil, false
+	}
+	b := tx.Session(&gorm.Session{Context: tx.Statement.Context})
+	b.Statement.Joins = kept
+	b.Statement.BuildClauses = tx.Statement.BuildClauses
+	b.Statement.SQL = strings.Builder{}
+	b.Statement.Vars = nil
+	callbacks.BuildQuerySQL(b)
+	if b.Error != nil {
+		return "", nil, false
+	}
+	return b.Statement.SQL.String(), b.Statement.Vars, true
+}
+
+func (m *Monitor) sample(tx *gorm.DB) {
+	if tx.Error != nil || tx.DryRun || rand.Float64() >= m.sampleRate() {
+		return
+	}
+	if _, locking := tx.Statement.Clauses["FOR"]; locking || tableOf(tx.Statement) == "" {
+		return
+	}
+	if sql, vars, ok := Baseline(tx); ok {
+		tx.InstanceSet(baselineKey, baselineQuery{sql: sql, vars: vars})
+	}
+}
+
+func (m *Monitor) measure(tx *gorm.DB) {
+	v, ok := tx.InstanceGet(baselineKey)
+	if !ok || tx.Error != nil {
+		return
+	}
+	select {
+	case m.inFlight <- struct{}{}:
+	default:
+		return
+	}
+	baseline := v.(baselineQuery)
+	versioned := baselineQuery{sql: tx.Statement.SQL.String(), vars: slices.Clone(tx.Statement.Vars)}
+	table := tableOf(tx.Statement)
+	ctx := context.WithoutCancel(tx.Statement.Context)
+	go func() {
+		defer func() { <-m.inFlight }()
+		ctx, cancel := context.WithTimeout(ctx, m.timeout())
+		defer cancel()
+		cost, err := m.explain(ctx, versioned)
+		var baselineCost float64
+		if err == nil {
+			baselineCost, err = m.explain(ctx, baseline)
+		}
+		m.record(table, cost, baselineCost, err)
+	}()
+}
+
+// explain returns the planner's total cost of q, or its execution time in
+// milliseconds with Execute.
+func (m *Monitor) explain(ctx context.Context, q baselineQuery) (float64, error) {
+	options := "FORMAT JSON"
+	if m.Execute {
+		options = "ANALYZE, FORMAT JSON"
+	}
+	rows, err := m.db.ConnPool.QueryContext(ctx, "EXPLAIN ("+options+") "+q.sql, q.vars...)
+	if err != nil {
+		return 0, fmt.Errorf("explaining sampled query failed: %w", err)
+	}
+	defer rows.Close()
+	var out []byte
+	if rows.Next() {
+		if err := rows.Scan(&out); err != nil {
+			return 0, fmt.Errorf("reading query plan failed: %w", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("reading query plan failed: %w", err)
+	}
+	var plans []struct {
+		Plan struct {
+			TotalCost float64 `json:"Total Cost"`
+		} `json:"Plan"`
+		ExecutionTime float64 `json:"Execution Time"`
+	}
+	if err := json.Unmarshal(out, &plans); err != nil {
+		return 0, fmt.Errorf("parsing query plan failed: %w", err)
+	}
+	if len(plans) == 0 {
+		return 0, fmt.Errorf("explaining sampled query returned no plan")
+	}
+	if m.Execute {
+		return plans[0].ExecutionTime, nil
+	}
+	return plans[0].Plan.TotalCost, nil
+}
+
+// record folds one sample of table into its report.
+func (m *Monitor) record(table string, cost, baselineCost float64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tables == nil {
+		m.tables = map[string]*TableReport{}
+	}
+	r, ok := m.tables[table]
+	if !ok {
+		r = &TableReport{Table: table}
+		m.tables[table] = r
+	}
+	if err != nil || baselineCost <= 0 {
+		r.Errors++
+		return
+	}
+	ratio := cost / baselineCost
+	if r.Samples == 0 {
+		r.Ratio, r.Cost, r.BaselineCost = ratio, cost, baselineCost
+	} else {
+		a := m.smoothing()
+		r.Ratio += a * (ratio - r.Ratio)
+		r.Cost += a * (cost - r.Cost)
+		r.BaselineCost += a * (baselineCost - r.BaselineCost)
+	}
+	r.Samples++
+}
+
+// Report returns the reports of the tables sampled so far, by table.
+func (m *Monitor) Report() []TableReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]TableReport, 0, len(m.tables))
+	for _, r := range m.tables {
+		out = append(out, *r)
+	}
+	slices.SortFunc(out, func(a, b TableReport) int { return strings.Compare(a.Table, b.Table) })
+	return out
+}
+
+var publishOnce sync.Once
+
+// Publish exposes the Report through expvar under "scd_overhead". Only the
+// first Monitor published in a process is exposed.
+func (m *Monitor) Publish() {
+	publishOnce.Do(func() {
+		expvar.Publish("scd_overhead", expvar.Func(func() any { return m.Report() }))
+	})
+}
+
+// tableOf returns the table stmt reads when it is a registered versioned
+// table, and "" otherwise.
+func tableOf(stmt *gorm.Statement) string {
+	table := stmt.Table
+	if table == "" && stmt.Schema != nil {
+		table = stmt.Schema.Table
+	}
+	if _, ok := scd.LookupModel(table); !ok {
+		return ""
+	}
+	return table
+}
+
+func (m *Monitor) sampleRate() float64 {
+	if m.SampleRate <= 0 {
+		return 0.01
+	}
+	return m.SampleRate
+}
+
+func (m *Monitor) smoothing() float64 {
+	if m.Smoothing <= 0 || m.Smoothing > 1 {
+		return 0.05
+	}
+	return m.Smoothing
+}
+
+func (m *Monitor) maxInFlight() int {
+	if m.MaxInFlight <= 0 {
+		return 2
+	}
+	return m.MaxInFlight
+}
+
+func (m *Monitor) timeout() time.Duration {
+	if m.Timeout <= 0 {
+		return 5 * time.Second
+	}
+	return m.Timeout
+}
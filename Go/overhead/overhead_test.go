@@ -0,0 +1,48 @@
+package overhead_test
+
+import (
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/overhead"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestBaselineLeavesTheVersionJoinOut(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("opening dry-run db: %v", err)
+	}
+	var baseline string
+	var vars []any
+	db.Callback().Query().Before("gorm:query").Register("test:baseline", func(tx *gorm.DB) {
+		// Subqueries are built through the callbacks too; keep the outer query's.
+		if sql, v, ok := overhead.Baseline(tx); ok {
+			baseline, vars = sql, v
+		}
+	})
+	var jobs []models.Job
+	stmt := db.Scopes(scd.Latest[models.Job]()).Where("jobs.status = ?", "active").Find(&jobs).Statement
+
+	if want := `SELECT * FROM "jobs" WHERE jobs.status = $1`; baseline != want {
+		t.Errorf("baseline = %s, want %s", baseline, want)
+	}
+	if len(vars) != 1 || vars[0] != "active" {
+		t.Errorf("baseline vars = %v", vars)
+	}
+	if sql := stmt.SQL.String(); !containsJoin(sql) {
+		t.Errorf("deriving the baseline changed the query: %s", sql)
+	}
+}
+
+func containsJoin(sql string) bool {
+	for i := 0; i+len(`JOIN (`) <= len(sql); i++ {
+		if sql[i:i+len(`JOIN (`)] == `JOIN (` {
+			return true
+		}
+	}
+	return false
+}
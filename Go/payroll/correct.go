@@ -0,0 +1,109 @@
+package payroll
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/periods"
+	"github.com/yourorg/Go/scd"
+	"github.com/yourorg/Go/scderr"
+	"gorm.io/gorm"
+)
+
+// ErrNoReason is returned by Correct when ctx carries no change reason.
+var ErrNoReason = scderr.New(scderr.Validation, "payroll: corrections need a change reason")
+
+// Correction is the outcome of Correct.
+type Correction struct {
+	// ChangeSet groups the versions written (see scd.WithChangeSet).
+	ChangeSet string
+	// Timelog is the timelog's new version.
+	Timelog models.Timelog
+	// Repriced is set when the line item was still pending in an open period
+	// and got a new version instead of an adjustment.
+	Repriced bool
+	// Adjustment is the line item created to bill the difference, or nil
+	// when none was needed.
+	Adjustment *models.PaymentLineItem
+}
+
+// Correct changes timelog timelogID with update and bills the difference
+// the change makes. A pending line item whose work lies in no closed period
+// is repriced, as ComputeLineItems would. Otherwise the line item stays as
+// it was billed and an adjustment is created instead: a pending line item
+// billing the difference, in the current period, whose CorrectsUID is the
+// UID of the line item version it corrects. Deleting the timelog
+// adjusts its amount to zero.
+//
+//	ctx = scd.WithChangeReason(ctx, "hours misreported, ticket #1234")
+//	c, err := payroll.Correct(ctx, db, "tl1", func(t *models.Timelog) error { t.Duration = 6; return nil })
+//
+// The timelog's new version is written as a period adjustment (see
+// periods.WithAdjustment), so ctx must carry a change reason; it needs the
+// periods table. Everything is written in one transaction and change set.
+func Correct(ctx context.Context, db *gorm.DB, timelogID string, update func(*models.Timelog) error) (Correction, error) {
+	c := Correction{ChangeSet: scd.NewChangeSetID()}
+	if scd.ChangeReasonFrom(ctx) == "" {
+		return c, fmt.Errorf("correcting timelog %s failed: %w", timelogID, ErrNoReason)
+	}
+	ctx = periods.WithAdjustment(scd.WithChangeSet(ctx, c.ChangeSet))
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		previous, created, err := scd.CreateNewSCDVersionReturning[models.Timelog](ctx, tx, timelogID, update)
+		if err != nil {
+			return fmt.Errorf("correcting timelog %s failed: %w", timelogID, err)
+		}
+		c.Timelog = created
+		bills, err := lineItemsByTimelog(ctx, tx, []string{timelogID})
+		if err != nil {
+			return err
+		}
+		b := bills[timelogID]
+		if b.item == nil {
+			// Nothing billed yet; ComputeLineItems bills the new version.
+			return nil
+		}
+		job, err := scd.ResolveLatestOf[models.Job](ctx, tx, created.JobUID)
+		if err != nil {
+			return fmt.Errorf("resolving job of timelog %s failed: %w", timelogID, err)
+		}
+		if job, err = jobAt(tx, job.ID, created); err != nil {
+			return err
+		}
+		p := Priced{Timelog: created, Job: job, Amount: created.Duration * job.Rate, Item: b.item, Adjustments: b.adjustments}
+		if created.IsDeleted {
+			p.Amount = 0
+		}
+		if p.UpToDate() {
+			return nil
+		}
+
+		closed := false
+		for _, start := range []time.Time{previous.TimeStart, created.TimeStart} {
+			_, in, err := periods.ClosedAt(ctx, tx, start)
+			if err != nil {
+				return err
+			}
+			closed = closed || in
+		}
+		if p.Item.Status == PendingStatus && !closed {
+			c.Repriced = true
+			return Apply(ctx, tx, p)
+		}
+		delta := p.Amount - p.Billed()
+		if math.Abs(delta) < 0.005 {
+			// Only the versions referenced changed; what was billed stands.
+			return nil
+		}
+		adj := models.PaymentLineItem{Versioned: models.Versioned{ID: fmt.Sprintf("%s-adj-%d", p.Item.ID, len(p.Adjustments)+1), Version: 1, UID: scd.NewUID()},
+			JobUID: job.UID, TimelogUID: created.UID, Amount: delta, Status: PendingStatus, CorrectsUID: p.Item.UID}
+		if err := tx.Create(&adj).Error; err != nil {
+			return fmt.Errorf("creating adjustment of %s failed: %w", p.Item.ID, scd.Translate(err))
+		}
+		c.Adjustment = &adj
+		return nil
+	})
+	return c, err
+}
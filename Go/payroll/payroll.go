@@ -0,0 +1,369 @@
+// Package payroll derives payment line items from timelogs, so amounts
+// follow the hours logged and the rates in effect instead of being entered
+// by hand:
+//
+//	res, err := payroll.ComputeLineItems(ctx, db, "cont1", repos.Period{From: jan, To: feb})
+//	fmt.Printf("change set %s: %d created, %d updated\n", res.ChangeSet, res.Created, res.Updated)
+package payroll
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/periods"
+	"github.com/yourorg/Go/repos"
+	"github.com/yourorg/Go/scd"
+	"github.com/yourorg/Go/scderr"
+	"gorm.io/gorm"
+)
+
+// PendingStatus is the status of line items payroll may still compute.
+const PendingStatus = "pending"
+
+// ErrNotApproved is returned when billing a timelog that is not approved
+// (see repos.TimelogRepo.ApproveTimelog).
+var ErrNotApproved = scderr.New(scderr.Conflict, "payroll: timelog is not approved")
+
+// Result counts what ComputeLineItems did.
+type Result struct {
+	// ChangeSet groups the line item versions written (see scd.WithChangeSet).
+	ChangeSet string
+	Timelogs  int
+	Created   int
+	Updated   int
+	Unchanged int
+	// Held lists the line items whose amount is out of date but which are no
+	// longer pending, e.g. already paid, or which bill work within a closed
+	// period (see periods.Close), including those that would be created;
+	// correct those deliberately, for instance with scdctl payments recompute
+	// or payroll.Correct.
+	Held []string
+}
+
+// ComputeLineItems prices every latest live, approved timelog of
+// contractorID that started within period (see Price). Timelogs without a line item get a
+// pending one, identified by LineItemID; pending line items whose amount or
+// referenced versions differ get a new version pinning the timelog and job
+// versions the amount was computed from. Line items of work within closed
+// periods are held instead. Everything is written in one transaction and
+// one change set.
+func ComputeLineItems(ctx context.Context, db *gorm.DB, contractorID string, period repos.Period) (Result, error) {
+	res := Result{ChangeSet: scd.NewChangeSetID()}
+	ctx = scd.WithChangeSet(ctx, res.ChangeSet)
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		priced, err := Price(ctx, tx, contractorID, period)
+		if err != nil {
+			return err
+		}
+		res.Timelogs = len(priced)
+		// Without the periods table no period is closed.
+		periodsKept := tx.Migrator().HasTable(&periods.Period{})
+		for _, p := range priced {
+			if !p.UpToDate() && periodsKept && (p.Item == nil || p.Item.Status == PendingStatus) {
+				closed, err := inClosedPeriod(ctx, tx, p)
+				if err != nil {
+					return err
+				}
+				if closed {
+					id := LineItemID(p.Timelog.ID)
+					if p.Item != nil {
+						id = p.Item.ID
+					}
+					res.Held = append(res.Held, id)
+					continue
+				}
+			}
+			switch {
+			case p.UpToDate():
+				res.Unchanged++
+			case p.Item != nil && p.Item.Status != PendingStatus:
+				res.Held = append(res.Held, p.Item.ID)
+			default:
+				if err := Apply(ctx, tx, p); err != nil {
+					return err
+				}
+				if p.Item == nil {
+					res.Created++
+				} else {
+					res.Updated++
+				}
+			}
+		}
+		return nil
+	})
+	return res, err
+}
+
+// inClosedPeriod reports whether applying p would change work within a
+// closed period, which periods.Enforce rejects: p's timelog, or the timelog
+// version its line item bills, started in one.
+func inClosedPeriod(ctx context.Context, tx *gorm.DB, p Priced) (bool, error) {
+	starts := []time.Time{p.Timelog.TimeStart}
+	if p.Item != nil && p.Item.TimelogUID != p.Timelog.UID {
+		var billed []time.Time
+		err := scd.AllVersions(ctx, tx, &models.Timelog{}).Where("uid = ?", p.Item.TimelogUID).Pluck("time_start", &billed).Error
+		if err != nil {
+			return false, fmt.Errorf("reading timelog billed by %s failed: %w", p.Item.ID, err)
+		}
+		starts = append(starts, billed...)
+	}
+	for _, start := range starts {
+		if _, closed, err := periods.ClosedAt(ctx, tx, start); err != nil || closed {
+			return closed, err
+		}
+	}
+	return false, nil
+}
+
+// Priced is a timelog with the amount payroll prices it at and the line
+// item currently billing it.
+type Priced struct {
+	Timelog models.Timelog
+	// Job is the version of the timelog's job in effect when the work
+	// started, whose rate prices it.
+	Job    models.Job
+	Amount float64
+	// Item is the timelog's latest live line item, or nil when it has none.
+	Item *models.PaymentLineItem
+	// Adjustments are the live adjustments correcting Item, by id (see
+	// Correct).
+	Adjustments []models.PaymentLineItem
+}
+
+// Billed is the amount billed for p's timelog: its line item's plus those
+// of the adjustments correcting it.
+func (p Priced) Billed() float64 {
+	if p.Item == nil {
+		return 0
+	}
+	billed := p.Item.Amount
+	for _, adj := range p.Adjustments {
+		billed += adj.Amount
+	}
+	return billed
+}
+
+// PinsTimelog reports whether p's line item, or one of its adjustments,
+// references the timelog version p was priced from.
+func (p Priced) PinsTimelog() bool {
+	return p.pins(func(li models.PaymentLineItem) bool { return li.TimelogUID == p.Timelog.UID })
+}
+
+// PinsJob reports whether p's line item, or one of its adjustments,
+// references the job version p was priced from.
+func (p Priced) PinsJob() bool {
+	return p.pins(func(li models.PaymentLineItem) bool { return li.JobUID == p.Job.UID })
+}
+
+func (p Priced) pins(match func(models.PaymentLineItem) bool) bool {
+	if p.Item == nil {
+		return false
+	}
+	return match(*p.Item) || slices.ContainsFunc(p.Adjustments, match)
+}
+
+// UpToDate reports whether p's billed amount is its priced amount and its
+// line item references the timelog and job versions it was priced from.
+func (p Priced) UpToDate() bool {
+	return p.Item != nil && math.Abs(p.Billed()-p.Amount) < 0.005 && p.PinsTimelog() && p.PinsJob()
+}
+
+// Price prices every latest live, approved timelog that started within
+// period, of contractorID or of every contractor when it is empty: its duration times
+// the rate of the job version in effect when the work started (see
+// scd.EffectiveAt). Timelogs are returned in time order.
+func Price(ctx context.Context, tx *gorm.DB, contractorID string, period repos.Period) ([]Priced, error) {
+	var timelogs []struct {
+		models.Timelog
+		JobEntity string `gorm:"column:job_entity"`
+	}
+	q := tx.WithContext(ctx).Model(&models.Timelog{}).
+		Scopes(repos.Latest[models.Timelog](), scd.JoinLatestOf[models.Job]("timelogs.job_uid", "job")).
+		Where("timelogs.time_start >= ? AND timelogs.time_start < ?", period.From, period.To).
+		Where("timelogs.approval_status = ?", models.TimelogApproved)
+	if contractorID != "" {
+		q = q.Where("job.contractor_id = ?", contractorID)
+	}
+	err := q.Select("timelogs.*, job.id AS job_entity").
+		Order("timelogs.time_start, timelogs.id").
+		Scan(&timelogs).Error
+	if err != nil {
+		return nil, fmt.Errorf("reading timelogs failed: %w", scd.Translate(err))
+	}
+	if len(timelogs) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, len(timelogs))
+	for i, t := range timelogs {
+		ids[i] = t.ID
+	}
+	items, err := lineItemsByTimelog(ctx, tx, ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Priced, len(timelogs))
+	for i, t := range timelogs {
+		job, err := jobAt(tx, t.JobEntity, t.Timelog)
+		if err != nil {
+			return nil, err
+		}
+		b := items[t.ID]
+		out[i] = Priced{Timelog: t.Timelog, Job: job, Amount: t.Duration * job.Rate, Item: b.item, Adjustments: b.adjustments}
+	}
+	return out, nil
+}
+
+// Apply brings the line item of p up to date in tx: it creates a pending one
+// when the timelog has none, and otherwise writes a new version of it
+// billing what p's amount and adjustments leave over, with p's versions.
+// Apply does not check the line item's status, but refuses timelogs that
+// are not approved with ErrNotApproved.
+func Apply(ctx context.Context, tx *gorm.DB, p Priced) error {
+	if p.Timelog.ApprovalStatus != models.TimelogApproved {
+		return fmt.Errorf("billing timelog %s failed: it is %s: %w", p.Timelog.ID, p.Timelog.ApprovalStatus, ErrNotApproved)
+	}
+	if p.Item == nil {
+		first := models.PaymentLineItem{Versioned: models.Versioned{ID: LineItemID(p.Timelog.ID), Version: 1, UID: scd.NewUID()},
+			JobUID: p.Job.UID, TimelogUID: p.Timelog.UID, Amount: p.Amount, Status: PendingStatus}
+		if err := tx.WithContext(ctx).Create(&first).Error; err != nil {
+			return fmt.Errorf("creating line item for timelog %s failed: %w", p.Timelog.ID, scd.Translate(err))
+		}
+		return nil
+	}
+	err := scd.CreateNewSCDVersionIf[models.PaymentLineItem](ctx, tx, p.Item.ID, p.Item.Version, func(li *models.PaymentLineItem) error {
+		li.Amount, li.JobUID, li.TimelogUID = p.Amount-(p.Billed()-p.Item.Amount), p.Job.UID, p.Timelog.UID
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("updating line item %s failed: %w", p.Item.ID, err)
+	}
+	return nil
+}
+
+// RecomputeOnRateChange makes every version changing a job's rate reprice
+// the job's pending line items in the same transaction: each gets a new
+// version, in the change set of the rate change, when its timelog's work
+// now falls under a different rate. Register it once at startup, like the
+// other scd.OnVersionCreated handlers.
+func RecomputeOnRateChange() {
+	scd.OnFieldChanged(models.Job{}, "Rate", func(tx *gorm.DB, event scd.VersionEvent) error {
+		_, err := recomputeJob(tx, event.ID)
+		return err
+	})
+}
+
+// recomputeJob reprices the pending line items, adjustments aside,
+// referencing any version of job jobID against the latest versions of their
+// timelogs, returning how many got a new version.
+func recomputeJob(tx *gorm.DB, jobID string) (int, error) {
+	ctx := tx.Statement.Context
+	var items []models.PaymentLineItem
+	err := tx.Model(&models.PaymentLineItem{}).Scopes(repos.Latest[models.PaymentLineItem]()).
+		Where("payment_line_items.status = ? AND COALESCE(payment_line_items.corrects_uid, '') = ''", PendingStatus).
+		Where("payment_line_items.job_uid IN (?)", scd.AllVersions(ctx, tx, &models.Job{}).Select("uid").Where("id = ?", jobID)).
+		Order("payment_line_items.id").
+		Find(&items).Error
+	if err != nil {
+		return 0, fmt.Errorf("reading line items of job %s failed: %w", jobID, scd.Translate(err))
+	}
+	if len(items) == 0 {
+		return 0, nil
+	}
+	uids := make([]string, len(items))
+	for i, item := range items {
+		uids[i] = item.TimelogUID
+	}
+	timelogs, err := scd.ResolveLatestOfAll[models.Timelog](ctx, tx, uids)
+	if err != nil {
+		return 0, err
+	}
+	var updated int
+	for _, item := range items {
+		t, ok := timelogs[item.TimelogUID]
+		if !ok || t.IsDeleted || t.ApprovalStatus != models.TimelogApproved {
+			continue
+		}
+		job, err := jobAt(tx, jobID, t)
+		if err != nil {
+			return updated, err
+		}
+		p := Priced{Timelog: t, Job: job, Amount: t.Duration * job.Rate, Item: &item}
+		if p.UpToDate() {
+			continue
+		}
+		if err := Apply(ctx, tx, p); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// jobAt returns the version of job jobID in effect when the work of t
+// started, whose rate prices it.
+func jobAt(tx *gorm.DB, jobID string, t models.Timelog) (models.Job, error) {
+	var job models.Job
+	err := tx.Scopes(scd.EffectiveAt[models.Job](t.TimeStart)).Where("jobs.id = ?", jobID).Take(&job).Error
+	if err != nil {
+		return job, fmt.Errorf("resolving rate of timelog %s failed: %w", t.ID, scd.Translate(err))
+	}
+	return job, nil
+}
+
+// LineItemID is the id of the line item ComputeLineItems creates for
+// timelog timelogID. Deriving it from the timelog keeps concurrent runs from
+// creating two line items for the same work.
+func LineItemID(timelogID string) string {
+	return "pli-" + timelogID
+}
+
+// billing is what bills one timelog: its line item and the adjustments
+// correcting it.
+type billing struct {
+	item        *models.PaymentLineItem
+	adjustments []models.PaymentLineItem
+}
+
+// lineItemsByTimelog returns the latest live line item of each of the
+// timelogs ids, whichever of their versions it references, with its
+// adjustments, by timelog id. When several line items reference one timelog
+// the first by id is used.
+func lineItemsByTimelog(ctx context.Context, tx *gorm.DB, ids []string) (map[string]billing, error) {
+	var refs []struct{ ID, UID string }
+	if err := scd.AllVersions(ctx, tx, &models.Timelog{}).Select("id, uid").Where("id IN ?", ids).Scan(&refs).Error; err != nil {
+		return nil, fmt.Errorf("reading timelog versions failed: %w", scd.Translate(err))
+	}
+	timelogOf := make(map[string]string, len(refs))
+	uids := make([]string, len(refs))
+	for i, r := range refs {
+		timelogOf[r.UID], uids[i] = r.ID, r.UID
+	}
+	var items []models.PaymentLineItem
+	err := tx.Model(&models.PaymentLineItem{}).Scopes(repos.Latest[models.PaymentLineItem]()).
+		Where("payment_line_items.timelog_uid IN ?", uids).
+		Order("payment_line_items.id").
+		Find(&items).Error
+	if err != nil {
+		return nil, fmt.Errorf("reading line items failed: %w", scd.Translate(err))
+	}
+	out := make(map[string]billing, len(items))
+	for _, item := range items {
+		id := timelogOf[item.TimelogUID]
+		if id == "" {
+			continue
+		}
+		b := out[id]
+		switch {
+		case item.CorrectsUID != "":
+			b.adjustments = append(b.adjustments, item)
+		case b.item == nil:
+			b.item = &item
+		}
+		out[id] = b
+	}
+	return out, nil
+}
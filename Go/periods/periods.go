@@ -0,0 +1,293 @@
+// Package periods closes payroll periods. Once a period is closed, new
+// versions of the timelogs whose work started within it, and of the payment
+// line items billing such timelogs, are rejected with ErrPeriodClosed, so
+// what was paid for a period stays as it was paid. Changes that must still
+// be made are written as adjustments (see WithAdjustment).
+//
+//	if err := periods.Migrate(db); err != nil { ... }
+//	periods.Enforce()
+//	if err := periods.InstallGuard(db); err != nil { ... }
+//	_, err := periods.Close(ctx, db, "2024-01", jan, feb)
+package periods
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"github.com/yourorg/Go/scderr"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Statuses of a Period.
+const (
+	Open   = "open"
+	Closed = "closed"
+)
+
+var (
+	// ErrPeriodClosed is returned when a version would change work within a
+	// closed period.
+	ErrPeriodClosed = scderr.New(scderr.Conflict, "periods: period is closed")
+	// ErrInvalidPeriod is returned when closing a period that does not end
+	// after it starts, or whose range differs from the one it was created with.
+	ErrInvalidPeriod = scderr.New(scderr.Validation, "periods: invalid period")
+)
+
+// Period is a payroll period covering the work started within [From, To).
+type Period struct {
+	ID       string     `gorm:"primaryKey;column:id" json:"id"`
+	From     time.Time  `gorm:"column:period_from;not null;index" json:"from"`
+	To       time.Time  `gorm:"column:period_to;not null" json:"to"`
+	Status   string     `gorm:"column:status;not null;index" json:"status"`
+	ClosedAt *time.Time `gorm:"column:closed_at" json:"closed_at,omitempty"`
+	ClosedBy string     `gorm:"column:closed_by" json:"closed_by,omitempty"`
+}
+
+func (Period) TableName() string { return "periods" }
+
+// Migrate creates the periods table.
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Period{})
+}
+
+// Close closes period id covering [from, to), creating it when it does not
+// exist yet. The actor of ctx (see scd.WithActor) is recorded as closing it.
+// Closing a closed period again changes nothing.
+func Close(ctx context.Context, db *gorm.DB, id string, from, to time.Time) (Period, error) {
+	var p Period
+	if !to.After(from) {
+		return p, fmt.Errorf("%w: %s ends before it starts", ErrInvalidPeriod, id)
+	}
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).Take(&p).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			p = Period{ID: id, From: from, To: to}
+		case err != nil:
+			return fmt.Errorf("reading period %s failed: %w", id, err)
+		case !p.From.Equal(from) || !p.To.Equal(to):
+			return fmt.Errorf("%w: %s covers %s to %s", ErrInvalidPeriod, id, p.From.Format(time.DateOnly), p.To.Format(time.DateOnly))
+		case p.Status == Closed:
+			return nil
+		}
+		now := time.Now().UTC()
+		p.Status, p.ClosedAt, p.ClosedBy = Closed, &now, scd.ActorFrom(ctx)
+		if err := tx.Save(&p).Error; err != nil {
+			return fmt.Errorf("closing period %s failed: %w", id, err)
+		}
+		return nil
+	})
+	return p, err
+}
+
+// Reopen reopens closed period id, e.g. to correct it, after which its
+// timelogs and line items accept new versions again.
+func Reopen(ctx context.Context, db *gorm.DB, id string) (Period, error) {
+	var p Period
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).Take(&p).Error; err != nil {
+			return fmt.Errorf("reading period %s failed: %w", id, scd.Translate(err))
+		}
+		p.Status, p.ClosedAt, p.ClosedBy = Open, nil, ""
+		if err := tx.Save(&p).Error; err != nil {
+			return fmt.Errorf("reopening period %s failed: %w", id, err)
+		}
+		return nil
+	})
+	return p, err
+}
+
+// List returns every period, in start order.
+func List(ctx context.Context, db *gorm.DB) ([]Period, error) {
+	var out []Period
+	if err := db.WithContext(ctx).Order("period_from, id").Find(&out).Error; err != nil {
+		return nil, fmt.Errorf("listing periods failed: %w", err)
+	}
+	return out, nil
+}
+
+// ClosedAt returns the closed period covering work started at t, or false
+// when t falls in no closed period.
+func ClosedAt(ctx context.Context, db *gorm.DB, t time.Time) (Period, bool, error) {
+	var p Period
+	res := db.WithContext(ctx).Where("status = ? AND period_from <= ? AND period_to > ?", Closed, t, t).
+		Order("period_from").Limit(1).Find(&p)
+	if res.Error != nil {
+		return p, false, fmt.Errorf("reading closed periods failed: %w", res.Error)
+	}
+	return p, res.RowsAffected > 0, nil
+}
+
+type adjustmentKey struct{}
+
+// WithAdjustment lets the versions written with ctx change work within
+// closed periods. Adjustments must carry a change reason (see
+// scd.WithChangeReason), which the versions record as the reason the closed
+// period was changed.
+func WithAdjustment(ctx context.Context) context.Context {
+	return context.WithValue(ctx, adjustmentKey{}, true)
+}
+
+func isAdjustment(ctx context.Context) bool {
+	ok, _ := ctx.Value(adjustmentKey{}).(bool)
+	return ok
+}
+
+// Enforce rejects new versions of timelogs and payment line items changing
+// work within closed periods: timelogs whose new or previous version started
+// in one, and line items whose new or previous version bills such a
+// timelog version. Adjustments (line items with a CorrectsUID) belong to the
+// period they were created in instead. It covers the versions taking effect through scd, such
+// as CreateNewSCDVersion, deletes, restores and approvals; drafts may still
+// be prepared for a closed period, but approving them needs an adjustment.
+// First versions written with a plain Create are covered by InstallGuard.
+// Register it once at startup, like the other scd.OnVersionCreated handlers.
+func Enforce() {
+	scd.OnVersionCreated(models.Timelog{}, checkTimelog)
+	scd.OnVersionCreated(models.PaymentLineItem{}, checkLineItem)
+}
+
+// InstallGuard registers the create callback applying Enforce's checks on db
+// to the first versions of timelogs and payment line items written with a
+// plain Create, such as the line items payroll.Apply creates, which no
+// OnVersionCreated handler sees.
+func InstallGuard(db *gorm.DB) error {
+	return db.Callback().Create().After("gorm:create").Register("periods:guard", guardCreate)
+}
+
+func guardCreate(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement.Schema == nil {
+		return
+	}
+	var check scd.VersionHandler
+	switch tx.Statement.Schema.ModelType {
+	case reflect.TypeOf(models.Timelog{}):
+		check = checkTimelog
+	case reflect.TypeOf(models.PaymentLineItem{}):
+		check = checkLineItem
+	default:
+		return
+	}
+	rv := reflect.Indirect(tx.Statement.ReflectValue)
+	rows := []reflect.Value{rv}
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		rows = rows[:0]
+		for i := range rv.Len() {
+			rows = append(rows, reflect.Indirect(rv.Index(i)))
+		}
+	}
+	for _, row := range rows {
+		if !row.CanAddr() {
+			continue
+		}
+		entity, ok := row.Addr().Interface().(scd.Entity)
+		if !ok || entity.GetVersion() != 1 {
+			continue
+		}
+		// Drafts are checked when they are approved.
+		if state := row.FieldByName("VersionState"); state.IsValid() && state.String() != "" && state.String() != scd.StateApproved {
+			continue
+		}
+		event := scd.VersionEvent{Table: tx.Statement.Table, ID: entity.GetID(), Version: 1, UID: entity.GetUID(), Entity: row.Addr().Interface()}
+		if err := check(tx.Session(&gorm.Session{NewDB: true}), event); err != nil {
+			tx.AddError(err)
+			return
+		}
+	}
+}
+
+func checkTimelog(tx *gorm.DB, event scd.VersionEvent) error {
+	t, ok := entityAs[models.Timelog](event.Entity)
+	if !ok {
+		return nil
+	}
+	starts := []time.Time{t.TimeStart}
+	var previous []time.Time
+	err := scd.AllVersions(tx.Statement.Context, tx, &models.Timelog{}).
+		Where("id = ? AND version < ?", event.ID, event.Version).
+		Order("version DESC").Limit(1).Pluck("time_start", &previous).Error
+	if err != nil {
+		return fmt.Errorf("reading version before %s v%d failed: %w", event.ID, event.Version, err)
+	}
+	return check(tx, event, append(starts, previous...))
+}
+
+func checkLineItem(tx *gorm.DB, event scd.VersionEvent) error {
+	li, ok := entityAs[models.PaymentLineItem](event.Entity)
+	if !ok {
+		return nil
+	}
+	ctx := tx.Statement.Context
+	if li.CorrectsUID != "" {
+		// Adjustments belong to the period they were created in, not to
+		// that of the work they correct; one written without a creation
+		// time is being created now.
+		var created []time.Time
+		err := scd.AllVersions(ctx, tx, &models.PaymentLineItem{}).
+			Where("id = ? AND created_at IS NOT NULL", event.ID).
+			Order("version").Limit(1).Pluck("created_at", &created).Error
+		if err != nil {
+			return fmt.Errorf("reading first version of %s failed: %w", event.ID, err)
+		}
+		if len(created) == 0 {
+			created = []time.Time{time.Now()}
+		}
+		return check(tx, event, created)
+	}
+	uids := []string{li.TimelogUID}
+	var previous []string
+	err := scd.AllVersions(ctx, tx, &models.PaymentLineItem{}).
+		Where("id = ? AND version < ?", event.ID, event.Version).
+		Order("version DESC").Limit(1).Pluck("timelog_uid", &previous).Error
+	if err != nil {
+		return fmt.Errorf("reading version before %s v%d failed: %w", event.ID, event.Version, err)
+	}
+	var starts []time.Time
+	err = scd.AllVersions(ctx, tx, &models.Timelog{}).
+		Where("uid IN ?", append(uids, previous...)).Pluck("time_start", &starts).Error
+	if err != nil {
+		return fmt.Errorf("reading timelogs of %s v%d failed: %w", event.ID, event.Version, err)
+	}
+	return check(tx, event, starts)
+}
+
+// check fails event when any of starts falls in a closed period, unless it
+// is a reasoned adjustment.
+func check(tx *gorm.DB, event scd.VersionEvent, starts []time.Time) error {
+	ctx := tx.Statement.Context
+	for _, start := range starts {
+		p, closed, err := ClosedAt(ctx, tx, start)
+		if err != nil {
+			return err
+		}
+		if !closed {
+			continue
+		}
+		if !isAdjustment(ctx) {
+			return fmt.Errorf("%w: %s %s changes work of %s in period %s", ErrPeriodClosed,
+				event.Table, event.ID, start.Format(time.DateOnly), p.ID)
+		}
+		if scd.ChangeReasonFrom(ctx) == "" {
+			return fmt.Errorf("%w: adjusting %s %s in period %s needs a change reason", ErrPeriodClosed,
+				event.Table, event.ID, p.ID)
+		}
+	}
+	return nil
+}
+
+func entityAs[T any](entity any) (T, bool) {
+	switch e := entity.(type) {
+	case *T:
+		return *e, e != nil
+	case T:
+		return e, true
+	}
+	var zero T
+	return zero, false
+}
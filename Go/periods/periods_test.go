@@ -0,0 +1,59 @@
+package periods_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/periods"
+	"github.com/yourorg/Go/scderr"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestCloseRejectsEmptyRange(t *testing.T) {
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := periods.Close(context.Background(), nil, "2024-01", jan, jan)
+	if !errors.Is(err, periods.ErrInvalidPeriod) {
+		t.Fatalf("Close error = %v, want ErrInvalidPeriod", err)
+	}
+}
+
+func TestErrPeriodClosedIsAConflict(t *testing.T) {
+	err := errors.Join(errors.New("context"), periods.ErrPeriodClosed)
+	if code := scderr.CodeOf(err); code != scderr.Conflict || code.Retryable() {
+		t.Fatalf("CodeOf = %s, want a non-retryable conflict", code)
+	}
+}
+
+func TestGuardRejectsFirstVersionInClosedPeriod(t *testing.T) {
+	jan := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatalf("opening dry-run db: %v", err)
+	}
+	// The timelog billed started on jan, in closed period 2024-01.
+	db.Callback().Query().After("gorm:query").Register("test:fill", func(tx *gorm.DB) {
+		switch dest := tx.Statement.Dest.(type) {
+		case *[]time.Time:
+			*dest = []time.Time{jan}
+		case *periods.Period:
+			*dest, tx.RowsAffected = periods.Period{ID: "2024-01", Status: periods.Closed}, 1
+		}
+	})
+	if err := periods.InstallGuard(db); err != nil {
+		t.Fatal(err)
+	}
+	first := models.PaymentLineItem{Versioned: models.Versioned{ID: "pli-tl1", Version: 1, UID: "uid-1"}, TimelogUID: "tl-uid-1"}
+	if err := db.Create(&first).Error; !errors.Is(err, periods.ErrPeriodClosed) {
+		t.Errorf("creating a line item billing closed-period work: %v, want ErrPeriodClosed", err)
+	}
+	second := first
+	second.Version = 2
+	if err := db.Create(&second).Error; err != nil {
+		t.Errorf("later versions are the handlers' to check, got %v", err)
+	}
+}
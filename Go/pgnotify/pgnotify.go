@@ -0,0 +1,83 @@
+// Package pgnotify wraps Postgres LISTEN/NOTIFY for the GORM connection pool.
+package pgnotify
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+// Notify queues payload on channel. Inside a transaction Postgres delivers it
+// only when the transaction commits, and drops it on rollback.
+func Notify(tx *gorm.DB, channel, payload string) error {
+	return tx.Exec("SELECT pg_notify(?, ?)", channel, payload).Error
+}
+
+// Listen holds a dedicated connection that LISTENs on channel and calls fn for
+// every notification until ctx is cancelled. When the connection drops it
+// reconnects with capped exponential backoff and calls onConnect, if set,
+// after every (re)connect so callers can catch up on anything missed while
+// disconnected; onConnect runs after LISTEN is issued, so nothing slips
+// between the catch-up and live delivery.
+func Listen(ctx context.Context, db *gorm.DB, channel string, onConnect func(context.Context) error, fn func(payload string)) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	backoff := 100 * time.Millisecond
+	for {
+		connected, err := listenOnce(ctx, sqlDB, channel, onConnect, fn)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if connected {
+			backoff = 100 * time.Millisecond
+		}
+		db.Logger.Warn(ctx, "pgnotify: listener on %s lost connection, retrying in %v: %v", channel, backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 10*time.Second)
+	}
+}
+
+// listenOnce runs one LISTEN session and reports whether it got as far as listening.
+func listenOnce(ctx context.Context, sqlDB *sql.DB, channel string, onConnect func(context.Context) error, fn func(string)) (bool, error) {
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	connected := false
+	err = conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("pgnotify: driver connection %T is not pgx", driverConn)
+		}
+		pg := c.Conn()
+		if _, err := pg.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return err
+		}
+		connected = true
+		if onConnect != nil {
+			if err := onConnect(ctx); err != nil {
+				return err
+			}
+		}
+		for {
+			n, err := pg.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			fn(n.Payload)
+		}
+	})
+	return connected, err
+}
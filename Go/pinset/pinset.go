@@ -0,0 +1,165 @@
+// Package pinset records exactly which versions a computation used, such as
+// the job and timelog versions behind a payment run, so the run can be
+// reproduced after newer versions appear.
+package pinset
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yourorg/Go/scd"
+	"github.com/yourorg/Go/scderr"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrExpired is returned when reading a pin set that has been expired.
+var ErrExpired = scderr.New(scderr.NotFound, "pinset: pin set has expired")
+
+// PinSet is a named, immutable selection of versions.
+type PinSet struct {
+	ID        string     `gorm:"primaryKey;column:id"`
+	Name      string     `gorm:"index;column:name"`
+	CreatedAt time.Time  `gorm:"column:created_at"`
+	ExpiredAt *time.Time `gorm:"column:expired_at"`
+}
+
+func (PinSet) TableName() string { return "scd_pin_sets" }
+
+// Member pins one version of one entity.
+type Member struct {
+	PinSetID string `gorm:"primaryKey;column:pin_set_id"`
+	Table    string `gorm:"primaryKey;column:table_name"`
+	EntityID string `gorm:"primaryKey;column:entity_id"`
+	Version  int    `gorm:"column:version"`
+	UID      string `gorm:"index;column:uid"`
+}
+
+func (Member) TableName() string { return "scd_pin_set_members" }
+
+// Migrate creates the pin set tables.
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&PinSet{}, &Member{})
+}
+
+// Create starts an empty pin set.
+func Create(ctx context.Context, db *gorm.DB, name string) (PinSet, error) {
+	set := PinSet{ID: scd.NewChangeSetID(), Name: name}
+	if err := db.WithContext(ctx).Create(&set).Error; err != nil {
+		return set, fmt.Errorf("creating pin set failed: %w", err)
+	}
+	return set, nil
+}
+
+// PinLatest adds the current latest version of each of ids to the pin set.
+// Call it in the same transaction that reads those entities for the run, so
+// the pins match what was used.
+func PinLatest(ctx context.Context, db *gorm.DB, setID string, model any, ids []string) error {
+	info, err := scd.Describe(db, model)
+	if err != nil {
+		return err
+	}
+	db = db.WithContext(ctx)
+	if err := checkLive(db, setID); err != nil {
+		return err
+	}
+	latest := scd.RawLatest(ctx, db, model, "WHERE id IN ?", ids)
+	err = db.Exec(
+		`INSERT INTO scd_pin_set_members (pin_set_id, table_name, entity_id, version, uid)
+		SELECT ?, ?, l.id, l.version, l.uid FROM (?) AS l
+		ON CONFLICT (pin_set_id, table_name, entity_id) DO NOTHING`,
+		setID, info.Table, latest).Error
+	if err != nil {
+		return fmt.Errorf("pinning %s versions failed: %w", info.Table, err)
+	}
+	return nil
+}
+
+// PinUIDs adds specific versions, identified by UID, to the pin set.
+func PinUIDs(ctx context.Context, db *gorm.DB, setID string, model any, uids []string) error {
+	info, err := scd.Describe(db, model)
+	if err != nil {
+		return err
+	}
+	db = db.WithContext(ctx)
+	if err := checkLive(db, setID); err != nil {
+		return err
+	}
+	// Superseded versions may live outside the main table (see scd.Layout).
+	versions := scd.AllVersions(ctx, db, model).Select("id, version, uid").Where("uid IN ?", uids)
+	err = db.Exec(
+		`INSERT INTO scd_pin_set_members (pin_set_id, table_name, entity_id, version, uid)
+		SELECT ?, ?, t.id, t.version, t.uid FROM (?) AS t
+		ON CONFLICT (pin_set_id, table_name, entity_id) DO NOTHING`,
+		setID, info.Table, versions).Error
+	if err != nil {
+		return fmt.Errorf("pinning %s versions failed: %w", info.Table, err)
+	}
+	return nil
+}
+
+// Pinned returns the exact versions of T recorded in the pin set.
+func Pinned[T any](ctx context.Context, db *gorm.DB, setID string) ([]T, error) {
+	var model T
+	info, err := scd.Describe(db, model)
+	if err != nil {
+		return nil, err
+	}
+	db = db.WithContext(ctx)
+	if err := checkLive(db, setID); err != nil {
+		return nil, err
+	}
+	var rows []T
+	err = scd.AllVersions(ctx, db, &model).
+		Joins("JOIN scd_pin_set_members p ON p.uid = ?", scd.Column(info.Table, "uid")).
+		Where("p.pin_set_id = ? AND p.table_name = ?", setID, info.Table).
+		Order(clause.OrderByColumn{Column: scd.Column(info.Table, "id")}).
+		Find(&rows).Error
+	return rows, err
+}
+
+// Get returns a pin set by id, expired or not.
+func Get(ctx context.Context, db *gorm.DB, setID string) (PinSet, error) {
+	var set PinSet
+	err := db.WithContext(ctx).First(&set, "id = ?", setID).Error
+	return set, err
+}
+
+// Expire marks a pin set expired and drops its members. The set row is kept
+// so later reads fail with ErrExpired instead of returning nothing.
+func Expire(ctx context.Context, db *gorm.DB, setID string) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&PinSet{}).Where("id = ?", setID).Update("expired_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Where("pin_set_id = ?", setID).Delete(&Member{}).Error
+	})
+}
+
+// ExpireOlderThan expires every live pin set created before cutoff and returns how many it expired.
+func ExpireOlderThan(ctx context.Context, db *gorm.DB, cutoff time.Time) (int, error) {
+	var ids []string
+	err := db.WithContext(ctx).Model(&PinSet{}).
+		Where("expired_at IS NULL AND created_at < ?", cutoff).Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+	for i, id := range ids {
+		if err := Expire(ctx, db, id); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
+
+func checkLive(db *gorm.DB, setID string) error {
+	set, err := Get(db.Statement.Context, db, setID)
+	if err != nil {
+		return fmt.Errorf("loading pin set %s failed: %w", setID, err)
+	}
+	if set.ExpiredAt != nil {
+		return ErrExpired
+	}
+	return nil
+}
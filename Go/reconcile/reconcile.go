@@ -0,0 +1,164 @@
+// Package reconcile checks payment line items against the timelogs they
+// bill: timelogs without a line item, line items referencing an outdated
+// version of their timelog or job, and amounts differing from what the
+// hours and rates add up to. It reports the discrepancies and can fix them
+// with new versions:
+//
+//	rep, err := reconcile.Run(ctx, db, reconcile.Options{Period: repos.Period{From: jan, To: feb}})
+//	for _, d := range rep.Discrepancies {
+//		fmt.Printf("%s %s: billed %.2f, expected %.2f\n", d.Kind, d.TimelogID, d.Actual, d.Expected)
+//	}
+package reconcile
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/yourorg/Go/payroll"
+	"github.com/yourorg/Go/repos"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+// Kind classifies a discrepancy.
+type Kind string
+
+const (
+	// MissingLineItem is a timelog no line item bills.
+	MissingLineItem Kind = "missing_line_item"
+	// StaleTimelog is a line item referencing, like its adjustments, a
+	// version of its timelog other than the latest.
+	StaleTimelog Kind = "stale_timelog"
+	// StaleJob is a line item referencing a version of its job other than
+	// the one in effect when the work started.
+	StaleJob Kind = "stale_job"
+	// AmountMismatch is a line item billing, with its adjustments, a
+	// different amount than the timelog's hours at its job's rate.
+	AmountMismatch Kind = "amount_mismatch"
+)
+
+// Discrepancy is one way a timelog and its line item disagree.
+type Discrepancy struct {
+	Kind      Kind   `json:"kind"`
+	TimelogID string `json:"timelog_id"`
+	// LineItemID, LineItemVersion and Status describe the line item; they
+	// are empty for MissingLineItem.
+	LineItemID      string `json:"line_item_id,omitempty"`
+	LineItemVersion int    `json:"line_item_version,omitempty"`
+	Status          string `json:"status,omitempty"`
+	// Expected is what payroll prices the timelog at, Actual what the line
+	// item and its adjustments bill.
+	Expected float64 `json:"expected"`
+	Actual   float64 `json:"actual"`
+	// Fixed is set when a Fix run wrote the version resolving it.
+	Fixed bool `json:"fixed,omitempty"`
+}
+
+// Options selects what Run reconciles.
+type Options struct {
+	// ContractorID restricts the run to one contractor (default all).
+	ContractorID string
+	// Period selects the timelogs by the time their work started.
+	Period repos.Period
+	// Fix resolves the discrepancies of pending line items, like
+	// payroll.ComputeLineItems: missing line items are created and stale
+	// ones get a new version. Line items no longer pending are reported as
+	// held instead, to be corrected deliberately.
+	Fix bool
+}
+
+// Report is the outcome of Run.
+type Report struct {
+	ContractorID string    `json:"contractor_id,omitempty"`
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	// Timelogs counts the timelogs checked and Reconciled those whose line
+	// item agrees with them.
+	Timelogs      int           `json:"timelogs"`
+	Reconciled    int           `json:"reconciled"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+	ByKind        map[Kind]int  `json:"by_kind"`
+	// Expected and Billed sum the amounts of the timelogs checked and of
+	// their line items.
+	Expected float64 `json:"expected"`
+	Billed   float64 `json:"billed"`
+	// ChangeSet groups the versions a Fix run wrote; Fixed counts the
+	// timelogs it fixed and Held lists the line items it left alone.
+	ChangeSet string   `json:"change_set,omitempty"`
+	Fixed     int      `json:"fixed"`
+	Held      []string `json:"held,omitempty"`
+}
+
+// Run reconciles the timelogs and line items selected by opts. Without Fix
+// it only reads, in one snapshot (see scd.WithSnapshot); with Fix checks and
+// fixes happen in one transaction and one change set.
+func Run(ctx context.Context, db *gorm.DB, opts Options) (Report, error) {
+	rep := Report{ContractorID: opts.ContractorID, From: opts.Period.From, To: opts.Period.To,
+		Discrepancies: []Discrepancy{}, ByKind: map[Kind]int{}}
+	check := func(tx *gorm.DB) error {
+		priced, err := payroll.Price(ctx, tx, opts.ContractorID, opts.Period)
+		if err != nil {
+			return err
+		}
+		rep.Timelogs = len(priced)
+		for _, p := range priced {
+			rep.Expected += p.Amount
+			rep.Billed += p.Billed()
+			found := Check(p)
+			if len(found) == 0 {
+				rep.Reconciled++
+				continue
+			}
+			if opts.Fix {
+				if p.Item != nil && p.Item.Status != payroll.PendingStatus {
+					rep.Held = append(rep.Held, p.Item.ID)
+				} else {
+					if err := payroll.Apply(ctx, tx, p); err != nil {
+						return err
+					}
+					for i := range found {
+						found[i].Fixed = true
+					}
+					rep.Fixed++
+				}
+			}
+			for _, d := range found {
+				rep.ByKind[d.Kind]++
+			}
+			rep.Discrepancies = append(rep.Discrepancies, found...)
+		}
+		return nil
+	}
+	if !opts.Fix {
+		return rep, scd.WithSnapshot(ctx, db, check)
+	}
+	rep.ChangeSet = scd.NewChangeSetID()
+	ctx = scd.WithChangeSet(ctx, rep.ChangeSet)
+	return rep, db.WithContext(ctx).Transaction(check)
+}
+
+// Check returns the discrepancies between a priced timelog and its line
+// item, none when they agree.
+func Check(p payroll.Priced) []Discrepancy {
+	if p.Item == nil {
+		return []Discrepancy{{Kind: MissingLineItem, TimelogID: p.Timelog.ID, Expected: p.Amount}}
+	}
+	d := Discrepancy{TimelogID: p.Timelog.ID, LineItemID: p.Item.ID, LineItemVersion: p.Item.Version,
+		Status: p.Item.Status, Expected: p.Amount, Actual: p.Billed()}
+	var out []Discrepancy
+	add := func(k Kind) {
+		d.Kind = k
+		out = append(out, d)
+	}
+	if !p.PinsTimelog() {
+		add(StaleTimelog)
+	}
+	if !p.PinsJob() {
+		add(StaleJob)
+	}
+	if math.Abs(p.Billed()-p.Amount) >= 0.005 {
+		add(AmountMismatch)
+	}
+	return out
+}
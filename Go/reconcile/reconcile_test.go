@@ -0,0 +1,81 @@
+package reconcile_test
+
+import (
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/payroll"
+	"github.com/yourorg/Go/reconcile"
+)
+
+func priced(item *models.PaymentLineItem) payroll.Priced {
+	return payroll.Priced{
+		Timelog: models.Timelog{Versioned: models.Versioned{ID: "tl1", Version: 2, UID: "tl1-v2"}, Duration: 3},
+		Job:     models.Job{Versioned: models.Versioned{ID: "job1", Version: 1, UID: "job1-v1"}, Rate: 40},
+		Amount:  120,
+		Item:    item,
+	}
+}
+
+func kinds(ds []reconcile.Discrepancy) []reconcile.Kind {
+	var out []reconcile.Kind
+	for _, d := range ds {
+		out = append(out, d.Kind)
+	}
+	return out
+}
+
+func TestCheck(t *testing.T) {
+	item := func(timelogUID string, amount float64) *models.PaymentLineItem {
+		return &models.PaymentLineItem{Versioned: models.Versioned{ID: "pli-tl1", Version: 1},
+			JobUID: "job1-v1", TimelogUID: timelogUID, Amount: amount, Status: payroll.PendingStatus}
+	}
+	for _, tc := range []struct {
+		name string
+		item *models.PaymentLineItem
+		want []reconcile.Kind
+	}{
+		{"reconciled", item("tl1-v2", 120.001), nil},
+		{"missing", nil, []reconcile.Kind{reconcile.MissingLineItem}},
+		{"stale timelog", item("tl1-v1", 120), []reconcile.Kind{reconcile.StaleTimelog}},
+		{"stale and mismatched", item("tl1-v1", 80), []reconcile.Kind{reconcile.StaleTimelog, reconcile.AmountMismatch}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := priced(tc.item)
+			got := reconcile.Check(p)
+			if len(kinds(got)) != len(tc.want) {
+				t.Fatalf("Check = %v, want %v", kinds(got), tc.want)
+			}
+			for i, k := range tc.want {
+				if got[i].Kind != k || got[i].TimelogID != "tl1" || got[i].Expected != 120 {
+					t.Errorf("discrepancy %d = %+v, want kind %s", i, got[i], k)
+				}
+			}
+			if p.UpToDate() != (len(got) == 0) {
+				t.Errorf("UpToDate = %v disagrees with %d discrepancies", p.UpToDate(), len(got))
+			}
+		})
+	}
+}
+
+func TestCheckStaleJob(t *testing.T) {
+	p := priced(&models.PaymentLineItem{Versioned: models.Versioned{ID: "pli-tl1", Version: 1},
+		JobUID: "job1-v0", TimelogUID: "tl1-v2", Amount: 120})
+	got := reconcile.Check(p)
+	if len(got) != 1 || got[0].Kind != reconcile.StaleJob || got[0].LineItemID != "pli-tl1" {
+		t.Fatalf("Check = %+v, want one stale_job", got)
+	}
+}
+
+func TestCheckCountsAdjustments(t *testing.T) {
+	p := priced(&models.PaymentLineItem{Versioned: models.Versioned{ID: "pli-tl1", Version: 2, UID: "pli-v2"},
+		JobUID: "job1-v1", TimelogUID: "tl1-v1", Amount: 80, Status: "paid"})
+	p.Adjustments = []models.PaymentLineItem{{Versioned: models.Versioned{ID: "pli-tl1-adj-1", Version: 1},
+		JobUID: "job1-v1", TimelogUID: "tl1-v2", Amount: 40, Status: payroll.PendingStatus, CorrectsUID: "pli-v2"}}
+	if got := reconcile.Check(p); len(got) != 0 {
+		t.Fatalf("Check = %+v, want the adjustment to reconcile the line item", got)
+	}
+	if p.Billed() != 120 || !p.UpToDate() {
+		t.Errorf("Billed = %.2f, UpToDate = %v", p.Billed(), p.UpToDate())
+	}
+}
@@ -1,16 +1,97 @@
 package repos
 
 import (
+	"context"
+	"time"
+
 	"github.com/yourorg/Go/scd"
 	"gorm.io/gorm"
 )
 
-// LatestSubquery returns a subquery selecting id, MAX(version) grouped by id for the given model.
-func LatestSubquery[T any](db *gorm.DB, model T) *gorm.DB {
-	return scd.LatestSubquery(db, model)
+// LatestSubquery returns scd.LiveSubquery: a subquery selecting the id and latest version
+// (as max_version) of every live entity of the given model. Soft-deleted entities are
+// left out unless ctx comes from scd.IncludeDeleted, and with scd.Config.Quarantine
+// so are quarantined ones unless it comes from scd.IncludeQuarantined.
+func LatestSubquery[T any](ctx context.Context, db *gorm.DB, model T) *gorm.DB {
+	return scd.LiveSubquery(ctx, db, model)
+}
+
+// Latest returns a scope restricting a query of T's table to the latest live version of each entity.
+func Latest[T any]() func(*gorm.DB) *gorm.DB {
+	return scd.Latest[T]()
+}
+
+// AsOfSubquery returns a subquery selecting id, MAX(version) among versions created at or before t.
+func AsOfSubquery[T any](ctx context.Context, db *gorm.DB, model T, t time.Time) *gorm.DB {
+	return scd.AsOfSubquery(ctx, db, model, t)
+}
+
+// ResolveAsOf returns, for each pair, its entity as it was at the pair's time, resolving all pairs in a few statements.
+func ResolveAsOf[T any](ctx context.Context, db *gorm.DB, pairs []scd.AsOfPair) ([]T, error) {
+	return scd.ResolveAsOf[T](ctx, db, pairs)
+}
+
+// EffectiveAt returns a scope restricting a query of T's table to the version of each entity in effect at business time t.
+func EffectiveAt[T any](t time.Time) func(*gorm.DB) *gorm.DB {
+	return scd.EffectiveAt[T](t)
 }
 
 // CreateNewSCDVersion creates a new SCD version for the given id and applies the updateFn.
-func CreateNewSCDVersion[T any](db *gorm.DB, id string, updateFn func(*T)) error {
-	return scd.CreateNewSCDVersion(db, id, updateFn)
+func CreateNewSCDVersion[T any, P scd.EntityPtr[T]](ctx context.Context, db *gorm.DB, id string, updateFn func(*T) error) error {
+	return scd.CreateNewSCDVersion[T, P](ctx, db, id, updateFn)
+}
+
+// CreateNewSCDVersionIf creates a new SCD version only if the latest version is still expectedVersion.
+func CreateNewSCDVersionIf[T any, P scd.EntityPtr[T]](ctx context.Context, db *gorm.DB, id string, expectedVersion int, updateFn func(*T) error) error {
+	return scd.CreateNewSCDVersionIf[T, P](ctx, db, id, expectedVersion, updateFn)
+}
+
+// CreateNewSCDVersionReturning creates a new SCD version and returns the previous and the created record.
+func CreateNewSCDVersionReturning[T any, P scd.EntityPtr[T]](ctx context.Context, db *gorm.DB, id string, updateFn func(*T) error) (previous, created T, err error) {
+	return scd.CreateNewSCDVersionReturning[T, P](ctx, db, id, updateFn)
+}
+
+// GetHistory returns the versions of an entity in version order.
+func GetHistory[T any](ctx context.Context, db *gorm.DB, id string, opts scd.HistoryOptions) ([]T, error) {
+	return scd.GetHistory[T](ctx, db, id, opts)
+}
+
+// SampleLatest returns a uniform random sample of up to n latest versions matching filter.
+func SampleLatest[T any](ctx context.Context, db *gorm.DB, n int, filter string, args ...any) ([]T, error) {
+	return scd.SampleLatest[T](ctx, db, n, filter, args...)
+}
+
+// GetLatest returns the latest version of an entity.
+func GetLatest[T any](ctx context.Context, db *gorm.DB, id string) (T, error) {
+	return scd.GetLatest[T](ctx, db, id)
+}
+
+// GetVersion returns one specific version of an entity.
+func GetVersion[T any](ctx context.Context, db *gorm.DB, id string, version int) (T, error) {
+	return scd.GetVersion[T](ctx, db, id, version)
+}
+
+// GetByUID returns the version identified by uid.
+func GetByUID[T any](ctx context.Context, db *gorm.DB, uid string) (T, error) {
+	return scd.GetByUID[T](ctx, db, uid)
+}
+
+// Diff returns the fields that changed between two versions of an entity.
+func Diff[T any](ctx context.Context, db *gorm.DB, id string, v1, v2 int) ([]scd.FieldChange, error) {
+	return scd.Diff[T](ctx, db, id, v1, v2)
+}
+
+// RevertToVersion appends a copy of an earlier version as the new latest version.
+func RevertToVersion[T any, P scd.EntityPtr[T]](ctx context.Context, db *gorm.DB, id string, version int) (T, error) {
+	return scd.RevertToVersion[T, P](ctx, db, id, version)
+}
+
+// DeleteAsNewVersion soft-deletes an entity by appending a tombstone version.
+func DeleteAsNewVersion[T any, P scd.EntityPtr[T]](ctx context.Context, db *gorm.DB, id string) error {
+	return scd.DeleteAsNewVersion[T, P](ctx, db, id)
+}
+
+// Restore undeletes an entity soft-deleted with DeleteAsNewVersion.
+func Restore[T any, P scd.EntityPtr[T]](ctx context.Context, db *gorm.DB, id string) error {
+	return scd.Restore[T, P](ctx, db, id)
 }
@@ -1,7 +1,13 @@
 package repos
 
 import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/yourorg/Go/dedup"
 	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
 	"gorm.io/gorm"
 )
 
@@ -9,22 +15,128 @@ type JobRepo struct {
 	DB *gorm.DB
 }
 
-func (r *JobRepo) FindActiveJobsByCompany(companyID string) ([]models.Job, error) {
-	var jobs []models.Job
-	subq := LatestSubquery(r.DB, models.Job{})
-	err := r.DB.Model(&models.Job{}).
-		Joins("JOIN (?) AS latest ON jobs.id = latest.id AND jobs.version = latest.max_version", subq).
-		Where("jobs.status = ? AND jobs.company_id = ?", "active", companyID).
-		Find(&jobs).Error
-	return jobs, err
-}
-
-func (r *JobRepo) FindActiveJobsByContractor(contractorID string) ([]models.Job, error) {
-	var jobs []models.Job
-	subq := LatestSubquery(r.DB, models.Job{})
-	err := r.DB.Model(&models.Job{}).
-		Joins("JOIN (?) AS latest ON jobs.id = latest.id AND jobs.version = latest.max_version", subq).
-		Where("jobs.status = ? AND jobs.contractor_id = ?", "active", contractorID).
-		Find(&jobs).Error
-	return jobs, err
+// Versions returns the generic repository of jobs, for reads and writes
+// JobRepo has no method of its own for.
+func (r *JobRepo) Versions() *SCDRepo[models.Job, *models.Job] {
+	return NewSCDRepo[models.Job](r.DB)
+}
+
+func (r *JobRepo) FindActiveJobsByCompany(ctx context.Context, companyID string, opts ...QueryOption) ([]models.Job, error) {
+	return r.Versions().Find(ctx, append([]QueryOption{WithFilters(Eq("status", models.JobActive), Eq("company_id", companyID))}, opts...)...)
+}
+
+func (r *JobRepo) FindActiveJobsByContractor(ctx context.Context, contractorID string, opts ...QueryOption) ([]models.Job, error) {
+	return r.Versions().Find(ctx, append([]QueryOption{WithFilters(Eq("status", models.JobActive), Eq("contractor_id", contractorID))}, opts...)...)
+}
+
+// FindJobAsOf returns job id as it was at time at.
+func (r *JobRepo) FindJobAsOf(ctx context.Context, id string, at time.Time) (models.Job, error) {
+	var job models.Job
+	subq := AsOfSubquery(ctx, r.DB, models.Job{}, at)
+	err := scd.JoinVersions(scd.AllVersions(ctx, r.DB, &models.Job{}), "jobs", "latest", subq).
+		Where("jobs.id = ?", id).
+		Take(&job).Error
+	return job, scd.Translate(err)
+}
+
+// FindJobEffectiveAt returns the version of job id in effect at business
+// time at, e.g. the rate that applies to work done that day.
+func (r *JobRepo) FindJobEffectiveAt(ctx context.Context, id string, at time.Time) (models.Job, error) {
+	var job models.Job
+	err := r.DB.WithContext(ctx).Model(&models.Job{}).
+		Scopes(EffectiveAt[models.Job](at)).
+		Where("jobs.id = ?", id).
+		Take(&job).Error
+	return job, scd.Translate(err)
+}
+
+// ScheduleJobChange records a change to job id now that takes effect at effective.
+func (r *JobRepo) ScheduleJobChange(ctx context.Context, id string, effective time.Time, updateFn func(*models.Job) error) (models.Job, error) {
+	return scd.ScheduleVersion[models.Job](ctx, r.DB, id, effective, updateFn)
+}
+
+// ScheduleJobRateChange enters a new rate for job id ahead of time: reads keep
+// returning the current rate until effective, when the activator writes it.
+func (r *JobRepo) ScheduleJobRateChange(ctx context.Context, id string, rate float64, effective time.Time) (scd.ScheduledChange, error) {
+	patch, err := json.Marshal(map[string]any{"rate": rate})
+	if err != nil {
+		return scd.ScheduledChange{}, err
+	}
+	change, err := scd.ScheduleChange(ctx, r.DB, models.Job{}, id, effective, patch)
+	return change, scd.Translate(err)
+}
+
+// ProposeJobRateChange submits a new rate for job id for approval; the job
+// keeps its current rate until ApproveJobRateChange. It returns the pending
+// version, whose number identifies it for approval.
+func (r *JobRepo) ProposeJobRateChange(ctx context.Context, id string, rate float64) (models.Job, error) {
+	draft, err := scd.SaveDraft[models.Job](ctx, r.DB, id, func(j *models.Job) error {
+		j.Rate = rate
+		return nil
+	})
+	if err != nil {
+		return draft, err
+	}
+	if err := scd.SubmitDraft(ctx, r.DB, models.Job{}, id, draft.Version); err != nil {
+		return draft, err
+	}
+	draft.VersionState = scd.StatePendingApproval
+	return draft, nil
+}
+
+// ApproveJobRateChange puts the proposed version of job id into effect. The
+// approver is the actor of ctx (see scd.WithActor) and must not be the one
+// who proposed the change.
+func (r *JobRepo) ApproveJobRateChange(ctx context.Context, id string, version int) error {
+	return scd.ApproveVersion(ctx, r.DB, models.Job{}, id, version)
+}
+
+// RejectJobRateChange sends the proposed version of job id back as a draft.
+func (r *JobRepo) RejectJobRateChange(ctx context.Context, id string, version int) error {
+	return scd.RejectVersion(ctx, r.DB, models.Job{}, id, version)
+}
+
+// TagJobs tags the latest versions of ids, e.g. with the name of the payroll
+// run about to read them, so the run can later be repeated on the same data.
+func (r *JobRepo) TagJobs(ctx context.Context, tag string, ids []string) error {
+	return scd.TagLatest(ctx, r.DB, models.Job{}, tag, ids)
+}
+
+// FindJobAtTag returns the version of job id carrying tag.
+func (r *JobRepo) FindJobAtTag(ctx context.Context, id, tag string) (models.Job, error) {
+	return scd.GetByTag[models.Job](ctx, r.DB, id, tag)
+}
+
+// FindJobsAtTag returns every job version carrying tag, by id.
+func (r *JobRepo) FindJobsAtTag(ctx context.Context, tag string) ([]models.Job, error) {
+	return scd.TaggedVersions[models.Job](ctx, r.DB, tag)
+}
+
+// ResolveJobsAsOf returns the job version effective at each pair's time, in
+// the order of pairs; jobs not yet created by then come back with an empty ID.
+func (r *JobRepo) ResolveJobsAsOf(ctx context.Context, pairs []scd.AsOfPair) ([]models.Job, error) {
+	return ResolveAsOf[models.Job](ctx, r.DB, pairs)
+}
+
+// RevertJob undoes changes to job id by re-publishing the given earlier version.
+func (r *JobRepo) RevertJob(ctx context.Context, id string, version int) (models.Job, error) {
+	return RevertToVersion[models.Job](ctx, r.DB, id, version)
+}
+
+// JobDuplicates matches imported jobs by title within a company and
+// contractor, including titles the jobs had during the last quarter.
+var JobDuplicates = dedup.Config{
+	Model:   models.Job{},
+	Fields:  []dedup.Field{{Column: "title", Weight: 2}, {Column: "company_id", Exact: true}, {Column: "contractor_id", Exact: true}},
+	History: 90 * 24 * time.Hour,
+}
+
+// FindDuplicateJobs returns the jobs that job, about to be imported, likely
+// duplicates, best match first.
+func (r *JobRepo) FindDuplicateJobs(ctx context.Context, job models.Job) ([]dedup.Candidate, error) {
+	d, err := dedup.New(r.DB, JobDuplicates)
+	if err != nil {
+		return nil, err
+	}
+	return d.Find(ctx, map[string]string{"title": job.Title, "company_id": job.CompanyID, "contractor_id": job.ContractorID})
 }
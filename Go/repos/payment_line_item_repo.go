@@ -1,8 +1,12 @@
 package repos
 
 import (
+	"context"
+	"fmt"
 	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
 	"gorm.io/gorm"
+	"math"
 	"time"
 )
 
@@ -10,14 +14,183 @@ type PaymentLineItemRepo struct {
 	DB *gorm.DB
 }
 
-func (r *PaymentLineItemRepo) FindLineItemsByContractorAndPeriod(contractorID string, from, to time.Time) ([]models.PaymentLineItem, error) {
+// Versions returns the generic repository of line items, for reads and
+// writes PaymentLineItemRepo has no method of its own for.
+func (r *PaymentLineItemRepo) Versions() *SCDRepo[models.PaymentLineItem, *models.PaymentLineItem] {
+	return NewSCDRepo[models.PaymentLineItem](r.DB)
+}
+
+func (r *PaymentLineItemRepo) FindLineItemsByContractorAndPeriod(ctx context.Context, contractorID string, from, to time.Time, opts ...QueryOption) ([]models.PaymentLineItem, error) {
+	q, _, err := queryOptions(opts).apply(r.DB.WithContext(ctx).Model(&models.PaymentLineItem{}).
+		Joins("JOIN timelogs ON payment_line_items.timelog_uid = timelogs.uid").
+		Joins("JOIN jobs ON payment_line_items.job_uid = jobs.uid").
+		Scopes(Latest[models.PaymentLineItem]()).
+		Where("jobs.contractor_id = ? AND timelogs.time_start >= ? AND timelogs.time_end <= ?", contractorID, from, to),
+		&models.PaymentLineItem{})
+	if err != nil {
+		return nil, err
+	}
 	var items []models.PaymentLineItem
-	subq := LatestSubquery(r.DB, models.PaymentLineItem{})
-	err := r.DB.Model(&models.PaymentLineItem{}).
+	err = q.Find(&items).Error
+	return items, scd.Translate(err)
+}
+
+// Period is a half-open time range [From, To).
+type Period struct {
+	From, To time.Time
+}
+
+// Page selects one page of a listing ordered by entity id: up to Limit rows
+// with an id greater than AfterID (empty for the first page).
+type Page struct {
+	Limit   int
+	AfterID string
+}
+
+// FindByStatusAndCompany returns latest line items in status for jobs of
+// companyID whose timelog started within period, ordered by id.
+func (r *PaymentLineItemRepo) FindByStatusAndCompany(ctx context.Context, status, companyID string, period Period, page Page, opts ...QueryOption) ([]models.PaymentLineItem, error) {
+	q, _, err := queryOptions(append([]QueryOption{WithLimit(page.Limit)}, opts...)).apply(r.DB.WithContext(ctx).Model(&models.PaymentLineItem{}).
 		Joins("JOIN timelogs ON payment_line_items.timelog_uid = timelogs.uid").
 		Joins("JOIN jobs ON payment_line_items.job_uid = jobs.uid").
-		Joins("JOIN (?) AS latest ON payment_line_items.id = latest.id AND payment_line_items.version = latest.max_version", subq).
-		Where("jobs.contractor_id = ? AND timelogs.time_start >= ? AND timelogs.time_end <= ?", contractorID, from, to).
-		Find(&items).Error
-	return items, err
+		Scopes(Latest[models.PaymentLineItem]()).
+		Where("payment_line_items.status = ? AND jobs.company_id = ?", status, companyID).
+		Where("timelogs.time_start >= ? AND timelogs.time_start < ?", period.From, period.To).
+		Where("payment_line_items.id > ?", page.AfterID),
+		&models.PaymentLineItem{})
+	if err != nil {
+		return nil, err
+	}
+	var items []models.PaymentLineItem
+	err = q.Find(&items).Error
+	return items, scd.Translate(err)
+}
+
+// FindPendingOlderThan returns latest line items that have been pending for
+// longer than age, i.e. whose latest version is pending and was written more
+// than age ago, oldest first.
+func (r *PaymentLineItemRepo) FindPendingOlderThan(ctx context.Context, age time.Duration, opts ...QueryOption) ([]models.PaymentLineItem, error) {
+	q, _, err := queryOptions(opts).apply(r.DB.WithContext(ctx).Model(&models.PaymentLineItem{}).
+		Scopes(Latest[models.PaymentLineItem]()).
+		Where("payment_line_items.status = ? AND payment_line_items.created_at < ?", "pending", time.Now().Add(-age)),
+		&models.PaymentLineItem{}, Asc("created_at"))
+	if err != nil {
+		return nil, err
+	}
+	var items []models.PaymentLineItem
+	err = q.Find(&items).Error
+	return items, scd.Translate(err)
+}
+
+// CurrentJobStatuses returns the status of the current latest version of the
+// job each of the line items ids was computed against, by line item id, even
+// when the line item pins an older version of the job.
+func (r *PaymentLineItemRepo) CurrentJobStatuses(ctx context.Context, ids []string) (map[string]string, error) {
+	var rows []struct{ ID, Status string }
+	err := r.DB.WithContext(ctx).Model(&models.PaymentLineItem{}).
+		Scopes(Latest[models.PaymentLineItem](), scd.JoinLatestOf[models.Job]("payment_line_items.job_uid", "job")).
+		Where("payment_line_items.id IN ?", ids).
+		Select("payment_line_items.id, COALESCE(job.status, '') AS status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, scd.Translate(err)
+	}
+	out := make(map[string]string, len(rows))
+	for _, row := range rows {
+		out[row.ID] = row.Status
+	}
+	return out, nil
+}
+
+// AmountCorrection is a line item whose amount no longer matches its
+// timelog's hours at the job rate in effect when the work started, e.g.
+// after a retroactive rate change.
+type AmountCorrection struct {
+	LineItemID string
+	// Version is the line item's latest version the correction was planned on.
+	Version   int
+	OldAmount float64
+	NewAmount float64
+	Hours     float64
+	Rate      float64
+	// JobUID and TimelogUID are the versions NewAmount was computed from,
+	// which the corrective version pins.
+	JobUID     string
+	TimelogUID string
+}
+
+// PlanRecompute re-resolves the amounts of the latest line items of
+// companyID's jobs whose latest timelog started within period: hours of the
+// latest timelog version times the rate of the job version in effect at the
+// timelog's start (see scd.EffectiveAt). Adjustments, which bill differences
+// rather than timelogs (see payroll.Correct), are left out. It returns the
+// line items whose amount changed by a cent or more, by id, without writing
+// anything.
+func (r *PaymentLineItemRepo) PlanRecompute(ctx context.Context, companyID string, period Period) ([]AmountCorrection, error) {
+	var out []AmountCorrection
+	// One snapshot, so rates and line items are read as of the same moment.
+	err := scd.WithSnapshot(ctx, r.DB, func(tx *gorm.DB) error {
+		var rows []struct {
+			ID         string
+			Version    int
+			Amount     float64
+			JobID      string
+			TimelogUID string
+			Duration   float64
+			TimeStart  time.Time
+		}
+		err := tx.Model(&models.PaymentLineItem{}).
+			Scopes(Latest[models.PaymentLineItem](),
+				scd.JoinLatestOf[models.Timelog]("payment_line_items.timelog_uid", "tl"),
+				scd.JoinLatestOf[models.Job]("payment_line_items.job_uid", "job")).
+			Where("job.company_id = ? AND NOT tl.is_deleted", companyID).
+			Where("tl.time_start >= ? AND tl.time_start < ?", period.From, period.To).
+			Where("COALESCE(payment_line_items.corrects_uid, '') = ''").
+			Select("payment_line_items.id, payment_line_items.version, payment_line_items.amount, job.id AS job_id, tl.uid AS timelog_uid, tl.duration, tl.time_start").
+			Order("payment_line_items.id").
+			Scan(&rows).Error
+		if err != nil {
+			return scd.Translate(err)
+		}
+		for _, row := range rows {
+			var job models.Job
+			err := tx.Scopes(scd.EffectiveAt[models.Job](row.TimeStart)).Where("jobs.id = ?", row.JobID).Take(&job).Error
+			if err != nil {
+				return fmt.Errorf("resolving rate of line item %s failed: %w", row.ID, scd.Translate(err))
+			}
+			amount := row.Duration * job.Rate
+			if math.Abs(amount-row.Amount) < 0.005 {
+				continue
+			}
+			out = append(out, AmountCorrection{LineItemID: row.ID, Version: row.Version, OldAmount: row.Amount, NewAmount: amount,
+				Hours: row.Duration, Rate: job.Rate, JobUID: job.UID, TimelogUID: row.TimelogUID})
+		}
+		return nil
+	})
+	return out, err
+}
+
+// ApplyCorrections writes a corrective version of every line item in
+// corrections, in one transaction and one change set, whose id it returns.
+// It fails without writing anything when a line item gained a version since
+// the corrections were planned.
+func (r *PaymentLineItemRepo) ApplyCorrections(ctx context.Context, corrections []AmountCorrection) (string, error) {
+	changeSet := scd.NewChangeSetID()
+	ctx = scd.WithChangeSet(ctx, changeSet)
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, c := range corrections {
+			err := CreateNewSCDVersionIf[models.PaymentLineItem](ctx, tx, c.LineItemID, c.Version, func(p *models.PaymentLineItem) error {
+				p.Amount, p.JobUID, p.TimelogUID = c.NewAmount, c.JobUID, c.TimelogUID
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("correcting line item %s failed: %w", c.LineItemID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return changeSet, nil
 }
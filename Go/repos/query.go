@@ -0,0 +1,249 @@
+package repos
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/yourorg/Go/scd"
+	"github.com/yourorg/Go/scderr"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrUnknownColumn is returned when query options filter or order on a
+// column the repository's model does not have.
+var ErrUnknownColumn = scderr.New(scderr.Validation, "repos: unknown column")
+
+// ErrInvalidCursor is returned for a cursor that is malformed or was issued
+// for a listing in another order.
+var ErrInvalidCursor = scderr.New(scderr.Validation, "repos: invalid cursor")
+
+// QueryOptions narrow and page the results of the Find methods returning
+// lists: only rows matching all of Filters, ordered by OrderBy, then by id
+// so pages are stable, skipping Offset rows and returning at most Limit of
+// them when it is set. Filters and OrderBy name columns of the model the
+// repository returns, which are checked against the model's schema, so the
+// options can be taken from API requests as they are.
+//
+// After is the alternative to Offset for deep pages: a cursor returned
+// with the previous page (see SCDRepo.FindPage), which starts the page
+// right after that page's last row instead of counting rows up to it.
+type QueryOptions struct {
+	Limit   int
+	Offset  int
+	After   string
+	OrderBy []Order
+	Filters []Filter
+}
+
+// Order orders results by Column, descending when Desc is set.
+type Order struct {
+	Column string
+	Desc   bool
+}
+
+// Asc and Desc order by column.
+func Asc(column string) Order  { return Order{Column: column} }
+func Desc(column string) Order { return Order{Column: column, Desc: true} }
+
+// QueryOption sets QueryOptions, e.g. WithLimit(50).
+type QueryOption func(*QueryOptions)
+
+// WithLimit returns at most n rows, WithOffset skips the first n.
+func WithLimit(n int) QueryOption  { return func(o *QueryOptions) { o.Limit = n } }
+func WithOffset(n int) QueryOption { return func(o *QueryOptions) { o.Offset = n } }
+
+// WithCursor starts the page after cursor, as returned with the previous one.
+func WithCursor(cursor string) QueryOption { return func(o *QueryOptions) { o.After = cursor } }
+
+// WithOrder orders by orders before the method's own order, if any.
+func WithOrder(orders ...Order) QueryOption {
+	return func(o *QueryOptions) { o.OrderBy = append(o.OrderBy, orders...) }
+}
+
+// WithFilters adds filters to those of the method.
+func WithFilters(filters ...Filter) QueryOption {
+	return func(o *QueryOptions) { o.Filters = append(o.Filters, filters...) }
+}
+
+// WithOptions applies all of options, e.g. as parsed from a request.
+func WithOptions(options QueryOptions) QueryOption {
+	return func(o *QueryOptions) {
+		if options.Limit > 0 {
+			o.Limit = options.Limit
+		}
+		if options.Offset > 0 {
+			o.Offset = options.Offset
+		}
+		if options.After != "" {
+			o.After = options.After
+		}
+		o.OrderBy = append(o.OrderBy, options.OrderBy...)
+		o.Filters = append(o.Filters, options.Filters...)
+	}
+}
+
+func queryOptions(opts []QueryOption) QueryOptions {
+	var o QueryOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// apply applies o to q, a query of model: its filters, the page its cursor
+// starts, then its order followed by defaultOrder, itself followed by id,
+// and its offset and limit. The returned keyset issues the cursors of the
+// rows read.
+func (o QueryOptions) apply(q *gorm.DB, model any, defaultOrder ...Order) (*gorm.DB, keyset, error) {
+	q, table, stmt, err := o.filter(q, model)
+	if err != nil {
+		return nil, keyset{}, err
+	}
+	keys := keyset{table: table, stmt: stmt}
+	seen := map[string]bool{}
+	for _, ord := range append(append(o.OrderBy[:len(o.OrderBy):len(o.OrderBy)], defaultOrder...), Asc("id")) {
+		if seen[ord.Column] {
+			continue
+		}
+		if err := checkColumn(stmt, table, ord.Column); err != nil {
+			return nil, keyset{}, err
+		}
+		seen[ord.Column] = true
+		keys.orders = append(keys.orders, ord)
+		if ord.Column == "id" {
+			break
+		}
+	}
+	if o.After != "" {
+		if o.Offset > 0 {
+			return nil, keyset{}, fmt.Errorf("%w: a cursor and an offset cannot both be set", ErrInvalidCursor)
+		}
+		after, err := keys.after(o.After)
+		if err != nil {
+			return nil, keyset{}, err
+		}
+		q = q.Where(after)
+	}
+	for _, ord := range keys.orders {
+		q = q.Order(clause.OrderByColumn{Column: scd.Column(table, ord.Column), Desc: ord.Desc})
+	}
+	if o.Offset > 0 {
+		q = q.Offset(o.Offset)
+	}
+	if o.Limit > 0 {
+		q = q.Limit(o.Limit)
+	}
+	return q, keys, nil
+}
+
+// keyset is the order of a listing of table, by unique keys since it ends
+// with id.
+type keyset struct {
+	table  string
+	stmt   *gorm.Statement
+	orders []Order
+}
+
+// cursorOf is what a cursor encodes: the order it was issued for and the
+// values of its columns on the last row of the page.
+type cursorOf struct {
+	Order  string `json:"o"`
+	Values []any  `json:"v"`
+}
+
+func (k keyset) String() string {
+	parts := make([]string, len(k.orders))
+	for i, ord := range k.orders {
+		parts[i] = ord.Column
+		if ord.Desc {
+			parts[i] += " DESC"
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// cursor returns the cursor of the page following the one ending in row, a
+// value of the listed model.
+func (k keyset) cursor(ctx context.Context, row any) (string, error) {
+	c := cursorOf{Order: k.String()}
+	rv := reflect.Indirect(reflect.ValueOf(row))
+	for _, ord := range k.orders {
+		v, _ := k.stmt.Schema.LookUpField(ord.Column).ValueOf(ctx, rv)
+		c.Values = append(c.Values, v)
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("encoding cursor failed: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// after returns the condition that a row comes after the row cursor was
+// issued for: greater (or less, descending) on the first column, or equal
+// on it and after on the next, and so on. It is a condition on the rows
+// the query returns, i.e. applied after the latest-version join, so the
+// order values compared are those of the latest versions. NULLs compare
+// with nothing, so page by columns that are never NULL.
+func (k keyset) after(cursor string) (clause.Expression, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	var c cursorOf
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if c.Order != k.String() || len(c.Values) != len(k.orders) {
+		return nil, fmt.Errorf("%w: issued for order %q, not %q", ErrInvalidCursor, c.Order, k.String())
+	}
+	var or []clause.Expression
+	for i, ord := range k.orders {
+		and := make([]clause.Expression, 0, i+1)
+		for j := range i {
+			and = append(and, clause.Eq{Column: scd.Column(k.table, k.orders[j].Column), Value: c.Values[j]})
+		}
+		col := scd.Column(k.table, ord.Column)
+		if ord.Desc {
+			and = append(and, clause.Lt{Column: col, Value: c.Values[i]})
+		} else {
+			and = append(and, clause.Gt{Column: col, Value: c.Values[i]})
+		}
+		or = append(or, clause.And(and...))
+	}
+	return clause.Or(or...), nil
+}
+
+// filter applies the filters of o to q, a query of model, and returns the
+// model's table and parsed statement.
+func (o QueryOptions) filter(q *gorm.DB, model any) (*gorm.DB, string, *gorm.Statement, error) {
+	stmt := &gorm.Statement{DB: q}
+	if err := stmt.Parse(model); err != nil {
+		return nil, "", nil, fmt.Errorf("parsing %T failed: %w", model, err)
+	}
+	table := stmt.Schema.Table
+	for _, f := range o.Filters {
+		if err := checkColumn(stmt, table, f.Column); err != nil {
+			return nil, "", nil, err
+		}
+		expr, err := f.expression(table)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		q = q.Where(expr)
+	}
+	return q, table, stmt, nil
+}
+
+// checkColumn fails with ErrUnknownColumn unless stmt's model, of table,
+// has column.
+func checkColumn(stmt *gorm.Statement, table, column string) error {
+	if f := stmt.Schema.LookUpField(column); f != nil && f.DBName == column {
+		return nil
+	}
+	return fmt.Errorf("%w: %s has no column %q", ErrUnknownColumn, table, column)
+}
@@ -0,0 +1,182 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SCDRepo is the repository of the versioned model T, reading its latest
+// versions and writing new ones without query code of its own:
+//
+//	jobs := repos.NewSCDRepo[models.Job](db)
+//	active, err := jobs.FindLatest(ctx, repos.Eq("status", models.JobActive), repos.Eq("company_id", "c1"))
+//
+// Model-specific repositories such as JobRepo wrap one and add the queries
+// that join other tables.
+type SCDRepo[T any, P scd.EntityPtr[T]] struct {
+	DB *gorm.DB
+}
+
+// NewSCDRepo returns the repository of T on db.
+func NewSCDRepo[T any, P scd.EntityPtr[T]](db *gorm.DB) *SCDRepo[T, P] {
+	return &SCDRepo[T, P]{DB: db}
+}
+
+// Filter is a condition on a column of a repository's table; see Eq and
+// the other constructors.
+type Filter struct {
+	Column string
+	Op     string
+	Value  any
+}
+
+// Eq, Neq, Gt, Gte, Lt, Lte and Like compare column with value.
+func Eq(column string, value any) Filter   { return Filter{column, "=", value} }
+func Neq(column string, value any) Filter  { return Filter{column, "<>", value} }
+func Gt(column string, value any) Filter   { return Filter{column, ">", value} }
+func Gte(column string, value any) Filter  { return Filter{column, ">=", value} }
+func Lt(column string, value any) Filter   { return Filter{column, "<", value} }
+func Lte(column string, value any) Filter  { return Filter{column, "<=", value} }
+func Like(column string, value any) Filter { return Filter{column, "LIKE", value} }
+
+// In matches column against any of values.
+func In[V any](column string, values []V) Filter {
+	vs := make([]any, len(values))
+	for i, v := range values {
+		vs[i] = v
+	}
+	return Filter{column, "IN", vs}
+}
+
+// expression returns f on the columns of table, the column quoted by the
+// dialect.
+func (f Filter) expression(table string) (clause.Expression, error) {
+	col := scd.Column(table, f.Column)
+	switch f.Op {
+	case "=":
+		return clause.Eq{Column: col, Value: f.Value}, nil
+	case "<>":
+		return clause.Neq{Column: col, Value: f.Value}, nil
+	case ">":
+		return clause.Gt{Column: col, Value: f.Value}, nil
+	case ">=":
+		return clause.Gte{Column: col, Value: f.Value}, nil
+	case "<":
+		return clause.Lt{Column: col, Value: f.Value}, nil
+	case "<=":
+		return clause.Lte{Column: col, Value: f.Value}, nil
+	case "LIKE":
+		return clause.Like{Column: col, Value: f.Value}, nil
+	case "IN":
+		values, _ := f.Value.([]any)
+		return clause.IN{Column: col, Values: values}, nil
+	}
+	return nil, fmt.Errorf("filtering %s on %s failed: unknown operator %q", table, f.Column, f.Op)
+}
+
+// latest returns the query of the latest live versions of T matching
+// filters, and T's table.
+func (r *SCDRepo[T, P]) latest(ctx context.Context, filters []Filter) (*gorm.DB, string, error) {
+	var model T
+	q := r.DB.WithContext(ctx).Model(&model).Scopes(Latest[T]())
+	q, table, _, err := QueryOptions{Filters: filters}.filter(q, &model)
+	return q, table, err
+}
+
+// Find returns the latest live version of every entity matching opts,
+// ordered by id unless they order otherwise.
+func (r *SCDRepo[T, P]) Find(ctx context.Context, opts ...QueryOption) ([]T, error) {
+	var model T
+	q, _, err := queryOptions(opts).apply(r.DB.WithContext(ctx).Model(&model).Scopes(Latest[T]()), &model)
+	if err != nil {
+		return nil, err
+	}
+	var out []T
+	err = q.Find(&out).Error
+	return out, scd.Translate(err)
+}
+
+// FindPage is Find for one page of at most the options' limit, returning
+// the cursor of the next page too, empty on the last one. Passed back
+// WithCursor and otherwise the same options, it starts the next page where
+// this one ended however deep into the listing, which an offset cannot do
+// without reading every row before it.
+func (r *SCDRepo[T, P]) FindPage(ctx context.Context, opts ...QueryOption) (items []T, next string, err error) {
+	o := queryOptions(opts)
+	lookahead := o
+	if o.Limit > 0 {
+		lookahead.Limit = o.Limit + 1
+	}
+	var model T
+	q, keys, err := lookahead.apply(r.DB.WithContext(ctx).Model(&model).Scopes(Latest[T]()), &model)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := q.Find(&items).Error; err != nil {
+		return nil, "", scd.Translate(err)
+	}
+	if o.Limit <= 0 || len(items) <= o.Limit {
+		return items, "", nil
+	}
+	items = items[:o.Limit]
+	next, err = keys.cursor(ctx, &items[o.Limit-1])
+	return items, next, err
+}
+
+// FindLatest returns the latest live version of every entity matching all
+// of filters, ordered by id.
+func (r *SCDRepo[T, P]) FindLatest(ctx context.Context, filters ...Filter) ([]T, error) {
+	return r.Find(ctx, WithFilters(filters...))
+}
+
+// FindLatestPage is FindLatest for one page: the entities after
+// page.AfterID, at most page.Limit of them when it is set.
+func (r *SCDRepo[T, P]) FindLatestPage(ctx context.Context, page Page, filters ...Filter) ([]T, error) {
+	if page.AfterID != "" {
+		filters = append(filters[:len(filters):len(filters)], Gt("id", page.AfterID))
+	}
+	return r.Find(ctx, WithFilters(filters...), WithLimit(page.Limit))
+}
+
+// FindLatestByID returns the latest live version of entity id; the error
+// wraps scd.ErrNotFound when there is none, including when it is deleted.
+func (r *SCDRepo[T, P]) FindLatestByID(ctx context.Context, id string) (T, error) {
+	var v T
+	q, table, err := r.latest(ctx, nil)
+	if err != nil {
+		return v, err
+	}
+	err = q.Where(clause.Eq{Column: scd.Column(table, "id"), Value: id}).Take(&v).Error
+	if err != nil {
+		return v, fmt.Errorf("fetching latest version of %s failed: %w", id, scd.Translate(err))
+	}
+	return v, nil
+}
+
+// Count returns how many entities have a latest live version matching all
+// of filters.
+func (r *SCDRepo[T, P]) Count(ctx context.Context, filters ...Filter) (int64, error) {
+	q, _, err := r.latest(ctx, filters)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = q.Count(&n).Error
+	return n, scd.Translate(err)
+}
+
+// History returns the versions of entity id in version order.
+func (r *SCDRepo[T, P]) History(ctx context.Context, id string, opts scd.HistoryOptions) ([]T, error) {
+	return scd.GetHistory[T](ctx, r.DB, id, opts)
+}
+
+// CreateVersion writes a new version of entity id with updateFn applied and
+// returns it (see scd.CreateNewSCDVersion).
+func (r *SCDRepo[T, P]) CreateVersion(ctx context.Context, id string, updateFn func(*T) error) (T, error) {
+	_, created, err := scd.CreateNewSCDVersionReturning[T, P](ctx, r.DB, id, updateFn)
+	return created, err
+}
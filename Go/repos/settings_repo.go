@@ -0,0 +1,145 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yourorg/Go/changefeed"
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+// Keys of the settings read across the system, with the defaults used until
+// a value is set.
+const (
+	// SettingPaymentPeriodDays is the length of a payment period in days (default 14).
+	SettingPaymentPeriodDays = "payment.period_days"
+	// SettingMaxTimelogHours is the longest duration a single timelog may
+	// record before it is refused (default 24).
+	SettingMaxTimelogHours = "validation.max_timelog_hours"
+)
+
+// SettingsRepo reads and writes system settings as versioned entities keyed
+// by setting name. Reads return the value in effect now, so a change
+// scheduled with SetSettingFrom applies from its effective time without a
+// restart.
+type SettingsRepo struct {
+	DB *gorm.DB
+}
+
+// Setting returns the version of setting key in effect at business time at.
+// Errors wrap scd.ErrNotFound when the setting was never set.
+func (r *SettingsRepo) Setting(ctx context.Context, key string, at time.Time) (models.Setting, error) {
+	var s models.Setting
+	err := r.DB.WithContext(ctx).Model(&models.Setting{}).
+		Scopes(EffectiveAt[models.Setting](at)).
+		Where("settings.id = ?", key).
+		Take(&s).Error
+	return s, scd.Translate(err)
+}
+
+// SetSetting makes value, encoded as JSON, the value of setting key from now
+// on, writing its first version if the setting is new. The context's actor
+// and change reason are recorded with it (see scd.WithActor).
+func (r *SettingsRepo) SetSetting(ctx context.Context, key string, value any) (models.Setting, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return models.Setting{}, fmt.Errorf("encoding setting %s failed: %w", key, err)
+	}
+	_, created, err := scd.CreateNewSCDVersionReturning[models.Setting](ctx, r.DB, key, func(s *models.Setting) error {
+		s.Value = string(encoded)
+		return nil
+	})
+	if !errors.Is(err, scd.ErrNotFound) {
+		return created, err
+	}
+	first := models.Setting{Versioned: models.Versioned{ID: key, Version: 1, UID: scd.NewUID()}, Value: string(encoded)}
+	if err := r.DB.WithContext(ctx).Create(&first).Error; err != nil {
+		return first, scd.Translate(err)
+	}
+	return first, nil
+}
+
+// SetSettingFrom records value as the value of the existing setting key from
+// effective on, e.g. a new payment period starting next month. Reads keep
+// returning the current value until then.
+func (r *SettingsRepo) SetSettingFrom(ctx context.Context, key string, value any, effective time.Time) (models.Setting, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return models.Setting{}, fmt.Errorf("encoding setting %s failed: %w", key, err)
+	}
+	return scd.ScheduleVersion[models.Setting](ctx, r.DB, key, effective, func(s *models.Setting) error {
+		s.Value = string(encoded)
+		return nil
+	})
+}
+
+// SettingHistory returns every value setting key has had, in version order.
+func (r *SettingsRepo) SettingHistory(ctx context.Context, key string) ([]models.Setting, error) {
+	return GetHistory[models.Setting](ctx, r.DB, key, scd.HistoryOptions{})
+}
+
+// WatchSetting streams the new versions of setting key until ctx is
+// cancelled, for processes that apply changes without a restart. Versions
+// effective later arrive when written; compare their ValidFrom with the
+// current time before applying them.
+func (r *SettingsRepo) WatchSetting(ctx context.Context, w *changefeed.Watcher, key string) (<-chan models.Setting, error) {
+	return changefeed.WatchEntity[models.Setting](ctx, w, key)
+}
+
+// PaymentPeriodDays returns SettingPaymentPeriodDays.
+func (r *SettingsRepo) PaymentPeriodDays(ctx context.Context) (int, error) {
+	return GetSetting(ctx, r, SettingPaymentPeriodDays, 14)
+}
+
+// MaxTimelogHours returns SettingMaxTimelogHours.
+func (r *SettingsRepo) MaxTimelogHours(ctx context.Context) (float64, error) {
+	return GetSetting(ctx, r, SettingMaxTimelogHours, 24.0)
+}
+
+// GetSetting returns setting key decoded as a V, or def when the setting was
+// never set or is not in effect yet:
+//
+//	days, err := repos.GetSetting(ctx, settings, repos.SettingPaymentPeriodDays, 14)
+//
+// A time.Duration may be stored as a string in time.ParseDuration's format.
+func GetSetting[V any](ctx context.Context, r *SettingsRepo, key string, def V) (V, error) {
+	return GetSettingAt(ctx, r, key, time.Now(), def)
+}
+
+// GetSettingAt is GetSetting for the value in effect at business time at,
+// e.g. the payment period that applied to a past run.
+func GetSettingAt[V any](ctx context.Context, r *SettingsRepo, key string, at time.Time, def V) (V, error) {
+	s, err := r.Setting(ctx, key, at)
+	if errors.Is(err, scd.ErrNotFound) {
+		return def, nil
+	}
+	if err != nil {
+		return def, err
+	}
+	return decodeSetting(s, def)
+}
+
+// decodeSetting decodes the value of s as a V.
+func decodeSetting[V any](s models.Setting, def V) (V, error) {
+	var v V
+	if d, ok := any(&v).(*time.Duration); ok {
+		var text string
+		if json.Unmarshal([]byte(s.Value), &text) == nil {
+			parsed, err := time.ParseDuration(text)
+			if err != nil {
+				return def, fmt.Errorf("setting %s is not a duration: %w", s.ID, err)
+			}
+			*d = parsed
+			return v, nil
+		}
+	}
+	if err := json.Unmarshal([]byte(s.Value), &v); err != nil {
+		return def, fmt.Errorf("setting %s is not a %T: %w", s.ID, def, err)
+	}
+	return v, nil
+}
@@ -1,8 +1,14 @@
 package repos
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
+
 	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"time"
 )
 
@@ -10,13 +16,215 @@ type TimelogRepo struct {
 	DB *gorm.DB
 }
 
-func (r *TimelogRepo) FindTimelogsByContractorAndPeriod(contractorID string, from, to time.Time) ([]models.Timelog, error) {
+// Versions returns the generic repository of timelogs, for reads and writes
+// TimelogRepo has no method of its own for.
+func (r *TimelogRepo) Versions() *SCDRepo[models.Timelog, *models.Timelog] {
+	return NewSCDRepo[models.Timelog](r.DB)
+}
+
+func (r *TimelogRepo) FindTimelogsByContractorAndPeriod(ctx context.Context, contractorID string, from, to time.Time, opts ...QueryOption) ([]models.Timelog, error) {
+	q, _, err := queryOptions(opts).apply(r.DB.WithContext(ctx).Model(&models.Timelog{}).
+		Joins("JOIN jobs ON timelogs.job_uid = jobs.uid").
+		Scopes(Latest[models.Timelog]()).
+		Where("jobs.contractor_id = ? AND timelogs.time_start >= ? AND timelogs.time_end <= ?", contractorID, from, to),
+		&models.Timelog{})
+	if err != nil {
+		return nil, err
+	}
 	var timelogs []models.Timelog
-	subq := LatestSubquery(r.DB, models.Timelog{})
-	err := r.DB.Model(&models.Timelog{}).
+	err = q.Find(&timelogs).Error
+	return timelogs, scd.Translate(err)
+}
+
+// SubmitTimelog submits timelog id for approval again after it was
+// rejected, writing a new version. Submit corrections in the same version
+// with SubmitTimelogWith.
+func (r *TimelogRepo) SubmitTimelog(ctx context.Context, id string) (models.Timelog, error) {
+	return r.SubmitTimelogWith(ctx, id, func(*models.Timelog) error { return nil })
+}
+
+// SubmitTimelogWith applies update to rejected timelog id and submits it for
+// approval, in one new version.
+func (r *TimelogRepo) SubmitTimelogWith(ctx context.Context, id string, update func(*models.Timelog) error) (models.Timelog, error) {
+	return r.moveApproval(ctx, id, models.TimelogRejected, models.TimelogSubmitted, update, nil)
+}
+
+// ApproveTimelog approves submitted timelog id, writing a new version, so
+// that payroll bills it. The approver is the actor of ctx (see
+// scd.WithActor), which must be set and must not be the one who submitted
+// it, or approval fails with scd.ErrSelfApproval.
+func (r *TimelogRepo) ApproveTimelog(ctx context.Context, id string) (models.Timelog, error) {
+	return r.moveApproval(ctx, id, models.TimelogSubmitted, models.TimelogApproved, nil, func(submitted models.Timelog) error {
+		if actor := scd.ActorFrom(ctx); actor == "" || actor == submitted.CreatedBy {
+			return fmt.Errorf("approving timelog %s failed: %w", id, scd.ErrSelfApproval)
+		}
+		return nil
+	})
+}
+
+// RejectTimelog sends submitted timelog id back to its author, writing a new
+// version whose change reason is reason.
+func (r *TimelogRepo) RejectTimelog(ctx context.Context, id, reason string) (models.Timelog, error) {
+	return r.moveApproval(scd.WithChangeReason(ctx, reason), id, models.TimelogSubmitted, models.TimelogRejected, nil, nil)
+}
+
+// moveApproval writes a version of timelog id moving it from approval status
+// from to to, failing with scd.ErrInvalidState when it is not in from.
+// update, when set, changes the new version too; vet, when set, vets the
+// version moved from and rolls the move back on error.
+func (r *TimelogRepo) moveApproval(ctx context.Context, id string, from, to models.TimelogApproval, update func(*models.Timelog) error, vet func(models.Timelog) error) (models.Timelog, error) {
+	var created models.Timelog
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		previous, next, err := CreateNewSCDVersionReturning[models.Timelog](ctx, tx, id, func(t *models.Timelog) error {
+			if t.ApprovalStatus != from {
+				return fmt.Errorf("timelog %s is %s, not %s: %w", id, t.ApprovalStatus, from, scd.ErrInvalidState)
+			}
+			if update != nil {
+				if err := update(t); err != nil {
+					return err
+				}
+			}
+			t.ApprovalStatus = to
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if vet != nil {
+			if err := vet(previous); err != nil {
+				return err
+			}
+		}
+		created = next
+		return nil
+	})
+	return created, err
+}
+
+// TimelogInPeriod is a timelog with the part of its duration that falls
+// within a period.
+type TimelogInPeriod struct {
+	models.Timelog
+	DurationInPeriod float64 `gorm:"column:duration_in_period"`
+}
+
+// DurationInPeriod is the SQL expression of the part of the duration of the
+// timelogs of table that falls within period, for selecting or summing:
+//
+//	db.Select("SUM(?)", repos.DurationInPeriod("timelogs", period))
+//
+// The duration is prorated over the time between the timelog's start and
+// end, so breaks within it count proportionally. A timelog ending when it
+// starts counts in full in the period it starts in.
+func DurationInPeriod(table string, period Period) clause.Expr {
+	start, end, duration := scd.Column(table, "time_start"), scd.Column(table, "time_end"), scd.Column(table, "duration")
+	from, to := gorm.Expr("CAST(? AS timestamptz)", period.From), gorm.Expr("CAST(? AS timestamptz)", period.To)
+	return gorm.Expr(`CASE WHEN ? <= ? THEN CASE WHEN ? >= ? AND ? < ? THEN ? ELSE 0 END
+		ELSE ? * GREATEST(0, EXTRACT(EPOCH FROM LEAST(?, ?) - GREATEST(?, ?))) / EXTRACT(EPOCH FROM ? - ?) END`,
+		end, start, start, from, start, to, duration,
+		duration, end, to, start, from, end, start)
+}
+
+// FindInPeriod returns the latest live timelogs of contractorID overlapping
+// period, including those only partly within it, each with the part of its
+// duration within the period, ordered by start.
+func (r *TimelogRepo) FindInPeriod(ctx context.Context, contractorID string, period Period, opts ...QueryOption) ([]TimelogInPeriod, error) {
+	q, _, err := queryOptions(opts).apply(r.DB.WithContext(ctx).Model(&models.Timelog{}).
 		Joins("JOIN jobs ON timelogs.job_uid = jobs.uid").
-		Joins("JOIN (?) AS latest ON timelogs.id = latest.id AND timelogs.version = latest.max_version", subq).
-		Where("jobs.contractor_id = ? AND timelogs.time_start >= ? AND timelogs.time_end <= ?", contractorID, from, to).
-		Find(&timelogs).Error
-	return timelogs, err
+		Scopes(Latest[models.Timelog]()).
+		Where("jobs.contractor_id = ? AND timelogs.time_start < ? AND (timelogs.time_end > ? OR timelogs.time_start >= ?)",
+			contractorID, period.To, period.From, period.From).
+		Select("timelogs.*, ? AS duration_in_period", DurationInPeriod("timelogs", period)),
+		&models.Timelog{}, Asc("time_start"))
+	if err != nil {
+		return nil, err
+	}
+	var rows []TimelogInPeriod
+	err = q.Scan(&rows).Error
+	return rows, scd.Translate(err)
+}
+
+// WeeklyUtilization is the hours a contractor logged in one week against the
+// capacity of the jobs they held in active status during that week.
+type WeeklyUtilization struct {
+	ContractorID  string    `gorm:"column:contractor_id"`
+	WeekStart     time.Time `gorm:"column:week_start"`
+	HoursLogged   float64   `gorm:"column:hours_logged"`
+	CapacityHours float64   `gorm:"column:capacity_hours"`
+	// Utilization is HoursLogged / CapacityHours, or nil when there was no capacity.
+	Utilization *float64 `gorm:"column:utilization"`
+}
+
+// UtilizationByWeek aggregates, per contractor and ISO week in [from, to), the
+// hours of latest timelogs against weeklyCapacity hours for every job that was
+// active at any point of the week. A job's status history is resolved from its
+// versions: each version is in effect from its created_at until the next one's.
+func (r *TimelogRepo) UtilizationByWeek(ctx context.Context, from, to time.Time, weeklyCapacity float64) ([]WeeklyUtilization, error) {
+	var rows []WeeklyUtilization
+	subq := LatestSubquery(ctx, r.DB, models.Timelog{})
+	err := r.DB.WithContext(ctx).Raw(`
+		WITH weeks AS (
+			SELECT generate_series(date_trunc('week', CAST(@from AS timestamptz)), CAST(@to AS timestamptz) - interval '1 microsecond', interval '1 week') AS week_start
+		),
+		job_spans AS (
+			SELECT id, contractor_id, status, is_deleted,
+				COALESCE(created_at, '-infinity') AS valid_from,
+				COALESCE(LEAD(created_at) OVER (PARTITION BY id ORDER BY version), 'infinity') AS valid_to
+			FROM jobs
+		),
+		capacity AS (
+			SELECT s.contractor_id, w.week_start, COUNT(DISTINCT s.id) * @capacity AS capacity_hours
+			FROM weeks w
+			JOIN job_spans s ON s.status = 'active' AND NOT s.is_deleted
+				AND s.valid_from < w.week_start + interval '1 week' AND s.valid_to > w.week_start
+			GROUP BY s.contractor_id, w.week_start
+		),
+		logged AS (
+			SELECT jobs.contractor_id, date_trunc('week', timelogs.time_start) AS week_start, SUM(timelogs.duration) AS hours
+			FROM timelogs
+			JOIN (@latest) AS latest ON timelogs.id = latest.id AND timelogs.version = latest.max_version
+			JOIN jobs ON timelogs.job_uid = jobs.uid
+			WHERE NOT timelogs.is_deleted AND timelogs.time_start >= @from AND timelogs.time_start < @to
+			GROUP BY jobs.contractor_id, date_trunc('week', timelogs.time_start)
+		)
+		SELECT COALESCE(c.contractor_id, l.contractor_id) AS contractor_id,
+			COALESCE(c.week_start, l.week_start) AS week_start,
+			COALESCE(l.hours, 0) AS hours_logged,
+			COALESCE(c.capacity_hours, 0) AS capacity_hours,
+			COALESCE(l.hours, 0) / NULLIF(c.capacity_hours, 0) AS utilization
+		FROM capacity c
+		FULL JOIN logged l ON c.contractor_id = l.contractor_id AND c.week_start = l.week_start
+		ORDER BY 1, 2`,
+		sql.Named("from", from), sql.Named("to", to), sql.Named("capacity", weeklyCapacity), sql.Named("latest", subq),
+	).Scan(&rows).Error
+	return rows, scd.Translate(err)
+}
+
+// TimelogAmount is a timelog priced at the rate of its job when the work
+// started.
+type TimelogAmount struct {
+	TimelogID string    `gorm:"column:timelog_id"`
+	TimeStart time.Time `gorm:"column:time_start"`
+	Hours     float64   `gorm:"column:hours"`
+	JobUID    string    `gorm:"column:job_uid"`
+	Rate      float64   `gorm:"column:rate"`
+	Amount    float64   `gorm:"column:amount"`
+}
+
+// FindAmountsAtWorkTime prices the latest live timelogs of contractorID that
+// started in [from, to) at the rate of the job version that was latest at
+// each timelog's start, not at today's rate. JobUID is that version, which
+// a line item computed from the timelog should pin.
+func (r *TimelogRepo) FindAmountsAtWorkTime(ctx context.Context, contractorID string, from, to time.Time, opts ...QueryOption) ([]TimelogAmount, error) {
+	q, _, err := queryOptions(opts).apply(r.DB.WithContext(ctx).Model(&models.Timelog{}).
+		Scopes(Latest[models.Timelog](), scd.JoinAsOf[models.Job]("timelogs.job_uid", "timelogs.time_start", "job")).
+		Where("job.contractor_id = ? AND timelogs.time_start >= ? AND timelogs.time_start < ?", contractorID, from, to).
+		Select("timelogs.id AS timelog_id, timelogs.time_start, timelogs.duration AS hours, job.uid AS job_uid, job.rate, timelogs.duration * job.rate AS amount"),
+		&models.Timelog{}, Asc("time_start"))
+	if err != nil {
+		return nil, err
+	}
+	var rows []TimelogAmount
+	err = q.Scan(&rows).Error
+	return rows, scd.Translate(err)
 }
@@ -0,0 +1,109 @@
+package repos_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/repos"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// dryRunPool lets transactions begin and end on a dry-run DB.
+type dryRunPool struct{ gorm.ConnPool }
+
+func (p *dryRunPool) BeginTx(context.Context, *sql.TxOptions) (gorm.ConnPool, error) { return p, nil }
+func (*dryRunPool) Commit() error                                                    { return nil }
+func (*dryRunPool) Rollback() error                                                  { return nil }
+
+// timelogDB is a dry-run DB on which every timelog read returns timelog t1 at
+// version 4 as latest sets it, and which collects the timelogs inserted.
+func timelogDB(t *testing.T, latest func(*models.Timelog)) (*gorm.DB, *[]models.Timelog) {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: &dryRunPool{}}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("opening dry-run db: %v", err)
+	}
+	db.Callback().Query().After("gorm:query").Register("test:fill", func(tx *gorm.DB) {
+		if tl, ok := tx.Statement.Dest.(*models.Timelog); ok {
+			*tl = models.Timelog{}
+			tl.ID, tl.Version, tl.UID, tl.VersionState = "t1", 4, "u4", scd.StateApproved
+			latest(tl)
+		}
+	})
+	var created []models.Timelog
+	db.Callback().Create().After("gorm:create").Register("test:created", func(tx *gorm.DB) {
+		if tl, ok := tx.Statement.Dest.(*models.Timelog); ok {
+			created = append(created, *tl)
+		}
+	})
+	return db, &created
+}
+
+func TestApproveTimelogNeedsAnotherApprover(t *testing.T) {
+	db, created := timelogDB(t, func(tl *models.Timelog) {
+		tl.ApprovalStatus, tl.CreatedBy = models.TimelogSubmitted, "alice"
+	})
+	r := &repos.TimelogRepo{DB: db}
+	for _, actor := range []string{"", "alice"} {
+		if _, err := r.ApproveTimelog(scd.WithActor(context.Background(), actor), "t1"); !errors.Is(err, scd.ErrSelfApproval) {
+			t.Errorf("approval by %q = %v, want ErrSelfApproval", actor, err)
+		}
+	}
+	approved, err := r.ApproveTimelog(scd.WithActor(context.Background(), "bob"), "t1")
+	if err != nil {
+		t.Fatalf("approval by bob: %v", err)
+	}
+	if approved.Version != 5 || approved.ApprovalStatus != models.TimelogApproved {
+		t.Errorf("approved = version %d %s, want version 5 approved", approved.Version, approved.ApprovalStatus)
+	}
+	// Inserting the approval must not send it back to review.
+	if last := (*created)[len(*created)-1]; last.ApprovalStatus != models.TimelogApproved {
+		t.Errorf("approval inserted as %s, want approved", last.ApprovalStatus)
+	}
+	if _, err := r.RejectTimelog(context.Background(), "t1", "wrong job"); err != nil {
+		t.Fatalf("reject: %v", err)
+	}
+	if _, err := r.SubmitTimelog(context.Background(), "t1"); !errors.Is(err, scd.ErrInvalidState) {
+		t.Errorf("submitting a submitted timelog = %v, want ErrInvalidState", err)
+	}
+}
+
+func TestEditingBilledFieldsResubmitsTimelog(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		update   func(*models.Timelog)
+		expected models.TimelogApproval
+	}{
+		{"duration", func(tl *models.Timelog) { tl.Duration = 6 }, models.TimelogSubmitted},
+		{"job", func(tl *models.Timelog) { tl.JobUID = "j2" }, models.TimelogApproved},
+	} {
+		db, created := timelogDB(t, func(tl *models.Timelog) {
+			tl.ApprovalStatus, tl.Duration, tl.JobUID = models.TimelogApproved, 8, "j1"
+		})
+		err := scd.CreateNewSCDVersion[models.Timelog](context.Background(), db, "t1", func(tl *models.Timelog) error {
+			tc.update(tl)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(*created) != 1 || (*created)[0].ApprovalStatus != tc.expected {
+			t.Errorf("%s: edited timelog is %v, want %s", tc.name, *created, tc.expected)
+		}
+	}
+
+	db, created := timelogDB(t, func(*models.Timelog) {})
+	if err := db.Create(&models.Timelog{Versioned: models.Versioned{ID: "t2", Version: 1}}).Error; err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if (*created)[0].ApprovalStatus != models.TimelogSubmitted {
+		t.Errorf("new timelog is %s, want submitted", (*created)[0].ApprovalStatus)
+	}
+}
@@ -0,0 +1,36 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// GetLatest returns the latest approved version of entity id, tombstones
+// included. Errors wrap ErrNotFound when the entity does not exist.
+func GetLatest[T any](ctx context.Context, db *gorm.DB, id string) (T, error) {
+	var v T
+	if err := approvedOnly(db.WithContext(ctx).Where("id = ?", id), &v).Order("version DESC").First(&v).Error; err != nil {
+		return v, fmt.Errorf("fetching latest version failed: %w", Translate(err))
+	}
+	return v, nil
+}
+
+// GetVersion returns one specific version of entity id.
+func GetVersion[T any](ctx context.Context, db *gorm.DB, id string, version int) (T, error) {
+	var v T
+	if err := AllVersions(ctx, db, &v).Where("id = ? AND version = ?", id, version).Take(&v).Error; err != nil {
+		return v, fmt.Errorf("fetching version %d failed: %w", version, Translate(err))
+	}
+	return v, nil
+}
+
+// GetByUID returns the version identified by uid, whether or not it is still the latest.
+func GetByUID[T any](ctx context.Context, db *gorm.DB, uid string) (T, error) {
+	var v T
+	if err := AllVersions(ctx, db, &v).Where("uid = ?", uid).Take(&v).Error; err != nil {
+		return v, fmt.Errorf("fetching version by uid failed: %w", Translate(err))
+	}
+	return v, nil
+}
@@ -0,0 +1,215 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Aggregate is an entity as it was in effect at one time together with the
+// entities depending on it then, as read by AggregateAsOf.
+type Aggregate struct {
+	Table string    `json:"table"`
+	ID    string    `json:"id"`
+	At    time.Time `json:"at"`
+	// Root is the version of the entity in effect at At, a value of its
+	// model such as a models.Job.
+	Root any `json:"root"`
+	// Dependents holds, by table, the versions of the dependents in effect at
+	// At as a slice of the table's model ordered by id, e.g. []models.Timelog
+	// under "timelogs". Every dependent model has an entry, empty if need be.
+	Dependents map[string]any `json:"dependents"`
+}
+
+// AggregateAsOf reads entity id of the registered model name, a table such as
+// "jobs" or a type name such as "job", as it was in effect at business time
+// t, together with its dependents in effect at t: the entities of the
+// models whose ModelOptions.References point at some version of it, those
+// pointing at some version of these, and so on. An entity referencing
+// several others of the aggregate, like a payment line item referencing a
+// job and one of its timelogs, is read once.
+//
+//	agg, err := scd.AggregateAsOf(ctx, db, "job", "job-42", disputed)
+//	timelogs := agg.Dependents["timelogs"].([]models.Timelog)
+//
+// Versions are selected as EffectiveAt selects them, and all of them in one
+// snapshot (see WithSnapshot), so the parts agree with each other even while
+// versions are written concurrently. Tombstoned entities are left out unless
+// ctx comes from IncludeDeleted. It fails with ErrNotFound when the entity
+// was not in effect at t.
+func AggregateAsOf(ctx context.Context, db *gorm.DB, name, id string, t time.Time) (Aggregate, error) {
+	agg := Aggregate{ID: id, At: t, Dependents: map[string]any{}}
+	root, ok := lookupModelNamed(name)
+	if !ok {
+		return agg, fmt.Errorf("%w: %s is not registered", ErrNotVersioned, name)
+	}
+	agg.Table = root.Table
+	rootType := modelType(root.Model)
+	deps := dependentsOf(rootType, Registrations())
+
+	err := WithSnapshot(ctx, db, func(tx *gorm.DB) error {
+		rows, err := findEffective(tx, rootType, t, clause.Eq{Column: Column(root.Table, "id"), Value: id})
+		if err != nil {
+			return fmt.Errorf("reading %s %s failed: %w", root.Table, id, err)
+		}
+		if rows.Len() == 0 {
+			return fmt.Errorf("%w: %s %s at %s", ErrNotFound, root.Table, id, t.Format(time.RFC3339))
+		}
+		agg.Root = rows.Index(0).Interface()
+
+		ids := map[reflect.Type][]string{rootType: {id}}
+		for _, r := range deps {
+			typ := modelType(r.Model)
+			model := reflect.New(typ).Interface()
+			info, err := Describe(tx, model)
+			if err != nil {
+				return err
+			}
+			var refs []clause.Expression
+			for _, ref := range r.Options.References {
+				target := modelType(ref.Target)
+				if len(ids[target]) == 0 {
+					continue
+				}
+				col, err := columnOf(tx, model, ref.Field)
+				if err != nil {
+					return err
+				}
+				uids := AllVersions(ctx, tx.Session(&gorm.Session{NewDB: true}), reflect.New(target).Interface()).
+					Select("uid").Where("id IN ?", ids[target])
+				refs = append(refs, clause.Expr{SQL: "? IN (?)", Vars: []any{Column(info.Table, col), uids}})
+			}
+			rows := reflect.MakeSlice(reflect.SliceOf(typ), 0, 0)
+			if len(refs) > 0 {
+				if rows, err = findEffective(tx, typ, t, clause.Or(refs...)); err != nil {
+					return fmt.Errorf("reading %s of %s %s failed: %w", info.Table, root.Table, id, err)
+				}
+			}
+			agg.Dependents[info.Table] = rows.Interface()
+			for i := 0; i < rows.Len(); i++ {
+				ids[typ] = append(ids[typ], rows.Index(i).Addr().Interface().(Entity).GetID())
+			}
+		}
+		return nil
+	})
+	return agg, err
+}
+
+// findEffective returns the versions of the model of type typ in effect at
+// t that match cond, as a slice ordered by id.
+func findEffective(tx *gorm.DB, typ reflect.Type, t time.Time, cond clause.Expression) (reflect.Value, error) {
+	model := reflect.New(typ).Interface()
+	info, err := Describe(tx, model)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	out := reflect.New(reflect.SliceOf(typ))
+	out.Elem().Set(reflect.MakeSlice(out.Elem().Type(), 0, 0))
+	err = tx.Session(&gorm.Session{NewDB: true}).Model(model).
+		Scopes(effectiveScopeOf(model, t, time.Time{})).
+		Where(cond).
+		Order(clause.OrderByColumn{Column: Column(info.Table, "id")}).
+		Find(out.Interface()).Error
+	if err != nil {
+		return reflect.Value{}, Translate(err)
+	}
+	return out.Elem(), nil
+}
+
+// dependentsOf returns the registrations of the models depending on the
+// model of type root, directly or through one another, each after those of
+// them it references. Models referencing each other in a cycle follow
+// registration order, seeing only the entities read before them.
+func dependentsOf(root reflect.Type, regs []Registration) []Registration {
+	in := map[reflect.Type]bool{root: true}
+	var reached []Registration
+	for grown := true; grown; {
+		grown = false
+		for _, r := range regs {
+			if t := modelType(r.Model); !in[t] && referencesAny(r, in) {
+				in[t] = true
+				reached = append(reached, r)
+				grown = true
+			}
+		}
+	}
+
+	done := map[reflect.Type]bool{root: true}
+	out := make([]Registration, 0, len(reached))
+	for len(out) < len(reached) {
+		next := -1
+		for i, r := range reached {
+			if done[modelType(r.Model)] {
+				continue
+			}
+			if next < 0 {
+				next = i
+			}
+			if ready(r, in, done) {
+				next = i
+				break
+			}
+		}
+		out = append(out, reached[next])
+		done[modelType(reached[next].Model)] = true
+	}
+	return out
+}
+
+// referencesAny reports whether r references one of the models of types.
+func referencesAny(r Registration, types map[reflect.Type]bool) bool {
+	for _, ref := range r.Options.References {
+		if types[modelType(ref.Target)] {
+			return true
+		}
+	}
+	return false
+}
+
+// ready reports whether every model of the aggregate, in, that r references
+// other than r's own has been read, i.e. is done.
+func ready(r Registration, in, done map[reflect.Type]bool) bool {
+	self := modelType(r.Model)
+	for _, ref := range r.Options.References {
+		if t := modelType(ref.Target); t != self && in[t] && !done[t] {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupModelNamed returns the descriptor of the registered model backed by
+// table name, or else of the one whose type is named name in any case.
+func lookupModelNamed(name string) (ModelDescriptor, bool) {
+	if d, ok := LookupModel(name); ok {
+		return d, true
+	}
+	for _, d := range RegisteredModels() {
+		if strings.EqualFold(d.Name, name) {
+			return d, true
+		}
+	}
+	return ModelDescriptor{}, false
+}
+
+// checkReference fails unless model type t has a column for field and the
+// reference has a target.
+func checkReference(t reflect.Type, ref Reference) error {
+	if ref.Target == nil {
+		return fmt.Errorf("reference field %s has no target", ref.Field)
+	}
+	s, err := schema.Parse(reflect.New(t).Interface(), &schemaCache, schema.NamingStrategy{})
+	if err != nil {
+		return fmt.Errorf("parsing model failed: %w", err)
+	}
+	if f := s.LookUpField(ref.Field); f == nil || f.DBName == "" {
+		return fmt.Errorf("reference field %s not found on %s", ref.Field, s.Name)
+	}
+	return nil
+}
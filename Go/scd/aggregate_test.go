@@ -0,0 +1,28 @@
+package scd_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+)
+
+func TestAggregateAsOfRejectsUnregisteredModel(t *testing.T) {
+	models.Register()
+	_, err := scd.AggregateAsOf(context.Background(), dryRunDB(t), "invoice", "inv-1", time.Now())
+	if !errors.Is(err, scd.ErrNotVersioned) {
+		t.Errorf("err = %v, want ErrNotVersioned", err)
+	}
+}
+
+func TestRegisterModelRejectsUnknownReferenceField(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a reference to a missing field did not panic")
+		}
+	}()
+	scd.RegisterModel(models.Timelog{}, scd.ModelOptions{References: []scd.Reference{{Field: "ProjectUID", Target: models.Job{}}}})
+}
@@ -0,0 +1,169 @@
+package scd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/yourorg/Go/scderr"
+	"gorm.io/gorm"
+)
+
+// VersionAnnotation attaches a tag, a comment or both to one version,
+// identified by its UID. Tags are unique per entity, so an entity can be read
+// back at a tag, e.g. the job versions a payroll run was computed from:
+//
+//	scd.TagLatest(ctx, db, models.Job{}, "payroll-2024-07", jobIDs)
+//	job, err := scd.GetByTag[models.Job](ctx, db, "job1", "payroll-2024-07")
+type VersionAnnotation struct {
+	ID       int64  `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	UID      string `gorm:"index;not null;column:uid" json:"uid"`
+	Table    string `gorm:"uniqueIndex:idx_version_annotations_tag,where:tag <> '';not null;column:table_name" json:"table"`
+	EntityID string `gorm:"uniqueIndex:idx_version_annotations_tag,where:tag <> '';not null;column:entity_id" json:"entity_id"`
+	Version  int    `gorm:"not null;column:version" json:"version"`
+	// Tag is empty for plain comments.
+	Tag       string    `gorm:"uniqueIndex:idx_version_annotations_tag,where:tag <> '';not null;default:'';column:tag" json:"tag,omitempty"`
+	Comment   string    `gorm:"not null;default:'';column:comment" json:"comment,omitempty"`
+	CreatedBy string    `gorm:"not null;default:'';column:created_by" json:"created_by,omitempty"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (VersionAnnotation) TableName() string { return "version_annotations" }
+
+var errEmptyTag = scderr.New(scderr.Validation, "scd: empty tag")
+
+// MigrateAnnotations creates the version annotations table.
+func MigrateAnnotations(db *gorm.DB) error {
+	return db.AutoMigrate(&VersionAnnotation{})
+}
+
+// TagVersion tags the version of model identified by uid, with comment as an
+// optional explanation. It fails with ErrTagExists when another version of
+// the entity already carries tag. The actor of ctx is recorded as the author.
+func TagVersion(ctx context.Context, db *gorm.DB, model any, uid, tag, comment string) (VersionAnnotation, error) {
+	if tag == "" {
+		return VersionAnnotation{}, errEmptyTag
+	}
+	return annotate(ctx, db, model, uid, tag, comment)
+}
+
+// AnnotateVersion attaches a free-text comment to the version of model
+// identified by uid. A version may carry any number of comments.
+func AnnotateVersion(ctx context.Context, db *gorm.DB, model any, uid, comment string) (VersionAnnotation, error) {
+	return annotate(ctx, db, model, uid, "", comment)
+}
+
+func annotate(ctx context.Context, db *gorm.DB, model any, uid, tag, comment string) (VersionAnnotation, error) {
+	info, err := Describe(db, model)
+	if err != nil {
+		return VersionAnnotation{}, err
+	}
+	db = db.WithContext(ctx)
+	var key struct {
+		ID      string
+		Version int
+	}
+	if err := AllVersions(ctx, db, model).Select("id, version").Where("uid = ?", uid).Take(&key).Error; err != nil {
+		return VersionAnnotation{}, fmt.Errorf("fetching version by uid failed: %w", Translate(err))
+	}
+	a := VersionAnnotation{UID: uid, Table: info.Table, EntityID: key.ID, Version: key.Version, Tag: tag, Comment: comment, CreatedBy: ActorFrom(ctx)}
+	if err := db.Create(&a).Error; err != nil {
+		return a, translateTagErr(err, info.Table, key.ID, tag)
+	}
+	return a, nil
+}
+
+// TagLatest tags the latest version of each of ids, leaving entities that
+// already carry tag alone. Call it in the transaction that reads the entities,
+// so the tagged versions are the ones used.
+func TagLatest(ctx context.Context, db *gorm.DB, model any, tag string, ids []string) error {
+	if tag == "" {
+		return errEmptyTag
+	}
+	info, err := Describe(db, model)
+	if err != nil {
+		return err
+	}
+	db = db.WithContext(ctx)
+	latest := RawLatest(ctx, db, model, "WHERE id IN ?", ids)
+	err = db.Exec(
+		`INSERT INTO version_annotations (uid, table_name, entity_id, version, tag, comment, created_by, created_at)
+		SELECT l.uid, ?, l.id, l.version, ?, '', ?, now() FROM (?) AS l
+		ON CONFLICT (table_name, entity_id, tag) WHERE tag <> '' DO NOTHING`,
+		info.Table, tag, ActorFrom(ctx), latest).Error
+	if err != nil {
+		return fmt.Errorf("tagging %s versions failed: %w", info.Table, err)
+	}
+	return nil
+}
+
+// UntagVersion removes tag from entity id of model. It fails with
+// ErrNotFound when the entity has no version with the tag.
+func UntagVersion(ctx context.Context, db *gorm.DB, model any, id, tag string) error {
+	info, err := Describe(db, model)
+	if err != nil {
+		return err
+	}
+	res := db.WithContext(ctx).Where("table_name = ? AND entity_id = ? AND tag = ?", info.Table, id, tag).Delete(&VersionAnnotation{})
+	if res.Error != nil {
+		return fmt.Errorf("removing tag %s failed: %w", tag, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Annotations returns the tags and comments of the version identified by
+// uid, oldest first.
+func Annotations(ctx context.Context, db *gorm.DB, uid string) ([]VersionAnnotation, error) {
+	var out []VersionAnnotation
+	if err := db.WithContext(ctx).Where("uid = ?", uid).Order("id").Find(&out).Error; err != nil {
+		return nil, fmt.Errorf("reading annotations failed: %w", err)
+	}
+	return out, nil
+}
+
+// GetByTag returns the version of entity id that carries tag. Errors wrap
+// ErrNotFound when there is none.
+func GetByTag[T any](ctx context.Context, db *gorm.DB, id, tag string) (T, error) {
+	var v T
+	err := AllVersions(ctx, db, &v).Where("uid = (?)", taggedUIDs(db, &v, tag).Where("entity_id = ?", id)).Take(&v).Error
+	if err != nil {
+		return v, fmt.Errorf("fetching %s at tag %s failed: %w", id, tag, Translate(err))
+	}
+	return v, nil
+}
+
+// TaggedVersions returns the versions of T carrying tag, one per entity, by id.
+func TaggedVersions[T any](ctx context.Context, db *gorm.DB, tag string) ([]T, error) {
+	var model T
+	var rows []T
+	err := AllVersions(ctx, db, &model).Where("uid IN (?)", taggedUIDs(db, &model, tag)).Order("id").Find(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("fetching versions at tag %s failed: %w", tag, Translate(err))
+	}
+	return rows, nil
+}
+
+// taggedUIDs selects the UIDs of model's versions carrying tag.
+func taggedUIDs(db *gorm.DB, model any, tag string) *gorm.DB {
+	q := db.Session(&gorm.Session{NewDB: true, Initialized: true}).Model(&VersionAnnotation{}).Select("uid")
+	info, err := Describe(db, model)
+	if err != nil {
+		q.AddError(err)
+		return q
+	}
+	return q.Where("table_name = ? AND tag = ?", info.Table, tag)
+}
+
+// translateTagErr turns the unique violation of a duplicate tag into ErrTagExists.
+func translateTagErr(err error, table, id, tag string) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return fmt.Errorf("%w: %s of %s is taken", ErrTagExists, tag, id)
+	}
+	return fmt.Errorf("annotating version of %s failed: %w", table, err)
+}
@@ -0,0 +1,26 @@
+package scd_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func TestGetByTagReadsTheTaggedVersionOfTheEntity(t *testing.T) {
+	db := dryRunDB(t)
+	var sql string
+	db.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	})
+	if _, err := scd.GetByTag[models.Job](context.Background(), db, "job1", "payroll-2024-07"); err != nil {
+		t.Fatal(err)
+	}
+	want := `WHERE uid = (SELECT "uid" FROM "version_annotations" WHERE (table_name = $1 AND tag = $2) AND entity_id = $3)`
+	if !strings.Contains(sql, `FROM "jobs"`) || !strings.Contains(sql, want) {
+		t.Errorf("tag not resolved per entity: %s", sql)
+	}
+}
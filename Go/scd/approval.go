@@ -0,0 +1,215 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Version states, stored in the version_state column of models embedding
+// Versioned. Only approved versions are ever the latest version of an entity.
+const (
+	StateDraft           = "draft"
+	StatePendingApproval = "pending_approval"
+	StateApproved        = "approved"
+)
+
+// SaveDraft writes a change that must be approved before it takes effect,
+// e.g. a rate change under a four-eyes rule:
+//
+//	draft, err := scd.SaveDraft[models.Job](scd.WithActor(ctx, "alice"), db, "job1", func(j *models.Job) error { j.Rate = 120; return nil })
+//	err = scd.SubmitDraft(ctx, db, models.Job{}, "job1", draft.Version)
+//	err = scd.ApproveVersion(scd.WithActor(ctx, "bob"), db, models.Job{}, "job1", draft.Version)
+//
+// The draft is a clone of the latest approved version with updateFn applied,
+// stored under the next version number with state StateDraft. Latest-version
+// reads skip it, and it is neither recorded in the change log nor passed to
+// the OnVersionCreated handlers until ApproveVersion. Versions written
+// meanwhile are numbered after it and still clone the latest approved
+// version. Drafts need LayoutSingleTable and a strategy computed at query
+// time; tables using StrategyIsLatest or StrategyPointer get
+// ErrUnsupportedUpdate.
+func SaveDraft[T any, P EntityPtr[T]](ctx context.Context, db *gorm.DB, id string, updateFn func(*T) error) (T, error) {
+	var model T
+	if err := draftsSupported(db, &model); err != nil {
+		return model, err
+	}
+	_, created, err := createVersion[T, P](ctx, db, id, nil, func(next *T) error {
+		if err := updateFn(next); err != nil {
+			return err
+		}
+		return setField(next, "VersionState", StateDraft)
+	})
+	return created, err
+}
+
+// SubmitDraft asks for a draft to be approved, moving it to
+// StatePendingApproval.
+func SubmitDraft(ctx context.Context, db *gorm.DB, model any, id string, version int) error {
+	return transition(ctx, db, model, id, version, StateDraft, StatePendingApproval)
+}
+
+// RejectVersion turns a version pending approval back into a draft, which
+// can be submitted again. To rework the change, save a new draft.
+func RejectVersion(ctx context.Context, db *gorm.DB, model any, id string, version int) error {
+	return transition(ctx, db, model, id, version, StatePendingApproval, StateDraft)
+}
+
+// ApproveVersion puts a version pending approval into effect: it becomes the
+// latest version of its entity, with CreatedAt set to now so as-of reads see
+// it from the moment it was approved, and ValidFrom set to now unless the
+// draft was effective-dated. It is then recorded and handled like any new
+// version, as an EventApprove change whose Actor is the approver. The
+// approver is the actor of ctx (see WithActor) and must not be the draft's
+// author, or approval fails with ErrSelfApproval. It fails with
+// ErrVersionConflict when another version was approved or written since the
+// draft was saved, since the draft would silently undo it, and with
+// ErrQuarantined when the entity is quarantined, as new versions do.
+func ApproveVersion(ctx context.Context, db *gorm.DB, model any, id string, version int) error {
+	return transition(ctx, db, model, id, version, StatePendingApproval, StateApproved)
+}
+
+// PendingApproval returns the versions of T awaiting approval, by entity and
+// version.
+func PendingApproval[T any](ctx context.Context, db *gorm.DB) ([]T, error) {
+	var versions []T
+	err := db.WithContext(ctx).Where("version_state = ?", StatePendingApproval).Order("id, version").Find(&versions).Error
+	if err != nil {
+		return nil, fmt.Errorf("fetching versions pending approval failed: %w", err)
+	}
+	return versions, nil
+}
+
+// transition moves version of entity id from state from to state to. The
+// entity's newest row is locked throughout, so transitions queue up behind
+// concurrent writers like any other write.
+func transition(ctx context.Context, db *gorm.DB, model any, id string, version int, from, to string) error {
+	t := modelType(model)
+	if !hasStates(model) {
+		return fmt.Errorf("%w: %s has no version_state column", ErrNotVersioned, t)
+	}
+	info, err := Describe(db, model)
+	if err != nil {
+		return err
+	}
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		latest := reflect.New(t).Interface()
+		if _, err := lockLatest(tx, id, latest); err != nil {
+			return err
+		}
+		target := reflect.New(t).Interface()
+		if err := tx.Where("id = ? AND version = ?", id, version).Take(target).Error; err != nil {
+			return fmt.Errorf("fetching version %d failed: %w", version, Translate(err))
+		}
+		if state := stateOf(target); state != from {
+			return fmt.Errorf("%w: version %d of %s is %s, not %s", ErrInvalidState, version, id, state, from)
+		}
+		if to != StateApproved {
+			stmt := fmt.Sprintf("UPDATE %s SET version_state = ? WHERE id = ? AND version = ?", quote(tx, info.Table))
+			if err := tx.Exec(stmt, to, id, version).Error; err != nil {
+				return fmt.Errorf("updating state of version %d failed: %w", version, err)
+			}
+			return nil
+		}
+		if author, ok := authorOf(target); ok {
+			if approver := ActorFrom(ctx); approver == "" || approver == author {
+				return fmt.Errorf("%w: version %d of %s was written by %q", ErrSelfApproval, version, id, author)
+			}
+		}
+		if _, approvedVersion, _ := versionedKey(latest); approvedVersion > version {
+			return fmt.Errorf("%w: %s is at version %d, approved after draft %d was saved", ErrVersionConflict, id, approvedVersion, version)
+		}
+		if err := checkQuarantine(tx, target); err != nil {
+			return err
+		}
+		return approve(tx, info, target)
+	})
+}
+
+// approve marks target approved and publishes it as saveVersion would have.
+func approve(tx *gorm.DB, info TableInfo, target any) error {
+	now := time.Now()
+	setField(target, "CreatedAt", &now)
+	setField(target, "VersionState", StateApproved)
+	validFrom, hasValidity := timeField(target, "ValidFrom")
+	if hasValidity && validFrom == nil {
+		validFrom = &now
+		setField(target, "ValidFrom", validFrom)
+	}
+	id, version, _ := versionedKey(target)
+	args := map[string]any{"state": StateApproved, "now": now, "from": validFrom, "id": id, "version": version}
+	set := "version_state = @state, created_at = @now"
+	if hasValidity {
+		set += ", valid_from = @from"
+	}
+	stmt := fmt.Sprintf("UPDATE %s SET %s WHERE id = @id AND version = @version", quote(tx, info.Table), set)
+	if err := tx.Exec(stmt, args).Error; err != nil {
+		return fmt.Errorf("approving version %d failed: %w", version, err)
+	}
+	return publishVersion(tx, target, EventApprove, validFrom)
+}
+
+// draftsSupported reports why model's table cannot hold drafts, if it cannot.
+func draftsSupported(db *gorm.DB, model any) error {
+	if !hasStates(model) {
+		return fmt.Errorf("%w: %T has no version_state column", ErrNotVersioned, model)
+	}
+	if latestOnly(model) {
+		return fmt.Errorf("%w: drafts need LayoutSingleTable", ErrUnsupportedUpdate)
+	}
+	info, err := Describe(db, model)
+	if err != nil {
+		return err
+	}
+	if s := currentConfig().strategyFor(info.Table); s == StrategyIsLatest || s == StrategyPointer {
+		return fmt.Errorf("%w: drafts need a strategy computed at query time, %s uses %s", ErrUnsupportedUpdate, info.Table, s)
+	}
+	return nil
+}
+
+// approvedOnly restricts q to approved versions of model, for models with a
+// version_state column. The state is inlined rather than bound, so the
+// subqueries built on it keep the bound variables of the caller's query.
+func approvedOnly(q *gorm.DB, model any) *gorm.DB {
+	if !hasStates(model) {
+		return q
+	}
+	return q.Where(approvedState)
+}
+
+// approvedState is the condition approvedOnly adds.
+const approvedState = "version_state = '" + StateApproved + "'"
+
+// hasStates reports whether model has the VersionState field of Versioned.
+// model may also point to an interface holding the model.
+func hasStates(model any) bool {
+	t := reflect.TypeOf(model)
+	if v := reflect.Indirect(reflect.ValueOf(model)); v.Kind() == reflect.Interface && !v.IsNil() {
+		t = v.Elem().Type()
+	}
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return false
+	}
+	f, ok := t.FieldByName("VersionState")
+	return ok && f.Type.Kind() == reflect.String
+}
+
+// stateOf returns entity's version state, StateApproved for models without one.
+func stateOf(entity any) string {
+	f := reflect.Indirect(reflect.ValueOf(entity)).FieldByName("VersionState")
+	if !f.IsValid() || f.Kind() != reflect.String || f.String() == "" {
+		return StateApproved
+	}
+	return f.String()
+}
+
+// approved reports whether entity is an approved version.
+func approved(entity any) bool {
+	return stateOf(entity) == StateApproved
+}
@@ -0,0 +1,25 @@
+package scd_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+)
+
+func TestSaveDraftNeedsAStrategyComputedAtQueryTime(t *testing.T) {
+	db := dryRunDB(t)
+	defer scd.Configure(scd.Config{})
+	for _, s := range []scd.Strategy{scd.StrategyIsLatest, scd.StrategyPointer} {
+		scd.Configure(scd.Config{Strategies: map[string]scd.Strategy{"jobs": s}})
+		_, err := scd.SaveDraft[models.Job](context.Background(), db, "job1", func(j *models.Job) error {
+			j.Rate = 120
+			return nil
+		})
+		if !errors.Is(err, scd.ErrUnsupportedUpdate) {
+			t.Errorf("%s: err = %v, want ErrUnsupportedUpdate", s, err)
+		}
+	}
+}
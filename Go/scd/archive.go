@@ -0,0 +1,210 @@
+package scd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ArchiveOptions controls Archive.
+type ArchiveOptions struct {
+	// To receives the archived versions as newline-delimited JSON instead of
+	// <table>_archive, e.g. a file on cheaper storage. A batch is deleted only
+	// once it was written, and synced if To has a Sync method like *os.File.
+	To io.Writer
+	// LegalHold keeps the versions any of the holds match in the table.
+	LegalHold []Hold
+	// BatchSize is the number of rows moved per batch (default 5000).
+	BatchSize int
+	// Limits bounds the run; see ApplyRetentionWithin.
+	Limits Limits
+}
+
+// ArchiveTable returns the name of the table Archive moves versions of table
+// to, <table>_archive.
+func ArchiveTable(table string) string {
+	return table + "_archive"
+}
+
+// Archive moves the versions of T superseded before cutoff out of the hot
+// table, into ArchiveTable unless opts.To is set, keeping the version in
+// effect at cutoff and everything after it:
+//
+//	res, err := scd.Archive[models.Job](ctx, db, time.Now().AddDate(-1, 0, 0), scd.ArchiveOptions{})
+//
+// The archive table has the table's columns, so history and as-of reads can
+// keep seeing archived versions by naming it in Config.ColdStorage:
+//
+//	scd.Configure(scd.Config{ColdStorage: map[string]string{"jobs": scd.ArchiveTable("jobs")}})
+//
+// Bring versions back with RestoreArchived, or RestoreFile for exports.
+// Versions pending approval and latest versions are never archived.
+func Archive[T any](ctx context.Context, db *gorm.DB, cutoff time.Time, opts ArchiveOptions) (RetentionResult, error) {
+	if cutoff.IsZero() {
+		var model T
+		return RetentionResult{}, fmt.Errorf("archiving %T needs a cutoff", model)
+	}
+	policy := PrunePolicy{KeepSince: cutoff, LegalHold: opts.LegalHold, Archive: true, BatchSize: opts.BatchSize, Limits: opts.Limits}
+	if opts.To == nil {
+		return Prune[T](ctx, db, policy)
+	}
+	return exportVersions[T](ctx, db, policy, opts.To)
+}
+
+// exportVersions is Prune writing the removed versions to w. Each batch is
+// selected for update, written and deleted in one transaction, so a
+// version is deleted only after it was written; a run that fails between the
+// two writes a batch again on retry, which RestoreFile tolerates.
+func exportVersions[T any](ctx context.Context, db *gorm.DB, policy PrunePolicy, w io.Writer) (RetentionResult, error) {
+	var model T
+	info, err := Describe(db, &model)
+	res := RetentionResult{Table: info.Table}
+	if err != nil {
+		return res, err
+	}
+	pos, err := policy.Limits.Resume.position(info.Table)
+	if err != nil {
+		return res, err
+	}
+	batchSize := policy.BatchSize
+	if batchSize <= 0 {
+		batchSize = 5000
+	}
+	db = db.WithContext(ctx)
+	target, cond, args := pruneCondition(db, &model, info, policy)
+	batch := fmt.Sprintf("SELECT t.* FROM %s t WHERE %s AND (t.id, t.version) > (?, ?) ORDER BY t.id, t.version LIMIT %d FOR UPDATE",
+		target, cond, batchSize)
+	sync, _ := w.(interface{ Sync() error })
+
+	b := policy.Limits.start(ctx)
+	for {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		if b.exhausted() {
+			res.Resume = newResumeToken(pos)
+			return res, nil
+		}
+		started := time.Now()
+		var n int
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var rows []T
+			if err := tx.Raw(batch, append(args[:len(args):len(args)], pos.ID, pos.Version)...).Scan(&rows).Error; err != nil {
+				return fmt.Errorf("reading versions to archive failed: %w", err)
+			}
+			if n = len(rows); n == 0 {
+				return nil
+			}
+			keys := make([][]any, n)
+			enc := json.NewEncoder(w)
+			for i := range rows {
+				pos.ID, pos.Version, _ = versionedKey(&rows[i])
+				keys[i] = []any{pos.ID, pos.Version}
+				if err := enc.Encode(rows[i]); err != nil {
+					return fmt.Errorf("writing archived versions failed: %w", err)
+				}
+			}
+			if sync != nil {
+				if err := sync.Sync(); err != nil {
+					return fmt.Errorf("syncing archived versions failed: %w", err)
+				}
+			}
+			if err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE (id, version) IN ?", target), keys).Error; err != nil {
+				return fmt.Errorf("deleting archived versions failed: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return res, fmt.Errorf("archiving %s failed: %w", info.Table, err)
+		}
+		b.batchDone(started)
+		res.Archived += int64(n)
+		if n < batchSize {
+			return res, nil
+		}
+	}
+}
+
+// RestoreArchived moves the archived versions of the entities ids, or all of
+// them when ids is empty, from ArchiveTable back into the table they were
+// archived from. Versions already back in the table are left as they are.
+// It returns the number of versions restored.
+func RestoreArchived[T any](ctx context.Context, db *gorm.DB, ids []string) (int64, error) {
+	var model T
+	info, err := Describe(db, &model)
+	if err != nil {
+		return 0, err
+	}
+	db = db.WithContext(ctx)
+	target := info.Table
+	if h := historyTable(&model, info.Table); h != "" {
+		target = h
+	}
+	cols := quoteColumns(db, "", append(append([]string(nil), info.Meta...), info.Columns...))
+	where, args := "", []any(nil)
+	if len(ids) > 0 {
+		where, args = " WHERE id IN ?", []any{ids}
+	}
+	stmt := fmt.Sprintf("WITH moved AS (DELETE FROM %[1]s%[4]s RETURNING *) INSERT INTO %[2]s (%[3]s) SELECT %[3]s FROM moved ON CONFLICT DO NOTHING",
+		quote(db, ArchiveTable(info.Table)), quote(db, target), cols, where)
+	res := db.Exec(stmt, args...)
+	if res.Error != nil {
+		return 0, fmt.Errorf("restoring archived %s failed: %w", info.Table, res.Error)
+	}
+	return res.RowsAffected, nil
+}
+
+// RestoreFile reads versions of T written by Archive with ArchiveOptions.To
+// back into their table, in batches of batchSize (default 1000). Versions
+// already in the table, e.g. from an export written twice, are skipped. The
+// audit fields are restored as exported, not stamped from ctx. It returns
+// the number of versions restored.
+func RestoreFile[T any](ctx context.Context, db *gorm.DB, r io.Reader, batchSize int) (int64, error) {
+	var model T
+	info, err := Describe(db, &model)
+	if err != nil {
+		return 0, err
+	}
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	db = db.WithContext(unaudited(ctx)).Clauses(clause.OnConflict{DoNothing: true})
+	if h := historyTable(&model, info.Table); h != "" {
+		db = db.Table(h)
+	}
+	var restored int64
+	flush := func(rows []T) error {
+		if len(rows) == 0 {
+			return nil
+		}
+		res := db.Create(&rows)
+		if res.Error != nil {
+			return fmt.Errorf("restoring %s from file failed: %w", info.Table, res.Error)
+		}
+		restored += res.RowsAffected
+		return nil
+	}
+	dec := json.NewDecoder(bufio.NewReader(r))
+	rows := make([]T, 0, batchSize)
+	for {
+		var v T
+		if err := dec.Decode(&v); err == io.EOF {
+			break
+		} else if err != nil {
+			return restored, fmt.Errorf("reading archived %s failed: %w", info.Table, err)
+		}
+		if rows = append(rows, v); len(rows) == batchSize {
+			if err := flush(rows); err != nil {
+				return restored, err
+			}
+			rows = rows[:0]
+		}
+	}
+	return restored, flush(rows)
+}
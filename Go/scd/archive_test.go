@@ -0,0 +1,25 @@
+package scd_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func TestRestoreArchivedMovesRowsBack(t *testing.T) {
+	db := dryRunDB(t)
+	var sql string
+	db.Callback().Raw().After("gorm:raw").Register("test:capture", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	})
+	if _, err := scd.RestoreArchived[models.Job](context.Background(), db, []string{"job1"}); err != nil {
+		t.Fatalf("RestoreArchived: %v", err)
+	}
+	want := `WITH moved AS (DELETE FROM "jobs_archive" WHERE id IN ($1) RETURNING *) INSERT INTO "jobs" ("id", "version", "uid"`
+	if len(sql) < len(want) || sql[:len(want)] != want {
+		t.Errorf("unexpected restore SQL:\n%s", sql)
+	}
+}
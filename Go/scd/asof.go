@@ -0,0 +1,118 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AsOfSubquery is LatestSubquery as of time t: it selects, per id, the latest
+// version created at or before t, so it can be joined in place of
+// LatestSubquery to see entities as they were then. Versions without
+// created_at predate the column and count as created before any t; entities
+// first created after t are absent. Cold storage is included, so join the
+// result against AllVersions rather than the table itself.
+func AsOfSubquery[T any](ctx context.Context, db *gorm.DB, model T, t time.Time) *gorm.DB {
+	return approvedOnly(AllVersions(ctx, db, &model).Where("COALESCE(created_at, '-infinity') <= ?", t), &model).
+		Select("id, MAX(version) as max_version").
+		Group("id")
+}
+
+// AsOfPair asks for entity ID as it was at time At.
+type AsOfPair struct {
+	ID string
+	At time.Time
+}
+
+// asOfBatchSize bounds the pairs resolved per statement, keeping the bound
+// parameters well below Postgres' limit of 65535.
+const asOfBatchSize = 5000
+
+// AsOfPairsQuery selects, for each of pairs in order, the version of its
+// entity that AsOfSubquery would select at its time, in a single statement.
+// A pair whose entity did not exist yet yields a row of NULLs. Prefer
+// ResolveAsOf, which splits large inputs into batches.
+func AsOfPairsQuery[T any](ctx context.Context, db *gorm.DB, model T, pairs []AsOfPair) *gorm.DB {
+	db = db.WithContext(ctx)
+	info, err := Describe(db, &model)
+	if err != nil {
+		db.AddError(err)
+		return db
+	}
+	values := make([]string, len(pairs))
+	args := make([]any, 0, 2*len(pairs))
+	for i, p := range pairs {
+		values[i] = fmt.Sprintf("(%d, CAST(? AS text), CAST(? AS timestamptz))", i)
+		args = append(args, p.ID, p.At)
+	}
+	var state string
+	if hasStates(&model) {
+		state = fmt.Sprintf(" AND %s.%s", quote(db, tableRef(info.Table)), approvedState)
+	}
+	query := fmt.Sprintf(`SELECT v.* FROM (VALUES %[3]s) AS p(ord, id, at)
+		LEFT JOIN LATERAL (
+			SELECT %[1]s.* FROM %[2]s
+			WHERE %[1]s.id = p.id AND COALESCE(%[1]s.created_at, '-infinity') <= p.at%[4]s
+			ORDER BY %[1]s.version DESC LIMIT 1
+		) v ON true
+		ORDER BY p.ord`, quote(db, tableRef(info.Table)), versionsRelation(db, &model, info, true), strings.Join(values, ", "), state)
+	return db.Raw(query, args...)
+}
+
+// ResolveAsOf answers many point-in-time lookups at once: the i-th result is
+// pairs[i].ID as it was at pairs[i].At, or the zero T (with an empty ID) when
+// the entity did not exist yet. Pairs are resolved in batches of a few
+// thousand, one statement each, instead of one query per pair.
+func ResolveAsOf[T any](ctx context.Context, db *gorm.DB, pairs []AsOfPair) ([]T, error) {
+	var model T
+	out := make([]T, len(pairs))
+	for start := 0; start < len(pairs); start += asOfBatchSize {
+		end := min(start+asOfBatchSize, len(pairs))
+		var batch []T
+		if err := AsOfPairsQuery(ctx, db, model, pairs[start:end]).Scan(&batch).Error; err != nil {
+			return nil, fmt.Errorf("resolving %d as-of pairs failed: %w", end-start, err)
+		}
+		copy(out[start:], batch)
+	}
+	return out, nil
+}
+
+// JoinAsOf returns a scope that left-joins onto a query, under alias, the
+// version of a T that was latest when each row says, the way AsOfSubquery
+// selects it for a single time. It answers per-row temporal questions
+// LatestSubquery cannot, e.g. the job rate in effect when a timelog's work
+// started rather than today's:
+//
+//	db.Model(&models.Timelog{}).
+//		Scopes(scd.Latest[models.Timelog](), scd.JoinAsOf[models.Job]("timelogs.job_uid", "timelogs.time_start", "job")).
+//		Select("timelogs.id, timelogs.duration * job.rate AS amount").Scan(&rows)
+//
+// ref is a table-qualified column of the query holding the UID of any
+// version of the T, as Timelog.JobUID does; at is an expression of the time
+// to look the T up at. Rows whose T did not exist yet at that time join
+// NULLs. The T is looked up in the versions of every layout and cold
+// storage, one index probe per row.
+func JoinAsOf[T any](ref, at, alias string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		var model T
+		info, err := Describe(db, &model)
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+		t := quote(db, tableRef(info.Table))
+		var state string
+		if hasStates(&model) {
+			state = fmt.Sprintf(" AND %s.%s", t, approvedState)
+		}
+		return db.Joins(fmt.Sprintf(`LEFT JOIN LATERAL (
+			SELECT %[1]s.* FROM %[2]s
+			WHERE %[1]s.id = (SELECT %[1]s.id FROM %[2]s WHERE %[1]s.uid = %[3]s LIMIT 1)
+				AND COALESCE(%[1]s.created_at, '-infinity') <= %[4]s%[5]s
+			ORDER BY %[1]s.version DESC LIMIT 1
+		) AS %[6]s ON true`, t, versionsRelation(db, &model, info, true), quote(db, ref), at, state, quote(db, alias)))
+	}
+}
@@ -0,0 +1,82 @@
+package scd_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+)
+
+func TestAsOfSubqueryFiltersByCreatedAt(t *testing.T) {
+	db := dryRunDB(t)
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var jobs []models.Job
+	stmt := db.Model(&models.Job{}).
+		Joins("JOIN (?) AS latest ON jobs.id = latest.id AND jobs.version = latest.max_version",
+			scd.AsOfSubquery(context.Background(), db, models.Job{}, at)).
+		Find(&jobs).Statement
+	sql := stmt.SQL.String()
+	if !strings.Contains(sql, "WHERE COALESCE(created_at, '-infinity') <= $1 AND version_state = 'approved' GROUP BY") {
+		t.Errorf("as-of filter missing: %s", sql)
+	}
+	if len(stmt.Vars) != 1 || stmt.Vars[0] != at {
+		t.Errorf("expected as-of time bound, got %v", stmt.Vars)
+	}
+}
+
+func TestAsOfReadsIncludeColdStorage(t *testing.T) {
+	scd.Configure(scd.Config{ColdStorage: map[string]string{"jobs": "jobs_cold"}})
+	defer scd.Configure(scd.Config{})
+	db := dryRunDB(t)
+	ctx := context.Background()
+	// The server passes models as interface values.
+	var model any = models.Job{}
+	var jobs []models.Job
+	sql := scd.AllVersions(ctx, db, model).
+		Joins("JOIN (?) AS latest ON jobs.id = latest.id AND jobs.version = latest.max_version",
+			scd.AsOfSubquery(ctx, db, model, time.Now())).
+		Find(&jobs).Statement.SQL.String()
+	if strings.Count(sql, `FROM "jobs_cold" c WHERE NOT EXISTS`) != 2 {
+		t.Errorf("cold versions not unioned into both sides: %s", sql)
+	}
+}
+
+func TestAsOfPairsQueryResolvesEachPairInOneStatement(t *testing.T) {
+	db := dryRunDB(t)
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pairs := []scd.AsOfPair{{ID: "j1", At: at}, {ID: "j2", At: at.AddDate(0, 1, 0)}, {ID: "j1", At: at.AddDate(0, 2, 0)}}
+	var jobs []models.Job
+	stmt := scd.AsOfPairsQuery(context.Background(), db, models.Job{}, pairs).Scan(&jobs).Statement
+	sql := stmt.SQL.String()
+	if !strings.Contains(sql, "(VALUES (0, CAST($1 AS text), CAST($2 AS timestamptz)), (1, ") {
+		t.Errorf("pairs not passed as a VALUES list: %s", sql)
+	}
+	if !strings.Contains(sql, "LEFT JOIN LATERAL") || !strings.HasSuffix(sql, "ORDER BY p.ord") {
+		t.Errorf("pairs not resolved in order with a lateral lookup: %s", sql)
+	}
+	if len(stmt.Vars) != 2*len(pairs) || stmt.Vars[5] != pairs[2].At {
+		t.Errorf("expected each pair bound, got %v", stmt.Vars)
+	}
+}
+
+func TestJoinAsOfLooksUpEachRowAtItsOwnTime(t *testing.T) {
+	db := dryRunDB(t)
+	var rows []map[string]any
+	sql := db.Model(&models.Timelog{}).
+		Scopes(scd.JoinAsOf[models.Job]("timelogs.job_uid", "timelogs.time_start", "job")).
+		Select("timelogs.id, job.rate").Find(&rows).Statement.SQL.String()
+	for _, want := range []string{
+		`LEFT JOIN LATERAL (`,
+		`WHERE "jobs".id = (SELECT "jobs".id FROM "jobs" WHERE "jobs".uid = "timelogs"."job_uid" LIMIT 1)`,
+		`AND COALESCE("jobs".created_at, '-infinity') <= timelogs.time_start AND "jobs".version_state = 'approved'`,
+		`ORDER BY "jobs".version DESC LIMIT 1`,
+		`) AS "job" ON true`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("as-of join lacks %q:\n%s", want, sql)
+		}
+	}
+}
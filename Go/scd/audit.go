@@ -0,0 +1,71 @@
+package scd
+
+import (
+	"context"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// auditFields pairs the audit fields of Versioned with the context values
+// they are filled in from.
+var auditFields = []struct {
+	field string
+	from  func(context.Context) string
+}{
+	{"CreatedBy", ActorFrom},
+	{"ChangeReason", ChangeReasonFrom},
+	{"Source", SourceFrom},
+}
+
+// stampAudit fills in the audit fields of the version rv from ctx, leaving
+// those the caller set explicitly.
+func stampAudit(ctx context.Context, rv reflect.Value) {
+	if ctx == nil {
+		return
+	}
+	rv = reflect.Indirect(rv)
+	if rv.Kind() != reflect.Struct {
+		return
+	}
+	for _, a := range auditFields {
+		f := rv.FieldByName(a.field)
+		if f.IsValid() && f.Kind() == reflect.String && f.CanSet() && f.String() == "" {
+			f.SetString(a.from(ctx))
+		}
+	}
+}
+
+// unaudited returns ctx without the values stampAudit fills in, for writes
+// that carry rows whose audit fields must be kept as they are.
+func unaudited(ctx context.Context) context.Context {
+	return WithSource(WithChangeReason(WithActor(ctx, ""), ""), "")
+}
+
+// auditCreate is the Plugin's create callback. It stamps rows inserted
+// directly, such as the first version of an entity, the way saveVersion
+// stamps the versions it writes.
+func auditCreate(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement.Schema == nil || tx.Statement.Schema.LookUpField("created_by") == nil {
+		return
+	}
+	ctx, rv := tx.Statement.Context, tx.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := range rv.Len() {
+			stampAudit(ctx, rv.Index(i))
+		}
+	case reflect.Struct:
+		stampAudit(ctx, rv)
+	}
+}
+
+// authorOf returns who wrote entity, as recorded in its CreatedBy field, and
+// whether the model records it at all.
+func authorOf(entity any) (string, bool) {
+	f := reflect.Indirect(reflect.ValueOf(entity)).FieldByName("CreatedBy")
+	if !f.IsValid() || f.Kind() != reflect.String {
+		return "", false
+	}
+	return f.String(), true
+}
@@ -0,0 +1,88 @@
+package scd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Limits bound how long a batched bulk operation runs. Once a limit would be
+// hit, the operation stops between batches instead of being cancelled inside
+// one: completed batches stay committed and the result carries a ResumeToken
+// from which a later run continues. The context deadline counts as a limit too.
+type Limits struct {
+	Deadline    time.Time
+	MaxDuration time.Duration
+	// Resume continues the run that returned this token.
+	Resume ResumeToken
+}
+
+// ResumeToken is an opaque position in a bulk operation. It is empty once the
+// operation has run to completion.
+type ResumeToken string
+
+type resumePosition struct {
+	Table   string `json:"table"`
+	ID      string `json:"id"`
+	Version int    `json:"version,omitempty"`
+}
+
+func newResumeToken(pos resumePosition) ResumeToken {
+	b, _ := json.Marshal(pos)
+	return ResumeToken(base64.RawURLEncoding.EncodeToString(b))
+}
+
+// position decodes the token, returning the zero position for an empty one.
+func (t ResumeToken) position(table string) (resumePosition, error) {
+	pos := resumePosition{Table: table}
+	if t == "" {
+		return pos, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(string(t))
+	if err == nil {
+		err = json.Unmarshal(b, &pos)
+	}
+	if err != nil {
+		return pos, fmt.Errorf("%w: %v", ErrInvalidResumeToken, err)
+	}
+	if pos.Table != table {
+		return pos, fmt.Errorf("%w: token is for %s, not %s", ErrInvalidResumeToken, pos.Table, table)
+	}
+	return pos, nil
+}
+
+// budget tracks a run against its Limits.
+type budget struct {
+	stop    time.Time
+	started time.Time
+	slowest time.Duration
+}
+
+func (l Limits) start(ctx context.Context) *budget {
+	b := &budget{started: time.Now(), stop: l.Deadline}
+	if l.MaxDuration > 0 {
+		b.tighten(b.started.Add(l.MaxDuration))
+	}
+	if d, ok := ctx.Deadline(); ok {
+		b.tighten(d)
+	}
+	return b
+}
+
+func (b *budget) tighten(t time.Time) {
+	if b.stop.IsZero() || t.Before(b.stop) {
+		b.stop = t
+	}
+}
+
+// exhausted reports whether there is no longer time for a batch as slow as
+// the slowest one so far. Call batchDone after each batch.
+func (b *budget) exhausted() bool {
+	return !b.stop.IsZero() && !time.Now().Add(b.slowest).Before(b.stop)
+}
+
+func (b *budget) batchDone(started time.Time) {
+	b.slowest = max(b.slowest, time.Since(started))
+}
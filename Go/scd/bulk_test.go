@@ -0,0 +1,27 @@
+package scd_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+)
+
+func TestRetentionStopsAtDeadlineWithResumeToken(t *testing.T) {
+	db := dryRunDB(t)
+	ctx := context.Background()
+	limits := scd.Limits{Deadline: time.Now().Add(-time.Second)}
+	res, err := scd.ApplyRetentionWithin(ctx, db, models.Job{}, scd.KeepLastN(3), 100, limits)
+	if err != nil || res.Resume == "" || res.Pruned != 0 {
+		t.Fatalf("expected an early stop with a resume token, got %+v, %v", res, err)
+	}
+
+	limits.Resume = res.Resume
+	_, err = scd.ApplyRetentionWithin(ctx, db, models.Timelog{}, scd.KeepLastN(3), 100, limits)
+	if !errors.Is(err, scd.ErrInvalidResumeToken) {
+		t.Errorf("token of jobs accepted for timelogs: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ChangeLogEntry is one row of the append-only change log. Seq is a global,
+// monotonically increasing cursor that consumers use to resume reading.
+type ChangeLogEntry struct {
+	Seq       int64     `gorm:"primaryKey;autoIncrement;column:seq"`
+	Table     string    `gorm:"index:idx_scd_change_log_entity;column:table_name"`
+	EntityID  string    `gorm:"index:idx_scd_change_log_entity;column:entity_id"`
+	Version   int       `gorm:"column:version"`
+	UID       string    `gorm:"column:uid"`
+	Kind      EventKind `gorm:"column:kind"`
+	ChangeSet string    `gorm:"index;column:change_set_id"`
+	// Actor is who wrote the version, or approved it for EventApprove entries.
+	Actor     string    `gorm:"column:actor"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+func (ChangeLogEntry) TableName() string { return "scd_change_log" }
+
+// MigrateChangeLog creates the change log table.
+func MigrateChangeLog(db *gorm.DB) error {
+	return db.AutoMigrate(&ChangeLogEntry{})
+}
+
+// ChangesSince returns up to limit change log entries for table with Seq greater than afterSeq.
+// An empty table matches every table.
+func ChangesSince(ctx context.Context, db *gorm.DB, table string, afterSeq int64, limit int) ([]ChangeLogEntry, error) {
+	var entries []ChangeLogEntry
+	q := db.WithContext(ctx).Where("seq > ?", afterSeq)
+	if table != "" {
+		q = q.Where("table_name = ?", table)
+	}
+	if err := q.Order("seq").Limit(limit).Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("reading change log failed: %w", err)
+	}
+	return entries, nil
+}
+
+// LatestSeq returns the highest sequence number in the change log, or 0 when it is empty.
+func LatestSeq(ctx context.Context, db *gorm.DB) (int64, error) {
+	var seq int64
+	err := db.WithContext(ctx).Model(&ChangeLogEntry{}).Select("COALESCE(MAX(seq), 0)").Scan(&seq).Error
+	return seq, err
+}
+
+// recordChange appends an entry for a freshly created version when the change
+// log is enabled, and stores the assigned sequence number in event.
+func recordChange(tx *gorm.DB, event *VersionEvent) error {
+	if !currentConfig().ChangeLog {
+		return nil
+	}
+	entry := ChangeLogEntry{Table: event.Table, EntityID: event.ID, Version: event.Version, UID: event.UID, Kind: event.Kind, ChangeSet: event.ChangeSet, Actor: event.Actor}
+	if err := tx.Create(&entry).Error; err != nil {
+		return fmt.Errorf("recording change failed: %w", err)
+	}
+	event.Seq = entry.Seq
+	return nil
+}
@@ -0,0 +1,82 @@
+package scd
+
+import "reflect"
+
+// CloneVersion returns a deep copy of v as the helpers clone versions: slices,
+// maps and pointers are copied rather than shared, so mutating the new
+// version in an update callback cannot reach the version it was cloned from.
+// Fields tagged scd:"-" are left at their zero value. Unexported fields are
+// copied shallowly.
+func CloneVersion[T any](v T) T {
+	var out T
+	dst := reflect.ValueOf(&out).Elem()
+	dst.Set(deepCopy(reflect.ValueOf(v), map[uintptr]reflect.Value{}))
+	return out
+}
+
+// deepCopy copies v recursively. seen maps pointers already copied, so
+// shared and cyclic references keep their shape.
+func deepCopy(v reflect.Value, seen map[uintptr]reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		if p, ok := seen[v.Pointer()]; ok {
+			return p
+		}
+		p := reflect.New(v.Type().Elem())
+		seen[v.Pointer()] = p
+		p.Elem().Set(deepCopy(v.Elem(), seen))
+		return p
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		s := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s.Index(i).Set(deepCopy(v.Index(i), seen))
+		}
+		return s
+	case reflect.Array:
+		a := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			a.Index(i).Set(deepCopy(v.Index(i), seen))
+		}
+		return a
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m.SetMapIndex(iter.Key(), deepCopy(iter.Value(), seen))
+		}
+		return m
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		i := reflect.New(v.Type()).Elem()
+		i.Set(deepCopy(v.Elem(), seen))
+		return i
+	case reflect.Struct:
+		s := reflect.New(v.Type()).Elem()
+		s.Set(v)
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if f.Tag.Get("scd") == "-" {
+				s.Field(i).SetZero()
+				continue
+			}
+			s.Field(i).Set(deepCopy(v.Field(i), seen))
+		}
+		return s
+	}
+	return v
+}
@@ -0,0 +1,41 @@
+package scd_test
+
+import (
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+)
+
+type richModel struct {
+	models.Versioned
+	Tags     []string
+	Labels   map[string]any
+	Manager  *string
+	Payload  []byte
+	Computed string `scd:"-"`
+}
+
+func TestCloneVersionIsDeep(t *testing.T) {
+	manager := "ana"
+	src := richModel{
+		Versioned: models.Versioned{ID: "r1", Version: 1},
+		Tags:      []string{"a"},
+		Labels:    map[string]any{"nested": []any{"x"}},
+		Manager:   &manager,
+		Payload:   []byte(`{"k":1}`),
+		Computed:  "cached",
+	}
+	c := scd.CloneVersion(src)
+	c.Tags[0] = "b"
+	c.Labels["nested"].([]any)[0] = "y"
+	*c.Manager = "bo"
+	c.Payload[0] = '['
+
+	if src.Tags[0] != "a" || src.Labels["nested"].([]any)[0] != "x" || manager != "ana" || src.Payload[0] != '{' {
+		t.Errorf("clone shares state with its source: %+v", src)
+	}
+	if c.ID != "r1" || c.Computed != "" {
+		t.Errorf("unexpected clone: %+v", c)
+	}
+}
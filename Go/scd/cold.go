@@ -0,0 +1,60 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// AllVersions returns db scoped to every version of model: its table or the
+// history table its layout keeps (see versionsRelation), and the cold
+// relation configured for it in Config.ColdStorage. The result is
+// aliased to the table name, so it can stand in for the table in history and
+// as-of queries. Versions present in both places are read from the table.
+func AllVersions(ctx context.Context, db *gorm.DB, model any) *gorm.DB {
+	db = db.WithContext(ctx)
+	info, err := Describe(db, model)
+	if err != nil {
+		return db.Model(model)
+	}
+	if rel := versionsRelation(db, model, info, true); rel != quote(db, info.Table) {
+		return db.Model(model).Table(rel)
+	}
+	return db.Model(model)
+}
+
+// CreateParquetColdTable creates <table>_cold, a parquet_fdw foreign table
+// over files with the columns of model's table, for use in
+// Config.ColdStorage. server is the parquet_fdw server to read through. Any
+// other relation with the same columns works as cold storage too, e.g. a view
+// over DuckDB's read_parquet when pg_duckdb is installed.
+func CreateParquetColdTable(ctx context.Context, db *gorm.DB, model any, server string, files []string) (string, error) {
+	info, err := Describe(db, model)
+	if err != nil {
+		return "", err
+	}
+	db = db.WithContext(ctx)
+	var columns []struct {
+		Name string
+		Type string
+	}
+	err = db.Raw(`SELECT attname AS name, format_type(atttypid, atttypmod) AS type FROM pg_attribute
+		WHERE attrelid = CAST(? AS regclass) AND attnum > 0 AND NOT attisdropped ORDER BY attnum`, quote(db, info.Table)).
+		Scan(&columns).Error
+	if err != nil {
+		return "", fmt.Errorf("reading columns of %s failed: %w", info.Table, err)
+	}
+	defs := make([]string, len(columns))
+	for i, c := range columns {
+		defs[i] = c.Name + " " + c.Type
+	}
+	cold := info.Table + "_cold"
+	stmts := []string{
+		"DROP FOREIGN TABLE IF EXISTS " + cold,
+		fmt.Sprintf("CREATE FOREIGN TABLE %s (%s) SERVER %s OPTIONS (filename %s)",
+			cold, strings.Join(defs, ", "), server, quoteList([]string{strings.Join(files, " ")})),
+	}
+	return cold, execAll(ctx, db, "creating cold table "+cold, stmts)
+}
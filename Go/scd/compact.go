@@ -0,0 +1,253 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CompactPolicy says which versions Compact merges away.
+type CompactPolicy struct {
+	// Window joins versions into a run when each was written within Window
+	// of the one before, e.g. the micro-changes of one import. Compact keeps
+	// the version the run started from and the run's last version, which
+	// represents the whole run; the versions in between are removed.
+	Window time.Duration
+	// OlderThan leaves versions written more recently alone, so runs still in
+	// progress are not split (default 24h).
+	OlderThan time.Duration
+	// DropNoOps also removes versions whose business fields and tombstone
+	// equal those of the version before, wherever they occur.
+	DropNoOps bool
+	// LegalHold keeps the versions any of the holds match, e.g.
+	// HoldTagged() or the versions other tables reference by UID.
+	LegalHold []Hold
+	// Annotate records on each representative version a comment (see
+	// AnnotateVersion) naming the versions merged into it and their authors.
+	// It needs MigrateAnnotations.
+	Annotate bool
+	// BatchSize is the number of entities compacted per transaction (default 500).
+	BatchSize int
+	// Limits bounds the run; see ApplyRetentionWithin.
+	Limits Limits
+}
+
+// CompactResult counts what a Compact run did.
+type CompactResult struct {
+	Table string
+	// Entities is the number of entities that lost versions.
+	Entities int64
+	// Removed is the number of versions removed.
+	Removed int64
+	// Resume is set when the run stopped at its Limits before finishing.
+	Resume ResumeToken
+}
+
+// Compact collapses the bursts of versions of T the policy describes into
+// one version each, e.g. to shorten the chains of micro-changes an import
+// leaves behind:
+//
+//	res, err := scd.Compact[models.Job](ctx, db, scd.CompactPolicy{Window: time.Minute, DropNoOps: true, Annotate: true})
+//
+// The latest version of an entity, drafts and versions pending approval are
+// never removed. Version numbers are not reused, so the versions kept keep
+// their numbers and UIDs and the history shows gaps where versions were
+// merged. As-of reads inside a compacted run see the state the run started
+// from until its last version. Entities are compacted in id order, each
+// batch in its own transaction.
+func Compact[T any](ctx context.Context, db *gorm.DB, policy CompactPolicy) (CompactResult, error) {
+	var model T
+	info, err := Describe(db, &model)
+	res := CompactResult{Table: info.Table}
+	if err != nil {
+		return res, err
+	}
+	if policy.Window <= 0 && !policy.DropNoOps {
+		return res, fmt.Errorf("compacting %s needs a Window or DropNoOps", info.Table)
+	}
+	pos, err := policy.Limits.Resume.position(info.Table)
+	if err != nil {
+		return res, err
+	}
+	batchSize := policy.BatchSize
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	olderThan := policy.OlderThan
+	if olderThan <= 0 {
+		olderThan = 24 * time.Hour
+	}
+	db = db.WithContext(ctx)
+	target := info.Table
+	if h := historyTable(&model, info.Table); h != "" {
+		target = h
+	}
+	target = quote(db, target)
+	scan, args := compactionScan(db, &model, info, policy, time.Now().Add(-olderThan))
+
+	b := policy.Limits.start(ctx)
+	for {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		if b.exhausted() {
+			res.Resume = newResumeToken(pos)
+			return res, nil
+		}
+		started := time.Now()
+		var ids []string
+		err := db.Raw(fmt.Sprintf("SELECT DISTINCT id FROM %s WHERE id > ? ORDER BY id LIMIT ?", target), pos.ID, batchSize).Scan(&ids).Error
+		if err != nil {
+			return res, fmt.Errorf("listing %s to compact failed: %w", info.Table, err)
+		}
+		if len(ids) == 0 {
+			return res, nil
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var rows []compactRow
+			if err := tx.Raw(scan, append(args[:len(args):len(args)], ids)...).Scan(&rows).Error; err != nil {
+				return fmt.Errorf("finding versions to compact failed: %w", err)
+			}
+			drop, merged := planCompaction(rows)
+			if len(drop) == 0 {
+				return nil
+			}
+			if err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE (id, version) IN ?", target), drop).Error; err != nil {
+				return fmt.Errorf("removing compacted versions failed: %w", err)
+			}
+			if policy.Annotate {
+				notes := make([]VersionAnnotation, len(merged))
+				for i, m := range merged {
+					notes[i] = VersionAnnotation{UID: m.into.UID, Table: info.Table, EntityID: m.into.ID, Version: m.into.Version,
+						Comment: m.comment(), CreatedBy: ActorFrom(ctx)}
+				}
+				if err := tx.Create(&notes).Error; err != nil {
+					return fmt.Errorf("annotating compacted versions failed: %w", err)
+				}
+			}
+			res.Removed += int64(len(drop))
+			for i, m := range merged {
+				if i == 0 || merged[i-1].into.ID != m.into.ID {
+					res.Entities++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return res, fmt.Errorf("compacting %s failed: %w", info.Table, err)
+		}
+		b.batchDone(started)
+		pos.ID = ids[len(ids)-1]
+		if len(ids) < batchSize {
+			return res, nil
+		}
+	}
+}
+
+// compactRow is a version of an entity being compacted.
+type compactRow struct {
+	ID        string
+	Version   int
+	UID       string
+	CreatedBy string
+	// Dropped is set for the versions the policy removes.
+	Dropped bool
+}
+
+// compactionScan returns the query listing the versions of the entities
+// bound to its last variable in (id, version) order, flagging those policy
+// removes among the versions written before cutoff.
+func compactionScan(db *gorm.DB, model any, info TableInfo, policy CompactPolicy, cutoff time.Time) (string, []any) {
+	var rules []string
+	var args []any
+	if policy.Window > 0 {
+		// Inside a run: written within the window of the version before and
+		// followed within the window by the next one.
+		rules = append(rules, "(t.created_at - LAG(t.created_at) OVER w <= ? * interval '1 microsecond' AND LEAD(t.created_at) OVER w - t.created_at <= ? * interval '1 microsecond')")
+		args = append(args, policy.Window.Microseconds(), policy.Window.Microseconds())
+	}
+	if policy.DropNoOps {
+		same := []string{"LAG(t.version) OVER w IS NOT NULL"}
+		cols := slices.Clone(info.Columns)
+		if slices.Contains(info.Meta, "is_deleted") {
+			cols = append(cols, "is_deleted")
+		}
+		for _, c := range cols {
+			same = append(same, fmt.Sprintf("t.%[1]s IS NOT DISTINCT FROM LAG(t.%[1]s) OVER w", quote(db, c)))
+		}
+		rules = append(rules, "("+strings.Join(same, " AND ")+")")
+	}
+	drop := fmt.Sprintf("LEAD(t.version) OVER w IS NOT NULL AND t.created_at < ? AND (%s)", strings.Join(rules, " OR "))
+	args = append([]any{cutoff}, args...)
+	for _, h := range policy.LegalHold {
+		if strings.TrimSpace(h.Where) == "" {
+			continue
+		}
+		drop += " AND (" + h.Where + ") IS FALSE"
+		args = append(args, h.Args...)
+	}
+	createdBy := "''"
+	if slices.Contains(info.Meta, "created_by") {
+		createdBy = "t.created_by"
+	}
+	where := "t.id IN ?"
+	if hasStates(model) {
+		where += " AND t." + approvedState
+	}
+	sql := fmt.Sprintf(`SELECT t.id, t.version, t.uid, %s AS created_by, COALESCE(%s, false) AS dropped
+		FROM %s WHERE %s
+		WINDOW w AS (PARTITION BY t.id ORDER BY t.version)
+		ORDER BY t.id, t.version`, createdBy, drop, versionsAs(db, model, info, "t"), where)
+	return sql, args
+}
+
+// merge is a version kept by Compact together with the versions merged into it.
+type merge struct {
+	into   compactRow
+	merged []compactRow
+}
+
+// comment describes the merge, e.g. "compacted versions 3, 4, 5 by alice, import".
+func (m merge) comment() string {
+	versions := make([]string, len(m.merged))
+	var authors []string
+	for i, r := range m.merged {
+		versions[i] = fmt.Sprint(r.Version)
+		if r.CreatedBy != "" && !slices.Contains(authors, r.CreatedBy) {
+			authors = append(authors, r.CreatedBy)
+		}
+	}
+	c := "compacted versions " + strings.Join(versions, ", ")
+	if len(authors) > 0 {
+		c += " by " + strings.Join(authors, ", ")
+	}
+	return c
+}
+
+// planCompaction returns the (id, version) keys of the rows to drop and the
+// rows they merge into: each dropped version merges into the next version
+// of its entity that is kept.
+func planCompaction(rows []compactRow) ([][]any, []merge) {
+	var drop [][]any
+	var merges []merge
+	var pending []compactRow
+	for i, r := range rows {
+		if i > 0 && rows[i-1].ID != r.ID {
+			pending = nil
+		}
+		if r.Dropped {
+			drop = append(drop, []any{r.ID, r.Version})
+			pending = append(pending, r)
+			continue
+		}
+		if len(pending) > 0 {
+			merges = append(merges, merge{into: r, merged: pending})
+			pending = nil
+		}
+	}
+	return drop, merges
+}
@@ -0,0 +1,30 @@
+package scd_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func TestCompactBatchesEntitiesInIDOrder(t *testing.T) {
+	db := dryRunDB(t)
+	var sql string
+	var vars []any
+	db.Callback().Row().After("gorm:row").Register("test:capture", func(tx *gorm.DB) {
+		sql, vars = tx.Statement.SQL.String(), tx.Statement.Vars
+	})
+	scd.Compact[models.Job](context.Background(), db, scd.CompactPolicy{DropNoOps: true, BatchSize: 50})
+	if want := `SELECT DISTINCT id FROM "jobs" WHERE id > $1 ORDER BY id LIMIT $2`; sql != want {
+		t.Errorf("compact listed entities with %q, want %q", sql, want)
+	}
+	if len(vars) != 2 || vars[0] != "" || vars[1] != 50 {
+		t.Errorf("unexpected vars %v", vars)
+	}
+
+	if _, err := scd.Compact[models.Job](context.Background(), db, scd.CompactPolicy{}); err == nil {
+		t.Error("Compact accepted a policy that compacts nothing")
+	}
+}
@@ -0,0 +1,71 @@
+package scd
+
+import "sync"
+
+// Config holds package-wide settings for the scd helpers.
+type Config struct {
+	// ChangeLog appends an entry to scd_change_log for every version created by this package.
+	ChangeLog bool
+	// MaxHistoryVersions caps how many versions of one entity a single history
+	// read may load; larger histories must be paged. Zero means DefaultMaxHistoryVersions.
+	MaxHistoryVersions int
+	// Strategies selects the read strategy of LatestSubquery per table;
+	// tables not listed use DefaultStrategy. See SwitchStrategy.
+	Strategies map[string]Strategy
+	// DefaultStrategy is the read strategy of tables missing from Strategies.
+	// Empty means StrategyMaxVersion. Only strategies that need no prepared
+	// storage make sense here: max_version, window, distinct_on and lateral.
+	DefaultStrategy Strategy
+	// UIDs generates the UID of every version created by this package. Nil
+	// means UUIDv4.
+	UIDs UIDGenerator
+	// ColdStorage names, per table, a relation holding versions offloaded
+	// from it, e.g. a foreign table over Parquet files created with
+	// CreateParquetColdTable. History and as-of reads include it; see AllVersions.
+	ColdStorage map[string]string
+	// Quarantine enforces the quarantines of QuarantineEntity: new versions
+	// of quarantined entities fail with ErrQuarantined and latest-version
+	// reads leave them out. It needs MigrateQuarantine.
+	Quarantine bool
+}
+
+// DefaultMaxHistoryVersions is the history read limit when Config leaves it unset.
+const DefaultMaxHistoryVersions = 1000
+
+func (c Config) maxHistoryVersions() int {
+	if c.MaxHistoryVersions <= 0 {
+		return DefaultMaxHistoryVersions
+	}
+	return c.MaxHistoryVersions
+}
+
+// NewUID returns a UID from the configured generator, for code that writes
+// versions without going through this package.
+func NewUID() string {
+	return currentConfig().newUID()
+}
+
+func (c Config) newUID() string {
+	if c.UIDs == nil {
+		return UUIDv4()
+	}
+	return c.UIDs()
+}
+
+var (
+	configMu sync.RWMutex
+	config   Config
+)
+
+// Configure replaces the package configuration. It is typically called once at startup.
+func Configure(c Config) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = c
+}
+
+func currentConfig() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
@@ -0,0 +1,114 @@
+package scd
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type changeSetKey struct{}
+
+type includeDeletedKey struct{}
+
+type skipUnchangedKey struct{}
+
+type (
+	actorKey        struct{}
+	changeReasonKey struct{}
+	sourceKey       struct{}
+)
+
+// WithChangeSet tags every version created with ctx (via db.WithContext) as
+// part of change set id, so related versions can be found and reviewed together.
+func WithChangeSet(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, changeSetKey{}, id)
+}
+
+// ChangeSetFrom returns the change set carried by ctx, if any.
+func ChangeSetFrom(ctx context.Context) string {
+	id, _ := ctx.Value(changeSetKey{}).(string)
+	return id
+}
+
+// WithActor records actor, e.g. a user ID, as the author of every version
+// created with ctx, in the CreatedBy column of models embedding Versioned. It
+// is also the approver for ApproveVersion.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor carried by ctx, if any.
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// WithChangeReason records why the versions created with ctx were written,
+// in their ChangeReason column.
+func WithChangeReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, changeReasonKey{}, reason)
+}
+
+// ChangeReasonFrom returns the change reason carried by ctx, if any.
+func ChangeReasonFrom(ctx context.Context) string {
+	reason, _ := ctx.Value(changeReasonKey{}).(string)
+	return reason
+}
+
+// WithSource records the channel the versions created with ctx came
+// through, e.g. "api" or "payroll-import", in their Source column.
+func WithSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// SourceFrom returns the source carried by ctx, if any.
+func SourceFrom(ctx context.Context) string {
+	source, _ := ctx.Value(sourceKey{}).(string)
+	return source
+}
+
+// IncludeDeleted makes LiveSubquery, and the repository queries built on it,
+// return soft-deleted entities along with live ones.
+func IncludeDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, includeDeletedKey{}, true)
+}
+
+func includesDeleted(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	ok, _ := ctx.Value(includeDeletedKey{}).(bool)
+	return ok
+}
+
+// SkipUnchanged makes CreateNewSCDVersion and its variants write nothing when
+// the update callback leaves the business fields as they were, which keeps
+// repeated imports of the same data from piling up identical versions.
+// CreateNewSCDVersionReturning then returns the latest version as created.
+func SkipUnchanged(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipUnchangedKey{}, true)
+}
+
+func skipsUnchanged(ctx context.Context) bool {
+	ok, _ := ctx.Value(skipUnchangedKey{}).(bool)
+	return ok
+}
+
+// NewChangeSetID returns a fresh random change set identifier.
+func NewChangeSetID() string {
+	return UUIDv4()
+}
+
+func changeSetOf(tx *gorm.DB) string {
+	if tx.Statement.Context == nil {
+		return ""
+	}
+	return ChangeSetFrom(tx.Statement.Context)
+}
+
+func actorOf(tx *gorm.DB) string {
+	if tx.Statement.Context == nil {
+		return ""
+	}
+	return ActorFrom(tx.Statement.Context)
+}
@@ -0,0 +1,123 @@
+package scd
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"gorm.io/gorm/schema"
+)
+
+// ModelDescriptor describes a registered model for generic tooling that
+// operates over every entity type, such as the HTTP server and scdctl.
+type ModelDescriptor struct {
+	// Name is the Go type name, e.g. "Job".
+	Name  string `json:"name"`
+	Table string `json:"table"`
+	// KeyColumns identify a single version of an entity.
+	KeyColumns []string          `json:"key_columns"`
+	Fields     []FieldDescriptor `json:"fields"`
+	Strategies Strategies        `json:"strategies"`
+	Retention  RetentionPolicy   `json:"retention"`
+	// Model is a zero value of the model type.
+	Model any `json:"-"`
+}
+
+// FieldDescriptor describes one mapped column of a model.
+type FieldDescriptor struct {
+	Name   string `json:"name"`
+	Column string `json:"column"`
+	Type   string `json:"type"`
+	// Meta is set for columns contributed by the embedded Versioned struct.
+	Meta bool `json:"meta"`
+	// PII is set for columns declared in ModelOptions.PII.
+	PII bool `json:"pii"`
+}
+
+// Strategies reports how a model's versions are queried and maintained.
+type Strategies struct {
+	// Latest is the configured read strategy of LatestSubquery.
+	Latest Strategy `json:"latest"`
+	// SoftDelete is set when the model has an is_deleted column.
+	SoftDelete bool `json:"soft_delete"`
+	// Validity is set when the model has valid_from/valid_to columns.
+	Validity bool `json:"validity"`
+	// Layout is the storage layout the model was registered with.
+	Layout Layout `json:"layout"`
+}
+
+// keyColumns identify a version in every versioned table.
+var keyColumns = []string{"id", "version"}
+
+var schemaCache sync.Map
+
+// RegisteredModels describes every registered model in registration order.
+// Table and column names follow GORM's default naming strategy.
+func RegisteredModels() []ModelDescriptor {
+	regs := Registrations()
+	out := make([]ModelDescriptor, 0, len(regs))
+	for _, r := range regs {
+		d, err := describeModel(r)
+		if err != nil {
+			// RegisterModel already parsed the model successfully.
+			panic(err)
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// PIIColumns returns the columns of d declared as personal data.
+func (d ModelDescriptor) PIIColumns() []string {
+	var cols []string
+	for _, f := range d.Fields {
+		if f.PII {
+			cols = append(cols, f.Column)
+		}
+	}
+	return cols
+}
+
+// LookupModel returns the descriptor of the registered model backed by table.
+func LookupModel(table string) (ModelDescriptor, bool) {
+	for _, d := range RegisteredModels() {
+		if d.Table == table {
+			return d, true
+		}
+	}
+	return ModelDescriptor{}, false
+}
+
+func describeModel(r Registration) (ModelDescriptor, error) {
+	s, err := schema.Parse(r.Model, &schemaCache, schema.NamingStrategy{})
+	if err != nil {
+		return ModelDescriptor{}, fmt.Errorf("parsing model failed: %w", err)
+	}
+	d := ModelDescriptor{
+		Name:       s.Name,
+		Table:      s.Table,
+		KeyColumns: keyColumns,
+		Strategies: Strategies{Latest: currentConfig().strategyFor(s.Table), Layout: layoutOf(r.Model)},
+		Retention:  r.Options.Retention,
+		Model:      r.Model,
+	}
+	for _, f := range s.Fields {
+		if f.DBName == "" {
+			continue
+		}
+		d.Fields = append(d.Fields, FieldDescriptor{
+			Name:   f.Name,
+			Column: f.DBName,
+			Type:   f.FieldType.String(),
+			Meta:   len(f.BindNames) > 1 && f.BindNames[0] == "Versioned",
+			PII:    slices.Contains(r.Options.PII, f.DBName),
+		})
+		switch f.DBName {
+		case "is_deleted":
+			d.Strategies.SoftDelete = true
+		case "valid_from":
+			d.Strategies.Validity = true
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,26 @@
+package scd_test
+
+import (
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+)
+
+func TestLookupModelDescribesRegisteredModel(t *testing.T) {
+	models.Register()
+	d, ok := scd.LookupModel("jobs")
+	if !ok {
+		t.Fatal("jobs is not registered")
+	}
+	if d.Name != "Job" || !d.Strategies.SoftDelete || !d.Strategies.Validity {
+		t.Errorf("unexpected descriptor: %+v", d)
+	}
+	meta := map[string]bool{}
+	for _, f := range d.Fields {
+		meta[f.Column] = f.Meta
+	}
+	if !meta["version"] || meta["status"] {
+		t.Errorf("meta flags wrong: %v", meta)
+	}
+}
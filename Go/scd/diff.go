@@ -0,0 +1,78 @@
+package scd
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FieldChange is one business field that differs between two versions.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// Diff loads versions v1 and v2 of entity id and returns the fields that
+// changed from v1 to v2.
+func Diff[T any](ctx context.Context, db *gorm.DB, id string, v1, v2 int) ([]FieldChange, error) {
+	from, err := GetVersion[T](ctx, db, id, v1)
+	if err != nil {
+		return nil, err
+	}
+	to, err := GetVersion[T](ctx, db, id, v2)
+	if err != nil {
+		return nil, err
+	}
+	return DiffValues(from, to), nil
+}
+
+// DiffValues compares two values of a versioned model field by field, in
+// declaration order. The embedded Versioned metadata is ignored; times are
+// compared as instants.
+func DiffValues[T any](from, to T) []FieldChange {
+	changes := []FieldChange{}
+	a, b := reflect.ValueOf(&from).Elem(), reflect.ValueOf(&to).Elem()
+	for a.Kind() == reflect.Pointer {
+		if a.IsNil() || b.IsNil() {
+			return changes
+		}
+		a, b = a.Elem(), b.Elem()
+	}
+	t := a.Type()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() || (f.Anonymous && f.Name == "Versioned") {
+			continue
+		}
+		old, cur := a.Field(i).Interface(), b.Field(i).Interface()
+		if !equalField(old, cur) {
+			changes = append(changes, FieldChange{Field: f.Name, Old: old, New: cur})
+		}
+	}
+	return changes
+}
+
+func equalField(a, b any) bool {
+	if ta, ok := a.(time.Time); ok {
+		return ta.Equal(b.(time.Time))
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// unchanged reports whether next differs from latest in nothing but version
+// metadata and fields excluded from cloning with scd:"-".
+func unchanged[T any](latest, next T) bool {
+	if isDeleted(&latest) != isDeleted(&next) {
+		return false
+	}
+	t := reflect.TypeOf(latest)
+	for _, c := range DiffValues(latest, next) {
+		if f, _ := t.FieldByName(c.Field); f.Tag.Get("scd") != "-" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,21 @@
+package scd_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+)
+
+func TestDiffValuesReportsBusinessFields(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	from := models.Timelog{Versioned: models.Versioned{ID: "t1", Version: 1}, Duration: 2, TimeStart: start, Type: "captured"}
+	to := from
+	to.Version, to.Duration, to.TimeStart = 2, 3, start.In(time.FixedZone("CET", 3600))
+
+	changes := scd.DiffValues(from, to)
+	if len(changes) != 1 || changes[0].Field != "Duration" || changes[0].Old != 2.0 || changes[0].New != 3.0 {
+		t.Errorf("unexpected changes: %+v", changes)
+	}
+}
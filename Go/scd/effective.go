@@ -0,0 +1,101 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// effectiveFrom is when a version takes effect in business time. Versions
+// written before valid_from was backfilled fall back to their created_at.
+const effectiveFrom = "COALESCE(valid_from, created_at, '-infinity')"
+
+// ScheduleVersion is CreateNewSCDVersionReturning for a change recorded now
+// but in effect from effective, e.g. a rate change starting next Monday. The
+// version is written immediately and becomes the latest version, while
+// EffectiveAt keeps returning the previous one until effective. An effective
+// time in the past records a retroactive correction. It returns the created
+// version. To keep a future change out of latest-version reads as well, use
+// ScheduleChange.
+func ScheduleVersion[T any, P EntityPtr[T]](ctx context.Context, db *gorm.DB, id string, effective time.Time, updateFn func(*T) error) (T, error) {
+	_, created, err := createVersion[T, P](ctx, db, id, nil, func(next *T) error {
+		if err := updateFn(next); err != nil {
+			return err
+		}
+		from := effective
+		if err := setField(next, "ValidFrom", &from); err != nil {
+			return fmt.Errorf("scheduling %s failed: %w", id, err)
+		}
+		return nil
+	})
+	return created, err
+}
+
+// EffectiveSubquery is LatestSubquery in business time: it selects, per id,
+// the version in effect at t, i.e. the one with the latest valid_from at or
+// before t, preferring the later version when two start together. With a
+// non-zero knownAt only versions created by then count, which answers "what
+// did we believe on knownAt was in effect at t" for reproducing past payroll
+// runs. Join the result against AllVersions, as for AsOfSubquery.
+func EffectiveSubquery[T any](ctx context.Context, db *gorm.DB, model T, t, knownAt time.Time) *gorm.DB {
+	return effectiveSubquery(ctx, db, &model, t, knownAt)
+}
+
+// effectiveSubquery is EffectiveSubquery of model, a pointer to a model.
+func effectiveSubquery(ctx context.Context, db *gorm.DB, model any, t, knownAt time.Time) *gorm.DB {
+	q := approvedOnly(AllVersions(ctx, db, model), model).
+		Select("DISTINCT ON (id) id, version as max_version").
+		Where(effectiveFrom+" <= ?", t).
+		Order("id, " + effectiveFrom + " DESC, version DESC")
+	if !knownAt.IsZero() {
+		q = q.Where("COALESCE(created_at, '-infinity') <= ?", knownAt)
+	}
+	return q
+}
+
+// EffectiveAt returns a scope restricting a query of T's table to the version
+// of each entity in effect at business time t, the way Latest restricts it to
+// the latest version:
+//
+//	db.WithContext(ctx).Scopes(scd.EffectiveAt[models.Job](payday)).Where("jobs.contractor_id = ?", c).Find(&jobs)
+//
+// Entities whose effective version is a tombstone are left out unless the
+// query's context comes from IncludeDeleted.
+func EffectiveAt[T any](t time.Time) func(*gorm.DB) *gorm.DB {
+	return effectiveScope[T](t, time.Time{})
+}
+
+// EffectiveAtAsOf is EffectiveAt as the history stood at knownAt, ignoring
+// versions recorded later; see EffectiveSubquery.
+func EffectiveAtAsOf[T any](t, knownAt time.Time) func(*gorm.DB) *gorm.DB {
+	return effectiveScope[T](t, knownAt)
+}
+
+func effectiveScope[T any](t, knownAt time.Time) func(*gorm.DB) *gorm.DB {
+	var model T
+	return effectiveScopeOf(&model, t, knownAt)
+}
+
+// effectiveScopeOf is the scope of EffectiveAtAsOf for model, a pointer to a
+// model, for callers knowing the model only at run time.
+func effectiveScopeOf(model any, t, knownAt time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		info, err := Describe(db, model)
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+		ctx := db.Statement.Context
+		if rel := versionsRelation(db, model, info, true); rel != quote(db, info.Table) {
+			db = db.Table(rel)
+		}
+		subq := effectiveSubquery(ctx, db.Session(&gorm.Session{NewDB: true, Initialized: true}), model, t, knownAt)
+		db = JoinVersions(db, info.Table, "effective", subq)
+		if liveOnly(ctx, info) {
+			db = db.Where("? IS NOT TRUE", Column(info.Table, "is_deleted"))
+		}
+		return db
+	}
+}
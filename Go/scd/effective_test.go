@@ -0,0 +1,35 @@
+package scd_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+)
+
+func TestEffectiveAtPicksVersionByValidFrom(t *testing.T) {
+	db := dryRunDB(t)
+	payday := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	var jobs []models.Job
+	stmt := db.WithContext(context.Background()).Scopes(scd.EffectiveAt[models.Job](payday)).Find(&jobs).Statement
+	sql := stmt.SQL.String()
+	if !strings.Contains(sql, "DISTINCT ON (id) id, version as max_version") ||
+		!strings.Contains(sql, "COALESCE(valid_from, created_at, '-infinity') <= $1") {
+		t.Errorf("effective version not selected by valid_from: %s", sql)
+	}
+	if strings.Contains(sql, "COALESCE(created_at, '-infinity')") {
+		t.Errorf("unexpected known-at filter: %s", sql)
+	}
+	if !strings.Contains(sql, `"jobs"."is_deleted" IS NOT TRUE`) {
+		t.Errorf("tombstones not excluded: %s", sql)
+	}
+
+	known := payday.AddDate(0, 0, -7)
+	stmt = db.WithContext(context.Background()).Scopes(scd.EffectiveAtAsOf[models.Job](payday, known)).Find(&jobs).Statement
+	if !strings.Contains(stmt.SQL.String(), "COALESCE(created_at, '-infinity') <= $2") || stmt.Vars[1] != known {
+		t.Errorf("versions recorded after known-at not excluded: %s %v", stmt.SQL.String(), stmt.Vars)
+	}
+}
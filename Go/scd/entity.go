@@ -0,0 +1,21 @@
+package scd
+
+// Entity is the version bookkeeping every versioned model exposes, normally
+// through an embedded models.Versioned. Helpers that write versions are
+// constrained on it, so a model that cannot be versioned fails to compile
+// instead of failing at runtime.
+type Entity interface {
+	GetID() string
+	GetVersion() int
+	SetVersion(int)
+	GetUID() string
+	SetUID(string)
+}
+
+// EntityPtr is satisfied by *T when T embeds the Entity methods. It lets
+// helpers be instantiated with the model type itself, as in
+// DeleteAsNewVersion[models.Job], while calling Entity methods on &value.
+type EntityPtr[T any] interface {
+	*T
+	Entity
+}
@@ -0,0 +1,71 @@
+package scd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/yourorg/Go/scderr"
+	"gorm.io/gorm"
+)
+
+// The errors below carry a scderr.Code, so scderr.CodeOf and
+// scderr.IsRetryable classify them wherever they end up.
+var (
+	// ErrNotFound is returned when the requested entity or version does not
+	// exist. It wraps gorm.ErrRecordNotFound, so checks for either match.
+	ErrNotFound = &scderr.Error{Code: scderr.NotFound, Message: "scd: not found", Err: gorm.ErrRecordNotFound}
+	// ErrNotVersioned is returned when a model or table is not a versioned
+	// model: it lacks the embedded Versioned columns or is not registered.
+	ErrNotVersioned = scderr.New(scderr.Validation, "scd: not a versioned model")
+	// ErrNotDeleted is returned by Restore when the latest version is not a tombstone.
+	ErrNotDeleted = scderr.New(scderr.Conflict, "scd: entity is not deleted")
+	// ErrNothingToRestore is returned by Restore when no live version precedes the tombstone.
+	ErrNothingToRestore = scderr.New(scderr.Conflict, "scd: no live version to restore")
+	// ErrStaleReference is returned when a guarded reference points at a superseded version.
+	ErrStaleReference = scderr.New(scderr.Validation, "scd: stale reference")
+	// ErrHistoryTooLarge is returned when an unpaged history read exceeds Config.MaxHistoryVersions.
+	ErrHistoryTooLarge = scderr.New(scderr.Validation, "scd: history too large to load in one call")
+	// ErrVersionConflict is returned by CreateNewSCDVersionIf when the entity
+	// has a newer version than the one the caller expected, and by every
+	// version write that keeps losing the race for the next version number.
+	ErrVersionConflict = scderr.New(scderr.Conflict, "scd: version conflict")
+	// ErrTenantMismatch is returned when a row is written for another tenant
+	// than the one in the session.
+	ErrTenantMismatch = scderr.New(scderr.Forbidden, "scd: tenant does not match session")
+	// ErrReadOnlySession is returned when a session with a read-only role writes.
+	ErrReadOnlySession = scderr.New(scderr.Forbidden, "scd: session is read-only")
+	// ErrInvalidResumeToken is returned when a bulk operation is resumed from a
+	// token it did not produce.
+	ErrInvalidResumeToken = scderr.New(scderr.Validation, "scd: invalid resume token")
+	// ErrUnsupportedUpdate is returned by the Plugin for updates it cannot turn
+	// into a single new version, and by SaveDraft for tables that cannot hold drafts.
+	ErrUnsupportedUpdate = scderr.New(scderr.Validation, "scd: update cannot be written as a new version")
+	// ErrInvalidPatch is returned when a patch is malformed or does not fit
+	// the model's schema.
+	ErrInvalidPatch = scderr.New(scderr.Validation, "scd: invalid patch")
+	// ErrPatchTestFailed is returned when a "test" operation of a JSON Patch
+	// does not match the latest version.
+	ErrPatchTestFailed = scderr.New(scderr.Conflict, "scd: patch test failed")
+	// ErrInvalidState is returned when a version is not in the state a step of
+	// the approval workflow starts from, e.g. approving a draft never submitted.
+	ErrInvalidState = scderr.New(scderr.Conflict, "scd: version is not in the required state")
+	// ErrSelfApproval is returned by ApproveVersion when the approver is the
+	// author of the version, or the context names no approver at all.
+	ErrSelfApproval = scderr.New(scderr.Forbidden, "scd: version must be approved by someone other than its author")
+	// ErrTagExists is returned by TagVersion when another version of the
+	// entity already carries the tag. Tags name one version and do not move.
+	ErrTagExists = scderr.New(scderr.Conflict, "scd: tag already names a version of the entity")
+)
+
+// Translate maps the GORM and driver errors callers care about onto the
+// values above, so layers above scd can classify them with scderr. Other
+// errors, including nil, are returned unchanged.
+func Translate(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case isVersionConflict(err):
+		return fmt.Errorf("%w: %w", ErrVersionConflict, err)
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+package scd_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/yourorg/Go/scd"
+	"github.com/yourorg/Go/scderr"
+	"gorm.io/gorm"
+)
+
+type plainRow struct {
+	ID   string
+	Name string
+}
+
+func TestDescribeRejectsUnversionedModel(t *testing.T) {
+	_, err := scd.Describe(dryRunDB(t), &plainRow{})
+	if !errors.Is(err, scd.ErrNotVersioned) {
+		t.Fatalf("err = %v, want ErrNotVersioned", err)
+	}
+}
+
+func TestErrNotFoundMatchesGorm(t *testing.T) {
+	if !errors.Is(scd.ErrNotFound, gorm.ErrRecordNotFound) {
+		t.Fatal("ErrNotFound does not wrap gorm.ErrRecordNotFound")
+	}
+}
+
+func TestErrorsAreClassified(t *testing.T) {
+	if got := scderr.CodeOf(scd.Translate(gorm.ErrRecordNotFound)); got != scderr.NotFound {
+		t.Errorf("record not found classified as %s", got)
+	}
+	wrapped := fmt.Errorf("updating job1 failed: %w", scd.ErrVersionConflict)
+	if got := scderr.CodeOf(wrapped); got != scderr.Conflict || scderr.IsRetryable(wrapped) {
+		t.Errorf("version conflict classified as %s", got)
+	}
+	if got := scderr.CodeOf(scd.ErrReadOnlySession); got != scderr.Forbidden {
+		t.Errorf("read-only session classified as %s", got)
+	}
+}
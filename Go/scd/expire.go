@@ -0,0 +1,127 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ExpireOptions selects the entities tombstoned by ExpireStale.
+type ExpireOptions struct {
+	// Where is an optional predicate over the latest rows, e.g. "status <> 'active'".
+	Where string
+	Args  []any
+	// Before only matches entities whose latest version was created before this
+	// time; the zero time matches every age. Versions without a created_at
+	// timestamp count as older than any cutoff.
+	Before time.Time
+	// BatchSize is the number of entities tombstoned per transaction (default 500).
+	BatchSize int
+	// DryRun counts matches and collects a sample without writing anything.
+	DryRun bool
+	// ChangeSet groups the tombstones; a fresh id is generated when empty.
+	// Pass the previous run's ChangeSet when resuming to keep one change set.
+	ChangeSet string
+	// Limits bound the run; see ExpireResult.Resume.
+	Limits Limits
+}
+
+// ExpireResult summarizes an ExpireStale run.
+type ExpireResult struct {
+	ChangeSet string
+	Matched   int
+	Deleted   int
+	// Sample holds up to the first 100 matched ids.
+	Sample []string
+	// Resume is set when the run stopped at its Limits before finishing.
+	Resume ResumeToken
+}
+
+// ExpireStale soft-deletes, in batches, every live entity whose latest version
+// matches opts, e.g. jobs that have not changed in two years:
+//
+//	scd.ExpireStale[models.Job](ctx, db, scd.ExpireOptions{
+//		Where:  "status <> ?", Args: []any{"active"},
+//		Before: time.Now().AddDate(-2, 0, 0),
+//	})
+//
+// All tombstones share one change set. Each batch commits on its own, so an
+// interrupted run can simply be repeated, or continued from res.Resume when it
+// stopped at opts.Limits.
+func ExpireStale[T any, P EntityPtr[T]](ctx context.Context, db *gorm.DB, opts ExpireOptions) (ExpireResult, error) {
+	const sampleSize = 100
+	res := ExpireResult{ChangeSet: opts.ChangeSet}
+	if res.ChangeSet == "" {
+		res.ChangeSet = NewChangeSetID()
+	}
+	batch := opts.BatchSize
+	if batch <= 0 {
+		batch = 500
+	}
+	where, filter := "TRUE", opts.Args
+	if opts.Where != "" {
+		where = "(" + opts.Where + ")"
+	}
+	if !opts.Before.IsZero() {
+		where = "COALESCE(created_at, '-infinity') < ? AND " + where
+		filter = append([]any{opts.Before}, filter...)
+	}
+	clause := fmt.Sprintf("WHERE NOT is_deleted AND %s AND id > ? ORDER BY id LIMIT ?", where)
+	ctx = WithChangeSet(ctx, res.ChangeSet)
+	db = db.WithContext(ctx)
+
+	var model T
+	info, err := Describe(db, model)
+	if err != nil {
+		return res, err
+	}
+	pos, err := opts.Limits.Resume.position(info.Table)
+	if err != nil {
+		return res, err
+	}
+	b := opts.Limits.start(ctx)
+	for {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		if b.exhausted() {
+			res.Resume = newResumeToken(pos)
+			return res, nil
+		}
+		started := time.Now()
+		args := append(slices.Clip(filter), pos.ID, batch)
+		var ids []string
+		if err := latestRows(db, model, "id", clause, args...).Scan(&ids).Error; err != nil {
+			return res, fmt.Errorf("selecting stale entities failed: %w", err)
+		}
+		if len(ids) == 0 {
+			return res, nil
+		}
+		res.Matched += len(ids)
+		if n := sampleSize - len(res.Sample); n > 0 {
+			res.Sample = append(res.Sample, ids[:min(n, len(ids))]...)
+		}
+		if opts.DryRun {
+			pos.ID = ids[len(ids)-1]
+			b.batchDone(started)
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, id := range ids {
+				if err := DeleteAsNewVersion[T, P](ctx, tx, id); err != nil {
+					return fmt.Errorf("tombstoning %s failed: %w", id, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return res, err
+		}
+		res.Deleted += len(ids)
+		pos.ID = ids[len(ids)-1]
+		b.batchDone(started)
+	}
+}
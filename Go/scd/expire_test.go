@@ -0,0 +1,41 @@
+package scd_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+type expireRecord struct {
+	models.Versioned
+	Status string
+}
+
+func TestExpireStaleWithoutBeforeMatchesEveryAge(t *testing.T) {
+	for _, tc := range []struct {
+		before time.Time
+		aged   bool
+		where  string
+	}{
+		{time.Time{}, false, "(status <> $1)"},
+		{time.Now().AddDate(-2, 0, 0), true, "(status <> $2)"},
+	} {
+		db, stmts := dryRunTxDB(t, func(*gorm.Statement) {})
+		// Dry-run scans fail, but the selecting statement is recorded first.
+		scd.ExpireStale[expireRecord](context.Background(), db, scd.ExpireOptions{
+			Where: "status <> ?", Args: []any{"active"}, Before: tc.before, DryRun: true,
+		})
+		sql := strings.Join(*stmts, "\n")
+		if !strings.Contains(sql, tc.where) {
+			t.Fatalf("selection lost the Where predicate:\n%s", sql)
+		}
+		if got := strings.Contains(sql, "created_at"); got != tc.aged {
+			t.Errorf("Before %v: age predicate present = %v, want %v:\n%s", tc.before, got, tc.aged, sql)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FollowMode decides what happens to dependents whose reference is
+// superseded by a new version of the entity it points at.
+type FollowMode string
+
+const (
+	// FollowRepoint creates a new version of each dependent that points at
+	// the parent's new version.
+	FollowRepoint FollowMode = "repoint"
+	// FollowFlag leaves dependents alone and records them in
+	// scd_stale_references for a person or job to act on.
+	FollowFlag FollowMode = "flag"
+)
+
+// StaleReference flags a dependent whose latest version still points at
+// StaleUID although its parent has moved on to LatestUID.
+type StaleReference struct {
+	Table     string    `gorm:"primaryKey;column:table_name"`
+	EntityID  string    `gorm:"primaryKey;column:entity_id"`
+	Field     string    `gorm:"primaryKey;column:field"`
+	StaleUID  string    `gorm:"column:stale_uid"`
+	LatestUID string    `gorm:"column:latest_uid"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+func (StaleReference) TableName() string { return "scd_stale_references" }
+
+// MigrateStaleReferences creates the table FollowFlag writes to.
+func MigrateStaleReferences(db *gorm.DB) error {
+	return db.AutoMigrate(&StaleReference{})
+}
+
+// StaleReferences lists the flagged dependents in model's table, oldest first.
+func StaleReferences(ctx context.Context, db *gorm.DB, model any) ([]StaleReference, error) {
+	info, err := Describe(db, model)
+	if err != nil {
+		return nil, err
+	}
+	var flags []StaleReference
+	err = db.WithContext(ctx).Where("table_name = ?", info.Table).Order("created_at, entity_id").Find(&flags).Error
+	if err != nil {
+		return nil, fmt.Errorf("listing stale references of %s failed: %w", info.Table, err)
+	}
+	return flags, nil
+}
+
+// FollowLatest enforces "track latest" semantics eagerly for one relationship:
+// whenever ref.Target gains a version, the live dependents of type T whose
+// ref.Field holds the UID of one of its earlier versions are repointed or
+// flagged according to mode. Tombstones are versions too, so deleting a
+// parent is followed like any other change:
+//
+//	scd.FollowLatest[models.Timelog](scd.Reference{Field: "JobUID", Target: models.Job{}}, scd.FollowRepoint)
+//
+// Dependents are handled in the parent's transaction, and repointed versions
+// run their own handlers, so chains of FollowLatest relationships cascade.
+// FollowFlag needs MigrateStaleReferences; a flag is cleared once a later
+// version of the dependent stops pointing at the stale UID.
+func FollowLatest[T any, P EntityPtr[T]](ref Reference, mode FollowMode) {
+	OnVersionCreated(ref.Target, func(tx *gorm.DB, event VersionEvent) error {
+		return followLatest[T, P](tx, ref, mode, event)
+	})
+	if mode == FollowFlag {
+		var model T
+		OnVersionCreated(model, func(tx *gorm.DB, event VersionEvent) error {
+			return clearStaleReference(tx, ref, event)
+		})
+	}
+}
+
+func followLatest[T any, P EntityPtr[T]](tx *gorm.DB, ref Reference, mode FollowMode, event VersionEvent) error {
+	var model T
+	info, err := Describe(tx, &model)
+	if err != nil {
+		return err
+	}
+	column, err := columnOf(tx, &model, ref.Field)
+	if err != nil {
+		return err
+	}
+	parents, err := versionsOf(tx, ref.Target)
+	if err != nil {
+		return err
+	}
+	ctx := tx.Statement.Context
+	var stale []struct {
+		ID       string
+		StaleUID string
+	}
+	err = tx.Raw(fmt.Sprintf(`SELECT cur.id, cur.%[1]s AS stale_uid FROM %[2]s cur
+		JOIN (?) AS latest ON cur.id = latest.id AND cur.version = latest.max_version
+		WHERE cur.%[1]s IN (SELECT uid FROM %[3]s WHERE id = ? AND uid <> ?)`, quote(tx, column), quote(tx, info.Table), parents),
+		LiveSubquery(ctx, tx, model), event.ID, event.UID).Scan(&stale).Error
+	if err != nil {
+		return fmt.Errorf("finding dependents of %s %s failed: %w", event.Table, event.ID, err)
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	switch mode {
+	case FollowRepoint:
+		for _, s := range stale {
+			_, _, err := createVersion[T, P](ctx, tx, s.ID, nil, func(dependent *T) error {
+				return setField(dependent, ref.Field, event.UID)
+			})
+			if err != nil {
+				return fmt.Errorf("repointing %s %s failed: %w", info.Table, s.ID, err)
+			}
+		}
+		return nil
+	case FollowFlag:
+		flags := make([]StaleReference, len(stale))
+		for i, s := range stale {
+			flags[i] = StaleReference{
+				Table: info.Table, EntityID: s.ID, Field: column,
+				StaleUID: s.StaleUID, LatestUID: event.UID, CreatedAt: time.Now(),
+			}
+		}
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&flags).Error; err != nil {
+			return fmt.Errorf("flagging dependents of %s %s failed: %w", event.Table, event.ID, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown follow mode %q", mode)
+}
+
+// clearStaleReference drops the flag of a dependent whose new version no
+// longer holds the stale UID.
+func clearStaleReference(tx *gorm.DB, ref Reference, event VersionEvent) error {
+	column, err := columnOf(tx, event.Entity, ref.Field)
+	if err != nil {
+		return err
+	}
+	var current string
+	if f := reflect.Indirect(reflect.ValueOf(event.Entity)).FieldByName(ref.Field); f.IsValid() {
+		current = fmt.Sprint(f.Interface())
+	}
+	err = tx.Where("table_name = ? AND entity_id = ? AND field = ? AND stale_uid <> ?", event.Table, event.ID, column, current).
+		Delete(&StaleReference{}).Error
+	if err != nil {
+		return fmt.Errorf("clearing stale reference of %s %s failed: %w", event.Table, event.ID, err)
+	}
+	return nil
+}
+
+// columnOf returns the column backing field of model.
+func columnOf(db *gorm.DB, model any, field string) (string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return "", fmt.Errorf("parsing model failed: %w", err)
+	}
+	f := stmt.Schema.LookUpField(field)
+	if f == nil || f.DBName == "" {
+		return "", fmt.Errorf("reference field %s not found on %s", field, stmt.Schema.Name)
+	}
+	return f.DBName, nil
+}
@@ -0,0 +1,159 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// GraphNode is one version in a Graph.
+type GraphNode struct {
+	UID     string `json:"uid"`
+	Table   string `json:"table"`
+	ID      string `json:"id"`
+	Version int    `json:"version"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// GraphEdge points from a version to a version it relates to: the next
+// version of the same entity (Kind "next") or a version it was derived from
+// (Kind "derived_from", with the referencing Field).
+type GraphEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Kind  string `json:"kind"`
+	Field string `json:"field,omitempty"`
+}
+
+// Graph is the version and provenance graph around one entity, ready to be
+// encoded as JSON or rendered with WriteDOT.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// EntityGraph builds the Graph of every version of entity id in table, the
+// versions they were derived from and the versions derived from them, as
+// recorded by TrackProvenance. table must belong to a registered model.
+func EntityGraph(ctx context.Context, db *gorm.DB, table, id string) (Graph, error) {
+	var g Graph
+	d, ok := LookupModel(table)
+	if !ok {
+		return g, fmt.Errorf("%w: %s is not registered", ErrNotVersioned, table)
+	}
+	db = db.WithContext(ctx)
+	from, err := versionsOf(db, d.Model)
+	if err != nil {
+		return g, err
+	}
+	var versions []GraphNode
+	err = db.Raw(fmt.Sprintf(`SELECT uid, ? AS "table", id, version, is_deleted AS deleted FROM %s WHERE id = ? ORDER BY version`, from), table, id).
+		Scan(&versions).Error
+	if err != nil {
+		return g, fmt.Errorf("loading versions of %s %s failed: %w", table, id, err)
+	}
+	if len(versions) == 0 {
+		return g, fmt.Errorf("%w: %s %s", ErrNotFound, table, id)
+	}
+	uids := make([]string, len(versions))
+	for i, v := range versions {
+		uids[i] = v.UID
+		if i > 0 {
+			g.Edges = append(g.Edges, GraphEdge{From: versions[i-1].UID, To: v.UID, Kind: "next"})
+		}
+	}
+
+	nodes := map[string]GraphNode{}
+	for _, v := range versions {
+		nodes[v.UID] = v
+	}
+	byTable := map[string][]string{}
+	for _, downstream := range []bool{false, true} {
+		edges, err := walkProvenance(ctx, db, uids, downstream)
+		if err != nil {
+			return g, fmt.Errorf("loading provenance of %s %s failed: %w", table, id, err)
+		}
+		for _, e := range edges {
+			g.Edges = append(g.Edges, GraphEdge{From: e.UID, To: e.SourceUID, Kind: "derived_from", Field: e.Field})
+			for uid, t := range map[string]string{e.UID: e.Table, e.SourceUID: e.SourceTable} {
+				if _, seen := nodes[uid]; !seen {
+					nodes[uid] = GraphNode{UID: uid, Table: t}
+					byTable[t] = append(byTable[t], uid)
+				}
+			}
+		}
+	}
+	for t, tableUIDs := range byTable {
+		d, ok := LookupModel(t)
+		if !ok {
+			continue
+		}
+		from, err := versionsOf(db, d.Model)
+		if err != nil {
+			return g, err
+		}
+		var found []GraphNode
+		err = db.Raw(fmt.Sprintf(`SELECT uid, ? AS "table", id, version, is_deleted AS deleted FROM %s WHERE uid IN ?`, from), t, tableUIDs).
+			Scan(&found).Error
+		if err != nil {
+			return g, fmt.Errorf("loading versions of %s failed: %w", t, err)
+		}
+		for _, n := range found {
+			nodes[n.UID] = n
+		}
+	}
+
+	for _, n := range nodes {
+		g.Nodes = append(g.Nodes, n)
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		a, b := g.Nodes[i], g.Nodes[j]
+		if a.Table != b.Table {
+			return a.Table < b.Table
+		}
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return a.Version < b.Version
+	})
+	return g, nil
+}
+
+// WriteDOT renders g in Graphviz DOT, one cluster per entity.
+func (g Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph scd {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	cluster := ""
+	for _, n := range g.Nodes {
+		if key := n.Table + "/" + n.ID; key != cluster {
+			if cluster != "" {
+				b.WriteString("\t}\n")
+			}
+			cluster = key
+			fmt.Fprintf(&b, "\tsubgraph %q {\n\t\tlabel=%q;\n", "cluster_"+key, key)
+		}
+		label := fmt.Sprintf("%s %s v%d", n.Table, n.ID, n.Version)
+		style := ""
+		if n.Deleted {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&b, "\t\t%q [label=%q%s];\n", n.UID, label, style)
+	}
+	if cluster != "" {
+		b.WriteString("\t}\n")
+	}
+	for _, e := range g.Edges {
+		if e.Kind == "next" {
+			fmt.Fprintf(&b, "\t%q -> %q [style=dotted];\n", e.From, e.To)
+			continue
+		}
+		fmt.Fprintf(&b, "\t%q -> %q [label=%q];\n", e.From, e.To, e.Field)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
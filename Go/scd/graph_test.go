@@ -0,0 +1,41 @@
+package scd_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yourorg/Go/scd"
+)
+
+func TestGraphWriteDOT(t *testing.T) {
+	g := scd.Graph{
+		Nodes: []scd.GraphNode{
+			{UID: "j1", Table: "jobs", ID: "job1", Version: 1},
+			{UID: "j2", Table: "jobs", ID: "job1", Version: 2},
+			{UID: "p1", Table: "payment_line_items", ID: "pli1", Version: 1, Deleted: true},
+		},
+		Edges: []scd.GraphEdge{
+			{From: "j1", To: "j2", Kind: "next"},
+			{From: "p1", To: "j1", Kind: "derived_from", Field: "job_uid"},
+		},
+	}
+	var b strings.Builder
+	if err := g.WriteDOT(&b); err != nil {
+		t.Fatal(err)
+	}
+	dot := b.String()
+	for _, want := range []string{
+		`subgraph "cluster_jobs/job1"`,
+		`"j2" [label="jobs job1 v2"];`,
+		`"p1" [label="payment_line_items pli1 v1", style=dashed];`,
+		`"j1" -> "j2" [style=dotted];`,
+		`"p1" -> "j1" [label="job_uid"];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("missing %s in:\n%s", want, dot)
+		}
+	}
+	if strings.Count(dot, "subgraph") != 2 {
+		t.Errorf("expected one cluster per entity:\n%s", dot)
+	}
+}
@@ -0,0 +1,55 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// HistoryOptions narrows GetHistory. The zero value returns every version.
+type HistoryOptions struct {
+	// AfterVersion skips versions up to and including it; pass the last
+	// version of the previous page to continue.
+	AfterVersion int
+	// Limit caps the number of versions returned. Without it the whole
+	// history is loaded, subject to Config.MaxHistoryVersions.
+	Limit int
+	// From and To restrict versions to created_at in [From, To); zero values
+	// leave that end open. Versions without created_at only match an open From.
+	From, To time.Time
+	// Override lifts the history size limit for unpaged reads.
+	Override bool
+}
+
+// GetHistory returns the versions of entity id in version order. An unpaged
+// read fails with ErrHistoryTooLarge when the entity has more versions than
+// Config.MaxHistoryVersions; page with Limit and AfterVersion instead.
+// Versions offloaded to Config.ColdStorage are included.
+func GetHistory[T any](ctx context.Context, db *gorm.DB, id string, opts HistoryOptions) ([]T, error) {
+	var model T
+	if opts.Limit <= 0 {
+		if _, err := CheckHistorySize(ctx, db, &model, id, opts.Override); err != nil {
+			return nil, err
+		}
+	}
+	q := AllVersions(ctx, db, &model).Where("id = ?", id).Order("version")
+	if opts.AfterVersion > 0 {
+		q = q.Where("version > ?", opts.AfterVersion)
+	}
+	if !opts.From.IsZero() {
+		q = q.Where("created_at >= ?", opts.From)
+	}
+	if !opts.To.IsZero() {
+		q = q.Where("COALESCE(created_at, '-infinity') < ?", opts.To)
+	}
+	if opts.Limit > 0 {
+		q = q.Limit(opts.Limit)
+	}
+	var versions []T
+	if err := q.Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("fetching history failed: %w", err)
+	}
+	return versions, nil
+}
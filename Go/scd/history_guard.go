@@ -0,0 +1,23 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// CheckHistorySize counts the versions of entity id and fails with
+// ErrHistoryTooLarge when there are more than Config.MaxHistoryVersions, unless
+// override is set. Callers that load a full history in one call should check
+// first and fall back to paging on error.
+func CheckHistorySize(ctx context.Context, db *gorm.DB, model any, id string, override bool) (int64, error) {
+	var count int64
+	if err := AllVersions(ctx, db, model).Where("id = ?", id).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("counting versions failed: %w", err)
+	}
+	if limit := currentConfig().maxHistoryVersions(); !override && count > int64(limit) {
+		return count, fmt.Errorf("%w: %s has %d versions, limit is %d", ErrHistoryTooLarge, id, count, limit)
+	}
+	return count, nil
+}
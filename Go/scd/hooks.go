@@ -0,0 +1,127 @@
+package scd
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// EventKind tells why a version was created.
+type EventKind string
+
+const (
+	EventVersion EventKind = "version"
+	EventDelete  EventKind = "delete"
+	EventRestore EventKind = "restore"
+	EventRevert  EventKind = "revert"
+	// EventApprove is a draft taking effect; see ApproveVersion.
+	EventApprove EventKind = "approve"
+)
+
+// VersionEvent describes a version that was just created. Entity points to the new row.
+type VersionEvent struct {
+	Table   string
+	ID      string
+	Version int
+	UID     string
+	Kind    EventKind
+	// Seq is the change log sequence number, or 0 when the change log is disabled.
+	Seq int64
+	// ChangeSet is the change set the version was created in, if any.
+	ChangeSet string
+	// Actor is the actor of the write (see WithActor), if any.
+	Actor  string
+	Entity any
+}
+
+// VersionHandler reacts to a new version. It runs inside the transaction that
+// created the version, so writes through tx commit or roll back with it and a
+// returned error aborts the version.
+type VersionHandler func(tx *gorm.DB, event VersionEvent) error
+
+var (
+	handlersMu  sync.RWMutex
+	handlers    = map[reflect.Type][]VersionHandler{}
+	anyHandlers []VersionHandler
+)
+
+// OnVersionCreated registers handler for every version of model's type created
+// through this package, including deletes and restores.
+func OnVersionCreated(model any, handler VersionHandler) {
+	t := modelType(model)
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[t] = append(handlers[t], handler)
+}
+
+// OnAnyVersionCreated registers handler for versions of every model. These
+// handlers run after the model-specific ones.
+func OnAnyVersionCreated(handler VersionHandler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	anyHandlers = append(anyHandlers, handler)
+}
+
+func runHandlers(tx *gorm.DB, event VersionEvent) error {
+	handlersMu.RLock()
+	hs := append(slices.Clone(handlers[modelType(event.Entity)]), anyHandlers...)
+	handlersMu.RUnlock()
+	for _, h := range hs {
+		if err := h(tx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newEvent(db *gorm.DB, entity any, kind EventKind) (VersionEvent, error) {
+	info, err := Describe(db, entity)
+	if err != nil {
+		return VersionEvent{}, err
+	}
+	id, version, uid := versionedKey(entity)
+	return VersionEvent{
+		Table: info.Table, ID: id, Version: version, UID: uid,
+		Kind: kind, ChangeSet: changeSetOf(db), Actor: actorOf(db), Entity: entity,
+	}, nil
+}
+
+func modelType(model any) reflect.Type {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// OnFieldChanged registers handler for the versions of model's type whose
+// field, a struct field name such as "Rate", differs from the latest
+// approved version before them, e.g. to reprice what depends on a job's
+// rate. First versions have nothing to differ from and are skipped. It
+// panics when model has no such field.
+func OnFieldChanged(model any, field string, handler VersionHandler) {
+	t := modelType(model)
+	if _, ok := t.FieldByName(field); !ok {
+		panic(fmt.Sprintf("scd: OnFieldChanged: %s has no field %s", t, field))
+	}
+	OnVersionCreated(model, func(tx *gorm.DB, event VersionEvent) error {
+		previous := reflect.New(t)
+		res := approvedOnly(AllVersions(tx.Statement.Context, tx, previous.Interface()).
+			Where("id = ? AND version < ?", event.ID, event.Version), previous.Interface()).
+			Order("version DESC").Limit(1).Find(previous.Interface())
+		if res.Error != nil {
+			return fmt.Errorf("reading version before %s v%d failed: %w", event.ID, event.Version, res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		current := reflect.Indirect(reflect.ValueOf(event.Entity))
+		if reflect.DeepEqual(current.FieldByName(field).Interface(), previous.Elem().FieldByName(field).Interface()) {
+			return nil
+		}
+		return handler(tx, event)
+	})
+}
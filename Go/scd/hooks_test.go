@@ -0,0 +1,18 @@
+package scd_test
+
+import (
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func TestOnFieldChangedRejectsUnknownField(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("OnFieldChanged accepted a field the model does not have")
+		}
+	}()
+	scd.OnFieldChanged(models.Job{}, "Salary", func(*gorm.DB, scd.VersionEvent) error { return nil })
+}
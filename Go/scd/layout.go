@@ -0,0 +1,220 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Layout names how a model's versions are stored, chosen per model with
+// ModelOptions.Layout.
+type Layout string
+
+const (
+	// LayoutSingleTable keeps every version in the model's table. It is the default.
+	LayoutSingleTable Layout = "single_table"
+	// LayoutHistoryTable keeps only the latest version of each entity in the
+	// model's table and moves superseded versions to <table>_versions, so the
+	// main table stays as small as the set of entities.
+	LayoutHistoryTable Layout = "history_table"
+	// LayoutCurrentHistory is SCD Type 4: the model's table holds only the
+	// latest version of each entity, and <table>_history receives every
+	// version, the latest included, as it is written. History reads never
+	// touch the main table, and latest reads never touch the history.
+	LayoutCurrentHistory Layout = "current_history"
+)
+
+// layoutOf returns the layout model was registered with.
+func layoutOf(model any) Layout {
+	if r, ok := registrationFor(modelType(model)); ok && r.Options.Layout != "" {
+		return r.Options.Layout
+	}
+	return LayoutSingleTable
+}
+
+// historyTable returns the table holding model's superseded versions under
+// its layout, or "" when they stay in the main table.
+func historyTable(model any, table string) string {
+	switch layoutOf(model) {
+	case LayoutHistoryTable:
+		return table + "_versions"
+	case LayoutCurrentHistory:
+		return table + "_history"
+	}
+	return ""
+}
+
+// latestOnly reports whether model's table holds nothing but the latest
+// version of each entity, so reads of the latest versions need no join.
+func latestOnly(model any) bool {
+	return layoutOf(model) != LayoutSingleTable
+}
+
+// versionsRelation renders a FROM item holding every version of model,
+// aliased to its table name so it can stand in for the table. Under
+// LayoutHistoryTable that is the table plus <table>_versions, under
+// LayoutCurrentHistory <table>_history alone; with cold set,
+// versions offloaded to Config.ColdStorage are added as well. It is the bare
+// table name, quoted for db's dialect, when there is nothing to combine.
+func versionsRelation(db *gorm.DB, model any, info TableInfo, cold bool) string {
+	parts := []string{info.Table}
+	switch layoutOf(model) {
+	case LayoutHistoryTable:
+		parts = append(parts, info.Table+"_versions")
+	case LayoutCurrentHistory:
+		parts = []string{info.Table + "_history"}
+	}
+	var coldRelation string
+	if cold {
+		coldRelation = currentConfig().ColdStorage[info.Table]
+	}
+	if len(parts) == 1 && parts[0] == info.Table && coldRelation == "" {
+		return quote(db, info.Table)
+	}
+	cols := append(append([]string(nil), info.Meta...), info.Columns...)
+	selects := make([]string, len(parts))
+	for i, p := range parts {
+		selects[i] = fmt.Sprintf("SELECT %s FROM %s", quoteColumns(db, "", cols), quote(db, p))
+	}
+	if coldRelation != "" {
+		var hot []string
+		for _, p := range parts {
+			hot = append(hot, fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s h WHERE h.id = c.id AND h.version = c.version)", quote(db, p)))
+		}
+		selects = append(selects, fmt.Sprintf("SELECT %s FROM %s c WHERE %s", quoteColumns(db, "c", cols), quote(db, coldRelation), strings.Join(hot, " AND ")))
+	}
+	return fmt.Sprintf("(%s) AS %s", strings.Join(selects, "\n\t\tUNION ALL\n\t\t"), quote(db, tableRef(info.Table)))
+}
+
+// versionsAs is versionsRelation under alias rather than the table name, for
+// queries that refer to every version and to the table itself at once.
+func versionsAs(db *gorm.DB, model any, info TableInfo, alias string) string {
+	rel := versionsRelation(db, model, info, false)
+	if rel == quote(db, info.Table) {
+		return rel + " " + alias
+	}
+	return fmt.Sprintf("(SELECT * FROM %s) %s", rel, alias)
+}
+
+// versionsOf is versionsRelation for a model that still has to be described.
+func versionsOf(db *gorm.DB, model any) (string, error) {
+	info, err := Describe(db, model)
+	if err != nil {
+		return "", err
+	}
+	return versionsRelation(db, model, info, true), nil
+}
+
+// PrepareLayout brings model's tables in line with the layout it was
+// registered with. Moving to LayoutHistoryTable creates <table>_versions,
+// moves every superseded version into it and installs the trigger that moves
+// the previous latest version on each insert. Moving to LayoutCurrentHistory
+// copies every version into <table>_history, drops the superseded ones from
+// the main table and installs triggers that append each insert to the history
+// and remove the version it supersedes, in the writer's transaction. Moving
+// back folds the history into the main table again. Writers need no changes
+// either way, and reads through the scd package see the same versions. It is
+// safe to re-run; switching between the two history layouts goes through
+// LayoutSingleTable.
+//
+// LayoutHistoryTable cannot be combined with ApplyVerticalSplit, which uses
+// the same <table>_versions name.
+func PrepareLayout(ctx context.Context, db *gorm.DB, model any) error {
+	info, err := Describe(db, model)
+	if err != nil {
+		return err
+	}
+	db = db.WithContext(ctx)
+	t := info.Table
+	qt, fn := quote(db, t), quote(db, derivedName(t, "scd_history_", ""))
+	versions, history := quote(db, t+"_versions"), quote(db, t+"_history")
+	current := quote(db, "idx_"+tableRef(t)+"_current")
+	var state struct {
+		Installed bool
+		Versions  bool
+		History   bool
+	}
+	err = db.Raw(`SELECT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'scd_history' AND tgrelid = CAST(@t AS regclass)) AS installed,
+		to_regclass(@versions) IS NOT NULL AS versions, to_regclass(@history) IS NOT NULL AS history`,
+		map[string]any{"t": qt, "versions": versions, "history": history}).Scan(&state).Error
+	if err != nil {
+		return fmt.Errorf("inspecting layout of %s failed: %w", t, err)
+	}
+
+	var stmts []string
+	switch layout := layoutOf(model); layout {
+	case LayoutHistoryTable:
+		if state.Installed && state.History {
+			return fmt.Errorf("moving %s to %s: prepare %s first", t, layout, LayoutSingleTable)
+		}
+		stmts = []string{
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (LIKE %s INCLUDING ALL)", versions, qt),
+			fmt.Sprintf(`CREATE OR REPLACE FUNCTION %[1]s() RETURNS trigger AS $$
+			BEGIN
+				WITH moved AS (DELETE FROM %[2]s WHERE id = NEW.id AND version < NEW.version RETURNING *)
+				INSERT INTO %[3]s SELECT * FROM moved;
+				RETURN NEW;
+			END
+			$$ LANGUAGE plpgsql`, fn, qt, versions),
+			fmt.Sprintf("DROP TRIGGER IF EXISTS scd_history ON %s", qt),
+			fmt.Sprintf("CREATE TRIGGER scd_history BEFORE INSERT ON %s FOR EACH ROW EXECUTE FUNCTION %s()", qt, fn),
+			fmt.Sprintf(`WITH moved AS (
+				DELETE FROM %[1]s o WHERE EXISTS (SELECT 1 FROM %[1]s n WHERE n.id = o.id AND n.version > o.version) RETURNING o.*)
+			INSERT INTO %[2]s SELECT * FROM moved`, qt, versions),
+			// One row per entity is what the layout promises; the index enforces it.
+			fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (id)", current, qt),
+		}
+	case LayoutCurrentHistory:
+		if state.Installed && state.Versions {
+			return fmt.Errorf("moving %s to %s: prepare %s first", t, layout, LayoutSingleTable)
+		}
+		stmts = []string{
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (LIKE %s INCLUDING ALL)", history, qt),
+			// Appending after the insert records the row as finally written;
+			// the history's primary key rejects a version written twice, so
+			// concurrent writers still see a version conflict.
+			fmt.Sprintf(`CREATE OR REPLACE FUNCTION %[1]s() RETURNS trigger AS $$
+			BEGIN
+				IF TG_WHEN = 'BEFORE' THEN
+					DELETE FROM %[2]s WHERE id = NEW.id AND version < NEW.version;
+				ELSE
+					INSERT INTO %[3]s SELECT NEW.*;
+				END IF;
+				RETURN NEW;
+			END
+			$$ LANGUAGE plpgsql`, fn, qt, history),
+			fmt.Sprintf("DROP TRIGGER IF EXISTS scd_history ON %s", qt),
+			fmt.Sprintf("DROP TRIGGER IF EXISTS scd_history_append ON %s", qt),
+			fmt.Sprintf("CREATE TRIGGER scd_history BEFORE INSERT ON %s FOR EACH ROW EXECUTE FUNCTION %s()", qt, fn),
+			fmt.Sprintf("CREATE TRIGGER scd_history_append AFTER INSERT ON %s FOR EACH ROW EXECUTE FUNCTION %s()", qt, fn),
+			fmt.Sprintf("INSERT INTO %s SELECT * FROM %s ON CONFLICT DO NOTHING", history, qt),
+			fmt.Sprintf("DELETE FROM %[1]s o WHERE EXISTS (SELECT 1 FROM %[1]s n WHERE n.id = o.id AND n.version > o.version)", qt),
+			fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (id)", current, qt),
+		}
+	case LayoutSingleTable:
+		if !state.Installed {
+			return nil
+		}
+		stmts = []string{
+			fmt.Sprintf("DROP TRIGGER scd_history ON %s", qt),
+			fmt.Sprintf("DROP TRIGGER IF EXISTS scd_history_append ON %s", qt),
+			fmt.Sprintf("DROP FUNCTION IF EXISTS %s()", fn),
+			fmt.Sprintf("DROP INDEX IF EXISTS %s", quote(db, derivedName(t, "idx_", "_current"))),
+		}
+		if state.History {
+			// The history holds the latest versions as well.
+			stmts = append(stmts,
+				fmt.Sprintf("INSERT INTO %s SELECT * FROM %s ON CONFLICT DO NOTHING", qt, history),
+				fmt.Sprintf("DROP TABLE %s", history))
+		} else {
+			stmts = append(stmts,
+				fmt.Sprintf("INSERT INTO %s SELECT * FROM %s", qt, versions),
+				fmt.Sprintf("DROP TABLE %s", versions))
+		}
+	default:
+		return fmt.Errorf("unknown layout %q", layout)
+	}
+	return execAll(ctx, db, "preparing layout of "+t, stmts)
+}
@@ -0,0 +1,61 @@
+package scd_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+)
+
+type layoutRecord struct {
+	models.Versioned
+	Name string
+}
+
+func TestHistoryLayoutReadsBothTables(t *testing.T) {
+	scd.RegisterModel(layoutRecord{}, scd.ModelOptions{Layout: scd.LayoutHistoryTable})
+	db := dryRunDB(t)
+	ctx := context.Background()
+
+	var history []layoutRecord
+	sql := scd.AllVersions(ctx, db, &layoutRecord{}).Where("id = ?", "r1").Find(&history).Statement.SQL.String()
+	if !strings.Contains(sql, `FROM "layout_records"`+"\n") {
+		t.Errorf("main table missing from history read: %s", sql)
+	}
+	if !strings.Contains(sql, `FROM "layout_records_versions"`) {
+		t.Errorf("history table missing from history read: %s", sql)
+	}
+
+	var latest []layoutRecord
+	sql = db.Table("layout_records cur").
+		Joins("JOIN (?) AS latest ON cur.id = latest.id AND cur.version = latest.max_version", scd.LatestSubquery(ctx, db, &layoutRecord{})).
+		Find(&latest).Statement.SQL.String()
+	if strings.Contains(sql, "_versions") {
+		t.Errorf("latest read should only touch the main table: %s", sql)
+	}
+}
+
+type currentRecord struct {
+	models.Versioned
+	Name string
+}
+
+func TestCurrentHistoryLayoutSkipsLatestJoin(t *testing.T) {
+	scd.RegisterModel(currentRecord{}, scd.ModelOptions{Layout: scd.LayoutCurrentHistory})
+	db := dryRunDB(t)
+	ctx := context.Background()
+
+	var history []currentRecord
+	sql := scd.AllVersions(ctx, db, &currentRecord{}).Where("id = ?", "r1").Find(&history).Statement.SQL.String()
+	if !strings.Contains(sql, `FROM "current_records_history") AS "current_records"`) || strings.Contains(sql, `FROM "current_records"`+"\n") {
+		t.Errorf("history read should only touch the history table: %s", sql)
+	}
+
+	var latest []currentRecord
+	sql = db.WithContext(ctx).Scopes(scd.Latest[currentRecord]()).Where("name = ?", "a").Find(&latest).Statement.SQL.String()
+	if strings.Contains(sql, "JOIN") || strings.Contains(sql, "_history") {
+		t.Errorf("latest read should be a plain scan of the current table: %s", sql)
+	}
+}
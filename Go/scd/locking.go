@@ -0,0 +1,82 @@
+package scd
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxVersionAttempts bounds how often a write is retried after losing the race
+// for the next version number.
+const maxVersionAttempts = 5
+
+// lockLatest reads the latest version of id into dest and locks it until tx
+// ends, so concurrent writers of the same entity queue up behind each other.
+// It returns the number of the newest row of id, which new versions follow:
+// when drafts were saved on top of the latest version (see SaveDraft), the
+// newest draft is locked and dest holds the latest approved version.
+//
+// Under the history layouts the trigger of the writer holding the lock
+// deletes the locked row, so a writer that waited for it finds no row at all;
+// lockLatest reports that as errLatestMoved, a version conflict, when the
+// entity exists once the lock is released.
+func lockLatest(tx *gorm.DB, id string, dest any) (int, error) {
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", id).Order("version DESC").First(dest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var rows int64
+		if tx.Model(dest).Where("id = ?", id).Count(&rows).Error == nil && rows > 0 {
+			err = errLatestMoved
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("fetching latest version failed: %w", Translate(err))
+	}
+	_, newest, _ := versionedKey(dest)
+	if approved(dest) {
+		return newest, nil
+	}
+	// The history layouts keep the approved version under a draft in the
+	// history relation.
+	reflect.ValueOf(dest).Elem().SetZero()
+	err = approvedOnly(AllVersions(tx.Statement.Context, tx, dest).Where("id = ?", id), dest).Order("version DESC").Take(dest).Error
+	if err != nil {
+		return 0, fmt.Errorf("fetching latest approved version failed: %w", Translate(err))
+	}
+	return newest, nil
+}
+
+// retryOnVersionConflict runs fn until it succeeds, fails for another reason
+// or maxVersionAttempts is reached. A writer blocked by lockLatest sees the
+// old latest row once the lock is released and collides with the version
+// created meanwhile; running fn again picks up the new latest version.
+func retryOnVersionConflict(fn func() error) error {
+	var err error
+	for range maxVersionAttempts {
+		if err = fn(); !isVersionConflict(err) {
+			return err
+		}
+	}
+	return Translate(err)
+}
+
+// errLatestMoved is how lockLatest reports that the row it waited for was
+// replaced by a newer version.
+var errLatestMoved = errors.New("latest version moved while waiting for its lock")
+
+// isVersionConflict reports whether err means another writer already created
+// the version being written: a violation of the primary key, or of the
+// one-row-per-entity index of the history layouts, or errLatestMoved.
+func isVersionConflict(err error) bool {
+	if errors.Is(err, errLatestMoved) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" &&
+		(strings.HasSuffix(pgErr.ConstraintName, "_pkey") || strings.HasSuffix(pgErr.ConstraintName, "_current"))
+}
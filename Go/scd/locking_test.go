@@ -0,0 +1,114 @@
+package scd_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func TestCurrentIndexViolationIsVersionConflict(t *testing.T) {
+	for _, constraint := range []string{"jobs_pkey", "idx_jobs_current"} {
+		err := scd.Translate(&pgconn.PgError{Code: "23505", ConstraintName: constraint})
+		if !errors.Is(err, scd.ErrVersionConflict) {
+			t.Errorf("violation of %s = %v, want ErrVersionConflict", constraint, err)
+		}
+	}
+	if err := scd.Translate(&pgconn.PgError{Code: "23505", ConstraintName: "idx_jobs_uid"}); errors.Is(err, scd.ErrVersionConflict) {
+		t.Errorf("violation of the uid index reported as a version conflict")
+	}
+}
+
+// A writer that waited for the lock on a row the history layouts' trigger
+// deleted finds no row; it must retry rather than report the entity missing.
+func TestWriterRetriesWhenLockedRowMoved(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		exists  int64
+		wantErr error
+		locks   int
+	}{
+		{"moved", 1, nil, 2},
+		{"missing", 0, scd.ErrNotFound, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			locks := 0
+			db, stmts := dryRunTxDB(t, func(stmt *gorm.Statement) {
+				switch dest := stmt.Dest.(type) {
+				case *int64:
+					*dest, stmt.DB.RowsAffected = tc.exists, 1
+				case *models.Job:
+					if !strings.Contains(stmt.SQL.String(), "FOR UPDATE") {
+						return
+					}
+					if locks++; locks == 1 {
+						stmt.DB.AddError(gorm.ErrRecordNotFound)
+						return
+					}
+					dest.ID, dest.Version, dest.VersionState = "job1", 4, scd.StateApproved
+				}
+			})
+			err := scd.CreateNewSCDVersion[models.Job](context.Background(), db, "job1", func(j *models.Job) error {
+				j.Rate = 120
+				return nil
+			})
+			if !errors.Is(err, tc.wantErr) && err != tc.wantErr {
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			}
+			if locks != tc.locks {
+				t.Errorf("latest version locked %d times, want %d:\n%s", locks, tc.locks, strings.Join(*stmts, "\n"))
+			}
+		})
+	}
+}
+
+// A writer whose insert collides with a version created meanwhile runs again
+// on the new latest version, and reports ErrVersionConflict once retries run out.
+func TestWriterLocksLatestAndRetriesConflicts(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		conflicts int
+		wantErr   error
+	}{
+		{"once", 1, nil},
+		{"always", 100, scd.ErrVersionConflict},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db, stmts := dryRunTxDB(t, func(stmt *gorm.Statement) {
+				if j, ok := stmt.Dest.(*models.Job); ok {
+					j.ID, j.Version, j.VersionState = "job1", 4, scd.StateApproved
+				}
+			})
+			inserts := 0
+			db.Callback().Create().Before("gorm:create").Register("test:conflict", func(tx *gorm.DB) {
+				if inserts++; inserts <= tc.conflicts {
+					tx.AddError(&pgconn.PgError{Code: "23505", ConstraintName: "jobs_pkey"})
+				}
+			})
+			err := scd.CreateNewSCDVersion[models.Job](context.Background(), db, "job1", func(j *models.Job) error {
+				j.Rate = 120
+				return nil
+			})
+			if !errors.Is(err, tc.wantErr) && err != tc.wantErr {
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			}
+			if tc.wantErr == nil && inserts != 2 {
+				t.Errorf("inserted %d times, want one retry", inserts)
+			}
+			locks := 0
+			for _, sql := range *stmts {
+				if strings.HasSuffix(sql, "FOR UPDATE") {
+					locks++
+				}
+			}
+			if locks != inserts {
+				t.Errorf("%d attempts locked the latest version %d times:\n%s", inserts, locks, strings.Join(*stmts, "\n"))
+			}
+		})
+	}
+}
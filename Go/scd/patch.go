@@ -0,0 +1,506 @@
+package scd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// MergePatch applies an RFC 7396 JSON Merge Patch to the latest version of
+// entity id and writes the result as a new version, which it returns as a
+// pointer to a new model value. Keys are column names, as the HTTP API renders
+// them; a null resets the column, and objects are merged into struct or map
+// columns member by member:
+//
+//	scd.MergePatch(ctx, db, models.Job{}, "job1", []byte(`{"rate": 120, "title": null}`))
+//
+// The patch is checked against the model's schema before anything is
+// written: unknown columns, version metadata and values of the wrong type fail
+// with ErrInvalidPatch.
+func MergePatch(ctx context.Context, db *gorm.DB, model any, id string, patch []byte) (any, error) {
+	return mergePatch(ctx, db, modelType(model), id, nil, patch, time.Time{})
+}
+
+// MergePatchIf is MergePatch for optimistic clients: it fails with
+// ErrVersionConflict unless the latest version is still expectedVersion, the
+// one the patch was computed against.
+func MergePatchIf(ctx context.Context, db *gorm.DB, model any, id string, expectedVersion int, patch []byte) (any, error) {
+	return mergePatch(ctx, db, modelType(model), id, expectVersion(id, expectedVersion), patch, time.Time{})
+}
+
+// mergePatch applies patch to the latest version of id. A non-zero effective
+// sets the valid_from of the created version, as ScheduleVersion does.
+func mergePatch(ctx context.Context, db *gorm.DB, t reflect.Type, id string, check func(latest int) error, patch []byte, effective time.Time) (any, error) {
+	members, fields, err := parseMergePatch(db, t, patch)
+	if err != nil {
+		return nil, err
+	}
+	return createVersionOf(ctx, db, t, id, check, func(_, next reflect.Value) error {
+		for column, raw := range members {
+			if err := mergeField(ctx, fields[column], next, raw); err != nil {
+				return err
+			}
+		}
+		if !effective.IsZero() {
+			if err := setField(next.Addr().Interface(), "ValidFrom", &effective); err != nil {
+				return fmt.Errorf("scheduling %s failed: %w", id, err)
+			}
+		}
+		return nil
+	})
+}
+
+// parseMergePatch checks patch against the schema of t and returns its
+// members along with the fields they set.
+func parseMergePatch(db *gorm.DB, t reflect.Type, patch []byte) (map[string]json.RawMessage, map[string]*schema.Field, error) {
+	s, err := parseSchema(db, t)
+	if err != nil {
+		return nil, nil, err
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &members); err != nil || members == nil {
+		return nil, nil, fmt.Errorf("%w: a merge patch must be a JSON object", ErrInvalidPatch)
+	}
+	fields := make(map[string]*schema.Field, len(members))
+	for column := range members {
+		f, err := patchableField(s, column)
+		if err != nil {
+			return nil, nil, err
+		}
+		fields[column] = f
+	}
+	return members, fields, nil
+}
+
+// parseSchema returns the GORM schema of model type t.
+func parseSchema(db *gorm.DB, t reflect.Type) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(reflect.New(t).Interface()); err != nil {
+		return nil, fmt.Errorf("parsing model failed: %w", err)
+	}
+	return stmt.Schema, nil
+}
+
+// patchableField returns the field behind column, refusing columns a patch
+// may not touch: unknown ones and the version metadata scd maintains.
+func patchableField(s *schema.Schema, column string) (*schema.Field, error) {
+	f := s.LookUpField(column)
+	if f == nil || f.DBName != column {
+		return nil, fmt.Errorf("%w: %s has no column %q", ErrInvalidPatch, s.Table, column)
+	}
+	if len(f.BindNames) > 1 && f.BindNames[0] == "Versioned" {
+		return nil, fmt.Errorf("%w: %s is maintained by scd", ErrInvalidPatch, column)
+	}
+	return f, nil
+}
+
+// mergeField merges the patch value raw into field f of the struct next.
+func mergeField(ctx context.Context, f *schema.Field, next reflect.Value, raw json.RawMessage) error {
+	patch, err := decodeJSON(raw)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidPatch, f.DBName, err)
+	}
+	if patch == nil {
+		f.ReflectValueOf(ctx, next).Set(reflect.Zero(f.FieldType))
+		return nil
+	}
+	target, err := fieldJSON(ctx, f, next)
+	if err != nil {
+		return err
+	}
+	return setFieldJSON(ctx, f, next, mergeJSON(target, patch))
+}
+
+// mergeJSON is the MergePatch algorithm of RFC 7396 on decoded JSON values.
+func mergeJSON(target, patch any) any {
+	members, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	doc, ok := target.(map[string]any)
+	if !ok {
+		doc = map[string]any{}
+	}
+	for k, v := range members {
+		if v == nil {
+			delete(doc, k)
+		} else {
+			doc[k] = mergeJSON(doc[k], v)
+		}
+	}
+	return doc
+}
+
+// decodeJSON decodes data keeping numbers exact.
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// PatchOp is one operation of an RFC 6902 JSON Patch.
+type PatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// JSONPatch applies an RFC 6902 JSON Patch to the latest version of entity id
+// and writes the result as a new version, which it returns as a pointer to a
+// new model value. The document patched is the latest version keyed by column
+// name, as the HTTP API renders it. "test" operations are evaluated against
+// the locked latest version, so they make the update conditional on the
+// fields they name; testing /version is an expected-version check:
+//
+//	[{"op": "test", "path": "/rate", "value": 100}, {"op": "replace", "path": "/rate", "value": 120}]
+//
+// A failed test returns ErrPatchTestFailed and writes nothing. Operations on
+// unknown columns or on version metadata fail with ErrInvalidPatch, as do
+// values of the wrong type.
+func JSONPatch(ctx context.Context, db *gorm.DB, model any, id string, patch []byte) (any, error) {
+	return jsonPatch(ctx, db, modelType(model), id, nil, patch)
+}
+
+// JSONPatchIf is JSONPatch failing with ErrVersionConflict unless the latest
+// version is still expectedVersion.
+func JSONPatchIf(ctx context.Context, db *gorm.DB, model any, id string, expectedVersion int, patch []byte) (any, error) {
+	return jsonPatch(ctx, db, modelType(model), id, expectVersion(id, expectedVersion), patch)
+}
+
+func jsonPatch(ctx context.Context, db *gorm.DB, t reflect.Type, id string, check func(latest int) error, patch []byte) (any, error) {
+	s, err := parseSchema(db, t)
+	if err != nil {
+		return nil, err
+	}
+	var ops []PatchOp
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("%w: a JSON patch must be an array of operations", ErrInvalidPatch)
+	}
+	for i, op := range ops {
+		if err := validateOp(s, op); err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return createVersionOf(ctx, db, t, id, check, func(latest, next reflect.Value) error {
+		return applyJSONPatch(ctx, s, ops, latest, next)
+	})
+}
+
+// applyJSONPatch applies ops to the document of latest, the locked latest
+// version, and sets the columns they change on next, the version to write.
+// Tests compare against latest, so they see its version number and metadata
+// rather than those next was given.
+func applyJSONPatch(ctx context.Context, s *schema.Schema, ops []PatchOp, latest, next reflect.Value) error {
+	doc := map[string]any{}
+	for _, f := range s.Fields {
+		if f.DBName == "" {
+			continue
+		}
+		v, err := fieldJSON(ctx, f, latest)
+		if err != nil {
+			return err
+		}
+		doc[f.DBName] = v
+	}
+	// Patch a deep copy, so doc keeps the latest version to compare with.
+	root, err := decodeJSON([]byte(mustJSON(doc)))
+	if err != nil {
+		return err
+	}
+	for i, op := range ops {
+		if root, err = applyOp(root, op); err != nil {
+			return fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	updated := root.(map[string]any)
+	for column, v := range updated {
+		if _, ok := doc[column]; !ok {
+			return fmt.Errorf("%w: %s has no column %q", ErrInvalidPatch, s.Table, column)
+		}
+		if jsonEqual(doc[column], v) {
+			continue
+		}
+		f, err := patchableField(s, column)
+		if err != nil {
+			return err
+		}
+		if err := setFieldJSON(ctx, f, next, v); err != nil {
+			return err
+		}
+	}
+	for column := range doc {
+		if _, ok := updated[column]; ok {
+			continue
+		}
+		f, err := patchableField(s, column)
+		if err != nil {
+			return err
+		}
+		f.ReflectValueOf(ctx, next).Set(reflect.Zero(f.FieldType))
+	}
+	return nil
+}
+
+// validateOp checks what can be checked about op before the latest version
+// is read: the operation, its paths and the columns it touches.
+func validateOp(s *schema.Schema, op PatchOp) error {
+	paths := []string{op.Path}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return fmt.Errorf("%w: %s needs a value", ErrInvalidPatch, op.Op)
+		}
+	case "remove":
+	case "move", "copy":
+		paths = append(paths, op.From)
+	default:
+		return fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, op.Op)
+	}
+	for i, p := range paths {
+		tokens, err := parsePointer(p)
+		if err != nil {
+			return err
+		}
+		if len(tokens) == 0 {
+			return fmt.Errorf("%w: %s cannot target the whole entity", ErrInvalidPatch, op.Op)
+		}
+		// Tests and the source of a copy only read, so they may name metadata.
+		if op.Op == "test" || op.Op == "copy" && i == 1 {
+			if f := s.LookUpField(tokens[0]); f == nil || f.DBName != tokens[0] {
+				return fmt.Errorf("%w: %s has no column %q", ErrInvalidPatch, s.Table, tokens[0])
+			}
+			continue
+		}
+		if _, err := patchableField(s, tokens[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyOp applies one operation to the decoded document root.
+func applyOp(root any, op PatchOp) (any, error) {
+	path, _ := parsePointer(op.Path)
+	switch op.Op {
+	case "test":
+		want, err := decodeJSON(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		got, err := lookupPointer(root, path)
+		if err != nil || !jsonEqual(got, want) {
+			return nil, fmt.Errorf("%w: %s does not match", ErrPatchTestFailed, op.Path)
+		}
+		return root, nil
+	case "add", "replace":
+		value, err := decodeJSON(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		return updatePointer(root, path, func(parent any, key string) (any, error) {
+			return setMember(parent, key, value, op.Op == "add")
+		})
+	case "remove":
+		return updatePointer(root, path, removeMember)
+	case "move", "copy":
+		from, _ := parsePointer(op.From)
+		value, err := lookupPointer(root, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+				return nil, fmt.Errorf("%w: cannot move %s into itself", ErrInvalidPatch, op.From)
+			}
+			if root, err = updatePointer(root, from, removeMember); err != nil {
+				return nil, err
+			}
+		} else {
+			// Copies must not alias the source.
+			if value, err = decodeJSON([]byte(mustJSON(value))); err != nil {
+				return nil, err
+			}
+		}
+		return updatePointer(root, path, func(parent any, key string) (any, error) {
+			return setMember(parent, key, value, true)
+		})
+	}
+	return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, op.Op)
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped tokens.
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, fmt.Errorf("%w: path %q does not start with /", ErrInvalidPatch, p)
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func lookupPointer(node any, tokens []string) (any, error) {
+	for _, t := range tokens {
+		var err error
+		if node, err = member(node, t); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+// updatePointer applies fn to the container holding the last token and
+// returns node with the result in place.
+func updatePointer(node any, tokens []string, fn func(parent any, key string) (any, error)) (any, error) {
+	if len(tokens) == 1 {
+		return fn(node, tokens[0])
+	}
+	child, err := member(node, tokens[0])
+	if err != nil {
+		return nil, err
+	}
+	if child, err = updatePointer(child, tokens[1:], fn); err != nil {
+		return nil, err
+	}
+	return setMember(node, tokens[0], child, false)
+}
+
+func member(node any, key string) (any, error) {
+	switch n := node.(type) {
+	case map[string]any:
+		if v, ok := n[key]; ok {
+			return v, nil
+		}
+	case []any:
+		if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(n) {
+			return n[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no member %q", ErrInvalidPatch, key)
+}
+
+// setMember sets key of parent to value. insert selects add semantics:
+// arrays grow at the index, or at the end for "-", and object members need
+// not exist yet.
+func setMember(parent any, key string, value any, insert bool) (any, error) {
+	switch n := parent.(type) {
+	case map[string]any:
+		if _, ok := n[key]; !ok && !insert {
+			return nil, fmt.Errorf("%w: no member %q", ErrInvalidPatch, key)
+		}
+		n[key] = value
+		return n, nil
+	case []any:
+		if insert && key == "-" {
+			return append(n, value), nil
+		}
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i > len(n) || i == len(n) && !insert {
+			return nil, fmt.Errorf("%w: index %q out of range", ErrInvalidPatch, key)
+		}
+		if !insert {
+			n[i] = value
+			return n, nil
+		}
+		return slices.Insert(n, i, value), nil
+	}
+	return nil, fmt.Errorf("%w: cannot set %q on a scalar", ErrInvalidPatch, key)
+}
+
+func removeMember(parent any, key string) (any, error) {
+	switch n := parent.(type) {
+	case map[string]any:
+		if _, ok := n[key]; ok {
+			delete(n, key)
+			return n, nil
+		}
+	case []any:
+		if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(n) {
+			return slices.Delete(n, i, i+1), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no member %q", ErrInvalidPatch, key)
+}
+
+// jsonEqual compares decoded JSON values, numbers by value.
+func jsonEqual(a, b any) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, errX := x.Float64()
+		fy, errY := y.Float64()
+		return errX == nil && errY == nil && fx == fy
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			if w, ok := y[k]; !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// fieldJSON returns field f of the struct next as decoded JSON.
+func fieldJSON(ctx context.Context, f *schema.Field, next reflect.Value) (any, error) {
+	data, err := json.Marshal(f.ReflectValueOf(ctx, next).Interface())
+	if err != nil {
+		return nil, fmt.Errorf("encoding %s failed: %w", f.DBName, err)
+	}
+	return decodeJSON(data)
+}
+
+// setFieldJSON stores the decoded JSON value v in field f of the struct next.
+func setFieldJSON(ctx context.Context, f *schema.Field, next reflect.Value, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding %s failed: %w", f.DBName, err)
+	}
+	value := reflect.New(f.FieldType)
+	if err := json.Unmarshal(data, value.Interface()); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidPatch, f.DBName, err)
+	}
+	f.ReflectValueOf(ctx, next).Set(value.Elem())
+	return nil
+}
+
+func mustJSON(v any) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
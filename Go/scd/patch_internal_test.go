@@ -0,0 +1,54 @@
+package scd
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Versioned stands in for models.Versioned, which scd cannot import.
+type Versioned struct {
+	ID      string `gorm:"primaryKey"`
+	Version int    `gorm:"primaryKey"`
+	UID     string
+}
+
+type patchedJob struct {
+	Versioned
+	Rate float64
+}
+
+func TestJSONPatchTestsSeeLockedLatestVersion(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("opening dry-run db: %v", err)
+	}
+	s, err := parseSchema(db, reflect.TypeOf(patchedJob{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := patchedJob{Versioned: Versioned{ID: "job1", Version: 3, UID: "uid-3"}, Rate: 100}
+	for _, tc := range []struct {
+		version int
+		wantErr error
+	}{{3, nil}, {2, ErrPatchTestFailed}} {
+		next := latest
+		next.Version, next.UID = 4, "uid-4"
+		ops := []PatchOp{
+			{Op: "test", Path: "/version", Value: []byte(mustJSON(tc.version))},
+			{Op: "replace", Path: "/rate", Value: []byte("120")},
+		}
+		err := applyJSONPatch(context.Background(), s, ops, reflect.ValueOf(&latest).Elem(), reflect.ValueOf(&next).Elem())
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("test /version %d: err = %v, want %v", tc.version, err, tc.wantErr)
+		}
+		if tc.wantErr == nil && (next.Rate != 120 || next.Version != 4) {
+			t.Errorf("test /version %d: next = %+v", tc.version, next)
+		}
+	}
+}
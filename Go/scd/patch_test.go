@@ -0,0 +1,47 @@
+package scd_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+)
+
+func TestMergePatchRejectsPatchesOutsideTheSchema(t *testing.T) {
+	db := dryRunDB(t)
+	for _, patch := range []string{
+		`{"no_such_column": 1}`,
+		`{"version": 7}`,
+		`{"is_deleted": true}`,
+		`{"Rate": 120}`,
+		`[{"op": "replace", "path": "/rate", "value": 120}]`,
+		`120`,
+	} {
+		_, err := scd.MergePatch(context.Background(), db, models.Job{}, "job1", []byte(patch))
+		if !errors.Is(err, scd.ErrInvalidPatch) {
+			t.Errorf("%s: err = %v, want ErrInvalidPatch", patch, err)
+		}
+	}
+}
+
+func TestJSONPatchRejectsInvalidOperations(t *testing.T) {
+	db := dryRunDB(t)
+	for _, patch := range []string{
+		`{"rate": 120}`,
+		`[{"op": "increment", "path": "/rate", "value": 1}]`,
+		`[{"op": "replace", "path": "rate", "value": 120}]`,
+		`[{"op": "replace", "path": "", "value": {}}]`,
+		`[{"op": "replace", "path": "/version", "value": 9}]`,
+		`[{"op": "add", "path": "/no_such_column", "value": 1}]`,
+		`[{"op": "test", "path": "/no_such_column", "value": 1}]`,
+		`[{"op": "move", "from": "/uid", "path": "/title"}]`,
+		`[{"op": "replace", "path": "/rate"}]`,
+	} {
+		_, err := scd.JSONPatch(context.Background(), db, models.Job{}, "job1", []byte(patch))
+		if !errors.Is(err, scd.ErrInvalidPatch) {
+			t.Errorf("%s: err = %v, want ErrInvalidPatch", patch, err)
+		}
+	}
+}
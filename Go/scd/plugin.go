@@ -0,0 +1,139 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+)
+
+// Plugin turns Update, Updates and Save calls on versioned models into new
+// versions, so plain GORM code keeps the history intact:
+//
+//	db.Use(scd.NewPlugin())
+//	db.Model(&job).Updates(map[string]any{"rate": 120}) // job now holds the new version
+//
+// The entity is identified by the model's ID, and the assigned columns are
+// applied to a clone of its latest version, exactly as CreateNewSCDVersion
+// would. The model is then overwritten with the version written. Updates of
+// other models run unchanged.
+type Plugin struct{}
+
+// NewPlugin returns the plugin to pass to db.Use.
+func NewPlugin() *Plugin {
+	return &Plugin{}
+}
+
+// Name implements gorm.Plugin.
+func (*Plugin) Name() string { return "scd" }
+
+// Initialize implements gorm.Plugin by wrapping the gorm:update callback. It
+// also fills in the audit fields (see WithActor) of versioned rows created
+// directly, as new versions get them.
+func (*Plugin) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("scd:audit", auditCreate); err != nil {
+		return err
+	}
+	update := db.Callback().Update()
+	original := update.Get("gorm:update")
+	if original == nil {
+		return fmt.Errorf("scd plugin: gorm:update callback not found")
+	}
+	return update.Replace("gorm:update", func(tx *gorm.DB) {
+		if tx.Error != nil || tx.Statement.Schema == nil || tx.Statement.Schema.LookUpField("version") == nil {
+			original(tx)
+			return
+		}
+		tx.AddError(updateAsVersion(tx))
+	})
+}
+
+func updateAsVersion(tx *gorm.DB) error {
+	stmt := tx.Statement
+	if stmt.ReflectValue.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: %s updates must target a single entity", ErrUnsupportedUpdate, stmt.Schema.Table)
+	}
+	model := stmt.ReflectValue.Interface()
+	if stmt.ReflectValue.CanAddr() {
+		model = stmt.ReflectValue.Addr().Interface()
+	}
+	id, _, _ := versionedKey(model)
+	if id == "" {
+		return fmt.Errorf("%w: %s updates need the entity ID set on the model", ErrUnsupportedUpdate, stmt.Schema.Table)
+	}
+	set := callbacks.ConvertToAssignments(stmt)
+	for _, a := range set {
+		if _, ok := a.Value.(clause.Expression); ok {
+			return fmt.Errorf("%w: %s is assigned an SQL expression", ErrUnsupportedUpdate, a.Column.Name)
+		}
+	}
+	if tx.DryRun {
+		return nil
+	}
+
+	db := tx.Session(&gorm.Session{NewDB: true, Initialized: true})
+	created, err := createVersionOf(stmt.Context, db, stmt.Schema.ModelType, id, nil, func(_, next reflect.Value) error {
+		for _, a := range set {
+			field := stmt.Schema.LookUpField(a.Column.Name)
+			// Version metadata is assigned by saveVersion, never by the caller.
+			if field == nil || len(field.BindNames) > 1 && field.BindNames[0] == "Versioned" {
+				continue
+			}
+			if err := field.Set(stmt.Context, next, a.Value); err != nil {
+				return fmt.Errorf("assigning %s failed: %w", a.Column.Name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if stmt.ReflectValue.CanSet() {
+		stmt.ReflectValue.Set(reflect.ValueOf(created).Elem())
+	}
+	// Save falls back to an insert when an update reports no rows.
+	tx.RowsAffected = 1
+	return nil
+}
+
+// createVersionOf is createVersion for a model type known only at run time.
+// check, when set, vets the locked latest version number, and apply edits
+// next, the clone of latest already given the metadata of the new version,
+// before it is saved.
+func createVersionOf(ctx context.Context, db *gorm.DB, t reflect.Type, id string, check func(latest int) error, apply func(latest, next reflect.Value) error) (any, error) {
+	db = db.WithContext(ctx)
+	var created any
+	err := retryOnVersionConflict(func() error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			latest := reflect.New(t)
+			newest, err := lockLatest(tx, id, latest.Interface())
+			if err != nil {
+				return err
+			}
+			next := reflect.New(t)
+			next.Elem().Set(deepCopy(latest.Elem(), map[uintptr]reflect.Value{}))
+			entity, ok := next.Interface().(Entity)
+			if !ok {
+				return fmt.Errorf("%w: %s does not implement scd.Entity", ErrNotVersioned, t)
+			}
+			if check != nil {
+				if err := check(entity.GetVersion()); err != nil {
+					return err
+				}
+			}
+			prepareVersion(entity, newest)
+			if err := apply(latest.Elem(), next.Elem()); err != nil {
+				return err
+			}
+			if err := saveVersion(tx, next.Interface(), EventVersion); err != nil {
+				return err
+			}
+			created = next.Interface()
+			return nil
+		})
+	})
+	return created, err
+}
@@ -0,0 +1,54 @@
+package scd_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func TestPluginRejectsUpdatesItCannotVersion(t *testing.T) {
+	db := dryRunDB(t)
+	if err := db.Use(scd.NewPlugin()); err != nil {
+		t.Fatal(err)
+	}
+	// Opening the update transaction would need a connection.
+	db = db.Session(&gorm.Session{SkipDefaultTransaction: true})
+	cases := map[string]*gorm.DB{
+		"no id":      db.Model(&models.Job{}).Where("status = ?", "active").Updates(map[string]any{"rate": 120}),
+		"expression": db.Model(&models.Job{Versioned: models.Versioned{ID: "job1"}}).Update("rate", gorm.Expr("rate + 1")),
+	}
+	for name, res := range cases {
+		if !errors.Is(res.Error, scd.ErrUnsupportedUpdate) {
+			t.Errorf("%s: err = %v, want ErrUnsupportedUpdate", name, res.Error)
+		}
+	}
+
+	job := models.Job{Versioned: models.Versioned{ID: "job1"}}
+	res := db.Model(&job).Updates(map[string]any{"rate": 120})
+	if res.Error != nil {
+		t.Fatalf("versionable update failed: %v", res.Error)
+	}
+	if sql := res.Statement.SQL.String(); sql != "" {
+		t.Errorf("update reached the database as %q", sql)
+	}
+}
+
+func TestPluginStampsAuditFieldsOnCreate(t *testing.T) {
+	db := dryRunDB(t)
+	if err := db.Use(scd.NewPlugin()); err != nil {
+		t.Fatal(err)
+	}
+	ctx := scd.WithChangeReason(scd.WithActor(context.Background(), "user-42"), "rate correction")
+	job := models.Job{Versioned: models.Versioned{ID: "job1", Version: 1, Source: "import"}}
+	res := db.WithContext(ctx).Session(&gorm.Session{SkipDefaultTransaction: true}).Create(&job)
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if job.CreatedBy != "user-42" || job.ChangeReason != "rate correction" || job.Source != "import" {
+		t.Errorf("got created_by %q, change_reason %q, source %q", job.CreatedBy, job.ChangeReason, job.Source)
+	}
+}
@@ -0,0 +1,146 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ProvenanceEdge records that the version UID was derived from the version
+// SourceUID, which Field of UID's row referenced when it was created.
+type ProvenanceEdge struct {
+	UID         string    `gorm:"primaryKey;column:uid"`
+	SourceUID   string    `gorm:"primaryKey;index;column:source_uid"`
+	Table       string    `gorm:"column:table_name"`
+	SourceTable string    `gorm:"column:source_table"`
+	Field       string    `gorm:"column:field"`
+	CreatedAt   time.Time `gorm:"column:created_at"`
+	// Depth is the distance from the version passed to Provenance, starting at 1.
+	Depth int `gorm:"-:migration;->;column:depth"`
+}
+
+func (ProvenanceEdge) TableName() string { return "scd_provenance" }
+
+// MigrateProvenance creates the provenance table.
+func MigrateProvenance(db *gorm.DB) error {
+	return db.AutoMigrate(&ProvenanceEdge{})
+}
+
+var (
+	provenanceMu sync.RWMutex
+	provenance   = map[reflect.Type][]Reference{}
+)
+
+// TrackProvenance records, for every created row of model, an edge to each
+// version its refs point at, e.g. the job and timelog versions a payment line
+// item was computed from:
+//
+//	scd.TrackProvenance(models.PaymentLineItem{},
+//		scd.Reference{Field: "JobUID", Target: models.Job{}},
+//		scd.Reference{Field: "TimelogUID", Target: models.Timelog{}})
+//
+// Edges are written in the creating transaction on databases passed to
+// InstallProvenance. Empty references are skipped.
+func TrackProvenance(model any, refs ...Reference) {
+	t := modelType(model)
+	provenanceMu.Lock()
+	defer provenanceMu.Unlock()
+	provenance[t] = append(provenance[t], refs...)
+}
+
+// InstallProvenance registers the create callback that records TrackProvenance edges on db.
+func InstallProvenance(db *gorm.DB) error {
+	return db.Callback().Create().After("gorm:create").Before("gorm:commit_or_rollback_transaction").Register("scd:provenance", recordProvenance)
+}
+
+// Provenance returns the edges reachable from the version uid, nearest first,
+// i.e. every source version it was derived from, directly or transitively.
+func Provenance(ctx context.Context, db *gorm.DB, uid string) ([]ProvenanceEdge, error) {
+	edges, err := walkProvenance(ctx, db, []string{uid}, false)
+	if err != nil {
+		return nil, fmt.Errorf("loading provenance of %s failed: %w", uid, err)
+	}
+	return edges, nil
+}
+
+// walkProvenance follows edges from uids towards their sources, or towards
+// the versions derived from them when downstream is set.
+func walkProvenance(ctx context.Context, db *gorm.DB, uids []string, downstream bool) ([]ProvenanceEdge, error) {
+	from, to := "uid", "source_uid"
+	if downstream {
+		from, to = to, from
+	}
+	var edges []ProvenanceEdge
+	err := db.WithContext(ctx).Raw(fmt.Sprintf(`WITH RECURSIVE walk AS (
+			SELECT p.*, 1 AS depth FROM scd_provenance p WHERE p.%[1]s IN ?
+			UNION
+			SELECT p.*, w.depth + 1 FROM scd_provenance p JOIN walk w ON p.%[1]s = w.%[2]s WHERE w.depth < 64
+		)
+		SELECT * FROM (SELECT DISTINCT ON (uid, source_uid) * FROM walk ORDER BY uid, source_uid, depth) nearest
+		ORDER BY depth, uid, source_uid`, from, to), uids).
+		Scan(&edges).Error
+	return edges, err
+}
+
+func recordProvenance(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement.Schema == nil {
+		return
+	}
+	provenanceMu.RLock()
+	refs := provenance[tx.Statement.Schema.ModelType]
+	provenanceMu.RUnlock()
+	if len(refs) == 0 {
+		return
+	}
+
+	rv := reflect.Indirect(tx.Statement.ReflectValue)
+	rows := []reflect.Value{rv}
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		rows = rows[:0]
+		for i := 0; i < rv.Len(); i++ {
+			rows = append(rows, reflect.Indirect(rv.Index(i)))
+		}
+	}
+	uidField := tx.Statement.Schema.LookUpField("uid")
+	if uidField == nil {
+		tx.AddError(fmt.Errorf("provenance tracked on %s, which has no uid column", tx.Statement.Schema.Name))
+		return
+	}
+	now := time.Now()
+	var edges []ProvenanceEdge
+	for _, ref := range refs {
+		field := tx.Statement.Schema.LookUpField(ref.Field)
+		if field == nil {
+			tx.AddError(fmt.Errorf("provenance field %s not found on %s", ref.Field, tx.Statement.Schema.Name))
+			return
+		}
+		info, err := Describe(tx, ref.Target)
+		if err != nil {
+			tx.AddError(err)
+			return
+		}
+		for _, row := range rows {
+			uid, _ := uidField.ValueOf(tx.Statement.Context, row)
+			source, _ := field.ValueOf(tx.Statement.Context, row)
+			if s, ok := source.(string); !ok || s == "" {
+				continue
+			}
+			edges = append(edges, ProvenanceEdge{
+				UID: fmt.Sprint(uid), SourceUID: fmt.Sprint(source),
+				Table: tx.Statement.Schema.Table, SourceTable: info.Table, Field: field.DBName, CreatedAt: now,
+			})
+		}
+	}
+	if len(edges) == 0 {
+		return
+	}
+	err := tx.Session(&gorm.Session{NewDB: true}).Clauses(clause.OnConflict{DoNothing: true}).Create(&edges).Error
+	if err != nil {
+		tx.AddError(fmt.Errorf("recording provenance failed: %w", err))
+	}
+}
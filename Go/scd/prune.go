@@ -0,0 +1,122 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PrunePolicy says which versions Prune removes. A version is removed only
+// when every rule set allows it, it is superseded by a newer approved
+// version, and no legal hold matches it; latest versions, drafts and
+// versions pending approval are never touched.
+type PrunePolicy struct {
+	// KeepLastN keeps the n most recent versions of each entity; 1 keeps only
+	// the latest.
+	KeepLastN int
+	// KeepSince keeps every version in effect at or after this time, so
+	// as-of reads from then on keep working: a version goes only once a
+	// newer version was created before KeepSince.
+	KeepSince time.Time
+	// LegalHold exempts the versions any of the holds match.
+	LegalHold []Hold
+	// Archive moves pruned versions to <table>_archive instead of deleting them.
+	Archive bool
+	// BatchSize is the number of rows removed per statement (default 5000).
+	BatchSize int
+	// Limits bounds the run; see ApplyRetentionWithin.
+	Limits Limits
+}
+
+// Hold is a condition over the columns of a version exempting it from
+// pruning, e.g. Hold{Where: "company_id = ?", Args: []any{"acme"}}. A
+// condition that evaluates to NULL holds the version too.
+type Hold struct {
+	Where string
+	Args  []any
+}
+
+// HoldEntities holds every version of the entities ids.
+func HoldEntities(ids ...string) Hold {
+	return Hold{Where: "id IN ?", Args: []any{ids}}
+}
+
+// HoldTagged holds the versions carrying a tag (see TagVersion), which
+// GetByTag must keep finding.
+func HoldTagged() Hold {
+	return Hold{Where: "uid IN (SELECT uid FROM version_annotations WHERE tag <> '')"}
+}
+
+// Prune removes old versions of T according to policy, e.g. every version
+// beyond the last 100 of an entity that was superseded over a year ago and
+// belongs to no company under investigation:
+//
+//	res, err := scd.Prune[models.Job](ctx, db, scd.PrunePolicy{
+//		KeepLastN: 100,
+//		KeepSince: time.Now().AddDate(-1, 0, 0),
+//		LegalHold: []scd.Hold{{Where: "company_id IN ?", Args: []any{held}}, scd.HoldTagged()},
+//	})
+//
+// At least one of KeepLastN and KeepSince must be set. Like
+// ApplyRetentionWithin, Prune works in batches in (id, version) order and
+// returns a resume token when it stops at policy.Limits.
+func Prune[T any](ctx context.Context, db *gorm.DB, policy PrunePolicy) (RetentionResult, error) {
+	var model T
+	info, err := Describe(db, &model)
+	if err != nil {
+		return RetentionResult{Table: info.Table}, err
+	}
+	if policy.KeepLastN < 0 || policy.KeepLastN == 0 && policy.KeepSince.IsZero() {
+		return RetentionResult{Table: info.Table}, fmt.Errorf("pruning %s needs KeepLastN >= 1 or KeepSince", info.Table)
+	}
+	target, cond, args := pruneCondition(db, &model, info, policy)
+	batchSize := policy.BatchSize
+	if batchSize <= 0 {
+		batchSize = 5000
+	}
+	return removeVersions(ctx, db.WithContext(ctx), info, target, cond, args, batchSize, policy.Archive, policy.Limits)
+}
+
+// pruneCondition returns the relation Prune removes rows from, quoted, and
+// the condition over its rows, aliased t, selecting those policy removes.
+func pruneCondition(db *gorm.DB, model any, info TableInfo, policy PrunePolicy) (string, string, []any) {
+	target, all := info.Table, versionsRelation(db, model, info, false)
+	if h := historyTable(model, info.Table); h != "" {
+		target = h
+	}
+	approved := ""
+	if hasStates(model) {
+		approved = " AND n." + approvedState
+	}
+
+	// Only superseded versions go: a newer approved version must exist, and
+	// under KeepSince it must have been created before the cutoff.
+	newer := "n.id = t.id AND n.version > t.version" + approved
+	var args []any
+	if !policy.KeepSince.IsZero() {
+		newer += " AND COALESCE(n.created_at, '-infinity') < ?"
+		args = append(args, policy.KeepSince)
+	}
+	conds := []string{fmt.Sprintf("EXISTS (SELECT 1 FROM %s WHERE %s)", versionsAs(db, model, info, "n"), newer)}
+	if approved != "" {
+		conds = append(conds, "t."+approvedState)
+	}
+	if policy.KeepLastN > 0 {
+		conds = append(conds, fmt.Sprintf(`(t.id, t.version) IN (
+			SELECT id, version FROM (
+				SELECT id, version, row_number() OVER (PARTITION BY id ORDER BY version DESC) AS rn FROM %s
+			) ranked WHERE rn > ?)`, all))
+		args = append(args, policy.KeepLastN)
+	}
+	for _, h := range policy.LegalHold {
+		if strings.TrimSpace(h.Where) == "" {
+			continue
+		}
+		conds = append(conds, "("+h.Where+") IS FALSE")
+		args = append(args, h.Args...)
+	}
+	return quote(db, target), strings.Join(conds, " AND "), args
+}
@@ -0,0 +1,44 @@
+package scd_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func TestPruneSparesLatestDraftsAndHeldVersions(t *testing.T) {
+	db := dryRunDB(t)
+	var sql string
+	var vars []any
+	db.Callback().Row().After("gorm:row").Register("test:capture", func(tx *gorm.DB) {
+		sql, vars = tx.Statement.SQL.String(), tx.Statement.Vars
+	})
+	cutoff := time.Now().AddDate(-1, 0, 0)
+	scd.Prune[models.Job](context.Background(), db, scd.PrunePolicy{
+		KeepLastN: 100,
+		KeepSince: cutoff,
+		LegalHold: []scd.Hold{{Where: "company_id = ?", Args: []any{"acme"}}, scd.HoldEntities("job1")},
+	})
+	for _, want := range []string{
+		`DELETE FROM "jobs" WHERE ctid IN (SELECT t.ctid FROM "jobs" t WHERE EXISTS (SELECT 1 FROM "jobs" n WHERE n.id = t.id AND n.version > t.version AND n.version_state = 'approved' AND COALESCE(n.created_at, '-infinity') < $1)`,
+		`AND t.version_state = 'approved'`,
+		`WHERE rn > $2)`,
+		`AND (company_id = $3) IS FALSE AND (id IN ($4)) IS FALSE AND (t.id, t.version) > ($5, $6)`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("prune SQL lacks %q:\n%s", want, sql)
+		}
+	}
+	if len(vars) != 6 || vars[0] != cutoff || vars[1] != 100 || vars[2] != "acme" {
+		t.Errorf("unexpected vars %v", vars)
+	}
+
+	if _, err := scd.Prune[models.Job](context.Background(), db, scd.PrunePolicy{}); err == nil {
+		t.Error("Prune accepted a policy that would keep only latest versions by default")
+	}
+}
@@ -0,0 +1,205 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yourorg/Go/scderr"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrQuarantined is returned when writing or approving a version of a
+// quarantined entity.
+var ErrQuarantined = scderr.New(scderr.Conflict, "scd: entity is quarantined")
+
+// Quarantine freezes one entity whose history is suspected to be corrupt,
+// until someone has looked at it: with Config.Quarantine set, new versions
+// of it and approvals of its drafts fail with ErrQuarantined, and
+// latest-version reads leave it out, as they do tombstones. Its history
+// stays readable.
+type Quarantine struct {
+	Table    string `gorm:"primaryKey;column:table_name" json:"table"`
+	EntityID string `gorm:"primaryKey;column:entity_id" json:"entity_id"`
+	Reason   string `gorm:"column:reason;not null" json:"reason"`
+	// Check names the automated check that quarantined the entity, empty
+	// when someone did by hand.
+	Check     string    `gorm:"column:check_name;not null;default:''" json:"check,omitempty"`
+	CreatedBy string    `gorm:"column:created_by;not null;default:''" json:"created_by,omitempty"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (Quarantine) TableName() string { return "scd_quarantines" }
+
+// QuarantineEvent is passed to the OnQuarantine handlers when an entity is
+// quarantined or released.
+type QuarantineEvent struct {
+	Quarantine
+	// Released is set when the entity was released; Actor is who did either.
+	Released bool
+	Actor    string
+}
+
+// QuarantineHandler reacts to a quarantine, e.g. by notifying the owners of
+// the entity. It runs inside the transaction recording it, so a returned
+// error aborts the quarantine or release.
+type QuarantineHandler func(tx *gorm.DB, event QuarantineEvent) error
+
+var (
+	quarantineMu       sync.RWMutex
+	quarantineHandlers []QuarantineHandler
+)
+
+// OnQuarantine registers handler for every entity quarantined or released.
+func OnQuarantine(handler QuarantineHandler) {
+	quarantineMu.Lock()
+	defer quarantineMu.Unlock()
+	quarantineHandlers = append(quarantineHandlers, handler)
+}
+
+func runQuarantineHandlers(tx *gorm.DB, event QuarantineEvent) error {
+	quarantineMu.RLock()
+	hs := append([]QuarantineHandler(nil), quarantineHandlers...)
+	quarantineMu.RUnlock()
+	for _, h := range hs {
+		if err := h(tx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type (
+	includeQuarantinedKey struct{}
+	quarantineRepairKey   struct{}
+)
+
+// IncludeQuarantined makes latest-version reads with ctx return quarantined
+// entities, e.g. to inspect them.
+func IncludeQuarantined(ctx context.Context) context.Context {
+	return context.WithValue(ctx, includeQuarantinedKey{}, true)
+}
+
+// WithQuarantineRepair lets the versions written with ctx through to
+// quarantined entities, for repairing their history before releasing them.
+func WithQuarantineRepair(ctx context.Context) context.Context {
+	return context.WithValue(ctx, quarantineRepairKey{}, true)
+}
+
+func ctxFlag(ctx context.Context, key any) bool {
+	if ctx == nil {
+		return false
+	}
+	ok, _ := ctx.Value(key).(bool)
+	return ok
+}
+
+// MigrateQuarantine creates the quarantine table.
+func MigrateQuarantine(db *gorm.DB) error {
+	return db.AutoMigrate(&Quarantine{})
+}
+
+// QuarantineEntity quarantines entity id of model for reason, check naming
+// the automated check that found it, if any. The actor of ctx is recorded as
+// having done it. Quarantining a quarantined entity returns the quarantine
+// in place.
+func QuarantineEntity(ctx context.Context, db *gorm.DB, model any, id, reason, check string) (Quarantine, error) {
+	info, err := Describe(db, model)
+	if err != nil {
+		return Quarantine{}, err
+	}
+	q := Quarantine{Table: info.Table, EntityID: id, Reason: reason, Check: check, CreatedBy: ActorFrom(ctx), CreatedAt: time.Now().UTC()}
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var n int64
+		if err := AllVersions(ctx, tx, model).Where("id = ?", id).Count(&n).Error; err != nil {
+			return fmt.Errorf("reading %s failed: %w", id, Translate(err))
+		}
+		if n == 0 {
+			return fmt.Errorf("quarantining %s failed: %w", id, ErrNotFound)
+		}
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&q)
+		if res.Error != nil {
+			return fmt.Errorf("quarantining %s failed: %w", id, res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return tx.Where("table_name = ? AND entity_id = ?", info.Table, id).Take(&q).Error
+		}
+		return runQuarantineHandlers(tx, QuarantineEvent{Quarantine: q, Actor: q.CreatedBy})
+	})
+	return q, err
+}
+
+// ReleaseQuarantine releases entity id of model from quarantine, failing
+// with ErrNotFound when it is not quarantined.
+func ReleaseQuarantine(ctx context.Context, db *gorm.DB, model any, id string) error {
+	info, err := Describe(db, model)
+	if err != nil {
+		return err
+	}
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var q Quarantine
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("table_name = ? AND entity_id = ?", info.Table, id).Take(&q).Error
+		if err != nil {
+			return fmt.Errorf("releasing %s from quarantine failed: %w", id, Translate(err))
+		}
+		if err := tx.Delete(&q).Error; err != nil {
+			return fmt.Errorf("releasing %s from quarantine failed: %w", id, err)
+		}
+		return runQuarantineHandlers(tx, QuarantineEvent{Quarantine: q, Released: true, Actor: ActorFrom(ctx)})
+	})
+}
+
+// ListQuarantined returns the quarantined entities of table, of every table
+// when it is empty, oldest first.
+func ListQuarantined(ctx context.Context, db *gorm.DB, table string) ([]Quarantine, error) {
+	var out []Quarantine
+	q := db.WithContext(ctx).Order("created_at, table_name, entity_id")
+	if table != "" {
+		q = q.Where("table_name = ?", table)
+	}
+	if err := q.Find(&out).Error; err != nil {
+		return nil, fmt.Errorf("listing quarantined entities failed: %w", err)
+	}
+	return out, nil
+}
+
+// hidesQuarantined reports whether latest-version reads under ctx leave out
+// quarantined entities.
+func hidesQuarantined(ctx context.Context) bool {
+	return currentConfig().Quarantine && !ctxFlag(ctx, includeQuarantinedKey{})
+}
+
+// notQuarantined is the condition that the entity whose id is id of table
+// is not quarantined.
+func notQuarantined(table string, id any) clause.Expr {
+	return clause.Expr{SQL: "NOT EXISTS (SELECT 1 FROM scd_quarantines q WHERE q.table_name = ? AND q.entity_id = ?)",
+		Vars: []any{table, id}}
+}
+
+// checkQuarantine fails a version of entity in tx's transaction with
+// ErrQuarantined when the entity is quarantined and Config.Quarantine is set.
+func checkQuarantine(tx *gorm.DB, entity any) error {
+	ctx := tx.Statement.Context
+	if !currentConfig().Quarantine || ctxFlag(ctx, quarantineRepairKey{}) {
+		return nil
+	}
+	e, ok := entity.(Entity)
+	if !ok {
+		return nil
+	}
+	info, err := Describe(tx, entity)
+	if err != nil {
+		return err
+	}
+	var q Quarantine
+	err = tx.Session(&gorm.Session{NewDB: true}).Where("table_name = ? AND entity_id = ?", info.Table, e.GetID()).Limit(1).Find(&q).Error
+	if err != nil {
+		return fmt.Errorf("checking quarantine of %s failed: %w", e.GetID(), err)
+	}
+	if q.EntityID != "" {
+		return fmt.Errorf("%w: %s since %s: %s", ErrQuarantined, e.GetID(), q.CreatedAt.Format(time.RFC3339), q.Reason)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package scd_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func TestLatestLeavesOutQuarantinedEntities(t *testing.T) {
+	db := dryRunDB(t)
+	latest := func(ctx context.Context) string {
+		return db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			var jobs []models.Job
+			return tx.WithContext(ctx).Scopes(scd.Latest[models.Job]()).Find(&jobs)
+		})
+	}
+	if sql := latest(context.Background()); strings.Contains(sql, "scd_quarantines") {
+		t.Errorf("quarantine filtered without Config.Quarantine:\n%s", sql)
+	}
+
+	scd.Configure(scd.Config{Quarantine: true})
+	defer scd.Configure(scd.Config{})
+	if sql := latest(context.Background()); !strings.Contains(sql, `NOT EXISTS (SELECT 1 FROM scd_quarantines q WHERE q.table_name = 'jobs'`) {
+		t.Errorf("quarantined entities not left out:\n%s", sql)
+	}
+	if sql := latest(scd.IncludeQuarantined(context.Background())); strings.Contains(sql, "scd_quarantines") {
+		t.Errorf("IncludeQuarantined still filters:\n%s", sql)
+	}
+}
@@ -0,0 +1,56 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// RawLatest runs a hand-written clause against the latest version of every
+// entity of model. The clause may start with WHERE and may continue with ORDER
+// BY or LIMIT; it sees the latest rows under the model's own table name, so both
+// "rate > ?" and "jobs.rate > ?" work:
+//
+//	var jobs []models.Job
+//	scd.RawLatest(ctx, db, models.Job{}, "WHERE rate > ? AND title ILIKE ?", 100, "%eng%").Scan(&jobs)
+func RawLatest[T any](ctx context.Context, db *gorm.DB, model T, clause string, args ...any) *gorm.DB {
+	return latestRows(db.WithContext(ctx), model, "*", clause, args...)
+}
+
+// latestRows selects columns from the latest rows of model followed by clause,
+// using db's context.
+func latestRows(db *gorm.DB, model any, columns, clause string, args ...any) *gorm.DB {
+	info, err := Describe(db, model)
+	if err != nil {
+		tx := db.Session(&gorm.Session{})
+		tx.AddError(err)
+		return tx
+	}
+	clause = strings.TrimSpace(clause)
+	if clause != "" && !hasKeywordPrefix(clause, "WHERE", "ORDER", "LIMIT") {
+		clause = "WHERE " + clause
+	}
+	if latestOnly(model) {
+		// The table holds only latest versions; tombstones are kept as RawLatest always has.
+		return db.Raw(fmt.Sprintf("SELECT %[3]s FROM %[1]s %[2]s", quote(db, info.Table), clause, columns), args...)
+	}
+	sql := fmt.Sprintf(
+		"SELECT %[3]s FROM (SELECT t.* FROM %[1]s t JOIN (?) AS latest ON t.id = latest.id AND t.version = latest.max_version) AS %[4]s %[2]s",
+		quote(db, info.Table), clause, columns, quote(db, tableRef(info.Table)))
+	return db.Raw(sql, append([]any{LatestSubquery(db.Statement.Context, db, model)}, args...)...)
+}
+
+func hasKeywordPrefix(s string, keywords ...string) bool {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return false
+	}
+	for _, k := range keywords {
+		if strings.EqualFold(fields[0], k) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package scd_test
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// dryRunDB returns a Postgres-dialect DB that renders SQL without connecting.
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("opening dry-run db: %v", err)
+	}
+	return db
+}
+
+// dryRunPool lets transactions begin and end on a dry-run DB.
+type dryRunPool struct{ gorm.ConnPool }
+
+func (p *dryRunPool) BeginTx(context.Context, *sql.TxOptions) (gorm.ConnPool, error) { return p, nil }
+func (*dryRunPool) Commit() error                                                    { return nil }
+func (*dryRunPool) Rollback() error                                                  { return nil }
+
+// dryRunTxDB is dryRunDB with transactions. The SQL of every statement run
+// is appended to the returned slice; fill, when non-nil, is called after
+// each query and may set its Dest as if rows had been read.
+func dryRunTxDB(t *testing.T, fill func(*gorm.Statement)) (*gorm.DB, *[]string) {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: &dryRunPool{}}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("opening dry-run db: %v", err)
+	}
+	var stmts []string
+	record := func(tx *gorm.DB) { stmts = append(stmts, tx.Statement.SQL.String()) }
+	db.Callback().Query().After("gorm:query").Register("test:fill", func(tx *gorm.DB) {
+		record(tx)
+		if fill != nil {
+			fill(tx.Statement)
+		}
+	})
+	db.Callback().Row().After("gorm:row").Register("test:record", record)
+	db.Callback().Create().After("gorm:create").Register("test:record", record)
+	db.Callback().Update().After("gorm:update").Register("test:record", record)
+	db.Callback().Raw().After("gorm:raw").Register("test:record", record)
+	return db, &stmts
+}
+
+func TestRawLatestScopesPredicate(t *testing.T) {
+	db := dryRunDB(t)
+	for _, clause := range []string{"WHERE rate > ? AND title ILIKE ?", "rate > ? AND title ILIKE ?"} {
+		var jobs []models.Job
+		stmt := scd.RawLatest(context.Background(), db, models.Job{}, clause, 100, "%eng%").Scan(&jobs).Statement
+		sql := stmt.SQL.String()
+		if !strings.Contains(sql, "MAX(version) as max_version") {
+			t.Errorf("%q: latest-version join missing: %s", clause, sql)
+		}
+		if !strings.HasSuffix(sql, `AS "jobs" WHERE rate > $1 AND title ILIKE $2`) {
+			t.Errorf("%q: predicate not applied to latest rows: %s", clause, sql)
+		}
+		if len(stmt.Vars) != 2 {
+			t.Errorf("%q: expected 2 bound vars, got %v", clause, stmt.Vars)
+		}
+	}
+}
+
+func TestLiveSubqueryExcludesTombstonesUnlessOptedOut(t *testing.T) {
+	db := dryRunDB(t)
+	ctx := context.Background()
+	render := func(ctx context.Context) string {
+		var jobs []models.Job
+		subq := scd.LiveSubquery(ctx, db, models.Job{})
+		return db.Model(&models.Job{}).Joins("JOIN (?) AS latest ON jobs.id = latest.id AND jobs.version = latest.max_version", subq).
+			Find(&jobs).Statement.SQL.String()
+	}
+	if sql := render(ctx); !strings.Contains(sql, "d.is_deleted") {
+		t.Errorf("tombstones not excluded: %s", sql)
+	}
+	if sql := render(scd.IncludeDeleted(ctx)); strings.Contains(sql, "d.is_deleted") {
+		t.Errorf("IncludeDeleted still filters tombstones: %s", sql)
+	}
+}
+
+func TestLatestScopeJoinsLiveSubquery(t *testing.T) {
+	db := dryRunDB(t)
+	var jobs []models.Job
+	sql := db.WithContext(context.Background()).Scopes(scd.Latest[models.Job]()).
+		Where("jobs.status = ?", "active").Find(&jobs).Statement.SQL.String()
+	if !strings.Contains(sql, `AS "latest" ON "jobs"."id" = "latest"."id" AND "jobs"."version" = "latest"."max_version"`) {
+		t.Errorf("latest-version join missing: %s", sql)
+	}
+	if n := strings.Count(sql, "jobs.status"); n != 1 {
+		t.Errorf("query condition applied %d times, want once on the outer query: %s", n, sql)
+	}
+	if !strings.Contains(sql, "d.is_deleted") {
+		t.Errorf("tombstones not excluded: %s", sql)
+	}
+}
+
+type reservedRecord struct {
+	models.Versioned
+	Total int
+}
+
+func (reservedRecord) TableName() string { return "billing.order" }
+
+func TestLatestQuotesCustomTableNames(t *testing.T) {
+	db := dryRunDB(t)
+	var rows []reservedRecord
+	sql := db.WithContext(context.Background()).Scopes(scd.Latest[reservedRecord]()).
+		Where("? > ?", scd.Column("billing.order", "total"), 10).Find(&rows).Statement.SQL.String()
+	for _, want := range []string{
+		`FROM "billing"."order" JOIN`,
+		`AS "latest" ON "order"."id" = "latest"."id" AND "order"."version" = "latest"."max_version"`,
+		`SELECT 1 FROM "billing"."order" d`,
+		`WHERE "order"."total" > $`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("want %s in %s", want, sql)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package scd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// WithSnapshot runs fn in a read-only REPEATABLE READ transaction, so every
+// read fn makes through tx sees the database as of its first statement:
+// latest-version reads of several tables, or of one table repeatedly, agree
+// with each other even while versions are created concurrently, instead of
+// seeing one entity before a change set and another after it.
+//
+//	err := scd.WithSnapshot(ctx, db, func(tx *gorm.DB) error {
+//		jobs := &repos.JobRepo{DB: tx}
+//		items := &repos.PaymentLineItemRepo{DB: tx}
+//		...
+//	})
+//
+// Writes through tx fail. Reads in a read-only snapshot never fail with
+// serialization errors, so fn is not retried. Called inside a transaction,
+// fn joins it as a savepoint and sees that transaction's snapshot instead.
+func WithSnapshot(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	return readSnapshot(ctx, db, sql.LevelRepeatableRead, fn)
+}
+
+// WithSerializableSnapshot is WithSnapshot in a SERIALIZABLE READ ONLY
+// DEFERRABLE transaction, which additionally never observes a state that no
+// serial order of the concurrent serializable writers could have produced.
+// Its first statement may wait until such a snapshot is available.
+func WithSerializableSnapshot(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	return readSnapshot(ctx, db, sql.LevelSerializable, fn)
+}
+
+func readSnapshot(ctx context.Context, db *gorm.DB, level sql.IsolationLevel, fn func(tx *gorm.DB) error) error {
+	_, nested := db.Statement.ConnPool.(gorm.TxCommitter)
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if level == sql.LevelSerializable && !nested {
+			if err := tx.Exec("SET TRANSACTION DEFERRABLE").Error; err != nil {
+				return fmt.Errorf("starting read snapshot failed: %w", err)
+			}
+		}
+		return fn(tx)
+	}, &sql.TxOptions{Isolation: level, ReadOnly: true})
+}
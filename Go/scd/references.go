@@ -0,0 +1,107 @@
+package scd
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Reference declares that Field of a model holds the UID of a version of Target.
+type Reference struct {
+	Field  string
+	Target any
+}
+
+var (
+	guardsMu sync.RWMutex
+	guards   = map[reflect.Type][]Reference{}
+)
+
+// GuardReferences makes every create of model fail with ErrStaleReference
+// when one of refs points at a version that is no longer the latest of its
+// entity, e.g. a payment line item computed from a superseded timelog:
+//
+//	scd.GuardReferences(models.PaymentLineItem{}, scd.Reference{Field: "TimelogUID", Target: models.Timelog{}})
+//
+// Guards take effect on databases passed to InstallGuards.
+func GuardReferences(model any, refs ...Reference) {
+	t := modelType(model)
+	guardsMu.Lock()
+	defer guardsMu.Unlock()
+	guards[t] = append(guards[t], refs...)
+}
+
+// InstallGuards registers the create callback that enforces GuardReferences on db.
+func InstallGuards(db *gorm.DB) error {
+	return db.Callback().Create().After("gorm:begin_transaction").Before("gorm:create").Register("scd:reference_guard", checkGuards)
+}
+
+// CheckLatest verifies inside tx that uid identifies the latest approved
+// version of its entity in target's table. The referenced row is locked FOR SHARE, so a
+// concurrent writer cannot commit a newer version until tx finishes.
+func CheckLatest(tx *gorm.DB, target any, uid string) error {
+	info, err := Describe(tx, target)
+	if err != nil {
+		return err
+	}
+	// Drafts saved on top of the latest version do not supersede it.
+	approved := ""
+	if hasStates(target) {
+		approved = " AND n." + approvedState
+	}
+	var latest []bool
+	query := fmt.Sprintf(
+		"SELECT NOT EXISTS (SELECT 1 FROM %[1]s n WHERE n.id = t.id AND n.version > t.version%[2]s) FROM %[1]s t WHERE t.uid = ? FOR SHARE OF t",
+		quote(tx, info.Table), approved)
+	if err := tx.Raw(query, uid).Scan(&latest).Error; err != nil {
+		return fmt.Errorf("checking reference %s failed: %w", uid, err)
+	}
+	if len(latest) == 0 {
+		return fmt.Errorf("%w: %s %s does not exist", ErrStaleReference, info.Table, uid)
+	}
+	if !latest[0] {
+		return fmt.Errorf("%w: %s %s has been superseded", ErrStaleReference, info.Table, uid)
+	}
+	return nil
+}
+
+func checkGuards(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement.Schema == nil {
+		return
+	}
+	guardsMu.RLock()
+	refs := guards[tx.Statement.Schema.ModelType]
+	guardsMu.RUnlock()
+	if len(refs) == 0 {
+		return
+	}
+
+	rv := reflect.Indirect(tx.Statement.ReflectValue)
+	rows := []reflect.Value{rv}
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		rows = rows[:0]
+		for i := 0; i < rv.Len(); i++ {
+			rows = append(rows, reflect.Indirect(rv.Index(i)))
+		}
+	}
+	check := tx.Session(&gorm.Session{NewDB: true})
+	for _, ref := range refs {
+		field := tx.Statement.Schema.LookUpField(ref.Field)
+		if field == nil {
+			tx.AddError(fmt.Errorf("guarded field %s not found on %s", ref.Field, tx.Statement.Schema.Name))
+			return
+		}
+		for _, row := range rows {
+			value, zero := field.ValueOf(tx.Statement.Context, row)
+			if zero {
+				continue
+			}
+			if err := CheckLatest(check, ref.Target, fmt.Sprint(value)); err != nil {
+				tx.AddError(err)
+				return
+			}
+		}
+	}
+}
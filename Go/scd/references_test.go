@@ -0,0 +1,18 @@
+package scd_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+)
+
+func TestCheckLatestIgnoresDrafts(t *testing.T) {
+	db, stmts := dryRunTxDB(t, nil)
+	scd.CheckLatest(db, &models.Timelog{}, "tl-uid-1")
+	want := `n.id = t.id AND n.version > t.version AND n.version_state = 'approved'`
+	if len(*stmts) == 0 || !strings.Contains((*stmts)[0], want) {
+		t.Errorf("reference check lacks %q: %v", want, *stmts)
+	}
+}
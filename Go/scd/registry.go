@@ -0,0 +1,77 @@
+package scd
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ModelOptions declares per-model behaviour at registration.
+type ModelOptions struct {
+	// Retention decides how much history the maintenance scheduler keeps.
+	Retention RetentionPolicy
+	// PII lists the columns holding personal data, which anonymized exports
+	// replace with pseudonyms.
+	PII []string
+	// RLS isolates the model's rows per tenant; see EnableRLS.
+	RLS *RLSPolicy
+	// Layout selects how versions are stored; see PrepareLayout.
+	Layout Layout
+	// References lists the fields holding the UID of a version of another
+	// registered model, which make the model a dependent of that model in
+	// AggregateAsOf.
+	References []Reference
+}
+
+// Registration is a model together with the options it was registered with.
+type Registration struct {
+	Model   any
+	Options ModelOptions
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []Registration
+)
+
+// RegisterModel makes model known to generic tooling such as the maintenance
+// scheduler. Registering the same type again replaces its options. It panics
+// if model cannot be parsed as a GORM model.
+func RegisterModel(model any, opts ModelOptions) {
+	t := modelType(model)
+	if _, err := describeModel(Registration{Model: reflect.New(t).Interface()}); err != nil {
+		panic(fmt.Sprintf("scd: registering %s: %v", t, err))
+	}
+	for _, ref := range opts.References {
+		if err := checkReference(t, ref); err != nil {
+			panic(fmt.Sprintf("scd: registering %s: %v", t, err))
+		}
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for i, r := range registry {
+		if modelType(r.Model) == t {
+			registry[i].Options = opts
+			return
+		}
+	}
+	registry = append(registry, Registration{Model: reflect.New(t).Elem().Interface(), Options: opts})
+}
+
+// Registrations returns all registered models in registration order.
+func Registrations() []Registration {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append([]Registration(nil), registry...)
+}
+
+func registrationFor(t reflect.Type) (Registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, r := range registry {
+		if modelType(r.Model) == t {
+			return r, true
+		}
+	}
+	return Registration{}, false
+}
@@ -0,0 +1,158 @@
+package scd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/yourorg/Go/scderr"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrVersionReserved is returned by ReserveVersion when another writer
+	// holds a live reservation of the entity's next version.
+	ErrVersionReserved = scderr.New(scderr.Conflict, "scd: next version is reserved")
+	// ErrReservationExpired is returned when committing a reservation whose
+	// time to live has passed.
+	ErrReservationExpired = scderr.New(scderr.Conflict, "scd: version reservation expired")
+)
+
+// VersionReservation is a version number handed out ahead of the write that
+// creates it, so a client can stage an edit while offline and commit it
+// later. It does not block other writers: when one of them creates the
+// reserved version first, committing the reservation fails with
+// ErrVersionConflict and the client rebases its edit on a new reservation.
+type VersionReservation struct {
+	Token    string `gorm:"primaryKey;column:token" json:"token"`
+	Table    string `gorm:"uniqueIndex:idx_scd_reservation_version;column:table_name" json:"table"`
+	EntityID string `gorm:"uniqueIndex:idx_scd_reservation_version;column:entity_id" json:"entity_id"`
+	// BaseVersion is the entity's latest approved version when the
+	// reservation was made, the version the staged edit is based on. Version
+	// follows the entity's newest version, which drafts may have taken past
+	// BaseVersion.
+	BaseVersion int       `gorm:"column:base_version" json:"base_version"`
+	Version     int       `gorm:"uniqueIndex:idx_scd_reservation_version;column:version" json:"version"`
+	Actor       string    `gorm:"column:actor" json:"actor,omitempty"`
+	CreatedAt   time.Time `gorm:"column:created_at" json:"created_at"`
+	ExpiresAt   time.Time `gorm:"index;column:expires_at" json:"expires_at"`
+}
+
+func (VersionReservation) TableName() string { return "scd_version_reservations" }
+
+// MigrateReservations creates the version reservations table.
+func MigrateReservations(db *gorm.DB) error {
+	return db.AutoMigrate(&VersionReservation{})
+}
+
+// ReserveVersion reserves the next version of entity id of model for ttl.
+// Expired reservations of the entity are dropped first; a live one of the
+// same version fails the call with ErrVersionReserved.
+func ReserveVersion(ctx context.Context, db *gorm.DB, model any, id string, ttl time.Duration) (VersionReservation, error) {
+	var r VersionReservation
+	info, err := Describe(db, model)
+	if err != nil {
+		return r, err
+	}
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		if err := tx.Where("table_name = ? AND entity_id = ? AND expires_at <= ?", info.Table, id, now).
+			Delete(&VersionReservation{}).Error; err != nil {
+			return fmt.Errorf("dropping expired reservations of %s failed: %w", id, err)
+		}
+		approvedVersion := "MAX(version)"
+		if hasStates(model) {
+			approvedVersion = "MAX(version) FILTER (WHERE " + approvedState + ")"
+		}
+		var latest struct{ Newest, Approved int }
+		err := tx.Model(model).Where("id = ?", id).
+			Select("COALESCE(MAX(version), 0) AS newest, COALESCE(" + approvedVersion + ", 0) AS approved").
+			Scan(&latest).Error
+		if err != nil {
+			return fmt.Errorf("reading newest version of %s failed: %w", id, Translate(err))
+		}
+		if latest.Approved == 0 {
+			return fmt.Errorf("reserving a version of %s failed: %w", id, ErrNotFound)
+		}
+		r = VersionReservation{Token: UUIDv4(), Table: info.Table, EntityID: id, BaseVersion: latest.Approved,
+			Version: latest.Newest + 1, Actor: ActorFrom(ctx), CreatedAt: now, ExpiresAt: now.Add(ttl)}
+		err = tx.Create(&r).Error
+		if pgErr := (*pgconn.PgError)(nil); errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Errorf("%w: %s v%d", ErrVersionReserved, id, r.Version)
+		}
+		if err != nil {
+			return fmt.Errorf("reserving a version of %s failed: %w", id, err)
+		}
+		return nil
+	})
+	return r, err
+}
+
+// CommitReservation writes the version reserved under token: write runs in
+// the transaction holding the reservation and must create the version based
+// on r.BaseVersion, e.g. with CreateNewSCDVersionIf or MergePatchIf. The
+// reservation is used up once write succeeds, or once it fails with
+// ErrVersionConflict because another writer created the version first.
+// Expired reservations fail with ErrReservationExpired.
+func CommitReservation(ctx context.Context, db *gorm.DB, token string, write func(tx *gorm.DB, r VersionReservation) error) error {
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var r VersionReservation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token = ?", token).Take(&r).Error; err != nil {
+			return fmt.Errorf("reading reservation %s failed: %w", token, Translate(err))
+		}
+		if !r.ExpiresAt.After(time.Now()) {
+			return fmt.Errorf("%w: %s v%d expired at %s", ErrReservationExpired, r.EntityID, r.Version, r.ExpiresAt.Format(time.RFC3339))
+		}
+		if err := write(tx, r); err != nil {
+			return err
+		}
+		if err := tx.Delete(&r).Error; err != nil {
+			return fmt.Errorf("releasing reservation %s failed: %w", token, err)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrVersionConflict) || errors.Is(err, ErrReservationExpired) {
+		if abandonErr := AbandonReservation(ctx, db, token); abandonErr != nil {
+			return errors.Join(err, abandonErr)
+		}
+	}
+	return err
+}
+
+// CommitReservedVersion commits the reservation under token with updateFn,
+// as CreateNewSCDVersionIf would apply it to the reserved version's base.
+func CommitReservedVersion[T any, P EntityPtr[T]](ctx context.Context, db *gorm.DB, token string, updateFn func(*T) error) error {
+	var model T
+	info, err := Describe(db, &model)
+	if err != nil {
+		return err
+	}
+	return CommitReservation(ctx, db, token, func(tx *gorm.DB, r VersionReservation) error {
+		if r.Table != info.Table {
+			return fmt.Errorf("committing reservation %s failed: it reserves a version of %s, not %s: %w", token, r.Table, info.Table, ErrNotFound)
+		}
+		return CreateNewSCDVersionIf[T, P](ctx, tx, r.EntityID, r.BaseVersion, updateFn)
+	})
+}
+
+// AbandonReservation drops the reservation under token, leaving its entity
+// unchanged. Abandoning an unknown or used-up reservation does nothing.
+func AbandonReservation(ctx context.Context, db *gorm.DB, token string) error {
+	if err := db.WithContext(ctx).Where("token = ?", token).Delete(&VersionReservation{}).Error; err != nil {
+		return fmt.Errorf("abandoning reservation %s failed: %w", token, err)
+	}
+	return nil
+}
+
+// PurgeExpiredReservations drops every expired reservation, returning how
+// many there were.
+func PurgeExpiredReservations(ctx context.Context, db *gorm.DB) (int64, error) {
+	res := db.WithContext(ctx).Where("expires_at <= ?", time.Now().UTC()).Delete(&VersionReservation{})
+	if res.Error != nil {
+		return 0, fmt.Errorf("purging expired reservations failed: %w", res.Error)
+	}
+	return res.RowsAffected, nil
+}
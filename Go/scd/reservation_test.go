@@ -0,0 +1,72 @@
+package scd_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func TestCommitReservedVersionAppliesToBaseVersion(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		r         scd.VersionReservation
+		wantErr   error
+		created   int
+		abandoned bool
+	}{
+		{"current base", scd.VersionReservation{Table: "jobs", EntityID: "job1", BaseVersion: 4, Version: 5}, nil, 1, false},
+		{"moved base", scd.VersionReservation{Table: "jobs", EntityID: "job1", BaseVersion: 3, Version: 4}, scd.ErrVersionConflict, 0, true},
+		{"other table", scd.VersionReservation{Table: "timelogs", EntityID: "job1", BaseVersion: 4, Version: 5}, scd.ErrNotFound, 0, false},
+		{"expired", scd.VersionReservation{Table: "jobs", EntityID: "job1", BaseVersion: 4, Version: 5}, scd.ErrReservationExpired, 0, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db, _ := dryRunTxDB(t, func(stmt *gorm.Statement) {
+				latestJob(stmt)
+				if r, ok := stmt.Dest.(*scd.VersionReservation); ok {
+					*r = tc.r
+					r.Token, r.ExpiresAt = "tok", time.Now().Add(time.Hour)
+					if tc.wantErr == scd.ErrReservationExpired {
+						r.ExpiresAt = time.Now().Add(-time.Minute)
+					}
+				}
+			})
+			created := createdJobs(db)
+			var deletes []string
+			db.Callback().Delete().After("gorm:delete").Register("test:record", func(tx *gorm.DB) {
+				deletes = append(deletes, tx.Statement.SQL.String())
+			})
+			err := scd.CommitReservedVersion[models.Job](context.Background(), db, "tok", func(j *models.Job) error {
+				j.Rate = 120
+				return nil
+			})
+			if !errors.Is(err, tc.wantErr) && err != tc.wantErr {
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			}
+			if len(*created) != tc.created {
+				t.Errorf("created %d versions, want %d", len(*created), tc.created)
+			}
+			// A successful commit releases the reservation, a failed one
+			// abandons it; either is a delete by token.
+			released := tc.wantErr == nil || tc.abandoned
+			if got := len(deletes) > 0 && strings.Contains(deletes[len(deletes)-1], `DELETE FROM "scd_version_reservations"`); got != released {
+				t.Errorf("reservation deleted = %v, want %v:\n%s", got, released, strings.Join(deletes, "\n"))
+			}
+		})
+	}
+}
+
+func TestReserveVersionBasesOnLatestApproved(t *testing.T) {
+	db, stmts := dryRunTxDB(t, nil)
+	// Dry-run scans fail, but the reading statement is recorded first.
+	scd.ReserveVersion(context.Background(), db, &models.Job{}, "job1", time.Hour)
+	sql := strings.Join(*stmts, "\n")
+	if !strings.Contains(sql, "COALESCE(MAX(version), 0) AS newest, COALESCE(MAX(version) FILTER (WHERE version_state = 'approved'), 0) AS approved") {
+		t.Errorf("reservation not based on the latest approved version:\n%s", sql)
+	}
+}
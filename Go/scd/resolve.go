@@ -0,0 +1,100 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// A UID reference such as PaymentLineItem.JobUID pins one version of its
+// target. GetByUID resolves it to that version; the helpers below resolve it
+// to the current latest version of the same entity instead, answering e.g.
+// "what is the job's status now" for a line item computed from an older
+// version of the job.
+
+// ResolveLatestOf returns the latest approved version of the entity uid
+// identifies a version of, tombstones included. Errors wrap ErrNotFound when
+// no version has uid.
+func ResolveLatestOf[T any](ctx context.Context, db *gorm.DB, uid string) (T, error) {
+	var v T
+	owner := AllVersions(ctx, db, &v).Select("id").Where("uid = ?", uid).Limit(1)
+	if err := approvedOnly(db.WithContext(ctx).Where("id = (?)", owner), &v).Order("version DESC").First(&v).Error; err != nil {
+		return v, fmt.Errorf("resolving latest version of %s failed: %w", uid, Translate(err))
+	}
+	return v, nil
+}
+
+// ResolveLatestOfAll is ResolveLatestOf for many references at once, in two
+// queries. The result maps each uid to the latest version of its entity;
+// uids no version has are missing from it.
+func ResolveLatestOfAll[T any](ctx context.Context, db *gorm.DB, uids []string) (map[string]T, error) {
+	out := make(map[string]T, len(uids))
+	if len(uids) == 0 {
+		return out, nil
+	}
+	var model T
+	var refs []struct{ ID, UID string }
+	if err := AllVersions(ctx, db, &model).Select("id, uid").Where("uid IN ?", uids).Scan(&refs).Error; err != nil {
+		return nil, fmt.Errorf("resolving version references failed: %w", Translate(err))
+	}
+	if len(refs) == 0 {
+		return out, nil
+	}
+	ids := make([]string, len(refs))
+	for i, r := range refs {
+		ids[i] = r.ID
+	}
+	info, err := Describe(db, &model)
+	if err != nil {
+		return nil, err
+	}
+	var latest []T
+	err = db.WithContext(IncludeDeleted(ctx)).Scopes(Latest[T]()).
+		Where(fmt.Sprintf("%s.id IN ?", quote(db, tableRef(info.Table))), ids).Find(&latest).Error
+	if err != nil {
+		return nil, fmt.Errorf("resolving latest versions failed: %w", Translate(err))
+	}
+	byID := make(map[string]T, len(latest))
+	for i := range latest {
+		id, _, _ := versionedKey(&latest[i])
+		byID[id] = latest[i]
+	}
+	for _, r := range refs {
+		if v, ok := byID[r.ID]; ok {
+			out[r.UID] = v
+		}
+	}
+	return out, nil
+}
+
+// JoinLatestOf returns a scope that left-joins onto a query the latest
+// approved version of the T whose versions column references by UID, under
+// alias, whichever version the reference pins:
+//
+//	db.Model(&models.PaymentLineItem{}).
+//		Scopes(scd.Latest[models.PaymentLineItem](), scd.JoinLatestOf[models.Job]("payment_line_items.job_uid", "job")).
+//		Select("payment_line_items.id, job.status").Scan(&rows)
+//
+// The joined rows have T's columns plus pinned_uid, the UID they were
+// joined on. Tombstones are joined too, so a deleted target shows as
+// is_deleted rather than vanishing; references to no version join NULLs.
+func JoinLatestOf[T any](column, alias string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		var model T
+		info, err := Describe(db, &model)
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+		ctx := db.Statement.Context
+		sub := db.Session(&gorm.Session{NewDB: true, Initialized: true}).WithContext(ctx)
+		rel := sub.Table(versionsAs(sub, &model, info, "p")).
+			Select("p.uid AS pinned_uid, l.*").
+			Joins(fmt.Sprintf("JOIN %s l ON l.id = p.id", quote(sub, info.Table)))
+		if !latestOnly(&model) {
+			rel = rel.Joins("JOIN (?) AS lv ON lv.id = l.id AND lv.max_version = l.version", LatestSubquery(ctx, sub, model))
+		}
+		return db.Joins(fmt.Sprintf("LEFT JOIN (?) AS %[1]s ON %[1]s.pinned_uid = %[2]s", quote(db, alias), quote(db, column)), rel)
+	}
+}
@@ -0,0 +1,44 @@
+package scd_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func TestResolveLatestOfFollowsTheReferencedEntity(t *testing.T) {
+	db := dryRunDB(t)
+	var sql string
+	db.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		if strings.Contains(tx.Statement.SQL.String(), "LIMIT") {
+			sql = tx.Statement.SQL.String()
+		}
+	})
+	scd.ResolveLatestOf[models.Job](context.Background(), db, "uid1")
+	want := `SELECT * FROM "jobs" WHERE id = (SELECT "id" FROM "jobs" WHERE uid = $1 LIMIT $2) AND version_state = 'approved' ORDER BY version DESC,"jobs"."id" LIMIT $3`
+	if sql != want {
+		t.Errorf("got\n%s\nwant\n%s", sql, want)
+	}
+}
+
+func TestJoinLatestOfJoinsOnThePinnedUID(t *testing.T) {
+	db := dryRunDB(t)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var rows []map[string]any
+		return tx.Model(&models.PaymentLineItem{}).
+			Scopes(scd.JoinLatestOf[models.Job]("payment_line_items.job_uid", "job")).
+			Select("payment_line_items.id, job.status").Find(&rows)
+	})
+	for _, want := range []string{
+		`LEFT JOIN (SELECT p.uid AS pinned_uid, l.* FROM "jobs" p JOIN "jobs" l ON l.id = p.id JOIN (SELECT id, MAX(version) as max_version FROM "jobs" WHERE version_state = 'approved' GROUP BY "id") AS lv`,
+		`AS "job" ON "job".pinned_uid = "payment_line_items"."job_uid"`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("join SQL lacks %q:\n%s", want, sql)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RetentionKind selects a retention strategy.
+type RetentionKind string
+
+const (
+	RetainAll      RetentionKind = "keep_all"
+	RetainLastN    RetentionKind = "keep_last_n"
+	RetainDuration RetentionKind = "keep_duration"
+	RetainArchive  RetentionKind = "archive"
+)
+
+// RetentionPolicy says which superseded versions may leave the hot table. The
+// latest approved version of an entity is never removed, nor are drafts and
+// pending versions. The zero value keeps everything.
+type RetentionPolicy struct {
+	Kind RetentionKind
+	// KeepLast is the number of most recent versions kept per entity (RetainLastN).
+	KeepLast int
+	// KeepFor is how long superseded history stays in the table (RetainDuration, RetainArchive).
+	KeepFor time.Duration
+}
+
+// KeepAll retains every version.
+func KeepAll() RetentionPolicy { return RetentionPolicy{Kind: RetainAll} }
+
+// KeepLastN retains the n most recent versions of each entity.
+func KeepLastN(n int) RetentionPolicy { return RetentionPolicy{Kind: RetainLastN, KeepLast: n} }
+
+// KeepFor deletes versions superseded more than d ago.
+func KeepFor(d time.Duration) RetentionPolicy {
+	return RetentionPolicy{Kind: RetainDuration, KeepFor: d}
+}
+
+// ArchiveAfter moves versions superseded more than d ago into <table>_archive.
+func ArchiveAfter(d time.Duration) RetentionPolicy {
+	return RetentionPolicy{Kind: RetainArchive, KeepFor: d}
+}
+
+// RetentionResult counts the rows a retention run removed from the hot table.
+type RetentionResult struct {
+	Table    string
+	Pruned   int64
+	Archived int64
+	// Resume is set when the run stopped at its Limits before finishing.
+	Resume ResumeToken
+}
+
+// ApplyRetention enforces policy on model's table in batches of batchSize rows.
+//
+// Duration-based policies keep the version that was in effect at the cutoff,
+// so as-of queries inside the retention window keep working: a version goes
+// only once a newer version was itself created before the cutoff.
+func ApplyRetention(ctx context.Context, db *gorm.DB, model any, policy RetentionPolicy, batchSize int) (RetentionResult, error) {
+	return ApplyRetentionWithin(ctx, db, model, policy, batchSize, Limits{})
+}
+
+// ApplyRetentionWithin is ApplyRetention bounded by limits. Batches are taken
+// in (id, version) order, so a run resumed from res.Resume skips the part of
+// the table already processed.
+func ApplyRetentionWithin(ctx context.Context, db *gorm.DB, model any, policy RetentionPolicy, batchSize int, limits Limits) (RetentionResult, error) {
+	info, err := Describe(db, model)
+	res := RetentionResult{Table: info.Table}
+	if err != nil {
+		return res, err
+	}
+	if batchSize <= 0 {
+		batchSize = 5000
+	}
+	db = db.WithContext(ctx)
+
+	// Under the history layouts only the history table holds prunable rows,
+	// but newer versions are looked up across every version.
+	target, all := info.Table, versionsRelation(db, model, info, false)
+	if h := historyTable(model, info.Table); h != "" {
+		target = h
+	}
+	target = quote(db, target)
+	// Drafts and pending versions neither count as newer versions nor go:
+	// only an approved version supersedes another.
+	approved, ranked := "", ""
+	if hasStates(model) {
+		approved, ranked = " AND n."+approvedState, " WHERE "+approvedState
+	}
+	var cond string
+	var args []any
+	switch policy.Kind {
+	case "", RetainAll:
+		return res, nil
+	case RetainLastN:
+		if policy.KeepLast < 1 {
+			return res, fmt.Errorf("keep-last-n retention on %s needs KeepLast >= 1", info.Table)
+		}
+		cond = fmt.Sprintf(`(t.id, t.version) IN (
+			SELECT id, version FROM (
+				SELECT id, version, row_number() OVER (PARTITION BY id ORDER BY version DESC) AS rn FROM %s%s
+			) ranked WHERE rn > ?)`, all, ranked)
+		args = []any{policy.KeepLast}
+	case RetainDuration, RetainArchive:
+		cond = fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM %s WHERE n.id = t.id AND n.version > t.version%s AND COALESCE(n.created_at, '-infinity') < ?)`,
+			versionsAs(db, model, info, "n"), approved)
+		args = []any{time.Now().Add(-policy.KeepFor)}
+	default:
+		return res, fmt.Errorf("unknown retention kind %q", policy.Kind)
+	}
+	if approved != "" {
+		cond += " AND t." + approvedState
+	}
+
+	return removeVersions(ctx, db, info, target, cond, args, batchSize, policy.Kind == RetainArchive, limits)
+}
+
+// removeVersions deletes the rows of target matching cond, a condition over
+// its rows aliased t, in batches of batchSize in (id, version) order, moving
+// them to <table>_archive when archive is set.
+func removeVersions(ctx context.Context, db *gorm.DB, info TableInfo, target, cond string, args []any, batchSize int, archive bool, limits Limits) (RetentionResult, error) {
+	res := RetentionResult{Table: info.Table}
+	pos, err := limits.Resume.position(info.Table)
+	if err != nil {
+		return res, err
+	}
+	batch := fmt.Sprintf("SELECT t.ctid FROM %s t WHERE %s AND (t.id, t.version) > (?, ?) ORDER BY t.id, t.version LIMIT %d",
+		target, cond, batchSize)
+	removed := fmt.Sprintf("gone AS (DELETE FROM %s WHERE ctid IN (%s) RETURNING *)", target, batch)
+	counter := &res.Pruned
+	if archive {
+		archive := quote(db, ArchiveTable(info.Table))
+		if err := db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (LIKE %s INCLUDING DEFAULTS)", archive, target)).Error; err != nil {
+			return res, fmt.Errorf("creating %s failed: %w", archive, err)
+		}
+		removed += fmt.Sprintf(", moved AS (INSERT INTO %s SELECT * FROM gone)", archive)
+		counter = &res.Archived
+	}
+	// Report the last removed key along with the count, for the resume position.
+	stmt := "WITH " + removed + " SELECT id, version, COUNT(*) OVER () AS n FROM gone ORDER BY id DESC, version DESC LIMIT 1"
+
+	b := limits.start(ctx)
+	for {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		if b.exhausted() {
+			res.Resume = newResumeToken(pos)
+			return res, nil
+		}
+		started := time.Now()
+		var last struct {
+			ID      string
+			Version int
+			N       int64
+		}
+		r := db.Raw(stmt, append(args[:len(args):len(args)], pos.ID, pos.Version)...).Scan(&last)
+		if r.Error != nil {
+			return res, fmt.Errorf("applying retention to %s failed: %w", info.Table, r.Error)
+		}
+		b.batchDone(started)
+		*counter += last.N
+		if last.N < int64(batchSize) {
+			return res, nil
+		}
+		pos.ID, pos.Version = last.ID, last.Version
+	}
+}
@@ -0,0 +1,39 @@
+package scd_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func TestRetentionCountsOnlyApprovedVersions(t *testing.T) {
+	db := dryRunDB(t)
+	var sql string
+	db.Callback().Row().After("gorm:row").Register("test:capture", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	})
+	for _, tc := range []struct {
+		policy scd.RetentionPolicy
+		want   []string
+	}{
+		{scd.KeepLastN(1), []string{
+			`row_number() OVER (PARTITION BY id ORDER BY version DESC) AS rn FROM "jobs" WHERE version_state = 'approved'`,
+			`) ranked WHERE rn > $1) AND t.version_state = 'approved'`,
+		}},
+		{scd.KeepFor(0), []string{
+			`n.version > t.version AND n.version_state = 'approved' AND COALESCE(n.created_at, '-infinity') < $1) AND t.version_state = 'approved'`,
+		}},
+	} {
+		sql = ""
+		scd.ApplyRetention(context.Background(), db, models.Job{}, tc.policy, 100)
+		for _, want := range tc.want {
+			if !strings.Contains(sql, want) {
+				t.Errorf("%s retention SQL lacks %q:\n%s", tc.policy.Kind, want, sql)
+			}
+		}
+	}
+}
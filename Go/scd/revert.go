@@ -0,0 +1,68 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// Revert appends a new version of entity id whose content is copied from an
+// earlier version, leaving the history in between intact. It works on a
+// model value rather than a type parameter so generic tooling can call it for
+// any registered model. Reverting to a tombstone deletes the entity again.
+func Revert(ctx context.Context, db *gorm.DB, model any, id string, version int) error {
+	_, err := revert(ctx, db, modelType(model), id, version)
+	return err
+}
+
+// RevertToVersion is Revert for a known model type. It returns the new latest
+// version, which carries a fresh UID.
+func RevertToVersion[T any, P EntityPtr[T]](ctx context.Context, db *gorm.DB, id string, version int) (T, error) {
+	var zero T
+	created, err := revert(ctx, db, reflect.TypeOf(zero), id, version)
+	if err != nil {
+		return zero, err
+	}
+	return *created.(*T), nil
+}
+
+func revert(ctx context.Context, db *gorm.DB, t reflect.Type, id string, version int) (any, error) {
+	db = db.WithContext(ctx)
+	var created any
+	err := retryOnVersionConflict(func() error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			latest := reflect.New(t).Interface()
+			newest, err := lockLatest(tx, id, latest)
+			if err != nil {
+				return err
+			}
+			target := reflect.New(t).Interface()
+			if err := AllVersions(ctx, tx, target).Where("id = ? AND version = ?", id, version).Take(target).Error; err != nil {
+				return fmt.Errorf("fetching version %d failed: %w", version, Translate(err))
+			}
+			if state := stateOf(target); state != StateApproved {
+				return fmt.Errorf("%w: version %d of %s is %s and was never in effect", ErrInvalidState, version, id, state)
+			}
+			// Drop the scd:"-" fields as a clone would.
+			rv := reflect.ValueOf(target).Elem()
+			rv.Set(deepCopy(rv, map[uintptr]reflect.Value{}))
+			entity, ok := target.(Entity)
+			if !ok {
+				return fmt.Errorf("%w: %s does not implement scd.Entity", ErrNotVersioned, t)
+			}
+			prepareVersion(entity, newest)
+			kind := EventRevert
+			if isDeleted(target) {
+				kind = EventDelete
+			}
+			if err := saveVersion(tx, target, kind); err != nil {
+				return err
+			}
+			created = target
+			return nil
+		})
+	})
+	return created, err
+}
@@ -0,0 +1,244 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Session identifies who a database session acts for. It is exposed to
+// Postgres as the transaction-local settings app.tenant_id and app.role, which
+// the policies created by EnableRLS read, so isolation also holds for readers
+// that do not go through Go.
+type Session struct {
+	TenantID string
+	Role     string
+}
+
+type sessionKey struct{}
+
+// WithSession attaches s to ctx. Transactions opened with InSession, and
+// writes on databases passed to InstallSession, apply it.
+func WithSession(ctx context.Context, s Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, s)
+}
+
+// SessionFrom returns the session carried by ctx, if any.
+func SessionFrom(ctx context.Context) (Session, bool) {
+	s, ok := ctx.Value(sessionKey{}).(Session)
+	return s, ok
+}
+
+// RLSPolicy declares how rows of a model are isolated; set it in
+// ModelOptions.RLS and install it with EnableRLS.
+type RLSPolicy struct {
+	// TenantColumn is compared with app.tenant_id.
+	TenantColumn string
+	// AdminRoles see and write rows of every tenant.
+	AdminRoles []string
+	// ReadOnlyRoles may read their tenant's rows but not write.
+	ReadOnlyRoles []string
+	// Force applies the policies to the table owner as well.
+	Force bool
+}
+
+func (p RLSPolicy) isAdmin(role string) bool    { return slices.Contains(p.AdminRoles, role) }
+func (p RLSPolicy) isReadOnly(role string) bool { return slices.Contains(p.ReadOnlyRoles, role) }
+
+// InSession runs fn in a transaction with the session from ctx applied.
+// Reads need it too: outside a transaction the settings would leak to
+// whatever request next uses the pooled connection.
+func InSession(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := applySession(tx); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// InstallSession registers callbacks on db that apply the session of the
+// statement's context to every create, update and delete, and reject creates
+// that RLS would refuse with ErrTenantMismatch or ErrReadOnlySession instead
+// of an opaque policy violation.
+func InstallSession(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:begin_transaction").Before("gorm:create").Register("scd:session", createInSession); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:begin_transaction").Before("gorm:update").Register("scd:session", writeInSession); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:begin_transaction").Before("gorm:delete").Register("scd:session", writeInSession)
+}
+
+// applySession sets the transaction-local session settings of tx. It does
+// nothing without a session or outside a transaction.
+func applySession(tx *gorm.DB) error {
+	if tx.Statement.Context == nil {
+		return nil
+	}
+	s, ok := SessionFrom(tx.Statement.Context)
+	if _, inTx := tx.Statement.ConnPool.(gorm.TxCommitter); !ok || !inTx {
+		return nil
+	}
+	err := tx.Session(&gorm.Session{NewDB: true}).
+		Exec("SELECT set_config('app.tenant_id', ?, true), set_config('app.role', ?, true)", s.TenantID, s.Role).Error
+	if err != nil {
+		return fmt.Errorf("applying session settings failed: %w", err)
+	}
+	return nil
+}
+
+func writeInSession(tx *gorm.DB) {
+	if tx.Error == nil {
+		tx.AddError(applySession(tx))
+	}
+}
+
+func createInSession(tx *gorm.DB) {
+	if tx.Error != nil {
+		return
+	}
+	if err := applySession(tx); err != nil {
+		tx.AddError(err)
+		return
+	}
+	s, ok := SessionFrom(tx.Statement.Context)
+	if !ok || tx.Statement.Schema == nil {
+		return
+	}
+	reg, ok := registrationFor(tx.Statement.Schema.ModelType)
+	if !ok || reg.Options.RLS == nil {
+		return
+	}
+	p := *reg.Options.RLS
+	if p.isReadOnly(s.Role) {
+		tx.AddError(fmt.Errorf("%w: role %q", ErrReadOnlySession, s.Role))
+		return
+	}
+	field := tx.Statement.Schema.LookUpField(p.TenantColumn)
+	if field == nil || p.isAdmin(s.Role) {
+		return
+	}
+	rv := reflect.Indirect(tx.Statement.ReflectValue)
+	rows := []reflect.Value{rv}
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		rows = rows[:0]
+		for i := 0; i < rv.Len(); i++ {
+			rows = append(rows, reflect.Indirect(rv.Index(i)))
+		}
+	}
+	for _, row := range rows {
+		tenant, _ := field.ValueOf(tx.Statement.Context, row)
+		if fmt.Sprint(tenant) != s.TenantID {
+			tx.AddError(fmt.Errorf("%w: %s %v, session tenant %s", ErrTenantMismatch, p.TenantColumn, tenant, s.TenantID))
+			return
+		}
+	}
+}
+
+// EnableRLS enables row-level security on model's table with the policies
+// derived from the RLSPolicy it was registered with. Under the history
+// layouts the history relation gets the same policies, so run it after
+// PrepareLayout. Run it in a migration; it is safe to re-run after changing
+// the policy.
+func EnableRLS(ctx context.Context, db *gorm.DB, model any) error {
+	info, p, err := rlsPolicyFor(db, model)
+	if err != nil {
+		return err
+	}
+	visible := fmt.Sprintf("%s::text = current_setting('app.tenant_id', true)", quote(db, p.TenantColumn))
+	if len(p.AdminRoles) > 0 {
+		visible = fmt.Sprintf("(%s OR current_setting('app.role', true) IN (%s))", visible, quoteList(p.AdminRoles))
+	}
+	writable := visible
+	if len(p.ReadOnlyRoles) > 0 {
+		writable = fmt.Sprintf("(%s AND COALESCE(current_setting('app.role', true), '') NOT IN (%s))", visible, quoteList(p.ReadOnlyRoles))
+	}
+	var stmts []string
+	for _, t := range rlsTables(db, model, info.Table) {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY", t))
+		if p.Force {
+			stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s FORCE ROW LEVEL SECURITY", t))
+		} else {
+			stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s NO FORCE ROW LEVEL SECURITY", t))
+		}
+		stmts = append(stmts, dropPolicies(t)...)
+		stmts = append(stmts,
+			fmt.Sprintf("CREATE POLICY scd_rls_select ON %s FOR SELECT USING (%s)", t, visible),
+			fmt.Sprintf("CREATE POLICY scd_rls_insert ON %s FOR INSERT WITH CHECK (%s)", t, writable),
+			fmt.Sprintf("CREATE POLICY scd_rls_update ON %s FOR UPDATE USING (%s) WITH CHECK (%s)", t, writable, writable),
+			fmt.Sprintf("CREATE POLICY scd_rls_delete ON %s FOR DELETE USING (%s)", t, writable),
+		)
+	}
+	return execAll(ctx, db, "enabling row-level security on "+info.Table, stmts)
+}
+
+// DisableRLS drops the policies created by EnableRLS and disables row-level security.
+func DisableRLS(ctx context.Context, db *gorm.DB, model any) error {
+	info, err := Describe(db, model)
+	if err != nil {
+		return err
+	}
+	var stmts []string
+	for _, t := range rlsTables(db, model, info.Table) {
+		stmts = append(stmts, dropPolicies(t)...)
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s DISABLE ROW LEVEL SECURITY", t))
+	}
+	return execAll(ctx, db, "disabling row-level security on "+info.Table, stmts)
+}
+
+// rlsTables returns the tables holding versions of model, quoted: its
+// table and, under the history layouts, the history relation.
+func rlsTables(db *gorm.DB, model any, table string) []string {
+	if h := historyTable(model, table); h != "" {
+		return []string{quote(db, table), quote(db, h)}
+	}
+	return []string{quote(db, table)}
+}
+
+func rlsPolicyFor(db *gorm.DB, model any) (TableInfo, RLSPolicy, error) {
+	info, err := Describe(db, model)
+	if err != nil {
+		return info, RLSPolicy{}, err
+	}
+	reg, ok := registrationFor(modelType(model))
+	if !ok || reg.Options.RLS == nil || reg.Options.RLS.TenantColumn == "" {
+		return info, RLSPolicy{}, fmt.Errorf("%s is not registered with an RLS policy", info.Table)
+	}
+	return info, *reg.Options.RLS, nil
+}
+
+// dropPolicies drops the policies of EnableRLS from table, quoted.
+func dropPolicies(table string) []string {
+	var stmts []string
+	for _, name := range []string{"scd_rls_select", "scd_rls_insert", "scd_rls_update", "scd_rls_delete"} {
+		stmts = append(stmts, fmt.Sprintf("DROP POLICY IF EXISTS %s ON %s", name, table))
+	}
+	return stmts
+}
+
+func execAll(ctx context.Context, db *gorm.DB, what string, stmts []string) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range stmts {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("%s failed: %w", what, err)
+			}
+		}
+		return nil
+	})
+}
+
+// quoteList renders values as a comma-separated list of SQL string literals.
+func quoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,93 @@
+package scd_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+)
+
+type tenantRecord struct {
+	models.Versioned
+	CompanyID string
+}
+
+type sessionRecord struct {
+	models.Versioned
+	CompanyID string
+}
+
+func TestSessionCreatesAreCheckedAgainstPolicy(t *testing.T) {
+	scd.RegisterModel(sessionRecord{}, scd.ModelOptions{RLS: &scd.RLSPolicy{
+		TenantColumn: "company_id", AdminRoles: []string{"admin"}, ReadOnlyRoles: []string{"auditor"},
+	}})
+	db, stmts := dryRunTxDB(t, nil)
+	if err := scd.InstallSession(db); err != nil {
+		t.Fatalf("InstallSession: %v", err)
+	}
+	create := func(s scd.Session, company string) error {
+		ctx := scd.WithSession(context.Background(), s)
+		return db.WithContext(ctx).Create(&sessionRecord{Versioned: models.Versioned{ID: "r1", Version: 1}, CompanyID: company}).Error
+	}
+	if err := create(scd.Session{TenantID: "c1"}, "c2"); !errors.Is(err, scd.ErrTenantMismatch) {
+		t.Errorf("create for another tenant = %v, want ErrTenantMismatch", err)
+	}
+	if err := create(scd.Session{TenantID: "c1", Role: "auditor"}, "c1"); !errors.Is(err, scd.ErrReadOnlySession) {
+		t.Errorf("create in a read-only session = %v, want ErrReadOnlySession", err)
+	}
+	*stmts = nil
+	if err := create(scd.Session{TenantID: "c1", Role: "admin"}, "c2"); err != nil {
+		t.Fatalf("admin create for another tenant: %v", err)
+	}
+	if len(*stmts) < 2 || !strings.Contains((*stmts)[0], "set_config('app.tenant_id'") {
+		t.Errorf("session settings not applied before the insert:\n%s", strings.Join(*stmts, "\n"))
+	}
+
+	*stmts = nil
+	if err := scd.EnableRLS(context.Background(), db, &sessionRecord{}); err != nil {
+		t.Fatalf("EnableRLS: %v", err)
+	}
+	sql := strings.Join(*stmts, "\n")
+	for _, want := range []string{
+		`FOR SELECT USING (("company_id"::text = current_setting('app.tenant_id', true) OR current_setting('app.role', true) IN ('admin')))`,
+		`NOT IN ('auditor')`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("RLS statements lack %q:\n%s", want, sql)
+		}
+	}
+}
+
+func TestEnableRLSCoversHistoryRelation(t *testing.T) {
+	scd.RegisterModel(tenantRecord{}, scd.ModelOptions{
+		Layout: scd.LayoutHistoryTable,
+		RLS:    &scd.RLSPolicy{TenantColumn: "company_id"},
+	})
+	db, stmts := dryRunTxDB(t, nil)
+	if err := scd.EnableRLS(context.Background(), db, &tenantRecord{}); err != nil {
+		t.Fatalf("EnableRLS: %v", err)
+	}
+	sql := strings.Join(*stmts, "\n")
+	for _, table := range []string{"tenant_records", "tenant_records_versions"} {
+		for _, want := range []string{
+			`ALTER TABLE "` + table + `" ENABLE ROW LEVEL SECURITY`,
+			`CREATE POLICY scd_rls_select ON "` + table + `" FOR SELECT USING ("company_id"::text = current_setting('app.tenant_id', true))`,
+			`CREATE POLICY scd_rls_insert ON "` + table + `" FOR INSERT`,
+		} {
+			if !strings.Contains(sql, want) {
+				t.Errorf("RLS statements lack %q:\n%s", want, sql)
+			}
+		}
+	}
+
+	*stmts = nil
+	if err := scd.DisableRLS(context.Background(), db, &tenantRecord{}); err != nil {
+		t.Fatalf("DisableRLS: %v", err)
+	}
+	if sql := strings.Join(*stmts, "\n"); !strings.Contains(sql, `ALTER TABLE "tenant_records_versions" DISABLE ROW LEVEL SECURITY`) {
+		t.Errorf("history relation left under RLS:\n%s", sql)
+	}
+}
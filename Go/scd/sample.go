@@ -0,0 +1,68 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// MaxSampleSize caps the n accepted by SampleLatest.
+const MaxSampleSize = 10000
+
+// sampleScanThreshold is the estimated row count below which SampleLatest
+// orders every latest row randomly instead of sampling table pages.
+const sampleScanThreshold = 100000
+
+// SampleLatest returns a uniform random sample of up to n latest versions that
+// match filter, a predicate over the latest rows as accepted by RawLatest (it
+// may be empty). Each entity is equally likely to be picked regardless of how
+// many versions it has.
+//
+// Small tables are shuffled in full. On large ones rows are drawn with
+// TABLESAMPLE BERNOULLI and kept when no newer version exists, which avoids
+// scanning the whole table; the sampling rate is raised until n rows are found,
+// so a selective filter can still end in a full scan.
+func SampleLatest[T any](ctx context.Context, db *gorm.DB, n int, filter string, args ...any) ([]T, error) {
+	if n <= 0 || n > MaxSampleSize {
+		return nil, fmt.Errorf("sample size must be between 1 and %d, got %d", MaxSampleSize, n)
+	}
+	var model T
+	info, err := Describe(db, &model)
+	if err != nil {
+		return nil, err
+	}
+	db = db.WithContext(ctx)
+	where := "TRUE"
+	if filter != "" {
+		where = "(" + filter + ")"
+	}
+	args = append(args[:len(args):len(args)], n)
+
+	var rows float64
+	if err := db.Raw("SELECT reltuples FROM pg_class WHERE oid = CAST(? AS regclass)", quote(db, info.Table)).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("estimating table size failed: %w", err)
+	}
+	var sample []T
+	if rows >= sampleScanThreshold {
+		// Start at four times the rate needed if every row were a latest version.
+		for percent := 400 * float64(n) / rows; percent < 100; percent *= 4 {
+			sql := fmt.Sprintf(
+				`SELECT * FROM (SELECT s.* FROM %[1]s s TABLESAMPLE BERNOULLI (%[2]f)
+				WHERE NOT EXISTS (SELECT 1 FROM %[1]s newer WHERE newer.id = s.id AND newer.version > s.version)) AS %[4]s
+				WHERE %[3]s ORDER BY random() LIMIT ?`, quote(db, info.Table), percent, where, quote(db, tableRef(info.Table)))
+			sample = nil
+			if err := db.Raw(sql, args...).Scan(&sample).Error; err != nil {
+				return nil, fmt.Errorf("sampling %s failed: %w", info.Table, err)
+			}
+			if len(sample) == n {
+				return sample, nil
+			}
+		}
+	}
+	sample = nil
+	if err := latestRows(db, &model, "*", "WHERE "+where+" ORDER BY random() LIMIT ?", args...).Scan(&sample).Error; err != nil {
+		return nil, fmt.Errorf("sampling %s failed: %w", info.Table, err)
+	}
+	return sample, nil
+}
@@ -1,46 +1,314 @@
 package scd
 
 import (
+	"context"
 	"fmt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"reflect"
+	"slices"
+	"time"
 )
 
-// LatestSubquery returns a subquery that selects the latest version per id
-func LatestSubquery[T any](db *gorm.DB, model T) *gorm.DB {
-	return db.Model(&model).
+// LatestSubquery returns a subquery that selects the latest version per id,
+// as (id, max_version), using the strategy configured for the model's table.
+// Entities whose latest version is a tombstone are included; see LiveSubquery.
+// Drafts and versions pending approval never count (see SaveDraft).
+// Under the history layouts the table holds only latest versions, so no
+// strategy is needed.
+func LatestSubquery[T any](ctx context.Context, db *gorm.DB, model T) *gorm.DB {
+	db = db.WithContext(ctx)
+	if latestOnly(&model) {
+		return db.Model(&model).Select("id, version as max_version")
+	}
+	if c := currentConfig(); len(c.Strategies) > 0 || c.DefaultStrategy != "" {
+		if info, err := Describe(db, &model); err == nil {
+			return strategySubquery(db, &model, info.Table, c.strategyFor(info.Table))
+		}
+	}
+	return approvedOnly(db.Model(&model), &model).
 		Select("id, MAX(version) as max_version").
 		Group("id")
 }
 
-// CreateNewSCDVersion clones the latest version of an entity with a new version number
-func CreateNewSCDVersion[T any](db *gorm.DB, id string, updateFn func(*T)) error {
-	var latest T
+// LiveSubquery is LatestSubquery without the entities whose latest version is
+// a tombstone, unless ctx was derived with IncludeDeleted. Models without an
+// is_deleted column get LatestSubquery unchanged. With Config.Quarantine,
+// quarantined entities are left out too, unless ctx was derived with
+// IncludeQuarantined.
+func LiveSubquery[T any](ctx context.Context, db *gorm.DB, model T) *gorm.DB {
+	subq := LatestSubquery(ctx, db, model)
+	info, err := Describe(db, &model)
+	if err != nil {
+		return subq
+	}
+	live, hideQuarantined := liveOnly(ctx, info), hidesQuarantined(ctx)
+	if !live && !hideQuarantined {
+		return subq
+	}
+	q := db.WithContext(ctx).Table("(?) AS live", subq).Select("live.id, live.max_version")
+	if live {
+		q = q.Where(fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s d WHERE d.id = live.id AND d.version = live.max_version AND d.is_deleted)", quote(db, info.Table)))
+	}
+	if hideQuarantined {
+		q = q.Where(notQuarantined(info.Table, clause.Column{Table: "live", Name: "id"}))
+	}
+	return q
+}
+
+// liveOnly reports whether reads of info's table under ctx leave out tombstones.
+func liveOnly(ctx context.Context, info TableInfo) bool {
+	return !includesDeleted(ctx) && slices.Contains(info.Meta, "is_deleted")
+}
 
-	// Fetch the latest version for the given ID
-	if err := db.Where("id = ?", id).Order("version DESC").First(&latest).Error; err != nil {
-		return fmt.Errorf("fetching latest version failed: %w", err)
+// Latest returns a scope that joins LiveSubquery onto a query of T's table,
+// restricting it to the latest live version of each entity:
+//
+//	db.WithContext(ctx).Scopes(scd.Latest[models.Job]()).Where("jobs.status = ?", "active").Find(&jobs)
+//
+// The join refers to the table by name, so the query must not alias it. The
+// query's context decides whether tombstones are included. Under the history
+// layouts the table already holds only latest versions and no join is added.
+func Latest[T any]() func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		var model T
+		info, err := Describe(db, &model)
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+		if latestOnly(&model) {
+			if hidesQuarantined(db.Statement.Context) {
+				db = db.Where(notQuarantined(info.Table, Column(info.Table, "id")))
+			}
+			if liveOnly(db.Statement.Context, info) {
+				return db.Where("? IS NOT TRUE", Column(info.Table, "is_deleted"))
+			}
+			return db
+		}
+		// Initialized detaches the subquery from the statement being built: a
+		// bare NewDB session shares it again once another session is derived
+		// from it, e.g. by WithContext, and the query's conditions leak in.
+		subq := LiveSubquery(db.Statement.Context, db.Session(&gorm.Session{NewDB: true, Initialized: true}), model)
+		return JoinVersions(db, info.Table, "latest", subq)
 	}
+}
+
+// CreateNewSCDVersion clones the latest version of an entity with a new version
+// number and a UID from Config.UIDs.
+// The latest row is locked while the new version is written, and the write is
+// retried if a concurrent caller created the same version first, so updateFn
+// may run more than once. An error from updateFn rolls back the transaction and
+// is returned unchanged.
+func CreateNewSCDVersion[T any, P EntityPtr[T]](ctx context.Context, db *gorm.DB, id string, updateFn func(*T) error) error {
+	_, _, err := createVersion[T, P](ctx, db, id, nil, updateFn)
+	return err
+}
 
-	// Copy the latest version to a new instance
-	newVersion := latest
+// CreateNewSCDVersionReturning is CreateNewSCDVersion returning the version it
+// was cloned from and the version it created, including the new version number
+// and UID.
+func CreateNewSCDVersionReturning[T any, P EntityPtr[T]](ctx context.Context, db *gorm.DB, id string, updateFn func(*T) error) (previous, created T, err error) {
+	return createVersion[T, P](ctx, db, id, nil, updateFn)
+}
+
+// CreateNewSCDVersionIf is CreateNewSCDVersion for edit flows: expectedVersion
+// is the version the caller based its changes on, and the call fails with
+// ErrVersionConflict when the entity has moved past it.
+func CreateNewSCDVersionIf[T any, P EntityPtr[T]](ctx context.Context, db *gorm.DB, id string, expectedVersion int, updateFn func(*T) error) error {
+	_, _, err := createVersion[T, P](ctx, db, id, expectVersion(id, expectedVersion), updateFn)
+	return err
+}
 
-	// Use reflection to find and increment the Version field
-	v := reflect.ValueOf(&newVersion).Elem()
-	versionField := v.FieldByName("Version")
-	if versionField.IsValid() && versionField.CanSet() && versionField.Kind() == reflect.Int {
-		versionField.SetInt(versionField.Int() + 1)
-	} else {
-		return fmt.Errorf("field 'Version' not found or not settable/int in struct")
+// expectVersion returns a createVersion check failing with ErrVersionConflict
+// unless entity id is at version expected.
+func expectVersion(id string, expected int) func(latest int) error {
+	return func(latest int) error {
+		if latest != expected {
+			return fmt.Errorf("%w: %s is at version %d, expected %d", ErrVersionConflict, id, latest, expected)
+		}
+		return nil
 	}
+}
+
+// createVersion implements CreateNewSCDVersion. check, when set, vets the
+// locked latest version number before anything is written. It returns the
+// latest version as read and the version written.
+func createVersion[T any, P EntityPtr[T]](ctx context.Context, db *gorm.DB, id string, check func(latest int) error, updateFn func(*T) error) (T, T, error) {
+	var previous, created T
+	db = db.WithContext(ctx)
+	err := retryOnVersionConflict(func() error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			// Fetch and lock the latest version for the given ID
+			var latest T
+			newest, err := lockLatest(tx, id, &latest)
+			if err != nil {
+				return err
+			}
+
+			// Copy the latest version to a new instance
+			newVersion := CloneVersion(latest)
+			resetVersionMeta(&newVersion)
 
-	// Apply custom changes via the callback
-	updateFn(&newVersion)
+			// Increment the version number
+			entity := P(&newVersion)
+			if check != nil {
+				if err := check(entity.GetVersion()); err != nil {
+					return err
+				}
+			}
+			entity.SetVersion(newest + 1)
+			entity.SetUID(currentConfig().newUID())
 
-	// Save the new version in the DB
-	if err := db.Create(&newVersion).Error; err != nil {
-		return fmt.Errorf("creating new version failed: %w", err)
+			// Apply custom changes via the callback; an error aborts the version
+			if err := updateFn(&newVersion); err != nil {
+				return err
+			}
+			if skipsUnchanged(ctx) && unchanged(latest, newVersion) {
+				previous, created = latest, latest
+				return nil
+			}
+
+			// Save the new version in the DB
+			if err := saveVersion(tx, &newVersion, EventVersion); err != nil {
+				return err
+			}
+			previous, created = latest, newVersion
+			return nil
+		})
+	})
+	return previous, created, err
+}
+
+// saveVersion inserts a prepared version, records it in the change log and
+// runs the OnVersionCreated handlers, all in one transaction. The audit
+// fields are filled in from db's context. Drafts are only inserted;
+// ApproveVersion publishes them.
+func saveVersion(db *gorm.DB, entity any, kind EventKind) error {
+	if err := checkQuarantine(db, entity); err != nil {
+		return err
 	}
+	now := time.Now()
+	setField(entity, "CreatedAt", &now)
+	stampAudit(db.Statement.Context, reflect.ValueOf(entity))
+	if deletedAt, ok := timeField(entity, "DeletedAt"); ok && deletedAt == nil && isDeleted(entity) {
+		setField(entity, "DeletedAt", &now)
+	}
+	draft := !approved(entity)
+	validFrom, hasValidity := timeField(entity, "ValidFrom")
+	if hasValidity && validFrom == nil && !draft {
+		validFrom = &now
+		setField(entity, "ValidFrom", validFrom)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(entity).Error; err != nil {
+			return fmt.Errorf("creating new version failed: %w", err)
+		}
+		if draft {
+			return nil
+		}
+		return publishVersion(tx, entity, kind, validFrom)
+	})
+}
 
+// publishVersion completes a version taking effect: it is fitted into the
+// business timeline when validFrom is set, recorded in the change log and
+// passed to the OnVersionCreated handlers.
+func publishVersion(tx *gorm.DB, entity any, kind EventKind, validFrom *time.Time) error {
+	event, err := newEvent(tx, entity, kind)
+	if err != nil {
+		return err
+	}
+	if validFrom != nil {
+		if err := closeValidity(tx, entity, event.ID, event.Version, *validFrom); err != nil {
+			return err
+		}
+	}
+	if err := recordChange(tx, &event); err != nil {
+		return err
+	}
+	return runHandlers(tx, event)
+}
+
+// closeValidity fits a version effective from validFrom into its entity's
+// business timeline: the version in effect just before validFrom now ends
+// there, and the new version ends where the next later-effective version, if
+// one was scheduled, begins. Versions are updated wherever the layout keeps
+// them.
+func closeValidity(tx *gorm.DB, entity any, id string, version int, validFrom time.Time) error {
+	info, err := Describe(tx, entity)
+	if err != nil {
+		return err
+	}
+	all := versionsRelation(tx, entity, info, false)
+	targets := []string{info.Table}
+	if h := historyTable(entity, info.Table); h != "" {
+		targets = append(targets, h)
+	}
+	args := map[string]any{"id": id, "version": version, "from": validFrom}
+	for _, target := range targets {
+		stmts := []string{
+			fmt.Sprintf(`UPDATE %[1]s SET valid_to = @from WHERE id = @id AND (valid_to IS NULL OR valid_to > @from) AND version = (
+				SELECT version FROM %[2]s WHERE id = @id AND version <> @version AND %[3]s <= @from
+				ORDER BY %[3]s DESC, version DESC LIMIT 1)`, quote(tx, target), all, effectiveFrom),
+			fmt.Sprintf(`UPDATE %[1]s SET valid_to = (SELECT MIN(valid_from) FROM %[2]s WHERE id = @id AND valid_from > @from)
+				WHERE id = @id AND version = @version`, quote(tx, target), all),
+		}
+		for _, stmt := range stmts {
+			if err := tx.Exec(stmt, args).Error; err != nil {
+				return fmt.Errorf("closing previous validity window failed: %w", err)
+			}
+		}
+	}
 	return nil
 }
+
+// resetVersionMeta clears the per-version metadata a clone inherits from the
+// version it was copied from, so saveVersion assigns fresh values.
+func resetVersionMeta(entity any) {
+	setField(entity, "CreatedAt", (*time.Time)(nil))
+	setField(entity, "CreatedBy", "")
+	setField(entity, "ChangeReason", "")
+	setField(entity, "Source", "")
+	setField(entity, "ValidFrom", (*time.Time)(nil))
+	setField(entity, "ValidTo", (*time.Time)(nil))
+	setField(entity, "DeletedAt", (*time.Time)(nil))
+}
+
+// timeField reads a *time.Time field, reporting whether the struct has it.
+func timeField(entity any, name string) (*time.Time, bool) {
+	f := reflect.ValueOf(entity).Elem().FieldByName(name)
+	if !f.IsValid() {
+		return nil, false
+	}
+	t, ok := f.Interface().(*time.Time)
+	return t, ok
+}
+
+// setField assigns value to the named exported field of the struct entity points to.
+func setField(entity any, name string, value any) error {
+	f := reflect.ValueOf(entity).Elem().FieldByName(name)
+	if !f.IsValid() || !f.CanSet() || f.Type() != reflect.TypeOf(value) {
+		return fmt.Errorf("field '%s' not found or not settable/%T in struct", name, value)
+	}
+	f.Set(reflect.ValueOf(value))
+	return nil
+}
+
+// versionedKey reads the ID, Version and UID fields of a versioned entity.
+func versionedKey(entity any) (id string, version int, uid string) {
+	if e, ok := entity.(Entity); ok {
+		return e.GetID(), e.GetVersion(), e.GetUID()
+	}
+	v := reflect.Indirect(reflect.ValueOf(entity))
+	if f := v.FieldByName("ID"); f.IsValid() && f.Kind() == reflect.String {
+		id = f.String()
+	}
+	if f := v.FieldByName("Version"); f.IsValid() && f.Kind() == reflect.Int {
+		version = int(f.Int())
+	}
+	if f := v.FieldByName("UID"); f.IsValid() && f.Kind() == reflect.String {
+		uid = f.String()
+	}
+	return id, version, uid
+}
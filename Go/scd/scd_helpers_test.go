@@ -0,0 +1,83 @@
+package scd_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+// latestJob fills every job read with version 4 of job1.
+func latestJob(stmt *gorm.Statement) {
+	if j, ok := stmt.Dest.(*models.Job); ok {
+		j.ID, j.Version, j.UID, j.Rate, j.VersionState = "job1", 4, "u4", 100, scd.StateApproved
+	}
+}
+
+func TestCreateNewSCDVersionIfRejectsMovedEntity(t *testing.T) {
+	raise := func(j *models.Job) error {
+		j.Rate = 120
+		return nil
+	}
+	db, _ := dryRunTxDB(t, latestJob)
+	created := createdJobs(db)
+	err := scd.CreateNewSCDVersionIf[models.Job](context.Background(), db, "job1", 3, raise)
+	if !errors.Is(err, scd.ErrVersionConflict) {
+		t.Fatalf("edit based on version 3 = %v, want ErrVersionConflict", err)
+	}
+	if len(*created) != 0 {
+		t.Fatalf("a rejected edit wrote %d versions", len(*created))
+	}
+	if err := scd.CreateNewSCDVersionIf[models.Job](context.Background(), db, "job1", 4, raise); err != nil {
+		t.Fatalf("edit based on version 4: %v", err)
+	}
+	if len(*created) != 1 || (*created)[0].Version != 5 {
+		t.Errorf("created %+v, want version 5", *created)
+	}
+}
+
+func TestCreateNewSCDVersionReturningReportsBothVersions(t *testing.T) {
+	db, _ := dryRunTxDB(t, latestJob)
+	previous, created, err := scd.CreateNewSCDVersionReturning[models.Job](context.Background(), db, "job1", func(j *models.Job) error {
+		j.Rate = 120
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("CreateNewSCDVersionReturning: %v", err)
+	}
+	if previous.Version != 4 || previous.Rate != 100 {
+		t.Errorf("previous = version %d at rate %v, want version 4 at 100", previous.Version, previous.Rate)
+	}
+	if created.Version != 5 || created.Rate != 120 || created.UID == "" || created.UID == previous.UID {
+		t.Errorf("created = version %d at rate %v with UID %q, want version 5 at 120 with a fresh UID", created.Version, created.Rate, created.UID)
+	}
+}
+
+func TestSkipUnchangedWritesNoIdenticalVersion(t *testing.T) {
+	for _, tc := range []struct {
+		rate    float64
+		created int
+	}{
+		{100, 0},
+		{120, 1},
+	} {
+		db, _ := dryRunTxDB(t, latestJob)
+		created := createdJobs(db)
+		_, got, err := scd.CreateNewSCDVersionReturning[models.Job](scd.SkipUnchanged(context.Background()), db, "job1", func(j *models.Job) error {
+			j.Rate = tc.rate
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("rate %v: %v", tc.rate, err)
+		}
+		if len(*created) != tc.created {
+			t.Errorf("rate %v: wrote %d versions, want %d", tc.rate, len(*created), tc.created)
+		}
+		if want := 4 + tc.created; got.Version != want {
+			t.Errorf("rate %v: returned version %d, want %d", tc.rate, got.Version, want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package scd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yourorg/Go/scderr"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ScheduledChange is a change entered ahead of the time it takes effect. It
+// is kept out of the versioned table, so latest-version reads ignore it, until
+// ActivateDueChanges writes it as a new version once EffectiveAt has passed.
+type ScheduledChange struct {
+	ID       int64  `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
+	Table    string `gorm:"index:idx_scd_scheduled_entity;column:table_name" json:"table"`
+	EntityID string `gorm:"index:idx_scd_scheduled_entity;column:entity_id" json:"entity_id"`
+	// Patch is the JSON Merge Patch applied to the latest version on activation.
+	Patch       string    `gorm:"column:patch" json:"patch"`
+	EffectiveAt time.Time `gorm:"index;column:effective_at" json:"effective_at"`
+	CreatedAt   time.Time `gorm:"column:created_at" json:"created_at"`
+	// ActivatedAt and Version are set once the change has been written.
+	ActivatedAt *time.Time `gorm:"column:activated_at" json:"activated_at,omitempty"`
+	Version     int        `gorm:"column:version" json:"version,omitempty"`
+	// Error is set when activation failed for good; the change is not retried.
+	Error string `gorm:"column:error" json:"error,omitempty"`
+}
+
+func (ScheduledChange) TableName() string { return "scd_scheduled_changes" }
+
+// MigrateScheduledChanges creates the scheduled changes table.
+func MigrateScheduledChanges(db *gorm.DB) error {
+	return db.AutoMigrate(&ScheduledChange{})
+}
+
+// ScheduleChange records a JSON Merge Patch, as taken by MergePatch, to be
+// applied to entity id at effectiveAt, e.g. a rate change entered weeks
+// before it starts:
+//
+//	scd.ScheduleChange(ctx, db, models.Job{}, "job1", firstOfMonth, []byte(`{"rate": 120}`))
+//
+// Until then reads see the entity unchanged. The patch is checked against the
+// schema now but applied on top of whatever the latest version is when
+// ActivateDueChanges picks it up; for models with valid_from the version it
+// writes is effective from effectiveAt exactly, however late the activator
+// runs. Unlike ScheduleVersion, which writes a version at once and leaves it
+// to EffectiveAt to hide it, nothing is written to the entity's history here.
+func ScheduleChange(ctx context.Context, db *gorm.DB, model any, id string, effectiveAt time.Time, patch []byte) (ScheduledChange, error) {
+	info, err := Describe(db, model)
+	if err != nil {
+		return ScheduledChange{}, err
+	}
+	if _, _, err := parseMergePatch(db, modelType(model), patch); err != nil {
+		return ScheduledChange{}, err
+	}
+	change := ScheduledChange{Table: info.Table, EntityID: id, Patch: string(patch), EffectiveAt: effectiveAt}
+	if err := db.WithContext(ctx).Create(&change).Error; err != nil {
+		return change, fmt.Errorf("scheduling change of %s failed: %w", id, err)
+	}
+	return change, nil
+}
+
+// PendingChanges returns the changes scheduled for entity id of model that
+// are not yet activated, in the order they will be applied. An empty id
+// returns those of every entity of the table.
+func PendingChanges(ctx context.Context, db *gorm.DB, model any, id string) ([]ScheduledChange, error) {
+	info, err := Describe(db, model)
+	if err != nil {
+		return nil, err
+	}
+	q := db.WithContext(ctx).Where("table_name = ? AND activated_at IS NULL AND error = ''", info.Table)
+	if id != "" {
+		q = q.Where("entity_id = ?", id)
+	}
+	var changes []ScheduledChange
+	if err := q.Order("effective_at, id").Find(&changes).Error; err != nil {
+		return nil, fmt.Errorf("reading scheduled changes failed: %w", err)
+	}
+	return changes, nil
+}
+
+// CancelScheduledChange removes a pending change. It fails with ErrNotFound
+// when the change does not exist or was already activated.
+func CancelScheduledChange(ctx context.Context, db *gorm.DB, changeID int64) error {
+	res := db.WithContext(ctx).Where("id = ? AND activated_at IS NULL", changeID).Delete(&ScheduledChange{})
+	if res.Error != nil {
+		return fmt.Errorf("cancelling scheduled change %d failed: %w", changeID, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ActivationResult summarizes an ActivateDueChanges run.
+type ActivationResult struct {
+	Activated int
+	// Failed counts changes that could not be applied, e.g. because the
+	// entity was deleted; their Error is recorded and they are not retried.
+	Failed int
+}
+
+// ActivateDueChanges writes every pending change with an EffectiveAt at or
+// before now as a new version of its entity, oldest first. Each change
+// commits on its own and is locked while it is applied, so several
+// activators can run at once. Tables must be registered with RegisterModel.
+// Retryable errors stop the run and leave the change pending for the next one.
+func ActivateDueChanges(ctx context.Context, db *gorm.DB, now time.Time) (ActivationResult, error) {
+	var res ActivationResult
+	db = db.WithContext(ctx)
+	for {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		var done bool
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var change ScheduledChange
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+				Where("activated_at IS NULL AND error = '' AND effective_at <= ?", now).
+				Order("effective_at, id").Take(&change).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				done = true
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("reading due changes failed: %w", err)
+			}
+			version, err := activate(ctx, tx, change)
+			if scderr.IsRetryable(err) {
+				return err
+			}
+			updates := map[string]any{"activated_at": time.Now(), "version": version}
+			if err != nil {
+				updates = map[string]any{"error": err.Error()}
+				res.Failed++
+			} else {
+				res.Activated++
+			}
+			if err := tx.Model(&change).Updates(updates).Error; err != nil {
+				return fmt.Errorf("recording activation of change %d failed: %w", change.ID, err)
+			}
+			return nil
+		})
+		if err != nil || done {
+			return res, err
+		}
+	}
+}
+
+// activate applies change and returns the version it created.
+func activate(ctx context.Context, tx *gorm.DB, change ScheduledChange) (int, error) {
+	d, ok := LookupModel(change.Table)
+	if !ok {
+		return 0, fmt.Errorf("%w: table %s is not registered", ErrNotVersioned, change.Table)
+	}
+	var effective time.Time
+	if d.Strategies.Validity {
+		effective = change.EffectiveAt
+	}
+	created, err := mergePatch(ctx, tx, modelType(d.Model), change.EntityID, nil, []byte(change.Patch), effective)
+	if err != nil {
+		return 0, err
+	}
+	return created.(Entity).GetVersion(), nil
+}
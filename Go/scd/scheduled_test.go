@@ -0,0 +1,38 @@
+package scd_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+func TestScheduleChangeRecordsPatchWithoutWritingAVersion(t *testing.T) {
+	db := dryRunDB(t).Session(&gorm.Session{SkipDefaultTransaction: true})
+	ctx := context.Background()
+	start := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	if _, err := scd.ScheduleChange(ctx, db, models.Job{}, "job1", start, []byte(`{"version": 9}`)); !errors.Is(err, scd.ErrInvalidPatch) {
+		t.Fatalf("err = %v, want ErrInvalidPatch", err)
+	}
+
+	var sql string
+	db.Callback().Create().After("gorm:create").Register("test:capture", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	})
+	change, err := scd.ScheduleChange(ctx, db, models.Job{}, "job1", start, []byte(`{"rate": 120}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change.Table != "jobs" || change.EntityID != "job1" || !change.EffectiveAt.Equal(start) {
+		t.Errorf("got %+v", change)
+	}
+	if !strings.Contains(sql, `INSERT INTO "scd_scheduled_changes"`) || strings.Contains(sql, `"jobs"`) {
+		t.Errorf("change not recorded on its own: %s", sql)
+	}
+}
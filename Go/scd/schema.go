@@ -0,0 +1,134 @@
+package scd
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TableInfo describes the table backing a versioned model.
+type TableInfo struct {
+	Table string
+	// Meta lists the columns contributed by the embedded Versioned struct (id, version, uid, ...).
+	Meta []string
+	// Columns lists the business columns, i.e. everything except Meta.
+	Columns []string
+}
+
+// Describe parses model using db's naming strategy and returns its table and business columns.
+func Describe(db *gorm.DB, model any) (TableInfo, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return TableInfo{}, fmt.Errorf("parsing model failed: %w", err)
+	}
+	if stmt.Schema.LookUpField("version") == nil {
+		return TableInfo{}, fmt.Errorf("%w: %s has no version column", ErrNotVersioned, stmt.Schema.Table)
+	}
+	info := TableInfo{Table: stmt.Schema.Table}
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		if len(field.BindNames) > 1 && field.BindNames[0] == "Versioned" {
+			info.Meta = append(info.Meta, field.DBName)
+			continue
+		}
+		info.Columns = append(info.Columns, field.DBName)
+	}
+	return info, nil
+}
+
+// ColumnList joins the business columns with commas, qualifying each with alias when it is non-empty.
+func (t TableInfo) ColumnList(alias string) string {
+	return qualify(alias, t.Columns)
+}
+
+// QuotedColumnList is ColumnList with each column quoted as an identifier of
+// db's dialect, for hand-written SQL; alias is written as given.
+func (t TableInfo) QuotedColumnList(db *gorm.DB, alias string) string {
+	return quoteColumns(db, alias, t.Columns)
+}
+
+func qualify(alias string, cols []string) string {
+	out := make([]string, len(cols))
+	for i, c := range cols {
+		if alias != "" {
+			c = alias + "." + c
+		}
+		out[i] = c
+	}
+	return strings.Join(out, ", ")
+}
+
+// quote renders name as an identifier of db's dialect, quoting each part of a
+// schema-qualified name, so hand-written SQL is safe for reserved words and
+// custom table names.
+func quote(db *gorm.DB, name string) string {
+	return db.Statement.Quote(name)
+}
+
+// quoteColumns is qualify with every column quoted by quote. The alias is
+// written as given: it is the caller's own, such as a table alias or the NEW
+// record of a trigger, which quoting would turn case-sensitive.
+func quoteColumns(db *gorm.DB, alias string, cols []string) string {
+	out := make([]string, len(cols))
+	for i, c := range cols {
+		out[i] = quote(db, c)
+		if alias != "" {
+			out[i] = alias + "." + out[i]
+		}
+	}
+	return strings.Join(out, ", ")
+}
+
+// tableRef is the name queries use to refer to the rows of table: the table
+// name without its schema, which is also the alias of versionsRelation.
+func tableRef(table string) string {
+	return table[strings.LastIndexByte(table, '.')+1:]
+}
+
+// derivedName is the name of an object scd derives from table, such as a
+// trigger function or an index: prefix and suffix around the table's name,
+// in the table's schema if it names one. Quote it with quote.
+func derivedName(table, prefix, suffix string) string {
+	i := strings.LastIndexByte(table, '.') + 1
+	return table[:i] + prefix + table[i:] + suffix
+}
+
+// TableRef returns the name queries use for table as a clause.Table, for
+// arguments such as db.Select("?.*", scd.TableRef(table)).
+func TableRef(table string) clause.Table {
+	return clause.Table{Name: tableRef(table)}
+}
+
+// Column returns column of table as a clause.Column, for use as a query
+// argument that the dialect quotes:
+//
+//	db.Where("? > ?", scd.Column(info.Table, "rate"), 100)
+func Column(table, column string) clause.Column {
+	return clause.Column{Table: tableRef(table), Name: column}
+}
+
+// JoinVersions joins subq, a subquery of (id, max_version) such as
+// LatestSubquery, onto a query of table under alias, restricting the query to
+// the versions subq selects. It is how Latest and EffectiveAt join; the
+// identifiers are quoted by the dialect, so custom, reserved and
+// schema-qualified table names work.
+func JoinVersions(db *gorm.DB, table, alias string, subq *gorm.DB) *gorm.DB {
+	return db.Joins(versionJoin, subq, clause.Table{Name: alias},
+		Column(table, "id"), clause.Column{Table: alias, Name: "id"},
+		Column(table, "version"), clause.Column{Table: alias, Name: "max_version"})
+}
+
+// versionJoin is the join JoinVersions adds.
+const versionJoin = "JOIN (?) AS ? ON ? = ? AND ? = ?"
+
+// IsVersionJoin reports whether join, the clause of a join added to a
+// query, is one JoinVersions added. Leaving those joins out of a query gives
+// the query a non-versioned table would have run, as the overhead package
+// does to measure what versioning costs.
+func IsVersionJoin(join string) bool {
+	return join == versionJoin
+}
@@ -0,0 +1,78 @@
+package scd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// DeleteAsNewVersion soft-deletes an entity by appending a tombstone version:
+// a copy of the latest version with IsDeleted set. History stays intact and
+// the entity can be brought back with Restore.
+func DeleteAsNewVersion[T any, P EntityPtr[T]](ctx context.Context, db *gorm.DB, id string) error {
+	db = db.WithContext(ctx)
+	return retryOnVersionConflict(func() error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			var latest T
+			newest, err := lockLatest(tx, id, &latest)
+			if err != nil {
+				return err
+			}
+			tombstone := CloneVersion(latest)
+			prepareVersion(P(&tombstone), newest)
+			if err := setField(&tombstone, "IsDeleted", true); err != nil {
+				return err
+			}
+			return saveVersion(tx, &tombstone, EventDelete)
+		})
+	})
+}
+
+// Restore undeletes an entity by appending a new live version cloned from the
+// last version before the tombstone. The restore is recorded as an
+// EventRestore change, so OnVersionCreated handlers recompute dependents just
+// as they would for an edit.
+func Restore[T any, P EntityPtr[T]](ctx context.Context, db *gorm.DB, id string) error {
+	db = db.WithContext(ctx)
+	return retryOnVersionConflict(func() error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			var latest T
+			newest, err := lockLatest(tx, id, &latest)
+			if err != nil {
+				return err
+			}
+			if !isDeleted(&latest) {
+				return ErrNotDeleted
+			}
+			var restored T
+			// Under the history layouts the main table holds only the tombstone.
+			err = approvedOnly(AllVersions(ctx, tx, &restored).Where("id = ? AND is_deleted = ?", id, false), &restored).
+				Order("version DESC").Take(&restored).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNothingToRestore
+			}
+			if err != nil {
+				return fmt.Errorf("fetching pre-tombstone version failed: %w", err)
+			}
+			restored = CloneVersion(restored)
+			prepareVersion(P(&restored), newest)
+			return saveVersion(tx, &restored, EventRestore)
+		})
+	})
+}
+
+// prepareVersion turns a copy of an existing row into the version after
+// latestVersion, with a fresh UID so the unique index is not violated.
+func prepareVersion(entity Entity, latestVersion int) {
+	resetVersionMeta(entity)
+	entity.SetVersion(latestVersion + 1)
+	entity.SetUID(currentConfig().newUID())
+}
+
+func isDeleted(entity any) bool {
+	f := reflect.ValueOf(entity).Elem().FieldByName("IsDeleted")
+	return f.IsValid() && f.Kind() == reflect.Bool && f.Bool()
+}
@@ -0,0 +1,110 @@
+package scd_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+type restoreHistoryRecord struct {
+	models.Versioned
+	Name string
+}
+
+type restoreCurrentRecord struct {
+	models.Versioned
+	Name string
+}
+
+func TestRestoreReadsPreTombstoneVersionFromHistory(t *testing.T) {
+	scd.RegisterModel(restoreHistoryRecord{}, scd.ModelOptions{Layout: scd.LayoutHistoryTable})
+	scd.RegisterModel(restoreCurrentRecord{}, scd.ModelOptions{Layout: scd.LayoutCurrentHistory})
+	// The locked latest version is the tombstone.
+	tombstone := func(v *models.Versioned) {
+		if v.ID == "" {
+			v.ID, v.Version, v.IsDeleted, v.VersionState = "r1", 3, true, scd.StateApproved
+		}
+	}
+	for _, tc := range []struct {
+		restore func(*gorm.DB) error
+		history string
+	}{
+		{func(db *gorm.DB) error { return scd.Restore[restoreHistoryRecord](context.Background(), db, "r1") }, `FROM "restore_history_records_versions"`},
+		{func(db *gorm.DB) error { return scd.Restore[restoreCurrentRecord](context.Background(), db, "r1") }, `FROM "restore_current_records_history"`},
+	} {
+		db, stmts := dryRunTxDB(t, func(stmt *gorm.Statement) {
+			switch r := stmt.Dest.(type) {
+			case *restoreHistoryRecord:
+				tombstone(&r.Versioned)
+			case *restoreCurrentRecord:
+				tombstone(&r.Versioned)
+			}
+		})
+		if err := tc.restore(db); err != nil {
+			t.Fatalf("Restore: %v", err)
+		}
+		var found bool
+		for _, sql := range *stmts {
+			found = found || strings.Contains(sql, tc.history) && strings.Contains(sql, "is_deleted = $")
+		}
+		if !found {
+			t.Errorf("pre-tombstone version not read from %s:\n%s", tc.history, strings.Join(*stmts, "\n"))
+		}
+	}
+}
+
+// createdJobs collects the jobs inserted through db.
+func createdJobs(db *gorm.DB) *[]models.Job {
+	var created []models.Job
+	db.Callback().Create().After("gorm:create").Register("test:created", func(tx *gorm.DB) {
+		if j, ok := tx.Statement.Dest.(*models.Job); ok {
+			created = append(created, *j)
+		}
+	})
+	return &created
+}
+
+func TestDeleteAsNewVersionAppendsTombstone(t *testing.T) {
+	db, _ := dryRunTxDB(t, func(stmt *gorm.Statement) {
+		if j, ok := stmt.Dest.(*models.Job); ok && j.ID == "" {
+			j.ID, j.Version, j.UID, j.Title, j.VersionState = "job1", 4, "u4", "Welder", scd.StateApproved
+		}
+	})
+	created := createdJobs(db)
+	if err := scd.DeleteAsNewVersion[models.Job](context.Background(), db, "job1"); err != nil {
+		t.Fatalf("DeleteAsNewVersion: %v", err)
+	}
+	if len(*created) != 1 {
+		t.Fatalf("created %d versions, want one tombstone", len(*created))
+	}
+	tomb := (*created)[0]
+	if tomb.Version != 5 || !tomb.IsDeleted || tomb.Title != "Welder" {
+		t.Errorf("tombstone = version %d, deleted %v, title %q; want a deleted copy as version 5", tomb.Version, tomb.IsDeleted, tomb.Title)
+	}
+	if tomb.UID == "" || tomb.UID == "u4" {
+		t.Errorf("tombstone UID = %q, want a fresh one", tomb.UID)
+	}
+
+	if err := scd.Restore[models.Job](context.Background(), db, "job1"); !errors.Is(err, scd.ErrNotDeleted) {
+		t.Errorf("Restore of a live entity = %v, want ErrNotDeleted", err)
+	}
+}
+
+func TestTombstoneRecordsDeletedAt(t *testing.T) {
+	db, _ := dryRunTxDB(t, latestJob)
+	created := createdJobs(db)
+	if err := scd.DeleteAsNewVersion[models.Job](context.Background(), db, "job1"); err != nil {
+		t.Fatalf("DeleteAsNewVersion: %v", err)
+	}
+	if len(*created) != 1 || (*created)[0].DeletedAt == nil {
+		t.Fatalf("tombstone %+v lacks DeletedAt", *created)
+	}
+	if tomb := (*created)[0]; !tomb.DeletedAt.Equal(*tomb.CreatedAt) {
+		t.Errorf("DeletedAt = %v, want the tombstone's CreatedAt %v", tomb.DeletedAt, tomb.CreatedAt)
+	}
+}
@@ -0,0 +1,337 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/yourorg/Go/scderr"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Strategy names how the latest version of each entity is found.
+type Strategy string
+
+const (
+	// StrategyMaxVersion groups by id and takes MAX(version). It needs no
+	// extra storage and is the default.
+	StrategyMaxVersion Strategy = "max_version"
+	// StrategyWindow ranks each entity's versions with row_number() and keeps
+	// the first. It needs no extra storage.
+	StrategyWindow Strategy = "window"
+	// StrategyDistinctOn keeps one row per id with DISTINCT ON, ordered by
+	// version descending. On Postgres it usually beats MAX(version) when
+	// entities have many versions. It needs no extra storage.
+	StrategyDistinctOn Strategy = "distinct_on"
+	// StrategyLateral looks up the newest version of each distinct id with a
+	// LATERAL index probe, which pays off when entities have very many
+	// versions. It needs no extra storage.
+	StrategyLateral Strategy = "lateral"
+	// StrategyIsLatest reads an is_latest flag column maintained by a trigger.
+	StrategyIsLatest Strategy = "is_latest"
+	// StrategyPointer reads a <table>_latest (id, version) table maintained by a trigger.
+	StrategyPointer Strategy = "pointer_table"
+)
+
+// StrategyState records the read strategy switched to with ActivateStrategy,
+// so every process can load it with LoadStrategies.
+type StrategyState struct {
+	Table     string   `gorm:"primaryKey;column:table_name"`
+	Strategy  Strategy `gorm:"column:strategy"`
+	UpdatedAt time.Time
+}
+
+func (StrategyState) TableName() string { return "scd_strategies" }
+
+// ErrStrategyInconsistent is returned by ActivateStrategy when the prepared
+// strategy disagrees with MAX(version) for some entity.
+var ErrStrategyInconsistent = scderr.New(scderr.Conflict, "scd: strategy disagrees with MAX(version)")
+
+// strategyFor returns the read strategy configured for table.
+func (c Config) strategyFor(table string) Strategy {
+	if s, ok := c.Strategies[table]; ok {
+		return s
+	}
+	if c.DefaultStrategy != "" {
+		return c.DefaultStrategy
+	}
+	return StrategyMaxVersion
+}
+
+// strategySubquery renders LatestSubquery for strategy s. Every strategy
+// selects (id, max_version) so callers can join any of them the same way.
+// The strategies computed at query time skip versions that are not approved;
+// the trigger-maintained ones never see any, since SaveDraft refuses them.
+func strategySubquery(db *gorm.DB, model any, table string, s Strategy) *gorm.DB {
+	switch s {
+	case StrategyIsLatest:
+		return db.Model(model).Select("id, version as max_version").Where("is_latest")
+	case StrategyPointer:
+		return db.Table("?", clause.Table{Name: table + "_latest"}).Select("id, version as max_version")
+	case StrategyWindow:
+		ranked := approvedOnly(db.Model(model), model).Select("id, version, row_number() OVER (PARTITION BY id ORDER BY version DESC) AS rn")
+		return db.Table("(?) AS ranked", ranked).Select("id, version as max_version").Where("rn = 1")
+	case StrategyDistinctOn:
+		return approvedOnly(db.Model(model), model).Select("DISTINCT ON (id) id, version as max_version").Order("id, version DESC")
+	case StrategyLateral:
+		var state string
+		if hasStates(model) {
+			state = " AND v." + approvedState
+		}
+		return db.Table(fmt.Sprintf("(SELECT DISTINCT id FROM %[1]s) AS e CROSS JOIN LATERAL (SELECT v.version FROM %[1]s v WHERE v.id = e.id%[2]s ORDER BY v.version DESC LIMIT 1) AS l", quote(db, table), state)).
+			Select("e.id, l.version as max_version")
+	}
+	return approvedOnly(db.Model(model), model).Select("id, MAX(version) as max_version").Group("id")
+}
+
+// PrepareStrategy makes strategy s available on model's table without
+// changing how it is read: it adds the flag column or pointer table, installs
+// the trigger that keeps it current on every insert, then backfills existing
+// entities batchSize at a time. It is safe to re-run.
+func PrepareStrategy(ctx context.Context, db *gorm.DB, model any, s Strategy, batchSize int) error {
+	info, err := Describe(db, model)
+	if err != nil {
+		return err
+	}
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	db = db.WithContext(ctx)
+	t := info.Table
+	qt, latest := quote(db, t), quote(db, t+"_latest")
+	isLatestFn, pointerFn := quote(db, derivedName(t, "scd_is_latest_", "")), quote(db, derivedName(t, "scd_pointer_", ""))
+	var setup []string
+	var backfill string
+	switch s {
+	case StrategyIsLatest:
+		setup = []string{
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS is_latest boolean NOT NULL DEFAULT false", qt),
+			fmt.Sprintf(`CREATE OR REPLACE FUNCTION %[2]s() RETURNS trigger AS $$
+			BEGIN
+				UPDATE %[1]s SET is_latest = false WHERE id = NEW.id AND is_latest AND version < NEW.version;
+				NEW.is_latest := NOT EXISTS (SELECT 1 FROM %[1]s WHERE id = NEW.id AND version > NEW.version);
+				RETURN NEW;
+			END
+			$$ LANGUAGE plpgsql`, qt, isLatestFn),
+			fmt.Sprintf("DROP TRIGGER IF EXISTS scd_is_latest ON %s", qt),
+			fmt.Sprintf("CREATE TRIGGER scd_is_latest BEFORE INSERT ON %s FOR EACH ROW EXECUTE FUNCTION %s()", qt, isLatestFn),
+		}
+		backfill = fmt.Sprintf(`UPDATE %s AS v SET is_latest = (v.version = m.max_version)
+			FROM (SELECT id, MAX(version) AS max_version FROM %[1]s WHERE id IN ? GROUP BY id) m
+			WHERE v.id = m.id AND v.is_latest IS DISTINCT FROM (v.version = m.max_version)`, qt)
+	case StrategyPointer:
+		setup = []string{
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id text PRIMARY KEY, version integer NOT NULL)", latest),
+			fmt.Sprintf(`CREATE OR REPLACE FUNCTION %[2]s() RETURNS trigger AS $$
+			BEGIN
+				INSERT INTO %[1]s AS l (id, version) VALUES (NEW.id, NEW.version)
+				ON CONFLICT (id) DO UPDATE SET version = GREATEST(l.version, EXCLUDED.version);
+				RETURN NEW;
+			END
+			$$ LANGUAGE plpgsql`, latest, pointerFn),
+			fmt.Sprintf("DROP TRIGGER IF EXISTS scd_pointer ON %s", qt),
+			fmt.Sprintf("CREATE TRIGGER scd_pointer AFTER INSERT ON %s FOR EACH ROW EXECUTE FUNCTION %s()", qt, pointerFn),
+		}
+		backfill = fmt.Sprintf(`INSERT INTO %s AS l (id, version)
+			SELECT id, MAX(version) FROM %s WHERE id IN ? GROUP BY id
+			ON CONFLICT (id) DO UPDATE SET version = GREATEST(l.version, EXCLUDED.version)`, latest, qt)
+	case StrategyMaxVersion, StrategyWindow, StrategyDistinctOn, StrategyLateral:
+		return nil
+	default:
+		return fmt.Errorf("unknown strategy %q", s)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range setup {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("preparing %s on %s failed: %w", s, t, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	cursor := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var ids []string
+		err := db.Raw(fmt.Sprintf("SELECT DISTINCT id FROM %s WHERE id > ? ORDER BY id LIMIT ?", qt), cursor, batchSize).Scan(&ids).Error
+		if err != nil {
+			return fmt.Errorf("listing %s ids failed: %w", t, err)
+		}
+		if len(ids) == 0 {
+			break
+		}
+		if err := db.Exec(backfill, ids).Error; err != nil {
+			return fmt.Errorf("backfilling %s on %s failed: %w", s, t, err)
+		}
+		cursor = ids[len(ids)-1]
+	}
+	if s == StrategyIsLatest {
+		// Built after the backfill so it also proves at most one flag per id.
+		stmt := fmt.Sprintf("CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS %s ON %s (id) WHERE is_latest", quote(db, "idx_"+tableRef(t)+"_is_latest"), qt)
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("indexing is_latest on %s failed: %w", t, err)
+		}
+	}
+	return nil
+}
+
+// VerifyStrategy counts the entities for which strategy s reports a
+// different latest version than MAX(version).
+func VerifyStrategy(ctx context.Context, db *gorm.DB, model any, s Strategy) (int64, error) {
+	info, err := Describe(db, model)
+	if err != nil {
+		return 0, err
+	}
+	db = db.WithContext(ctx)
+	var mismatches int64
+	err = db.Raw(`SELECT COUNT(*) FROM (?) AS m FULL JOIN (?) AS s ON s.id = m.id
+		WHERE m.max_version IS DISTINCT FROM s.max_version`,
+		strategySubquery(db, model, info.Table, StrategyMaxVersion),
+		strategySubquery(db, model, info.Table, s)).Scan(&mismatches).Error
+	if err != nil {
+		return 0, fmt.Errorf("verifying %s on %s failed: %w", s, info.Table, err)
+	}
+	return mismatches, nil
+}
+
+// ActivateStrategy verifies strategy s and records it as the read strategy of
+// model's table, applying it to this process. Other processes pick it up on
+// their next LoadStrategies.
+func ActivateStrategy(ctx context.Context, db *gorm.DB, model any, s Strategy) error {
+	info, err := Describe(db, model)
+	if err != nil {
+		return err
+	}
+	n, err := VerifyStrategy(ctx, db, model, s)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("%w: %d entities of %s", ErrStrategyInconsistent, n, info.Table)
+	}
+	db = db.WithContext(ctx)
+	if err := db.AutoMigrate(&StrategyState{}); err != nil {
+		return err
+	}
+	state := StrategyState{Table: info.Table, Strategy: s, UpdatedAt: time.Now()}
+	if err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&state).Error; err != nil {
+		return fmt.Errorf("recording strategy of %s failed: %w", info.Table, err)
+	}
+	return LoadStrategies(ctx, db)
+}
+
+// LoadStrategies applies the read strategies recorded by ActivateStrategy to
+// this process. Call it after Configure, which resets them.
+func LoadStrategies(ctx context.Context, db *gorm.DB) error {
+	db = db.WithContext(ctx)
+	if !db.Migrator().HasTable(&StrategyState{}) {
+		return nil
+	}
+	var states []StrategyState
+	if err := db.Find(&states).Error; err != nil {
+		return fmt.Errorf("loading strategies failed: %w", err)
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	strategies := maps.Clone(config.Strategies)
+	if strategies == nil {
+		strategies = map[string]Strategy{}
+	}
+	for _, st := range states {
+		strategies[st.Table] = st.Strategy
+	}
+	config.Strategies = strategies
+	return nil
+}
+
+// CleanupStrategies drops the triggers and storage of every strategy other
+// than the one recorded for model's table. Run it only once every reader has
+// loaded the new strategy.
+func CleanupStrategies(ctx context.Context, db *gorm.DB, model any) error {
+	info, err := Describe(db, model)
+	if err != nil {
+		return err
+	}
+	db = db.WithContext(ctx)
+	t := info.Table
+	active := StrategyMaxVersion
+	if db.Migrator().HasTable(&StrategyState{}) {
+		var state StrategyState
+		err := db.Where("table_name = ?", t).Limit(1).Find(&state).Error
+		if err != nil {
+			return fmt.Errorf("loading strategy of %s failed: %w", t, err)
+		}
+		if state.Strategy != "" {
+			active = state.Strategy
+		}
+	}
+	qt := quote(db, t)
+	var stmts []string
+	if active != StrategyIsLatest {
+		stmts = append(stmts,
+			fmt.Sprintf("DROP TRIGGER IF EXISTS scd_is_latest ON %s", qt),
+			fmt.Sprintf("DROP FUNCTION IF EXISTS %s()", quote(db, derivedName(t, "scd_is_latest_", ""))),
+			fmt.Sprintf("DROP INDEX CONCURRENTLY IF EXISTS %s", quote(db, derivedName(t, "idx_", "_is_latest"))),
+			fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS is_latest", qt))
+	}
+	if active != StrategyPointer {
+		stmts = append(stmts,
+			fmt.Sprintf("DROP TRIGGER IF EXISTS scd_pointer ON %s", qt),
+			fmt.Sprintf("DROP FUNCTION IF EXISTS %s()", quote(db, derivedName(t, "scd_pointer_", ""))),
+			fmt.Sprintf("DROP TABLE IF EXISTS %s", quote(db, t+"_latest")))
+	}
+	for _, stmt := range stmts {
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("cleaning up strategies of %s failed: %w", t, err)
+		}
+	}
+	return nil
+}
+
+// SwitchOptions controls SwitchStrategy.
+type SwitchOptions struct {
+	BatchSize int
+	// CleanupAfter waits this long after activation, for other processes to
+	// load the new strategy, before dropping the old one's storage. Zero
+	// skips cleanup; run CleanupStrategies later instead.
+	CleanupAfter time.Duration
+	// Progress, if set, is called before each step with a short description.
+	Progress func(step string)
+}
+
+// SwitchStrategy moves model's table to strategy s online: prepare and
+// backfill, verify, activate, then optionally clean up the previous strategy.
+// Writers keep working throughout because every preparation is maintained by
+// triggers, and readers keep using the old strategy until they load the new one.
+func SwitchStrategy(ctx context.Context, db *gorm.DB, model any, s Strategy, opts SwitchOptions) error {
+	progress := opts.Progress
+	if progress == nil {
+		progress = func(string) {}
+	}
+	progress(fmt.Sprintf("preparing %s and backfilling", s))
+	if err := PrepareStrategy(ctx, db, model, s, opts.BatchSize); err != nil {
+		return err
+	}
+	progress("verifying against MAX(version) and activating")
+	if err := ActivateStrategy(ctx, db, model, s); err != nil {
+		return err
+	}
+	if opts.CleanupAfter <= 0 {
+		return nil
+	}
+	progress(fmt.Sprintf("waiting %s for readers to load the new strategy", opts.CleanupAfter))
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(opts.CleanupAfter):
+	}
+	progress("cleaning up previous strategies")
+	return CleanupStrategies(ctx, db, model)
+}
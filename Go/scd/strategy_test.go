@@ -0,0 +1,34 @@
+package scd_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+)
+
+func TestLatestSubqueryUsesConfiguredStrategy(t *testing.T) {
+	db := dryRunDB(t)
+	defer scd.Configure(scd.Config{})
+	cases := map[scd.Strategy]string{
+		scd.StrategyMaxVersion: `SELECT id, MAX(version) as max_version FROM "jobs" WHERE version_state = 'approved' GROUP BY "id"`,
+		scd.StrategyIsLatest:   `SELECT id, version as max_version FROM "jobs" WHERE is_latest`,
+		scd.StrategyPointer:    `SELECT id, version as max_version FROM "jobs_latest"`,
+		scd.StrategyWindow:     `SELECT id, version as max_version FROM (SELECT id, version, row_number() OVER (PARTITION BY id ORDER BY version DESC) AS rn FROM "jobs" WHERE version_state = 'approved') AS ranked WHERE rn = 1`,
+		scd.StrategyDistinctOn: `SELECT DISTINCT ON (id) id, version as max_version FROM "jobs" WHERE version_state = 'approved' ORDER BY id, version DESC`,
+		scd.StrategyLateral:    `CROSS JOIN LATERAL (SELECT v.version FROM "jobs" v WHERE v.id = e.id AND v.version_state = 'approved' ORDER BY v.version DESC LIMIT 1) AS l`,
+	}
+	for s, want := range cases {
+		scd.Configure(scd.Config{Strategies: map[string]scd.Strategy{"jobs": s}})
+		var jobs []models.Job
+		sql := db.Model(&models.Job{}).
+			Joins("JOIN (?) AS latest ON jobs.id = latest.id AND jobs.version = latest.max_version",
+				scd.LatestSubquery(context.Background(), db, models.Job{})).
+			Find(&jobs).Statement.SQL.String()
+		if !strings.Contains(sql, want) {
+			t.Errorf("%s: want %s in %s", s, want, sql)
+		}
+	}
+}
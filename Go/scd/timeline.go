@@ -0,0 +1,94 @@
+package scd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// HistoryDigest summarizes a run of versions without loading them.
+type HistoryDigest struct {
+	Count          int64      `json:"count"`
+	FirstCreatedAt *time.Time `json:"first_created_at,omitempty"`
+	LastCreatedAt  *time.Time `json:"last_created_at,omitempty"`
+	// ChangedFields lists the business columns that changed between any two
+	// consecutive versions of the run.
+	ChangedFields []string `json:"changed_fields"`
+}
+
+// Timeline is the recent history of an entity in full plus a digest of the
+// versions before it.
+type Timeline[T any] struct {
+	// Recent holds the most recent versions in version order.
+	Recent []T
+	Older  HistoryDigest
+}
+
+// GetTimeline returns the latest recent versions of entity id in full and
+// summarizes the rest, so history views stay cheap for heavily edited entities.
+func GetTimeline[T any](ctx context.Context, db *gorm.DB, id string, recent int) (Timeline[T], error) {
+	var tl Timeline[T]
+	if recent <= 0 {
+		return tl, fmt.Errorf("recent must be positive, got %d", recent)
+	}
+	err := db.WithContext(ctx).Where("id = ?", id).Order("version DESC").Limit(recent).Find(&tl.Recent).Error
+	if err != nil {
+		return tl, fmt.Errorf("fetching recent versions failed: %w", err)
+	}
+	if len(tl.Recent) == 0 {
+		return tl, fmt.Errorf("fetching recent versions failed: %w", ErrNotFound)
+	}
+	slices.Reverse(tl.Recent)
+	if len(tl.Recent) < recent {
+		return tl, nil
+	}
+	_, oldest, _ := versionedKey(&tl.Recent[0])
+	var model T
+	tl.Older, err = SummarizeHistory(ctx, db, &model, id, oldest)
+	return tl, err
+}
+
+// SummarizeHistory digests the versions of entity id before beforeVersion.
+func SummarizeHistory(ctx context.Context, db *gorm.DB, model any, id string, beforeVersion int) (HistoryDigest, error) {
+	digest := HistoryDigest{ChangedFields: []string{}}
+	info, err := Describe(db, model)
+	if err != nil {
+		return digest, err
+	}
+	changed := make([]string, len(info.Columns))
+	flags := make([]string, len(info.Columns))
+	for i, c := range info.Columns {
+		changed[i] = fmt.Sprintf("LAG(version) OVER w IS NOT NULL AND %[1]s IS DISTINCT FROM LAG(%[1]s) OVER w AS c%[2]d", quote(db, c), i)
+		flags[i] = fmt.Sprintf("COALESCE(bool_or(c%d), false)", i)
+	}
+	query := fmt.Sprintf(`SELECT COUNT(*), MIN(created_at), MAX(created_at), %s
+		FROM (SELECT created_at, %s FROM %s WHERE id = ? AND version < ? WINDOW w AS (ORDER BY version)) v`,
+		strings.Join(flags, ", "), strings.Join(changed, ", "), versionsRelation(db, model, info, true))
+
+	var first, last sql.NullTime
+	dest := []any{&digest.Count, &first, &last}
+	values := make([]bool, len(info.Columns))
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+	if err := db.WithContext(ctx).Raw(query, id, beforeVersion).Row().Scan(dest...); err != nil {
+		return digest, fmt.Errorf("summarizing history failed: %w", err)
+	}
+	if first.Valid {
+		digest.FirstCreatedAt = &first.Time
+	}
+	if last.Valid {
+		digest.LastCreatedAt = &last.Time
+	}
+	for i, c := range info.Columns {
+		if values[i] {
+			digest.ChangedFields = append(digest.ChangedFields, c)
+		}
+	}
+	return digest, nil
+}
@@ -0,0 +1,67 @@
+package scd
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// UIDGenerator returns a new, globally unique version UID.
+type UIDGenerator func() string
+
+// UUIDv4 returns a random RFC 4122 version 4 UUID.
+func UUIDv4() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b)
+}
+
+// UUIDv7 returns an RFC 9562 version 7 UUID. Its leading millisecond
+// timestamp keeps UIDs of newer versions sorting after older ones, which keeps
+// inserts into the uid index local.
+func UUIDv7() string {
+	var b [16]byte
+	rand.Read(b[6:])
+	putMillis(b[:6], time.Now())
+	b[6] = b[6]&0x0f | 0x70
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b)
+}
+
+// ULID returns a 26-character ULID: a millisecond timestamp followed by 80
+// random bits, in Crockford base32, so it sorts by creation time.
+func ULID() string {
+	const alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	var b [16]byte
+	putMillis(b[:6], time.Now())
+	rand.Read(b[6:])
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = alphabet[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// Prefixed returns a generator that prepends prefix to the UIDs of gen, e.g.
+// Prefixed("job_", ULID).
+func Prefixed(prefix string, gen UIDGenerator) UIDGenerator {
+	return func() string { return prefix + gen() }
+}
+
+func putMillis(b []byte, t time.Time) {
+	ms := uint64(t.UnixMilli())
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+}
+
+func formatUUID(b [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
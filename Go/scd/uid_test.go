@@ -0,0 +1,37 @@
+package scd_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourorg/Go/scd"
+)
+
+func TestUIDGeneratorFormats(t *testing.T) {
+	cases := []struct {
+		gen     scd.UIDGenerator
+		pattern string
+	}{
+		{scd.UUIDv4, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{scd.UUIDv7, `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{scd.ULID, `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`},
+		{scd.Prefixed("job_", scd.ULID), `^job_[0-7][0-9A-HJKMNP-TV-Z]{25}$`},
+	}
+	for _, c := range cases {
+		if uid := c.gen(); !regexp.MustCompile(c.pattern).MatchString(uid) {
+			t.Errorf("%s does not match %s", uid, c.pattern)
+		}
+	}
+}
+
+func TestTimeOrderedUIDsSortByCreation(t *testing.T) {
+	for _, gen := range []scd.UIDGenerator{scd.UUIDv7, scd.ULID} {
+		first := gen()
+		time.Sleep(2 * time.Millisecond)
+		if second := gen(); strings.Compare(first, second) >= 0 {
+			t.Errorf("%s generated after %s sorts before it", second, first)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package scd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// VerticalSplit describes a model whose rarely-changing columns are stored once
+// per entity instead of being copied into every version. For Job, rate and
+// status churn while title and company stay put:
+//
+//	scd.ApplyVerticalSplit(ctx, db, scd.VerticalSplit{
+//		Model:         models.Job{},
+//		StableColumns: []string{"title", "company_id", "contractor_id"},
+//	})
+type VerticalSplit struct {
+	Model any
+	// StableColumns live in <table>_stable keyed by entity id; every other
+	// business column stays versioned in <table>_versions.
+	StableColumns []string
+}
+
+// ApplyVerticalSplit moves an existing model table into <table>_stable and
+// <table>_versions and replaces it with a view of the original name joining
+// the two by entity id. INSTEAD OF triggers route inserts, updates and deletes
+// on the view to the right table, so repos, LatestSubquery and
+// CreateNewSCDVersion keep working against the model unchanged.
+//
+// Stable columns are not versioned: a new version carrying a different title
+// overwrites the single stored title. Once split, the model must no longer be
+// passed to AutoMigrate; migrate the two underlying tables instead.
+func ApplyVerticalSplit(ctx context.Context, db *gorm.DB, split VerticalSplit) error {
+	db = db.WithContext(ctx)
+	info, err := Describe(db, split.Model)
+	if err != nil {
+		return err
+	}
+	stable, hot, err := split.partition(info)
+	if err != nil {
+		return err
+	}
+	versioned := append(slices.Clone(info.Meta), hot...)
+	qt := quote(db, info.Table)
+	stableTable, versionsTable := quote(db, info.Table+"_stable"), quote(db, info.Table+"_versions")
+	fn := quote(db, derivedName(info.Table, "scd_split_", ""))
+	uidIndex := quote(db, "idx_"+tableRef(info.Table)+"_versions_uid")
+
+	assign := func(cols []string) string {
+		out := make([]string, len(cols))
+		for i, c := range cols {
+			out[i] = fmt.Sprintf("%s = NEW.%s", quote(db, c), quote(db, c))
+		}
+		return strings.Join(out, ", ")
+	}
+	stableUpsert := fmt.Sprintf(
+		"INSERT INTO %s (id, %s) VALUES (NEW.id, %s) ON CONFLICT (id) DO UPDATE SET %s;",
+		stableTable, quoteColumns(db, "", stable), quoteColumns(db, "NEW", stable), strings.ReplaceAll(assign(stable), "NEW.", "EXCLUDED."))
+	trigger := fmt.Sprintf(
+		`CREATE FUNCTION %[1]s() RETURNS trigger AS $$
+		BEGIN
+			IF TG_OP = 'DELETE' THEN
+				DELETE FROM %[3]s WHERE id = OLD.id AND version = OLD.version;
+				DELETE FROM %[2]s s WHERE s.id = OLD.id AND NOT EXISTS (SELECT 1 FROM %[3]s v WHERE v.id = OLD.id);
+				RETURN OLD;
+			ELSIF TG_OP = 'UPDATE' THEN
+				UPDATE %[3]s SET %[4]s WHERE id = OLD.id AND version = OLD.version;
+				%[5]s
+				RETURN NEW;
+			END IF;
+			%[5]s
+			INSERT INTO %[3]s (%[6]s) VALUES (%[7]s);
+			RETURN NEW;
+		END
+		$$ LANGUAGE plpgsql`,
+		fn, stableTable, versionsTable, assign(versioned), stableUpsert,
+		quoteColumns(db, "", versioned), quoteColumns(db, "NEW", versioned))
+
+	stmts := []string{
+		fmt.Sprintf("CREATE TABLE %s AS SELECT DISTINCT ON (id) id, %s FROM %s ORDER BY id, version DESC",
+			stableTable, quoteColumns(db, "", stable), qt),
+		fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (id)", stableTable),
+		fmt.Sprintf("CREATE TABLE %s AS SELECT %s FROM %s", versionsTable, quoteColumns(db, "", versioned), qt),
+		fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (id, version)", versionsTable),
+		fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (uid)", uidIndex, versionsTable),
+		"DROP TABLE " + qt,
+		fmt.Sprintf("CREATE VIEW %s AS SELECT %s, %s FROM %s v JOIN %s s ON s.id = v.id",
+			qt, quoteColumns(db, "v", versioned), quoteColumns(db, "s", stable), versionsTable, stableTable),
+		trigger,
+		fmt.Sprintf("CREATE TRIGGER scd_split INSTEAD OF INSERT OR UPDATE OR DELETE ON %s FOR EACH ROW EXECUTE FUNCTION %s()",
+			qt, fn),
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, s := range stmts {
+			if err := tx.Exec(s).Error; err != nil {
+				return fmt.Errorf("splitting %s failed: %w", info.Table, err)
+			}
+		}
+		return nil
+	})
+}
+
+// RevertVerticalSplit folds a split model back into a single table, restoring
+// the stable columns onto every version.
+func RevertVerticalSplit(ctx context.Context, db *gorm.DB, split VerticalSplit) error {
+	db = db.WithContext(ctx)
+	info, err := Describe(db, split.Model)
+	if err != nil {
+		return err
+	}
+	all := append(slices.Clone(info.Meta), info.Columns...)
+	qt, merged := quote(db, info.Table), quote(db, info.Table+"_merged")
+	stmts := []string{
+		fmt.Sprintf("CREATE TABLE %s AS SELECT %s FROM %s", merged, quoteColumns(db, "", all), qt),
+		fmt.Sprintf("DROP VIEW %s", qt),
+		fmt.Sprintf("DROP FUNCTION %s()", quote(db, derivedName(info.Table, "scd_split_", ""))),
+		fmt.Sprintf("DROP TABLE %s, %s", quote(db, info.Table+"_stable"), quote(db, info.Table+"_versions")),
+		// The new name is unqualified: the table stays in its schema.
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", merged, quote(db, tableRef(info.Table))),
+		fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (id, version)", qt),
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, s := range stmts {
+			if err := tx.Exec(s).Error; err != nil {
+				return fmt.Errorf("merging %s failed: %w", info.Table, err)
+			}
+		}
+		// Let the model's own tags restore the remaining indexes.
+		return tx.AutoMigrate(split.Model)
+	})
+}
+
+// partition separates the model's business columns into stable and hot sets.
+func (s VerticalSplit) partition(info TableInfo) (stable, hot []string, err error) {
+	for _, c := range s.StableColumns {
+		if !slices.Contains(info.Columns, c) {
+			return nil, nil, fmt.Errorf("stable column %q is not a business column of %s", c, info.Table)
+		}
+	}
+	for _, c := range info.Columns {
+		if slices.Contains(s.StableColumns, c) {
+			stable = append(stable, c)
+		} else {
+			hot = append(hot, c)
+		}
+	}
+	if len(stable) == 0 || len(hot) == 0 {
+		return nil, nil, fmt.Errorf("vertical split of %s needs at least one stable and one versioned column", info.Table)
+	}
+	return stable, hot, nil
+}
@@ -0,0 +1,40 @@
+package scd_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+)
+
+func TestApplyVerticalSplitSeparatesStableColumns(t *testing.T) {
+	db, stmts := dryRunTxDB(t, nil)
+	split := scd.VerticalSplit{Model: models.Job{}, StableColumns: []string{"title", "company_id"}}
+	if err := scd.ApplyVerticalSplit(context.Background(), db, split); err != nil {
+		t.Fatalf("ApplyVerticalSplit: %v", err)
+	}
+	sql := strings.Join(*stmts, "\n")
+	for _, want := range []string{
+		`CREATE TABLE "jobs_stable" AS SELECT DISTINCT ON (id) id, "title", "company_id" FROM "jobs"`,
+		`CREATE TABLE "jobs_versions" AS SELECT`,
+		`CREATE VIEW "jobs" AS SELECT`,
+		`JOIN "jobs_stable" s ON s.id = v.id`,
+		`INSTEAD OF INSERT OR UPDATE OR DELETE ON "jobs"`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("split missing %q:\n%s", want, sql)
+		}
+	}
+	for _, s := range *stmts {
+		if strings.HasPrefix(s, `CREATE TABLE "jobs_versions"`) && (strings.Contains(s, `"title"`) || !strings.Contains(s, `"rate"`)) {
+			t.Errorf("versions table should hold only the hot columns: %s", s)
+		}
+	}
+
+	split.StableColumns = []string{"version"}
+	if err := scd.ApplyVerticalSplit(context.Background(), db, split); err == nil {
+		t.Errorf("splitting off a metadata column should fail")
+	}
+}
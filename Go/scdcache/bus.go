@@ -0,0 +1,166 @@
+package scdcache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yourorg/Go/pgnotify"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+// Invalidation announces that entity ID of Table has a new version.
+type Invalidation struct {
+	Table string `json:"table"`
+	ID    string `json:"id"`
+}
+
+// Bus carries invalidations between instances. Publish receives the
+// transaction that created the version so implementations can defer delivery
+// until commit; Subscribe blocks until ctx is cancelled, calling onReset
+// whenever messages may have been lost (e.g. after a reconnect).
+type Bus interface {
+	Publish(tx *gorm.DB, inv Invalidation) error
+	Subscribe(ctx context.Context, onReset func(), fn func(Invalidation)) error
+}
+
+// Invalidatable is implemented by every Cache.
+type Invalidatable interface {
+	Table() string
+	Invalidate(id string)
+	Clear()
+}
+
+// PublishVersions announces every version created through the scd package on bus.
+func PublishVersions(bus Bus) {
+	scd.OnAnyVersionCreated(func(tx *gorm.DB, event scd.VersionEvent) error {
+		return bus.Publish(tx, Invalidation{Table: event.Table, ID: event.ID})
+	})
+}
+
+// Attach subscribes caches to bus and blocks until ctx is cancelled.
+func Attach(ctx context.Context, bus Bus, caches ...Invalidatable) error {
+	byTable := map[string][]Invalidatable{}
+	for _, c := range caches {
+		byTable[c.Table()] = append(byTable[c.Table()], c)
+	}
+	reset := func() {
+		for _, c := range caches {
+			c.Clear()
+		}
+	}
+	return bus.Subscribe(ctx, reset, func(inv Invalidation) {
+		for _, c := range byTable[inv.Table] {
+			c.Invalidate(inv.ID)
+		}
+	})
+}
+
+// PostgresBus uses LISTEN/NOTIFY, so invalidations are sent on commit and
+// never for rolled-back versions.
+type PostgresBus struct {
+	DB      *gorm.DB
+	Channel string
+}
+
+func (b PostgresBus) channel() string {
+	if b.Channel == "" {
+		return "scd_invalidations"
+	}
+	return b.Channel
+}
+
+func (b PostgresBus) Publish(tx *gorm.DB, inv Invalidation) error {
+	payload, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	return pgnotify.Notify(tx, b.channel(), string(payload))
+}
+
+func (b PostgresBus) Subscribe(ctx context.Context, onReset func(), fn func(Invalidation)) error {
+	onConnect := func(context.Context) error {
+		onReset()
+		return nil
+	}
+	return pgnotify.Listen(ctx, b.DB, b.channel(), onConnect, func(payload string) {
+		var inv Invalidation
+		if json.Unmarshal([]byte(payload), &inv) == nil {
+			fn(inv)
+		}
+	})
+}
+
+// MemoryBus delivers invalidations within one process. An invalidation
+// published in a transaction is delivered once Postgres reports the
+// transaction committed, and dropped if it rolls back, so no reader refills
+// a cache with the version being replaced; one published without a
+// transaction is delivered immediately. It suits single-instance
+// deployments and tests.
+type MemoryBus struct {
+	// PollInterval is how often the status of a publishing transaction is
+	// checked until it ends, default 10ms.
+	PollInterval time.Duration
+
+	mu   sync.RWMutex
+	subs []func(Invalidation)
+}
+
+func (b *MemoryBus) Publish(tx *gorm.DB, inv Invalidation) error {
+	if tx == nil {
+		b.deliver(inv)
+		return nil
+	}
+	var xid int64
+	if err := tx.Raw("SELECT txid_current()").Scan(&xid).Error; err != nil {
+		return fmt.Errorf("reading id of the publishing transaction failed: %w", err)
+	}
+	// The status is polled on the pool tx was begun from, outside tx.
+	db := tx.Session(&gorm.Session{NewDB: true, Context: context.Background()})
+	db.Statement.ConnPool = db.Config.ConnPool
+	go b.deliverOnCommit(db, xid, inv)
+	return nil
+}
+
+// deliverOnCommit delivers inv once transaction xid committed. When its
+// status cannot be read inv is delivered anyway: a needless invalidation
+// costs a reload, a missed one serves a stale version.
+func (b *MemoryBus) deliverOnCommit(db *gorm.DB, xid int64, inv Invalidation) {
+	interval := b.PollInterval
+	if interval <= 0 {
+		interval = 10 * time.Millisecond
+	}
+	for {
+		var status *string
+		if err := db.Raw("SELECT txid_status(?)", xid).Scan(&status).Error; err != nil || status == nil {
+			break
+		}
+		if *status == "aborted" {
+			return
+		}
+		if *status == "committed" {
+			break
+		}
+		time.Sleep(interval)
+	}
+	b.deliver(inv)
+}
+
+func (b *MemoryBus) deliver(inv Invalidation) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, fn := range b.subs {
+		fn(inv)
+	}
+}
+
+func (b *MemoryBus) Subscribe(ctx context.Context, _ func(), fn func(Invalidation)) error {
+	b.mu.Lock()
+	b.subs = append(b.subs, fn)
+	b.mu.Unlock()
+	<-ctx.Done()
+	return ctx.Err()
+}
@@ -0,0 +1,90 @@
+package scdcache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// subscribe attaches fn to b and waits until it is subscribed.
+func subscribe(t *testing.T, b *MemoryBus, fn func(Invalidation)) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go b.Subscribe(ctx, func() {}, fn)
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		b.mu.RLock()
+		n := len(b.subs)
+		b.mu.RUnlock()
+		if n > 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("subscription did not start")
+		}
+	}
+}
+
+func TestMemoryBusDeliversWithoutTransactionAtOnce(t *testing.T) {
+	var b MemoryBus
+	got := make(chan Invalidation, 1)
+	subscribe(t, &b, func(inv Invalidation) { got <- inv })
+	if err := b.Publish(nil, Invalidation{Table: "jobs", ID: "job1"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case inv := <-got:
+		if inv.ID != "job1" {
+			t.Errorf("delivered %+v", inv)
+		}
+	default:
+		t.Error("invalidation not delivered on Publish")
+	}
+}
+
+func TestMemoryBusDeliversAfterCommit(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_DSN not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect database: %v", err)
+	}
+	b := MemoryBus{PollInterval: time.Millisecond}
+	got := make(chan Invalidation, 2)
+	subscribe(t, &b, func(inv Invalidation) { got <- inv })
+
+	rollback := errors.New("rollback")
+	db.Transaction(func(tx *gorm.DB) error {
+		if err := b.Publish(tx, Invalidation{Table: "jobs", ID: "rolled-back"}); err != nil {
+			t.Fatal(err)
+		}
+		return rollback
+	})
+	db.Transaction(func(tx *gorm.DB) error {
+		if err := b.Publish(tx, Invalidation{Table: "jobs", ID: "committed"}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+		select {
+		case inv := <-got:
+			t.Errorf("%s delivered before its transaction ended", inv.ID)
+		default:
+		}
+		return nil
+	})
+	select {
+	case inv := <-got:
+		if inv.ID != "committed" {
+			t.Errorf("delivered %s, want only the committed invalidation", inv.ID)
+		}
+	case <-time.After(time.Second):
+		t.Error("committed invalidation not delivered")
+	}
+}
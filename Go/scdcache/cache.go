@@ -0,0 +1,91 @@
+// Package scdcache keeps latest versions in process memory and drops entries
+// when any instance creates a newer version, announced over a Bus.
+package scdcache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+// Cache holds the latest version of entities of type T, keyed by id.
+type Cache[T any] struct {
+	db    *gorm.DB
+	table string
+	ttl   time.Duration
+
+	// load reads the latest version of an entity on a miss.
+	load func(ctx context.Context, id string) (T, error)
+
+	mu      sync.RWMutex
+	entries map[string]entry[T]
+	// gens counts the invalidations of each id since the last Clear, which
+	// increments epoch, so a load can tell whether its entity was
+	// invalidated while it ran.
+	gens  map[string]uint64
+	epoch uint64
+}
+
+type entry[T any] struct {
+	value   T
+	expires time.Time
+}
+
+// New returns a cache for T. Entries also expire after ttl as a safety net
+// against missed invalidations; zero disables expiry.
+func New[T any](db *gorm.DB, ttl time.Duration) (*Cache[T], error) {
+	var model T
+	info, err := scd.Describe(db, model)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cache[T]{db: db, table: info.Table, ttl: ttl, entries: map[string]entry[T]{}, gens: map[string]uint64{}}
+	c.load = func(ctx context.Context, id string) (T, error) { return scd.GetLatest[T](ctx, c.db, id) }
+	return c, nil
+}
+
+// Table returns the table whose invalidations this cache consumes.
+func (c *Cache[T]) Table() string { return c.table }
+
+// Get returns the latest version of id, loading it on a miss. A version
+// loaded while id was invalidated is returned but not cached, as it may be
+// the version the invalidation announced a successor of.
+func (c *Cache[T]) Get(ctx context.Context, id string) (T, error) {
+	c.mu.RLock()
+	e, ok := c.entries[id]
+	gen, epoch := c.gens[id], c.epoch
+	c.mu.RUnlock()
+	if ok && (c.ttl == 0 || time.Now().Before(e.expires)) {
+		return e.value, nil
+	}
+
+	latest, err := c.load(ctx, id)
+	if err != nil {
+		return latest, err
+	}
+	c.mu.Lock()
+	if c.gens[id] == gen && c.epoch == epoch {
+		c.entries[id] = entry[T]{value: latest, expires: time.Now().Add(c.ttl)}
+	}
+	c.mu.Unlock()
+	return latest, nil
+}
+
+// Invalidate drops id from the cache.
+func (c *Cache[T]) Invalidate(id string) {
+	c.mu.Lock()
+	delete(c.entries, id)
+	c.gens[id]++
+	c.mu.Unlock()
+}
+
+// Clear drops every entry, e.g. after the bus reconnects and may have missed messages.
+func (c *Cache[T]) Clear() {
+	c.mu.Lock()
+	c.entries, c.gens = map[string]entry[T]{}, map[string]uint64{}
+	c.epoch++
+	c.mu.Unlock()
+}
@@ -0,0 +1,34 @@
+package scdcache
+
+import (
+	"context"
+	"testing"
+)
+
+type item struct {
+	ID      string
+	Version int
+}
+
+func TestGetDropsFillRacingInvalidation(t *testing.T) {
+	loads := 0
+	c := &Cache[item]{entries: map[string]entry[item]{}, gens: map[string]uint64{}}
+	c.load = func(ctx context.Context, id string) (item, error) {
+		loads++
+		if loads == 1 {
+			// A newer version commits and is announced while v1 is read.
+			c.Invalidate(id)
+		}
+		return item{ID: id, Version: loads}, nil
+	}
+	ctx := context.Background()
+	if got, _ := c.Get(ctx, "a"); got.Version != 1 {
+		t.Fatalf("first Get = %+v, want the version loaded", got)
+	}
+	if got, _ := c.Get(ctx, "a"); got.Version != 2 {
+		t.Fatalf("second Get = %+v: the version read during the invalidation was cached", got)
+	}
+	if got, _ := c.Get(ctx, "a"); got.Version != 2 || loads != 2 {
+		t.Fatalf("third Get = %+v after %d loads, want the cached second version", got, loads)
+	}
+}
@@ -0,0 +1,216 @@
+// Package scdclient is a Go client for the SCD HTTP API served by scdserver.
+//
+//	c := scdclient.New("http://scd.internal:8080")
+//	job, err := c.Jobs.Get(ctx, "job1")
+//	for job, err := range c.Jobs.All(ctx) { ... }
+package scdclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/yourorg/Go/scderr"
+)
+
+// ErrNotFound is matched by errors.Is for 404 responses.
+var ErrNotFound = errors.New("scdclient: not found")
+
+// APIError is returned for non-2xx responses. It carries the server's
+// classification, so scderr.CodeOf and scderr.IsRetryable work on it.
+type APIError struct {
+	StatusCode int
+	Code       scderr.Code
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("scdclient: %d %s", e.StatusCode, e.Message)
+}
+
+func (e *APIError) Is(target error) bool {
+	return target == ErrNotFound && e.ErrorCode() == scderr.NotFound
+}
+
+// ErrorCode returns the code sent by the server, or one derived from the
+// status for responses without a code, e.g. from a proxy.
+func (e *APIError) ErrorCode() scderr.Code {
+	if e.Code != "" {
+		return e.Code
+	}
+	return scderr.FromStatus(e.StatusCode)
+}
+
+// Retryable reports whether the request may succeed if sent again.
+func (e *APIError) Retryable() bool { return e.ErrorCode().Retryable() }
+
+// RetryPolicy controls retries of failed requests. Transport errors and
+// responses whose error is retryable (serialization failures, deadlocks,
+// timeouts, 429 and unreachable backends) are retried with exponential
+// backoff and full jitter; validation errors, conflicts and not found are not.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy is used by New unless overridden with WithRetryPolicy.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 4, BaseDelay: 100 * time.Millisecond, MaxDelay: 2 * time.Second}
+
+// backoff returns the sleep before retry number attempt (starting at 1).
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return rand.N(d + 1)
+}
+
+// Client talks to one SCD server. The typed resources are ready to use after New.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	retry      RetryPolicy
+	actor      string
+
+	Jobs             Resource[Job]
+	Timelogs         Resource[Timelog]
+	PaymentLineItems Resource[PaymentLineItem]
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient replaces http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) { c.retry = p }
+}
+
+// WithActor sends actor as the author of every change made through the
+// client, recorded in the versions' created_by column.
+func WithActor(actor string) Option {
+	return func(c *Client) { c.actor = actor }
+}
+
+type changeReasonKey struct{}
+
+// WithChangeReason attaches reason to the changes made with ctx, recorded in
+// the versions' change_reason column.
+func WithChangeReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, changeReasonKey{}, reason)
+}
+
+// New returns a client for the server at baseURL.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		retry:      DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.Jobs = Resource[Job]{client: c, table: "jobs"}
+	c.Timelogs = Resource[Timelog]{client: c, table: "timelogs"}
+	c.PaymentLineItems = Resource[PaymentLineItem]{client: c, table: "payment_line_items"}
+	return c
+}
+
+// do sends a request with in encoded as the JSON body, retrying retryable
+// failures, and decodes a JSON response into out. in and out may be nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	return c.send(ctx, method, path, query, nil, in, out)
+}
+
+// send is do with extra request headers, which override the defaults.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, header http.Header, in, out any) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var payload []byte
+	if in != nil {
+		var err error
+		if payload, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= max(c.retry.MaxAttempts, 1); attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.retry.backoff(attempt - 1)):
+			}
+		}
+		var reqBody io.Reader
+		if payload != nil {
+			reqBody = bytes.NewReader(payload)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+		if err != nil {
+			return err
+		}
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if c.actor != "" {
+			req.Header.Set("X-Actor", c.actor)
+		}
+		if reason, _ := ctx.Value(changeReasonKey{}).(string); reason != "" {
+			req.Header.Set("X-Change-Reason", reason)
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		retry, err := c.roundTrip(req, out)
+		if err == nil || !retry {
+			return err
+		}
+		lastErr = err
+	}
+	return lastErr
+}
+
+func (c *Client) roundTrip(req *http.Request, out any) (retry bool, err error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return req.Context().Err() == nil, err
+	}
+	defer resp.Body.Close()
+	return decodeResponse(resp, out)
+}
+
+// decodeResponse decodes a JSON response into out, which may be nil, or
+// turns an error response into an APIError, reporting whether it is worth
+// retrying.
+func decodeResponse(resp *http.Response, out any) (retry bool, err error) {
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error string      `json:"error"`
+			Code  scderr.Code `json:"code"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		apiErr := &APIError{StatusCode: resp.StatusCode, Code: e.Code, Message: e.Error}
+		return apiErr.Retryable(), apiErr
+	}
+	if out == nil {
+		return false, nil
+	}
+	return false, json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,248 @@
+package scdclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yourorg/Go/scderr"
+)
+
+func TestAllFollowsCursorsAndRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		p := Page[Job]{Items: []Job{{Versioned: Versioned{ID: "job1", Version: 2}}}, NextCursor: "job1"}
+		if r.URL.Query().Get("cursor") == "job1" {
+			p = Page[Job]{Items: []Job{{Versioned: Versioned{ID: "job2", Version: 1}}}}
+		}
+		json.NewEncoder(w).Encode(p)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}))
+	var ids []string
+	for job, err := range c.Jobs.All(context.Background()) {
+		if err != nil {
+			t.Fatalf("iterating jobs: %v", err)
+		}
+		ids = append(ids, job.ID)
+	}
+	if len(ids) != 2 || ids[0] != "job1" || ids[1] != "job2" {
+		t.Fatalf("got ids %v, want [job1 job2]", ids)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("got %d calls, want 3 (one retried 503 plus two pages)", calls.Load())
+	}
+}
+
+func TestAllSnapshotPinsFirstPageOnly(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		p := Page[Job]{Items: []Job{{Versioned: Versioned{ID: "job1", Version: 2}}}, NextCursor: "snap.abc", Snapshot: "2024-01-01T00:00:00Z"}
+		switch {
+		case q.Get("cursor") == "snap.abc" && q.Get("snapshot") == "":
+			p = Page[Job]{Items: []Job{{Versioned: Versioned{ID: "job2", Version: 1}}}}
+		case q.Get("cursor") != "" || q.Get("snapshot") != "true":
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(p)
+	}))
+	defer srv.Close()
+
+	var ids []string
+	for job, err := range New(srv.URL).Jobs.AllSnapshot(context.Background()) {
+		if err != nil {
+			t.Fatalf("iterating jobs: %v", err)
+		}
+		ids = append(ids, job.ID)
+	}
+	if len(ids) != 2 || ids[0] != "job1" || ids[1] != "job2" {
+		t.Fatalf("got ids %v, want [job1 job2]", ids)
+	}
+}
+
+func TestGetNotFoundIsNotRetried(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "record not found"})
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL).Jobs.Get(context.Background(), "missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("got %d calls, want 1", calls.Load())
+	}
+}
+
+func TestRetriesFollowErrorCode(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch n := calls.Add(1); {
+		case r.URL.Path == "/jobs/conflicted":
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]any{"error": "version conflict", "code": "conflict"})
+		case n == 1:
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]any{"error": "deadlock detected", "code": "deadlock"})
+		default:
+			json.NewEncoder(w).Encode(Job{Versioned: Versioned{ID: "job1", Version: 1}})
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}))
+	if _, err := c.Jobs.Get(context.Background(), "job1"); err != nil {
+		t.Fatalf("deadlock was not retried: %v", err)
+	}
+	calls.Store(0)
+	_, err := c.Jobs.Get(context.Background(), "conflicted")
+	if scderr.CodeOf(err) != scderr.Conflict || scderr.IsRetryable(err) {
+		t.Fatalf("got %v (code %s), want a non-retryable conflict", err, scderr.CodeOf(err))
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("conflict sent %d times, want 1", calls.Load())
+	}
+}
+
+func TestMergePatchSendsConditionalPatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var patch map[string]any
+		json.NewDecoder(r.Body).Decode(&patch)
+		if r.Method != "PATCH" || r.Header.Get("Content-Type") != "application/merge-patch+json" || r.Header.Get("If-Match") != "3" {
+			t.Errorf("got %s with Content-Type %q and If-Match %q", r.Method, r.Header.Get("Content-Type"), r.Header.Get("If-Match"))
+		}
+		if patch["rate"] != 120.0 {
+			t.Errorf("got patch %v", patch)
+		}
+		json.NewEncoder(w).Encode(Job{Versioned: Versioned{ID: "job1", Version: 4}, Rate: 120})
+	}))
+	defer srv.Close()
+
+	job, err := New(srv.URL).Jobs.MergePatch(context.Background(), "job1", map[string]any{"rate": 120}, 3)
+	if err != nil || job.Version != 4 {
+		t.Fatalf("got %+v, %v", job, err)
+	}
+}
+
+func TestReservationCommitConflict(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /jobs/job1/reservations":
+			if r.URL.Query().Get("ttl") != "1h0m0s" {
+				t.Errorf("got ttl %q", r.URL.Query().Get("ttl"))
+			}
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(Reservation{Token: "tok", EntityID: "job1", BaseVersion: 3, Version: 4})
+		case "POST /jobs/job1/reservations/tok/commit":
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]any{"error": "scd: version conflict", "code": scderr.Conflict})
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	jobs := New(srv.URL).Jobs
+	res, err := jobs.Reserve(context.Background(), "job1", time.Hour)
+	if err != nil || res.Version != 4 {
+		t.Fatalf("Reserve = %+v, %v", res, err)
+	}
+	_, err = jobs.CommitReservation(context.Background(), res, map[string]any{"rate": 120})
+	if scderr.CodeOf(err) != scderr.Conflict {
+		t.Fatalf("CommitReservation error = %v, want a conflict", err)
+	}
+}
+
+func TestClientSendsActorAndChangeReason(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Actor") != "user-42" || r.Header.Get("X-Change-Reason") != "rate correction" {
+			t.Errorf("got X-Actor %q and X-Change-Reason %q", r.Header.Get("X-Actor"), r.Header.Get("X-Change-Reason"))
+		}
+		json.NewEncoder(w).Encode(Job{Versioned: Versioned{ID: "job1", Version: 2, CreatedBy: "user-42"}})
+	}))
+	defer srv.Close()
+
+	ctx := WithChangeReason(context.Background(), "rate correction")
+	job, err := New(srv.URL, WithActor("user-42")).Jobs.MergePatch(ctx, "job1", map[string]any{"rate": 120}, 0)
+	if err != nil || job.CreatedBy != "user-42" {
+		t.Fatalf("got %+v, %v", job, err)
+	}
+}
+
+func TestScheduleMergePatchSendsEffectiveTime(t *testing.T) {
+	effective := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" || r.URL.Query().Get("effective_at") != "2024-04-01T00:00:00Z" || r.Header.Get("If-Match") != "" {
+			t.Errorf("got %s %s with If-Match %q", r.Method, r.URL, r.Header.Get("If-Match"))
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(ScheduledChange{ID: 7, EntityID: "job1", Patch: `{"rate":120}`, EffectiveAt: effective})
+	}))
+	defer srv.Close()
+
+	change, err := New(srv.URL).Jobs.ScheduleMergePatch(context.Background(), "job1", map[string]any{"rate": 120}, effective)
+	if err != nil || change.ID != 7 || !change.EffectiveAt.Equal(effective) {
+		t.Fatalf("got %+v, %v", change, err)
+	}
+}
+
+func TestJSONPatchFailedTestIsAConflict(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ops []PatchOp
+		json.NewDecoder(r.Body).Decode(&ops)
+		if r.Header.Get("Content-Type") != "application/json-patch+json" || len(ops) != 2 || ops[0].Op != "test" {
+			t.Errorf("got Content-Type %q and ops %+v", r.Header.Get("Content-Type"), ops)
+		}
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]any{"error": "scd: patch test failed: /rate does not match", "code": "conflict"})
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL).Jobs.JSONPatch(context.Background(), "job1", []PatchOp{
+		{Op: "test", Path: "/rate", Value: 100},
+		{Op: "replace", Path: "/rate", Value: 120},
+	}, 0)
+	if scderr.CodeOf(err) != scderr.Conflict {
+		t.Fatalf("got %v, want a conflict", err)
+	}
+}
+
+func TestWatchYieldsStreamedVersions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/jobs/job1/watch" {
+			t.Errorf("got path %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for v := 1; v <= 2; v++ {
+			data, _ := json.Marshal(Job{Versioned: Versioned{ID: "job1", Version: v}})
+			w.Write([]byte("event: version\ndata: " + string(data) + "\n\n"))
+		}
+	}))
+	defer srv.Close()
+
+	var versions []int
+	for job, err := range New(srv.URL).Jobs.Watch(context.Background(), "job1") {
+		if err != nil {
+			break
+		}
+		versions = append(versions, job.Version)
+	}
+	if len(versions) != 2 || versions[0] != 1 || versions[1] != 2 {
+		t.Fatalf("got versions %v, want [1 2]", versions)
+	}
+}
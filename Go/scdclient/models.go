@@ -0,0 +1,42 @@
+package scdclient
+
+import "time"
+
+// Versioned carries the SCD key fields present on every entity, and who
+// wrote the version, why and through which channel.
+type Versioned struct {
+	ID           string `json:"id"`
+	Version      int    `json:"version"`
+	UID          string `json:"uid"`
+	CreatedBy    string `json:"created_by,omitempty"`
+	ChangeReason string `json:"change_reason,omitempty"`
+	Source       string `json:"source,omitempty"`
+}
+
+type Job struct {
+	Versioned
+	Status       string  `json:"status"`
+	Rate         float64 `json:"rate"`
+	Title        string  `json:"title"`
+	CompanyID    string  `json:"company_id"`
+	ContractorID string  `json:"contractor_id"`
+}
+
+type Timelog struct {
+	Versioned
+	Duration       float64   `json:"duration"`
+	TimeStart      time.Time `json:"time_start"`
+	TimeEnd        time.Time `json:"time_end"`
+	Type           string    `json:"type"`
+	JobUID         string    `json:"job_uid"`
+	ApprovalStatus string    `json:"approval_status,omitempty"`
+}
+
+type PaymentLineItem struct {
+	Versioned
+	JobUID      string  `json:"job_uid"`
+	TimelogUID  string  `json:"timelog_uid"`
+	Amount      float64 `json:"amount"`
+	Status      string  `json:"status"`
+	CorrectsUID string  `json:"corrects_uid,omitempty"`
+}
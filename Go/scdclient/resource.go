@@ -0,0 +1,341 @@
+package scdclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page is one page of a list response.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Snapshot is the time a walk started by AllSnapshot is pinned to.
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+// Resource exposes the endpoints of one versioned table.
+type Resource[T any] struct {
+	client *Client
+	table  string
+}
+
+// Get returns the latest version of entity id.
+func (r Resource[T]) Get(ctx context.Context, id string) (T, error) {
+	var v T
+	err := r.client.do(ctx, "GET", "/"+r.table+"/"+url.PathEscape(id), nil, nil, &v)
+	return v, err
+}
+
+// Version returns one specific version of entity id.
+func (r Resource[T]) Version(ctx context.Context, id string, version int) (T, error) {
+	var v T
+	path := "/" + r.table + "/" + url.PathEscape(id) + "/versions/" + strconv.Itoa(version)
+	err := r.client.do(ctx, "GET", path, nil, nil, &v)
+	return v, err
+}
+
+// MergePatch applies an RFC 7396 JSON Merge Patch to entity id and returns
+// the version it created. patch is keyed by column name, e.g.
+// map[string]any{"rate": 120}, and a nil value resets the column. With
+// expectedVersion above zero the update only succeeds while id is still at
+// that version, and fails with a conflict otherwise.
+func (r Resource[T]) MergePatch(ctx context.Context, id string, patch any, expectedVersion int) (T, error) {
+	var v T
+	header := http.Header{"Content-Type": {"application/merge-patch+json"}}
+	if expectedVersion > 0 {
+		header.Set("If-Match", strconv.Itoa(expectedVersion))
+	}
+	err := r.client.send(ctx, "PATCH", "/"+r.table+"/"+url.PathEscape(id), nil, header, patch, &v)
+	return v, err
+}
+
+// Reservation is a version number reserved for an edit staged offline.
+type Reservation struct {
+	Token       string    `json:"token"`
+	EntityID    string    `json:"entity_id"`
+	BaseVersion int       `json:"base_version"`
+	Version     int       `json:"version"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Reserve reserves the next version of entity id for ttl, or the server's
+// default when ttl is 0. The server must run with reservations enabled.
+func (r Resource[T]) Reserve(ctx context.Context, id string, ttl time.Duration) (Reservation, error) {
+	var res Reservation
+	q := url.Values{}
+	if ttl > 0 {
+		q.Set("ttl", ttl.String())
+	}
+	err := r.client.do(ctx, "POST", "/"+r.table+"/"+url.PathEscape(id)+"/reservations", q, nil, &res)
+	return res, err
+}
+
+// CommitReservation writes the version reserved by res as a JSON Merge Patch
+// of the version it was based on. It fails with a conflict when another
+// writer created the version first or the reservation expired; reserve
+// again and rebase the edit on the latest version then.
+func (r Resource[T]) CommitReservation(ctx context.Context, res Reservation, patch any) (T, error) {
+	var v T
+	header := http.Header{"Content-Type": {"application/merge-patch+json"}}
+	err := r.client.send(ctx, "POST", r.reservationPath(res)+"/commit", nil, header, patch, &v)
+	return v, err
+}
+
+// AbandonReservation drops res without writing anything.
+func (r Resource[T]) AbandonReservation(ctx context.Context, res Reservation) error {
+	return r.client.do(ctx, "DELETE", r.reservationPath(res), nil, nil, nil)
+}
+
+func (r Resource[T]) reservationPath(res Reservation) string {
+	return "/" + r.table + "/" + url.PathEscape(res.EntityID) + "/reservations/" + url.PathEscape(res.Token)
+}
+
+// ScheduledChange is a merge patch entered ahead of the time it takes effect.
+type ScheduledChange struct {
+	ID          int64      `json:"id"`
+	EntityID    string     `json:"entity_id"`
+	Patch       string     `json:"patch"`
+	EffectiveAt time.Time  `json:"effective_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ActivatedAt *time.Time `json:"activated_at,omitempty"`
+	Version     int        `json:"version,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// ScheduleMergePatch is MergePatch for a change that takes effect at
+// effectiveAt, e.g. a rate change entered weeks in advance. Reads keep
+// returning the entity unchanged until the server activates the change, which
+// then applies it to the version that is latest at that point.
+func (r Resource[T]) ScheduleMergePatch(ctx context.Context, id string, patch any, effectiveAt time.Time) (ScheduledChange, error) {
+	var c ScheduledChange
+	header := http.Header{"Content-Type": {"application/merge-patch+json"}}
+	query := url.Values{"effective_at": {effectiveAt.Format(time.RFC3339Nano)}}
+	err := r.client.send(ctx, "PATCH", "/"+r.table+"/"+url.PathEscape(id), query, header, patch, &c)
+	return c, err
+}
+
+// Scheduled returns the changes of entity id that are scheduled but not yet
+// in effect, in the order they take effect.
+func (r Resource[T]) Scheduled(ctx context.Context, id string) ([]ScheduledChange, error) {
+	var p struct {
+		Items []ScheduledChange `json:"items"`
+	}
+	err := r.client.do(ctx, "GET", "/"+r.table+"/"+url.PathEscape(id)+"/scheduled", nil, nil, &p)
+	return p.Items, err
+}
+
+// CancelScheduled removes a scheduled change of entity id before it takes effect.
+func (r Resource[T]) CancelScheduled(ctx context.Context, id string, changeID int64) error {
+	path := "/" + r.table + "/" + url.PathEscape(id) + "/scheduled/" + strconv.FormatInt(changeID, 10)
+	return r.client.do(ctx, "DELETE", path, nil, nil, nil)
+}
+
+// PatchOp is one operation of an RFC 6902 JSON Patch. Paths are JSON
+// Pointers into the entity keyed by column name, e.g. "/rate".
+type PatchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	From  string `json:"from,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+// JSONPatch applies an RFC 6902 JSON Patch to entity id and returns the
+// version it created. "test" operations are checked against the latest
+// version first, so they make the update conditional on individual fields; a
+// failed test is reported as a conflict and writes nothing. expectedVersion
+// works as for MergePatch.
+func (r Resource[T]) JSONPatch(ctx context.Context, id string, ops []PatchOp, expectedVersion int) (T, error) {
+	var v T
+	header := http.Header{"Content-Type": {"application/json-patch+json"}}
+	if expectedVersion > 0 {
+		header.Set("If-Match", strconv.Itoa(expectedVersion))
+	}
+	err := r.client.send(ctx, "PATCH", "/"+r.table+"/"+url.PathEscape(id), nil, header, ops, &v)
+	return v, err
+}
+
+// GetAsOf returns entity id as it was at time t.
+func (r Resource[T]) GetAsOf(ctx context.Context, id string, t time.Time) (T, error) {
+	var v T
+	err := r.client.do(ctx, "GET", "/"+r.table+"/"+url.PathEscape(id), asOfQuery(url.Values{}, t), nil, &v)
+	return v, err
+}
+
+// List returns one page of latest versions ordered by id, starting after cursor.
+func (r Resource[T]) List(ctx context.Context, cursor string, limit int) (Page[T], error) {
+	var p Page[T]
+	err := r.client.do(ctx, "GET", "/"+r.table, pageQuery(cursor, limit), nil, &p)
+	return p, err
+}
+
+// ListAsOf is List for the versions that were latest at time t.
+func (r Resource[T]) ListAsOf(ctx context.Context, t time.Time, cursor string, limit int) (Page[T], error) {
+	var p Page[T]
+	err := r.client.do(ctx, "GET", "/"+r.table, asOfQuery(pageQuery(cursor, limit), t), nil, &p)
+	return p, err
+}
+
+// HistoryPage returns one page of the versions of entity id in version order.
+func (r Resource[T]) HistoryPage(ctx context.Context, id, cursor string, limit int) (Page[T], error) {
+	var p Page[T]
+	err := r.client.do(ctx, "GET", "/"+r.table+"/"+url.PathEscape(id)+"/versions", pageQuery(cursor, limit), nil, &p)
+	return p, err
+}
+
+// HistoryDigest summarizes versions omitted from a Timeline.
+type HistoryDigest struct {
+	Count          int        `json:"count"`
+	FirstCreatedAt *time.Time `json:"first_created_at,omitempty"`
+	LastCreatedAt  *time.Time `json:"last_created_at,omitempty"`
+	ChangedFields  []string   `json:"changed_fields"`
+}
+
+// Timeline is the recent history of an entity plus a digest of older versions.
+type Timeline[T any] struct {
+	Recent []T           `json:"recent"`
+	Older  HistoryDigest `json:"older"`
+}
+
+// Timeline returns the last recent versions of entity id in full and a
+// digest of the rest. recent <= 0 uses the server default.
+func (r Resource[T]) Timeline(ctx context.Context, id string, recent int) (Timeline[T], error) {
+	var tl Timeline[T]
+	q := url.Values{}
+	if recent > 0 {
+		q.Set("recent", strconv.Itoa(recent))
+	}
+	err := r.client.do(ctx, "GET", "/"+r.table+"/"+url.PathEscape(id)+"/timeline", q, nil, &tl)
+	return tl, err
+}
+
+// All iterates over the latest version of every entity, fetching pages as needed.
+// Iteration stops after yielding the first error.
+func (r Resource[T]) All(ctx context.Context) iter.Seq2[T, error] {
+	return paginate(func(cursor string) (Page[T], error) { return r.List(ctx, cursor, 0) })
+}
+
+// AllSnapshot is All pinned to the time of its first page: every page
+// holds the versions latest at that time, so entities changed or created
+// while iterating are neither repeated nor skipped.
+func (r Resource[T]) AllSnapshot(ctx context.Context) iter.Seq2[T, error] {
+	return paginate(func(cursor string) (Page[T], error) {
+		q := pageQuery(cursor, 0)
+		if cursor == "" {
+			q.Set("snapshot", "true")
+		}
+		var p Page[T]
+		err := r.client.do(ctx, "GET", "/"+r.table, q, nil, &p)
+		return p, err
+	})
+}
+
+// AllAsOf iterates over every entity as it was at time t.
+func (r Resource[T]) AllAsOf(ctx context.Context, t time.Time) iter.Seq2[T, error] {
+	return paginate(func(cursor string) (Page[T], error) { return r.ListAsOf(ctx, t, cursor, 0) })
+}
+
+// History iterates over every version of entity id in version order.
+func (r Resource[T]) History(ctx context.Context, id string) iter.Seq2[T, error] {
+	return paginate(func(cursor string) (Page[T], error) { return r.HistoryPage(ctx, id, cursor, 0) })
+}
+
+// Watch streams entity id as it changes: first its latest version, then every
+// new version as it is written, until ctx is cancelled or the stream breaks,
+// which is yielded as an error. A slow consumer skips to the newest version.
+// The server must run with watches enabled.
+func (r Resource[T]) Watch(ctx context.Context, id string) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		req, err := http.NewRequestWithContext(ctx, "GET", r.client.baseURL+"/"+r.table+"/"+url.PathEscape(id)+"/watch", nil)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		req.Header.Set("Accept", "text/event-stream")
+		resp, err := r.client.httpClient.Do(req)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			_, err := decodeResponse(resp, nil)
+			yield(zero, err)
+			return
+		}
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(nil, 1<<20)
+		var data strings.Builder
+		for scanner.Scan() {
+			line := scanner.Text()
+			if v, ok := strings.CutPrefix(line, "data:"); ok {
+				data.WriteString(strings.TrimPrefix(v, " "))
+				continue
+			}
+			if line != "" || data.Len() == 0 {
+				continue
+			}
+			var v T
+			err := json.Unmarshal([]byte(data.String()), &v)
+			data.Reset()
+			if !yield(v, err) || err != nil {
+				return
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		err = scanner.Err()
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		yield(zero, err)
+	}
+}
+
+func paginate[T any](fetch func(cursor string) (Page[T], error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		cursor := ""
+		for {
+			p, err := fetch(cursor)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range p.Items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if p.NextCursor == "" {
+				return
+			}
+			cursor = p.NextCursor
+		}
+	}
+}
+
+func asOfQuery(q url.Values, t time.Time) url.Values {
+	q.Set("as_of", t.Format(time.RFC3339Nano))
+	return q
+}
+
+func pageQuery(cursor string, limit int) url.Values {
+	q := url.Values{}
+	if cursor != "" {
+		q.Set("cursor", cursor)
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	return q
+}
@@ -0,0 +1,60 @@
+package scdclient
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// Statement is a contractor's statement for one month, as assembled by the
+// server from one consistent snapshot.
+type Statement struct {
+	ContractorID string            `json:"contractor_id"`
+	Month        string            `json:"month"`
+	From         time.Time         `json:"from"`
+	To           time.Time         `json:"to"`
+	Jobs         []StatementJob    `json:"jobs"`
+	Timesheet    []TimesheetEntry  `json:"timesheet"`
+	LineItems    []PaymentLineItem `json:"line_items"`
+	Totals       StatementTotals   `json:"totals"`
+	GeneratedAt  time.Time         `json:"generated_at"`
+}
+
+// StatementJob is an active job with the rate in effect at the end of the
+// month and its current rate.
+type StatementJob struct {
+	ID          string  `json:"id"`
+	Version     int     `json:"version"`
+	Title       string  `json:"title"`
+	CompanyID   string  `json:"company_id"`
+	Rate        float64 `json:"rate"`
+	CurrentRate float64 `json:"current_rate"`
+}
+
+// TimesheetEntry is one timelog priced at the rate of its job when the work
+// started.
+type TimesheetEntry struct {
+	TimelogID string    `json:"timelog_id"`
+	TimeStart time.Time `json:"time_start"`
+	Hours     float64   `json:"hours"`
+	JobUID    string    `json:"job_uid"`
+	Rate      float64   `json:"rate"`
+	Amount    float64   `json:"amount"`
+}
+
+// StatementTotals sums up a statement.
+type StatementTotals struct {
+	Hours    float64            `json:"hours"`
+	Earned   float64            `json:"earned"`
+	Billed   float64            `json:"billed"`
+	ByStatus map[string]float64 `json:"by_status"`
+}
+
+// ContractorStatement returns the statement of contractorID for the month of
+// month, in UTC.
+func (c *Client) ContractorStatement(ctx context.Context, contractorID string, month time.Time) (Statement, error) {
+	var st Statement
+	query := url.Values{"month": {month.UTC().Format("2006-01")}}
+	err := c.do(ctx, "GET", "/contractors/"+url.PathEscape(contractorID)+"/statement", query, nil, &st)
+	return st, err
+}
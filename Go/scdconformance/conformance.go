@@ -0,0 +1,295 @@
+// Package scdconformance is the behaviour every database backend and
+// latest-version strategy of the scd package must show, as a test suite
+// they can be run against. A new backend or strategy passes it before it is
+// relied on:
+//
+//	func TestConformance(t *testing.T) {
+//		scdconformance.Run(t, scdconformance.Backend{
+//			Open:     func(t *testing.T) *gorm.DB { return openTestDB(t) },
+//			Strategy: scd.StrategyLateral,
+//		})
+//	}
+//
+// The suite writes its own model, Item, to its own table and keeps its
+// entities apart by a random prefix per run, so it needs no empty database
+// and several runs may share one.
+package scdconformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Item is the versioned model the suite writes.
+type Item struct {
+	models.Versioned
+	Name  string `gorm:"column:name"`
+	Count int    `gorm:"column:count"`
+}
+
+func (Item) TableName() string { return "scd_conformance_items" }
+
+// Backend is what the suite runs against.
+type Backend struct {
+	// Open returns the database to check. The suite migrates Item's table.
+	Open func(t *testing.T) *gorm.DB
+	// Strategy is the latest-version read strategy checked, prepared and
+	// activated on Item's table for the run; empty keeps the configured one.
+	Strategy scd.Strategy
+	// Writers is how many writers the concurrent check runs, default 8.
+	// Backends serializing every connection, e.g. an in-memory database,
+	// may set 1.
+	Writers int
+}
+
+var registerOnce sync.Once
+
+// Run checks b: creating entities and versions, concurrent version bumps,
+// as-of reads, soft deletes and restores, reverts, and paging through
+// latest versions while they change.
+func Run(t *testing.T, b Backend) {
+	t.Helper()
+	registerOnce.Do(func() { scd.RegisterModel(Item{}, scd.ModelOptions{}) })
+	db := b.Open(t)
+	ctx := context.Background()
+	if err := db.AutoMigrate(&Item{}); err != nil {
+		t.Fatalf("migrating %s: %v", Item{}.TableName(), err)
+	}
+	if b.Strategy != "" {
+		if err := activate(ctx, db, b.Strategy); err != nil {
+			t.Fatalf("activating strategy %s: %v", b.Strategy, err)
+		}
+		t.Cleanup(func() {
+			if err := scd.ActivateStrategy(ctx, db, &Item{}, scd.StrategyMaxVersion); err != nil {
+				t.Errorf("restoring strategy: %v", err)
+			}
+		})
+	}
+	s := &suite{db: db, prefix: "conf-" + scd.NewUID()[:8] + "-", writers: b.Writers}
+	if s.writers <= 0 {
+		s.writers = 8
+	}
+	t.Run("Create", s.create)
+	t.Run("ConcurrentBump", s.concurrentBump)
+	t.Run("AsOf", s.asOf)
+	t.Run("SoftDelete", s.softDelete)
+	t.Run("Revert", s.revert)
+	t.Run("Pagination", s.pagination)
+}
+
+// activate makes s the read strategy of Item's table, preparing the storage
+// of the strategies that need some.
+func activate(ctx context.Context, db *gorm.DB, s scd.Strategy) error {
+	if s == scd.StrategyIsLatest || s == scd.StrategyPointer {
+		if err := scd.PrepareStrategy(ctx, db, &Item{}, s, 0); err != nil {
+			return err
+		}
+	}
+	return scd.ActivateStrategy(ctx, db, &Item{}, s)
+}
+
+type suite struct {
+	db      *gorm.DB
+	prefix  string
+	writers int
+}
+
+// newItem creates the first version of a fresh entity named name.
+func (s *suite) newItem(t *testing.T, name string) Item {
+	t.Helper()
+	item := Item{Versioned: models.Versioned{ID: s.prefix + name, Version: 1, UID: scd.NewUID()}, Name: name}
+	if err := s.db.Create(&item).Error; err != nil {
+		t.Fatalf("creating %s: %v", item.ID, err)
+	}
+	return item
+}
+
+// bump writes a new version of id with update.
+func (s *suite) bump(t *testing.T, id string, update func(*Item)) {
+	t.Helper()
+	err := scd.CreateNewSCDVersion[Item](context.Background(), s.db, id, func(it *Item) error {
+		update(it)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("versioning %s: %v", id, err)
+	}
+}
+
+// latest reads the latest live version of id; found is false when there is none.
+func (s *suite) latest(t *testing.T, ctx context.Context, id string) (item Item, found bool) {
+	t.Helper()
+	res := s.db.WithContext(ctx).Scopes(scd.Latest[Item]()).
+		Where(clause.Eq{Column: scd.Column(Item{}.TableName(), "id"), Value: id}).Limit(1).Find(&item)
+	if res.Error != nil {
+		t.Fatalf("reading latest %s: %v", id, res.Error)
+	}
+	return item, res.RowsAffected > 0
+}
+
+func (s *suite) versions(t *testing.T, id string) []Item {
+	t.Helper()
+	var out []Item
+	if err := scd.AllVersions(context.Background(), s.db, &Item{}).Where("id = ?", id).Order("version").Find(&out).Error; err != nil {
+		t.Fatalf("reading versions of %s: %v", id, err)
+	}
+	return out
+}
+
+func (s *suite) create(t *testing.T) {
+	first := s.newItem(t, "create")
+	got, ok := s.latest(t, context.Background(), first.ID)
+	if !ok || got.Version != 1 || got.Name != "create" {
+		t.Fatalf("latest after create = %+v (found %v), want version 1", got, ok)
+	}
+	s.bump(t, first.ID, func(it *Item) { it.Name = "renamed" })
+	got, _ = s.latest(t, context.Background(), first.ID)
+	if got.Version != 2 || got.Name != "renamed" || got.UID == first.UID || got.UID == "" {
+		t.Fatalf("latest after a new version = %+v, want version 2 with a new UID", got)
+	}
+	if history := s.versions(t, first.ID); len(history) != 2 || history[0].Name != "create" {
+		t.Fatalf("history = %+v, want both versions with the first unchanged", history)
+	}
+	if err := scd.CreateNewSCDVersion[Item](context.Background(), s.db, s.prefix+"missing", func(*Item) error { return nil }); !errors.Is(err, scd.ErrNotFound) {
+		t.Errorf("versioning a missing entity: %v, want ErrNotFound", err)
+	}
+}
+
+func (s *suite) concurrentBump(t *testing.T) {
+	item := s.newItem(t, "bump")
+	var wg sync.WaitGroup
+	errs := make(chan error, s.writers)
+	for range s.writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- scd.CreateNewSCDVersion[Item](context.Background(), s.db, item.ID, func(it *Item) error {
+				it.Count++
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent version: %v", err)
+		}
+	}
+	got, _ := s.latest(t, context.Background(), item.ID)
+	if got.Version != s.writers+1 || got.Count != s.writers {
+		t.Fatalf("latest = version %d count %d, want version %d count %d: every writer builds on the one before",
+			got.Version, got.Count, s.writers+1, s.writers)
+	}
+	for i, v := range s.versions(t, item.ID) {
+		if v.Version != i+1 {
+			t.Fatalf("versions are not contiguous: position %d holds version %d", i, v.Version)
+		}
+	}
+}
+
+func (s *suite) asOf(t *testing.T) {
+	before := time.Now().Add(-time.Minute)
+	item := s.newItem(t, "asof")
+	time.Sleep(10 * time.Millisecond)
+	between := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	s.bump(t, item.ID, func(it *Item) { it.Name = "later" })
+
+	got, err := scd.ResolveAsOf[Item](context.Background(), s.db, []scd.AsOfPair{
+		{ID: item.ID, At: before}, {ID: item.ID, At: between}, {ID: item.ID, At: time.Now().Add(time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("resolving as-of: %v", err)
+	}
+	if got[0].ID != "" {
+		t.Errorf("as of before its creation = version %d, want none", got[0].Version)
+	}
+	if got[1].Version != 1 || got[1].Name != "asof" {
+		t.Errorf("as of between its versions = version %d %q, want version 1", got[1].Version, got[1].Name)
+	}
+	if got[2].Version != 2 || got[2].Name != "later" {
+		t.Errorf("as of now = version %d %q, want version 2", got[2].Version, got[2].Name)
+	}
+}
+
+func (s *suite) softDelete(t *testing.T) {
+	ctx := context.Background()
+	item := s.newItem(t, "delete")
+	if err := scd.DeleteAsNewVersion[Item](ctx, s.db, item.ID); err != nil {
+		t.Fatalf("deleting: %v", err)
+	}
+	if got, ok := s.latest(t, ctx, item.ID); ok {
+		t.Fatalf("deleted entity still read as latest: %+v", got)
+	}
+	got, ok := s.latest(t, scd.IncludeDeleted(ctx), item.ID)
+	if !ok || !got.IsDeleted || got.Version != 2 {
+		t.Fatalf("latest including deleted = %+v (found %v), want the version 2 tombstone", got, ok)
+	}
+	if err := scd.Restore[Item](ctx, s.db, item.ID); err != nil {
+		t.Fatalf("restoring: %v", err)
+	}
+	got, ok = s.latest(t, ctx, item.ID)
+	if !ok || got.IsDeleted || got.Version != 3 || got.Name != "delete" {
+		t.Fatalf("latest after restore = %+v (found %v), want a live version 3", got, ok)
+	}
+}
+
+func (s *suite) revert(t *testing.T) {
+	item := s.newItem(t, "revert")
+	s.bump(t, item.ID, func(it *Item) { it.Name, it.Count = "changed", 7 })
+	reverted, err := scd.RevertToVersion[Item](context.Background(), s.db, item.ID, 1)
+	if err != nil {
+		t.Fatalf("reverting: %v", err)
+	}
+	got, _ := s.latest(t, context.Background(), item.ID)
+	if got.Version != 3 || got.Name != "revert" || got.Count != 0 || reverted.Version != 3 {
+		t.Fatalf("latest after revert = %+v, want version 3 with version 1's fields", got)
+	}
+	if history := s.versions(t, item.ID); len(history) != 3 || history[1].Name != "changed" {
+		t.Fatalf("history = %+v, want the reverted version kept", history)
+	}
+}
+
+func (s *suite) pagination(t *testing.T) {
+	const n, pageSize = 25, 10
+	var want []string
+	for i := range n {
+		want = append(want, s.newItem(t, fmt.Sprintf("page-%02d", i)).ID)
+	}
+	table := Item{}.TableName()
+	var seen []string
+	cursor := s.prefix + "page-"
+	for page := 0; ; page++ {
+		var items []Item
+		err := s.db.Scopes(scd.Latest[Item]()).
+			Where("? > ? AND ? LIKE ?", scd.Column(table, "id"), cursor, scd.Column(table, "id"), s.prefix+"page-%").
+			Order(clause.OrderByColumn{Column: scd.Column(table, "id")}).Limit(pageSize).Find(&items).Error
+		if err != nil {
+			t.Fatalf("reading page %d: %v", page, err)
+		}
+		for _, it := range items {
+			seen = append(seen, it.ID)
+		}
+		if len(items) < pageSize {
+			break
+		}
+		cursor = items[len(items)-1].ID
+		// New versions mid-walk must neither repeat nor hide entities.
+		s.bump(t, want[0], func(it *Item) { it.Count++ })
+		s.bump(t, want[n-1], func(it *Item) { it.Count++ })
+	}
+	if !slices.Equal(seen, want) {
+		t.Fatalf("paged through %v, want %v", seen, want)
+	}
+}
@@ -0,0 +1,106 @@
+package scdconformance_test
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/scd"
+	"github.com/yourorg/Go/scdconformance"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func openPostgres(t *testing.T) *gorm.DB {
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_DSN not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect database: %v", err)
+	}
+	return db
+}
+
+func TestPostgres(t *testing.T) {
+	for _, s := range []scd.Strategy{scd.StrategyMaxVersion, scd.StrategyWindow, scd.StrategyDistinctOn,
+		scd.StrategyLateral, scd.StrategyIsLatest, scd.StrategyPointer} {
+		t.Run(string(s), func(t *testing.T) {
+			scdconformance.Run(t, scdconformance.Backend{Open: openPostgres, Strategy: s})
+		})
+	}
+}
+
+type historyLayoutItem struct {
+	models.Versioned
+	Count int
+}
+
+type currentLayoutItem struct {
+	models.Versioned
+	Count int
+}
+
+// Under the history layouts the insert trigger moves the row concurrent
+// writers wait to lock; every writer must still get its version in.
+func TestConcurrentWritersPerLayout(t *testing.T) {
+	db := openPostgres(t)
+	scd.RegisterModel(historyLayoutItem{}, scd.ModelOptions{Layout: scd.LayoutHistoryTable})
+	scd.RegisterModel(currentLayoutItem{}, scd.ModelOptions{Layout: scd.LayoutCurrentHistory})
+	t.Run(string(scd.LayoutHistoryTable), func(t *testing.T) {
+		concurrentWriters(t, db, func(it *historyLayoutItem) *int { return &it.Count })
+	})
+	t.Run(string(scd.LayoutCurrentHistory), func(t *testing.T) {
+		concurrentWriters(t, db, func(it *currentLayoutItem) *int { return &it.Count })
+	})
+}
+
+func concurrentWriters[T any, P scd.EntityPtr[T]](t *testing.T, db *gorm.DB, count func(*T) *int) {
+	t.Helper()
+	ctx := context.Background()
+	var first T
+	if err := db.AutoMigrate(&first); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	if err := scd.PrepareLayout(ctx, db, &first); err != nil {
+		t.Fatalf("preparing layout: %v", err)
+	}
+	id := "conf-" + scd.NewUID()[:8]
+	P(&first).SetVersion(1)
+	P(&first).SetUID(scd.NewUID())
+	reflect.ValueOf(&first).Elem().FieldByName("ID").SetString(id)
+	if err := db.Create(&first).Error; err != nil {
+		t.Fatalf("creating %s: %v", id, err)
+	}
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- scd.CreateNewSCDVersion[T, P](ctx, db, id, func(it *T) error {
+				*count(it)++
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent version: %v", err)
+		}
+	}
+	var latest T
+	if err := db.Where("id = ?", id).Take(&latest).Error; err != nil {
+		t.Fatalf("reading latest %s: %v", id, err)
+	}
+	if v := P(&latest).GetVersion(); v != writers+1 || *count(&latest) != writers {
+		t.Errorf("latest = version %d count %d, want version %d count %d", v, *count(&latest), writers+1, writers)
+	}
+}
@@ -0,0 +1,190 @@
+// Package scderr is the error taxonomy shared by the scd package, the
+// repositories, the HTTP server and the client SDK. Every error is classified
+// by a Code, and each Code is either retryable or not, so callers and retry
+// policies decide the same way whichever layer they sit on:
+//
+//	if scderr.IsRetryable(err) { ... try again ... }
+//
+// The package only depends on the standard library, so the client SDK can
+// use it without pulling in GORM or the database driver.
+package scderr
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Code classifies an error. Codes are sent over the wire, so they are stable.
+type Code string
+
+const (
+	// NotFound means the entity or version does not exist.
+	NotFound Code = "not_found"
+	// Conflict means the request lost to, or contradicts, another write.
+	Conflict Code = "conflict"
+	// Validation means the request is malformed or breaks a constraint.
+	Validation Code = "validation"
+	// Forbidden means the session may not perform the request.
+	Forbidden Code = "forbidden"
+	// SerializationFailure means the database aborted the transaction to keep
+	// it serializable; running it again normally succeeds.
+	SerializationFailure Code = "serialization_failure"
+	// Deadlock means the database aborted the transaction to break a deadlock.
+	Deadlock Code = "deadlock"
+	// Timeout means a deadline, statement timeout or lock timeout expired.
+	Timeout Code = "timeout"
+	// Unavailable means the database or server could not be reached.
+	Unavailable Code = "unavailable"
+	// Internal is every other error.
+	Internal Code = "internal"
+)
+
+// Retryable reports whether an operation failing with c may succeed if
+// repeated unchanged.
+func (c Code) Retryable() bool {
+	switch c {
+	case SerializationFailure, Deadlock, Timeout, Unavailable:
+		return true
+	}
+	return false
+}
+
+// HTTPStatus returns the status the server responds with for c.
+func (c Code) HTTPStatus() int {
+	switch c {
+	case NotFound:
+		return http.StatusNotFound
+	case Conflict:
+		return http.StatusConflict
+	case Validation:
+		return http.StatusBadRequest
+	case Forbidden:
+		return http.StatusForbidden
+	case SerializationFailure, Deadlock, Unavailable:
+		return http.StatusServiceUnavailable
+	case Timeout:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// FromStatus is the inverse of HTTPStatus, for responses without a code.
+func FromStatus(status int) Code {
+	switch {
+	case status == http.StatusNotFound:
+		return NotFound
+	case status == http.StatusConflict:
+		return Conflict
+	case status == http.StatusForbidden || status == http.StatusUnauthorized:
+		return Forbidden
+	case status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable || status == http.StatusBadGateway:
+		return Unavailable
+	case status == http.StatusGatewayTimeout || status == http.StatusRequestTimeout:
+		return Timeout
+	case status >= 400 && status < 500:
+		return Validation
+	}
+	return Internal
+}
+
+// Error is an error with a Code. Sentinel errors of the scd package are
+// *Error values, so errors.Is keeps working on them.
+type Error struct {
+	Code    Code
+	Message string
+	// Err is the underlying error, if any.
+	Err error
+}
+
+// New returns an error with code and message.
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap attaches code to err, keeping err's message. It returns nil for a nil err.
+func Wrap(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Message: err.Error(), Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil && e.Message != e.Err.Error() {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// ErrorCode returns e.Code.
+func (e *Error) ErrorCode() Code { return e.Code }
+
+// Retryable reports whether e.Code is retryable.
+func (e *Error) Retryable() bool { return e.Code.Retryable() }
+
+// CodeOf classifies err. Errors carrying a code (anything with an ErrorCode
+// method, such as *Error or the client's APIError) report it; Postgres errors
+// are classified by SQLSTATE, and network errors and expired contexts by
+// their kind. Everything else is Internal.
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+	var coded interface{ ErrorCode() Code }
+	if errors.As(err, &coded) {
+		return coded.ErrorCode()
+	}
+	// pgconn.PgError, without importing the driver.
+	var pg interface{ SQLState() string }
+	if errors.As(err, &pg) {
+		return codeOfSQLState(pg.SQLState())
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Timeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return Timeout
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return Unavailable
+	}
+	return Internal
+}
+
+// IsRetryable reports whether the operation that failed with err may succeed
+// if repeated unchanged.
+func IsRetryable(err error) bool {
+	return CodeOf(err).Retryable()
+}
+
+// codeOfSQLState maps Postgres error codes onto the taxonomy.
+func codeOfSQLState(state string) Code {
+	switch state {
+	case "40001":
+		return SerializationFailure
+	case "40P01":
+		return Deadlock
+	case "57014", "55P03": // query_canceled by statement_timeout, lock_not_available
+		return Timeout
+	case "57P01", "57P02", "57P03", "53300": // shutdowns, cannot_connect_now, too_many_connections
+		return Unavailable
+	case "23505":
+		return Conflict
+	case "42501":
+		return Forbidden
+	}
+	switch {
+	case strings.HasPrefix(state, "08"): // connection exceptions
+		return Unavailable
+	case strings.HasPrefix(state, "22"), strings.HasPrefix(state, "23"): // data exceptions, integrity violations
+		return Validation
+	}
+	return Internal
+}
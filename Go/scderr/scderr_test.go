@@ -0,0 +1,53 @@
+package scderr_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/yourorg/Go/scderr"
+)
+
+// pgError mimics pgconn.PgError, which exposes its SQLSTATE the same way.
+type pgError struct{ code string }
+
+func (e *pgError) Error() string    { return "pg error " + e.code }
+func (e *pgError) SQLState() string { return e.code }
+
+func TestCodeOfClassifiesErrors(t *testing.T) {
+	for _, tc := range []struct {
+		err       error
+		want      scderr.Code
+		retryable bool
+	}{
+		{fmt.Errorf("saving: %w", &pgError{"40001"}), scderr.SerializationFailure, true},
+		{&pgError{"40P01"}, scderr.Deadlock, true},
+		{&pgError{"57014"}, scderr.Timeout, true},
+		{&pgError{"08006"}, scderr.Unavailable, true},
+		{&pgError{"23505"}, scderr.Conflict, false},
+		{&pgError{"23502"}, scderr.Validation, false},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), scderr.Timeout, true},
+		{scderr.Wrap(scderr.NotFound, errors.New("missing")), scderr.NotFound, false},
+		{errors.New("boom"), scderr.Internal, false},
+	} {
+		if got := scderr.CodeOf(tc.err); got != tc.want {
+			t.Errorf("CodeOf(%v) = %s, want %s", tc.err, got, tc.want)
+		}
+		if got := scderr.IsRetryable(tc.err); got != tc.retryable {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tc.err, got, tc.retryable)
+		}
+	}
+}
+
+func TestHTTPStatusRoundTrips(t *testing.T) {
+	for _, code := range []scderr.Code{scderr.NotFound, scderr.Conflict, scderr.Validation, scderr.Forbidden, scderr.Timeout, scderr.Internal} {
+		if got := scderr.FromStatus(code.HTTPStatus()); got != code {
+			t.Errorf("FromStatus(%d) = %s, want %s", code.HTTPStatus(), got, code)
+		}
+	}
+	if !scderr.FromStatus(http.StatusTooManyRequests).Retryable() {
+		t.Error("429 is not retryable")
+	}
+}
@@ -0,0 +1,81 @@
+// Package scdtest holds helpers for test, benchmark and demo environments.
+// Nothing in it belongs anywhere near a database with real data.
+package scdtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/yourorg/Go/exportjob"
+	"github.com/yourorg/Go/periods"
+	"github.com/yourorg/Go/pinset"
+	"github.com/yourorg/Go/scd"
+	"github.com/yourorg/Go/snapshot"
+	"gorm.io/gorm"
+)
+
+// AllowResetEnv names the environment variable that must be set to "unsafe"
+// before ResetAll touches a database.
+const AllowResetEnv = "SCD_ALLOW_RESET"
+
+// ErrResetNotAllowed is returned by ResetAll when AllowResetEnv is not set.
+var ErrResetNotAllowed = errors.New("scdtest: reset refused, set " + AllowResetEnv + "=unsafe to allow it")
+
+// ResetAll empties every registered versioned table together with the
+// tables the scd packages keep beside them: pointer and archive tables,
+// the change log, provenance, version annotations, quarantines, version
+// reservations, scheduled changes, snapshot cursors, pin sets, accounting
+// periods and export jobs.
+// Strategy state is kept, as it describes schema rather than data. Tables
+// are truncated in one statement, dependents first, and identities restart.
+func ResetAll(ctx context.Context, db *gorm.DB) error {
+	if os.Getenv(AllowResetEnv) != "unsafe" {
+		return ErrResetNotAllowed
+	}
+	regs := scd.Registrations()
+	if len(regs) == 0 {
+		return errors.New("scdtest: no registered models to reset")
+	}
+	var candidates []string
+	// Models are registered dependencies first, so reverse for dependents first.
+	for _, reg := range slices.Backward(regs) {
+		info, err := scd.Describe(db, reg.Model)
+		if err != nil {
+			return err
+		}
+		candidates = append(candidates, info.Table+"_latest", info.Table+"_archive", info.Table+"_versions", info.Table+"_history", info.Table)
+	}
+	candidates = append(candidates,
+		scd.ChangeLogEntry{}.TableName(),
+		scd.ProvenanceEdge{}.TableName(),
+		scd.StaleReference{}.TableName(),
+		scd.VersionAnnotation{}.TableName(),
+		scd.Quarantine{}.TableName(),
+		scd.VersionReservation{}.TableName(),
+		scd.ScheduledChange{}.TableName(),
+		snapshot.Cursor{}.TableName(),
+		pinset.Member{}.TableName(),
+		pinset.PinSet{}.TableName(),
+		periods.Period{}.TableName(),
+		exportjob.Job{}.TableName(),
+	)
+
+	db = db.WithContext(ctx)
+	var tables []string
+	for _, t := range candidates {
+		if db.Migrator().HasTable(t) {
+			tables = append(tables, db.Statement.Quote(t))
+		}
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+	if err := db.Exec(fmt.Sprintf("TRUNCATE TABLE %s RESTART IDENTITY CASCADE", strings.Join(tables, ", "))).Error; err != nil {
+		return fmt.Errorf("resetting tables failed: %w", err)
+	}
+	return nil
+}
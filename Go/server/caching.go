@@ -0,0 +1,89 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Versions are immutable once approved, so a version number or the set of
+// versions a response is built from identifies its body. Responses carry
+// that as ETag, and, except on listing pages, the newest created_at among
+// them as Last-Modified, so polling clients can revalidate with If-None-Match
+// or If-Modified-Since and get an empty 304 while nothing changed.
+
+// rowETag is the entity tag of one version: its version number, which
+// If-Match on PATCH accepts back, qualified by its approval state unless it
+// is approved, since approving changes a version in place.
+func rowETag(row map[string]any) string {
+	tag := fmt.Sprint(row["version"])
+	if state, _ := row["version_state"].(string); state != "" && state != "approved" {
+		tag += "." + state
+	}
+	return `"` + tag + `"`
+}
+
+// rowsETag is the weak entity tag of a response built from rows and extra,
+// e.g. a page and its cursor.
+func rowsETag(rows []map[string]any, extra ...any) string {
+	h := sha256.New()
+	for _, row := range rows {
+		fmt.Fprintf(h, "%v\x00%v\x00%v\x00", row["id"], row["version"], row["version_state"])
+	}
+	fmt.Fprint(h, extra...)
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:12]) + `"`
+}
+
+// lastModified is the newest created_at among rows, zero when none has one.
+func lastModified(rows ...map[string]any) time.Time {
+	var newest time.Time
+	for _, row := range rows {
+		if t := feedTime(row["created_at"]); t.After(newest) {
+			newest = t
+		}
+	}
+	return newest
+}
+
+// notModified sets the validators of a response and, for a GET or HEAD
+// whose conditional headers they satisfy, responds 304 and returns true.
+// If-None-Match takes precedence over If-Modified-Since, as RFC 9110 asks.
+func notModified(w http.ResponseWriter, r *http.Request, etag string, modified time.Time) bool {
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Cache-Control", "no-cache")
+	if !modified.IsZero() {
+		h.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagMatches(inm, etag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil || modified.IsZero() || modified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether the If-None-Match list header contains etag,
+// comparing weakly.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
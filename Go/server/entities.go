@@ -0,0 +1,476 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// page is the envelope returned by list endpoints. NextCursor is empty on the last page.
+type page struct {
+	Items      []map[string]any `json:"items"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	// Truncated is set when a full history was requested but exceeded the
+	// server's limit, so only the first page is returned.
+	Truncated    bool  `json:"truncated,omitempty"`
+	VersionCount int64 `json:"version_count,omitempty"`
+	// Snapshot is the time a snapshot=true walk is pinned to.
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+// listLatest returns latest versions ordered by id: GET /{table}?limit=&cursor=&as_of=&snapshot=.
+// The cursor is the last id of the previous page; as_of (RFC 3339) returns the
+// versions that were latest at that time instead. With snapshot=true the walk
+// is pinned to the time of its first page (or to as_of): every page of it
+// returns the versions latest at that time, so entities changed or created
+// mid-walk are neither repeated nor skipped. Pinned walks report the time as
+// snapshot and carry it in their cursors.
+func (s *Server) listLatest(w http.ResponseWriter, r *http.Request) {
+	model, table, ok := s.model(w, r)
+	if !ok {
+		return
+	}
+	limit := pageSize(r)
+	walk, err := parseWalk(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var q *gorm.DB
+	if walk.pinned() {
+		q = s.latestAsOf(r.Context(), model, table, walk.asOf)
+	} else if q, ok = s.latest(w, r, model, table); !ok {
+		return
+	}
+	q = q.Order(clause.OrderByColumn{Column: scd.Column(table, "id")}).Limit(limit + 1)
+	if walk.after != "" {
+		q = q.Where("? > ?", scd.Column(table, "id"), walk.after)
+	}
+	var rows []map[string]any
+	if err := q.Find(&rows).Error; err != nil {
+		writeFailure(w, err)
+		return
+	}
+	p := paginate(rows, limit, func(row map[string]any) string {
+		return walk.cursor(fmt.Sprint(row["id"]))
+	})
+	if walk.pinned() {
+		p.Snapshot = walk.asOf.Format(time.RFC3339Nano)
+	}
+	writePage(w, r, p)
+}
+
+// getLatest returns the latest version of one entity: GET /{table}/{id}?as_of=.
+func (s *Server) getLatest(w http.ResponseWriter, r *http.Request) {
+	model, table, ok := s.model(w, r)
+	if !ok {
+		return
+	}
+	q, ok := s.latest(w, r, model, table)
+	if !ok {
+		return
+	}
+	var row map[string]any
+	err := q.Where("? = ?", scd.Column(table, "id"), r.PathValue("id")).Take(&row).Error
+	writeRow(w, r, row, err)
+}
+
+// maxPatchBytes bounds the body of a PATCH request.
+const maxPatchBytes = 1 << 20
+
+// patch updates one entity and returns the version it created:
+// PATCH /{table}/{id}, with either a JSON Merge Patch body
+// (application/merge-patch+json) or a JSON Patch one
+// (application/json-patch+json), whose "test" operations make the update
+// conditional on individual fields. An If-Match header holding a version
+// number makes the whole update conditional: it fails with 409 once the
+// entity has moved on. With effective_at (RFC 3339) a merge patch is
+// scheduled instead; see schedule.
+func (s *Server) patch(w http.ResponseWriter, r *http.Request) {
+	model, _, ok := s.model(w, r)
+	if !ok {
+		return
+	}
+	apply, applyIf := scd.MergePatch, scd.MergePatchIf
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch ct {
+	case "application/merge-patch+json", "application/json":
+	case "application/json-patch+json":
+		apply, applyIf = scd.JSONPatch, scd.JSONPatchIf
+	default:
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("content type %q is not a merge patch or JSON patch", ct))
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchBytes))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	id := r.PathValue("id")
+	if v := r.URL.Query().Get("effective_at"); v != "" {
+		s.schedule(w, r, model, id, v, ct, body)
+		return
+	}
+	var created any
+	if match := r.Header.Get("If-Match"); match != "" {
+		expected, convErr := strconv.Atoi(strings.Trim(match, `"`))
+		if convErr != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("If-Match must be a version number: %w", convErr))
+			return
+		}
+		created, err = applyIf(r.Context(), s.DB, model, id, expected, body)
+	} else {
+		created, err = apply(r.Context(), s.DB, model, id, body)
+	}
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	var row map[string]any
+	err = scd.AllVersions(r.Context(), s.DB, model).
+		Where("id = ? AND version = ?", id, created.(scd.Entity).GetVersion()).Take(&row).Error
+	writeRow(w, r, row, err)
+}
+
+// schedule records a merge patch that takes effect at effective and responds
+// 202 with the scheduled change; reads see the entity unchanged until the
+// activator applies it. JSON Patches and If-Match are refused, as both would
+// be checked against a version that does not exist yet.
+func (s *Server) schedule(w http.ResponseWriter, r *http.Request, model any, id, effective, ct string, body []byte) {
+	if ct == "application/json-patch+json" {
+		writeError(w, http.StatusUnsupportedMediaType, errors.New("only merge patches can be scheduled"))
+		return
+	}
+	if r.Header.Get("If-Match") != "" {
+		writeError(w, http.StatusBadRequest, errors.New("If-Match cannot be combined with effective_at"))
+		return
+	}
+	at, err := time.Parse(time.RFC3339Nano, effective)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("effective_at: %w", err))
+		return
+	}
+	change, err := scd.ScheduleChange(r.Context(), s.DB, model, id, at, body)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, change)
+}
+
+// listScheduled returns the pending scheduled changes of one entity in the
+// order they take effect: GET /{table}/{id}/scheduled.
+func (s *Server) listScheduled(w http.ResponseWriter, r *http.Request) {
+	model, _, ok := s.model(w, r)
+	if !ok {
+		return
+	}
+	changes, err := scd.PendingChanges(r.Context(), s.DB, model, r.PathValue("id"))
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	if changes == nil {
+		changes = []scd.ScheduledChange{}
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Items []scd.ScheduledChange `json:"items"`
+	}{changes})
+}
+
+// cancelScheduled removes a pending scheduled change of one entity:
+// DELETE /{table}/{id}/scheduled/{change}.
+func (s *Server) cancelScheduled(w http.ResponseWriter, r *http.Request) {
+	model, _, ok := s.model(w, r)
+	if !ok {
+		return
+	}
+	changeID, err := strconv.ParseInt(r.PathValue("change"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("change: %w", err))
+		return
+	}
+	pending, err := scd.PendingChanges(r.Context(), s.DB, model, r.PathValue("id"))
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	if !slices.ContainsFunc(pending, func(c scd.ScheduledChange) bool { return c.ID == changeID }) {
+		writeFailure(w, scd.ErrNotFound)
+		return
+	}
+	if err := scd.CancelScheduledChange(r.Context(), s.DB, changeID); err != nil {
+		writeFailure(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// transition returns the handler of POST /{table}/{id}/versions/{version}/<step>,
+// which moves a version through the approval workflow with step, one of
+// scd.SubmitDraft, scd.ApproveVersion and scd.RejectVersion, and responds 204.
+func (s *Server) transition(step func(ctx context.Context, db *gorm.DB, model any, id string, version int) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		model, _, ok := s.model(w, r)
+		if !ok {
+			return
+		}
+		version, err := strconv.Atoi(r.PathValue("version"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("version: %w", err))
+			return
+		}
+		if err := step(r.Context(), s.DB, model, r.PathValue("id"), version); err != nil {
+			writeFailure(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// listVersions returns the history of one entity in version order:
+// GET /{table}/{id}/versions?limit=&cursor=, where cursor is the last version seen.
+// With all=true the whole history is returned in one response, unless it is
+// larger than scd's history limit; then the first page and a cursor are
+// returned instead, flagged as truncated. override=true lifts the limit.
+func (s *Server) listVersions(w http.ResponseWriter, r *http.Request) {
+	model, _, ok := s.model(w, r)
+	if !ok {
+		return
+	}
+	limit := pageSize(r)
+	var truncated bool
+	var count int64
+	if r.URL.Query().Get("all") == "true" {
+		var err error
+		count, err = scd.CheckHistorySize(r.Context(), s.DB, model, r.PathValue("id"), r.URL.Query().Get("override") == "true")
+		switch {
+		case errors.Is(err, scd.ErrHistoryTooLarge):
+			truncated, limit = true, maxPageSize
+		case err != nil:
+			writeFailure(w, err)
+			return
+		default:
+			limit = max(int(count), 1)
+		}
+	}
+	q := scd.AllVersions(r.Context(), s.DB, model).
+		Where("id = ?", r.PathValue("id")).Order("version").Limit(limit + 1)
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		q = q.Where("version > ?", cursor)
+	}
+	var rows []map[string]any
+	if err := q.Find(&rows).Error; err != nil {
+		writeFailure(w, err)
+		return
+	}
+	p := paginate(rows, limit, func(row map[string]any) string {
+		return fmt.Sprint(row["version"])
+	})
+	p.Truncated, p.VersionCount = truncated, count
+	writePage(w, r, p)
+}
+
+// timeline returns the most recent versions of one entity in full plus a
+// digest of older ones: GET /{table}/{id}/timeline?recent=, default 10.
+func (s *Server) timeline(w http.ResponseWriter, r *http.Request) {
+	model, _, ok := s.model(w, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	recent, err := strconv.Atoi(r.URL.Query().Get("recent"))
+	if err != nil || recent <= 0 {
+		recent = 10
+	}
+	recent = min(recent, maxPageSize)
+	var rows []map[string]any
+	err = scd.AllVersions(r.Context(), s.DB, model).
+		Where("id = ?", id).Order("version DESC").Limit(recent).Find(&rows).Error
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	if len(rows) == 0 {
+		writeFailure(w, scd.ErrNotFound)
+		return
+	}
+	slices.Reverse(rows)
+	out := struct {
+		Recent []map[string]any  `json:"recent"`
+		Older  scd.HistoryDigest `json:"older"`
+	}{Recent: rows, Older: scd.HistoryDigest{ChangedFields: []string{}}}
+	if notModified(w, r, rowsETag(rows), lastModified(rows...)) {
+		return
+	}
+	if len(rows) == recent {
+		out.Older, err = scd.SummarizeHistory(r.Context(), s.DB, model, id, toInt(rows[0]["version"]))
+		if err != nil {
+			writeFailure(w, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// watch streams the versions of one entity as server-sent events, for pages
+// that live-update while the entity is edited elsewhere:
+// GET /{table}/{id}/watch. The latest version is sent first, then each new
+// one as it commits, every one as a "version" event holding the row. A client
+// that falls behind skips to the newest version.
+func (s *Server) watch(w http.ResponseWriter, r *http.Request) {
+	model, table, ok := s.model(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	ctx, id := r.Context(), r.PathValue("id")
+	// Watch before reading the latest version, so nothing written in between is lost.
+	entries := s.Watcher.Watch(ctx, table, id)
+	var row map[string]any
+	latest := joinLatest(s.DB.WithContext(ctx).Model(model), scd.LiveSubquery(ctx, s.DB, model), table)
+	if err := latest.Where("? = ?", scd.Column(table, "id"), id).Take(&row).Error; err != nil {
+		writeFailure(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	send := func(row map[string]any) {
+		data, _ := json.Marshal(row)
+		fmt.Fprintf(w, "event: version\ndata: %s\n\n", data)
+		flusher.Flush()
+	}
+	send(row)
+	last := toInt(row["version"])
+	for e := range entries {
+		if e.Version <= last {
+			continue
+		}
+		row = nil
+		err := scd.AllVersions(ctx, s.DB, model).Where("id = ? AND version = ?", id, e.Version).Take(&row).Error
+		if err != nil {
+			continue
+		}
+		send(row)
+		last = e.Version
+	}
+}
+
+// getVersion returns one specific version: GET /{table}/{id}/versions/{version}.
+func (s *Server) getVersion(w http.ResponseWriter, r *http.Request) {
+	model, _, ok := s.model(w, r)
+	if !ok {
+		return
+	}
+	var row map[string]any
+	err := scd.AllVersions(r.Context(), s.DB, model).
+		Where("id = ? AND version = ?", r.PathValue("id"), r.PathValue("version")).Take(&row).Error
+	writeRow(w, r, row, err)
+}
+
+// model resolves the {table} path segment to a registered model.
+func (s *Server) model(w http.ResponseWriter, r *http.Request) (any, string, bool) {
+	table := r.PathValue("table")
+	model, ok := s.Models[table]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown table %q", table))
+	}
+	return model, table, ok
+}
+
+// latest selects the latest rows of table, leaving out tombstones and
+// quarantined entities as scd.LiveSubquery does, or those as of the as_of
+// query parameter. It writes a 400 and returns false when as_of is malformed.
+func (s *Server) latest(w http.ResponseWriter, r *http.Request, model any, table string) (*gorm.DB, bool) {
+	ctx := r.Context()
+	if v := r.URL.Query().Get("as_of"); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("as_of: %w", err))
+			return nil, false
+		}
+		return s.latestAsOf(ctx, model, table, t), true
+	}
+	return joinLatest(s.DB.WithContext(ctx).Model(model), scd.LiveSubquery(ctx, s.DB, model), table), true
+}
+
+// latestAsOf selects the rows of table that were latest at t.
+func (s *Server) latestAsOf(ctx context.Context, model any, table string, t time.Time) *gorm.DB {
+	return joinLatest(scd.AllVersions(ctx, s.DB, model), scd.AsOfSubquery(ctx, s.DB, model, t), table)
+}
+
+// joinLatest restricts base to the rows of table selected by subq, a
+// subquery of (id, max_version).
+func joinLatest(base, subq *gorm.DB, table string) *gorm.DB {
+	return scd.JoinVersions(base.Select("?.*", scd.TableRef(table)), table, "latest", subq)
+}
+
+func pageSize(r *http.Request) int {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		return defaultPageSize
+	}
+	return min(limit, maxPageSize)
+}
+
+// paginate trims the look-ahead row fetched beyond limit and derives the next cursor.
+func paginate(rows []map[string]any, limit int, cursor func(map[string]any) string) page {
+	p := page{Items: rows}
+	if p.Items == nil {
+		p.Items = []map[string]any{}
+	}
+	if len(rows) > limit {
+		p.Items = rows[:limit]
+		p.NextCursor = cursor(rows[limit-1])
+	}
+	return p
+}
+
+// writeRow responds with one version, or with 304 when it is the version the
+// client already has; see notModified.
+func writeRow(w http.ResponseWriter, r *http.Request, row map[string]any, err error) {
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	if notModified(w, r, rowETag(row), lastModified(row)) {
+		return
+	}
+	writeJSON(w, http.StatusOK, row)
+}
+
+// writePage responds with a page of a listing, or with 304 when the client
+// already has the same versions on it. Pages carry no Last-Modified: an
+// entity leaving a page changes it without making any of its rows newer.
+func writePage(w http.ResponseWriter, r *http.Request, p page) {
+	if notModified(w, r, rowsETag(p.Items, p.NextCursor, p.Truncated, p.VersionCount), time.Time{}) {
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func toInt(v any) int {
+	n, _ := strconv.Atoi(fmt.Sprint(v))
+	return n
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/yourorg/Go/exportjob"
+	"gorm.io/gorm"
+)
+
+// createExport starts an asynchronous history export: POST /exports {"table": "jobs"}.
+// With "anonymize": true the PII columns of the table are pseudonymized.
+func (s *Server) createExport(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Table     string `json:"table"`
+		Anonymize bool   `json:"anonymize"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	create := s.Exports.Create
+	if req.Anonymize {
+		create = s.Exports.CreateAnonymized
+	}
+	job, err := create(r.Context(), req.Table)
+	if errors.Is(err, exportjob.ErrUnknownTable) {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	w.Header().Set("Location", "/exports/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// getExport reports the status and progress of an export job.
+func (s *Server) getExport(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.loadExport(w, r)
+	if ok {
+		writeJSON(w, http.StatusOK, job)
+	}
+}
+
+// downloadExport streams the NDJSON result of a finished export.
+func (s *Server) downloadExport(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.loadExport(w, r)
+	if !ok {
+		return
+	}
+	if job.Status != exportjob.StatusDone {
+		writeError(w, http.StatusConflict, fmt.Errorf("export %s is %s", job.ID, job.Status))
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", job.Table+"-"+job.ID+".ndjson"))
+	http.ServeFile(w, r, job.Path)
+}
+
+func (s *Server) loadExport(w http.ResponseWriter, r *http.Request) (exportjob.Job, bool) {
+	job, err := s.Exports.Get(r.Context(), r.PathValue("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("export %s not found", r.PathValue("id")))
+		return job, false
+	}
+	if err != nil {
+		writeFailure(w, err)
+		return job, false
+	}
+	return job, true
+}
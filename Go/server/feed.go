@@ -0,0 +1,250 @@
+package server
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yourorg/Go/scd"
+)
+
+// maxFeedEntries bounds the entries of a feed.
+const maxFeedEntries = 100
+
+// feedEntry is one version in a feed, independent of the feed format.
+type feedEntry struct {
+	ID      string
+	URL     string
+	Title   string
+	Content string
+	Author  string
+	Updated time.Time
+}
+
+// feed serves the recent versions of a whole table, GET /feeds/{table}, or of
+// one entity, GET /{table}/{id}/feed, newest first, for readers that follow
+// changes without consuming the change log. The feed is Atom unless
+// format=json or an Accept header of application/feed+json asks for JSON
+// Feed; limit caps the entries (default 50, at most 100). Each entry lists
+// the fields the version changed. Drafts and versions pending approval are
+// left out.
+func (s *Server) feed(w http.ResponseWriter, r *http.Request) {
+	model, table, ok := s.model(w, r)
+	if !ok {
+		return
+	}
+	info, err := scd.Describe(s.DB, model)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	ctx, id := r.Context(), r.PathValue("id")
+	q := scd.AllVersions(ctx, s.DB, model).Order("created_at DESC").Order("version DESC").Limit(min(pageSize(r), maxFeedEntries))
+	if id != "" {
+		q = q.Where("id = ?", id)
+	}
+	if slices.Contains(info.Meta, "version_state") {
+		q = q.Where("version_state = ?", scd.StateApproved)
+	}
+	var rows []map[string]any
+	if err := q.Find(&rows).Error; err != nil {
+		writeFailure(w, err)
+		return
+	}
+	if id != "" && len(rows) == 0 {
+		writeFailure(w, scd.ErrNotFound)
+		return
+	}
+
+	if notModified(w, r, rowsETag(rows, r.URL.Query().Get("format"), r.Header.Get("Accept")), lastModified(rows...)) {
+		return
+	}
+	base := baseURL(r)
+	entries := make([]feedEntry, len(rows))
+	for i, row := range rows {
+		entityID, version := fmt.Sprint(row["id"]), toInt(row["version"])
+		var previous map[string]any
+		prev := scd.AllVersions(ctx, s.DB, model).Where("id = ? AND version < ?", entityID, version).Order("version DESC").Limit(1)
+		if slices.Contains(info.Meta, "version_state") {
+			prev = prev.Where("version_state = ?", scd.StateApproved)
+		}
+		if err := prev.Find(&previous).Error; err != nil {
+			writeFailure(w, err)
+			return
+		}
+		if len(previous) == 0 {
+			previous = nil
+		}
+		author, _ := row["created_by"].(string)
+		entries[i] = feedEntry{
+			ID:      fmt.Sprintf("urn:scd:%s:%s", table, row["uid"]),
+			URL:     fmt.Sprintf("%s/%s/%s/versions/%d", base, table, entityID, version),
+			Title:   entryTitle(table, entityID, version, row, previous),
+			Content: changedFields(info.Columns, previous, row),
+			Author:  author,
+			Updated: feedTime(row["created_at"]),
+		}
+	}
+
+	title, self := fmt.Sprintf("Changes to %s", table), fmt.Sprintf("%s/feeds/%s", base, table)
+	if id != "" {
+		title, self = fmt.Sprintf("Changes to %s %s", table, id), fmt.Sprintf("%s/%s/%s/feed", base, table, id)
+	}
+	if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/feed+json") {
+		writeJSONFeed(w, title, self, entries)
+		return
+	}
+	writeAtom(w, title, self, entries)
+}
+
+// entryTitle names the change a version made, e.g. "jobs job1 v3 updated".
+func entryTitle(table, id string, version int, row, previous map[string]any) string {
+	change := "updated"
+	switch {
+	case row["is_deleted"] == true:
+		change = "deleted"
+	case previous == nil:
+		change = "created"
+	case previous["is_deleted"] == true:
+		change = "restored"
+	}
+	return fmt.Sprintf("%s %s v%d %s", table, id, version, change)
+}
+
+// changedFields lists the columns that differ from previous to row, one
+// "column: old → new" line each, or every column for a first version.
+func changedFields(columns []string, previous, row map[string]any) string {
+	var lines []string
+	for _, c := range columns {
+		cur := fmt.Sprint(row[c])
+		if previous == nil {
+			lines = append(lines, fmt.Sprintf("%s: %s", c, cur))
+			continue
+		}
+		if old := fmt.Sprint(previous[c]); old != cur {
+			lines = append(lines, fmt.Sprintf("%s: %s → %s", c, old, cur))
+		}
+	}
+	if len(lines) == 0 {
+		return "No field changed."
+	}
+	return strings.Join(lines, "\n")
+}
+
+func feedTime(v any) time.Time {
+	switch t := v.(type) {
+	case time.Time:
+		return t.UTC()
+	case *time.Time:
+		if t != nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
+
+// baseURL is the scheme and host the request was addressed to, honouring
+// X-Forwarded-Proto from a proxy in front of the server.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if p := r.Header.Get("X-Forwarded-Proto"); p != "" {
+		scheme = p
+	}
+	return scheme + "://" + r.Host
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  *atomPerson `xml:"author,omitempty"`
+	Content atomText    `xml:"content"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomPerson  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+func writeAtom(w http.ResponseWriter, title, self string, entries []feedEntry) {
+	f := atomFeed{ID: self, Title: title, Updated: feedUpdated(entries).Format(time.RFC3339),
+		Links: []atomLink{{Href: self, Rel: "self"}}, Author: atomPerson{Name: "scd"}}
+	for _, e := range entries {
+		entry := atomEntry{ID: e.ID, Title: e.Title, Updated: e.Updated.Format(time.RFC3339),
+			Link: atomLink{Href: e.URL}, Content: atomText{Type: "text", Body: e.Content}}
+		if e.Author != "" {
+			entry.Author = &atomPerson{Name: e.Author}
+		}
+		f.Entries = append(f.Entries, entry)
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, xml.Header)
+	xml.NewEncoder(w).Encode(f)
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	ContentText   string           `json:"content_text"`
+	DatePublished string           `json:"date_published"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+}
+
+func writeJSONFeed(w http.ResponseWriter, title, self string, entries []feedEntry) {
+	items := make([]jsonFeedItem, len(entries))
+	for i, e := range entries {
+		items[i] = jsonFeedItem{ID: e.ID, URL: e.URL, Title: e.Title, ContentText: e.Content, DatePublished: e.Updated.Format(time.RFC3339)}
+		if e.Author != "" {
+			items[i].Authors = []jsonFeedAuthor{{Name: e.Author}}
+		}
+	}
+	w.Header().Set("Content-Type", "application/feed+json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]any{
+		"version":  "https://jsonfeed.org/version/1.1",
+		"title":    title,
+		"feed_url": self,
+		"items":    items,
+	})
+}
+
+// feedUpdated is the time of the newest entry, or now for an empty feed.
+func feedUpdated(entries []feedEntry) time.Time {
+	if len(entries) == 0 {
+		return time.Now().UTC()
+	}
+	return entries[0].Updated
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/yourorg/Go/periods"
+)
+
+// listPeriods returns every payroll period: GET /periods.
+func (s *Server) listPeriods(w http.ResponseWriter, r *http.Request) {
+	out, err := periods.List(r.Context(), s.DB)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	if out == nil {
+		out = []periods.Period{}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// closePeriod closes a payroll period, creating it if needed:
+// POST /periods/{id}/close with a body of {"from": ..., "to": ...}, RFC 3339
+// times bounding the work it covers, to exclusive. The X-Actor header is
+// recorded as closing it.
+func (s *Server) closePeriod(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPatchBytes)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("period body: %w", err))
+		return
+	}
+	p, err := periods.Close(r.Context(), s.DB, r.PathValue("id"), body.From, body.To)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// reopenPeriod reopens a closed payroll period: POST /periods/{id}/reopen.
+func (s *Server) reopenPeriod(w http.ResponseWriter, r *http.Request) {
+	p, err := periods.Reopen(r.Context(), s.DB, r.PathValue("id"))
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
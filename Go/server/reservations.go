@@ -0,0 +1,102 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultReservationTTL = 15 * time.Minute
+	maxReservationTTL     = 7 * 24 * time.Hour
+)
+
+// reserveVersion reserves the next version of one entity for an offline
+// edit: POST /{table}/{id}/reservations?ttl=, default 15 minutes, responding
+// 201 with the reservation. See scd.ReserveVersion.
+func (s *Server) reserveVersion(w http.ResponseWriter, r *http.Request) {
+	model, _, ok := s.model(w, r)
+	if !ok {
+		return
+	}
+	ttl := defaultReservationTTL
+	if v := r.URL.Query().Get("ttl"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > maxReservationTTL {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("ttl must be a positive duration up to %s", maxReservationTTL))
+			return
+		}
+		ttl = d
+	}
+	res, err := scd.ReserveVersion(r.Context(), s.DB, model, r.PathValue("id"), ttl)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, res)
+}
+
+// commitReservation writes the version reserved under a token:
+// POST /{table}/{id}/reservations/{token}/commit, with a JSON Merge Patch or
+// JSON Patch body as for PATCH, applied to the version the reservation was
+// based on. It fails with 409 when another writer created the version first
+// or the reservation expired, using the reservation up either way.
+func (s *Server) commitReservation(w http.ResponseWriter, r *http.Request) {
+	model, table, ok := s.model(w, r)
+	if !ok {
+		return
+	}
+	applyIf := scd.MergePatchIf
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch ct {
+	case "application/merge-patch+json", "application/json":
+	case "application/json-patch+json":
+		applyIf = scd.JSONPatchIf
+	default:
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("content type %q is not a merge patch or JSON patch", ct))
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchBytes))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	id := r.PathValue("id")
+	var version int
+	err = scd.CommitReservation(r.Context(), s.DB, r.PathValue("token"), func(tx *gorm.DB, res scd.VersionReservation) error {
+		if res.Table != table || res.EntityID != id {
+			return fmt.Errorf("reservation of %s %s: %w", table, id, scd.ErrNotFound)
+		}
+		created, err := applyIf(r.Context(), tx, model, id, res.BaseVersion, body)
+		if err != nil {
+			return err
+		}
+		version = created.(scd.Entity).GetVersion()
+		return nil
+	})
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	var row map[string]any
+	err = scd.AllVersions(r.Context(), s.DB, model).Where("id = ? AND version = ?", id, version).Take(&row).Error
+	writeRow(w, r, row, err)
+}
+
+// abandonReservation drops a reservation without writing anything:
+// DELETE /{table}/{id}/reservations/{token}.
+func (s *Server) abandonReservation(w http.ResponseWriter, r *http.Request) {
+	if _, _, ok := s.model(w, r); !ok {
+		return
+	}
+	if err := scd.AbandonReservation(r.Context(), s.DB, r.PathValue("token")); err != nil {
+		writeFailure(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,149 @@
+// Package server exposes the SCD tables over HTTP.
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/yourorg/Go/changefeed"
+	"github.com/yourorg/Go/exportjob"
+	"github.com/yourorg/Go/periods"
+	"github.com/yourorg/Go/scd"
+	"github.com/yourorg/Go/scderr"
+	"github.com/yourorg/Go/slo"
+	"github.com/yourorg/Go/usage"
+	"gorm.io/gorm"
+)
+
+// Server holds the dependencies shared by all handlers.
+type Server struct {
+	DB *gorm.DB
+	// Models maps the table names served under /{table} to their versioned models.
+	Models  map[string]any
+	Exports *exportjob.Manager
+	// SLO, when set, is reported under GET /slo.
+	SLO *slo.Tracker
+	// Usage, when set, is reported under GET /usage.
+	Usage *usage.Tracker
+	// Watcher, when set and running, serves GET /{table}/{id}/watch.
+	Watcher *changefeed.Watcher
+	// Reservations serves /{table}/{id}/reservations; the reservations table
+	// must be migrated (see scd.MigrateReservations).
+	Reservations bool
+	// Periods serves the payroll periods under /periods; the periods table
+	// must be migrated (see periods.Migrate).
+	Periods bool
+}
+
+// Handler returns the HTTP routes served by s.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /models", s.listModels)
+	mux.HandleFunc("POST /exports", s.createExport)
+	mux.HandleFunc("GET /exports/{id}", s.getExport)
+	mux.HandleFunc("GET /exports/{id}/download", s.downloadExport)
+	if s.SLO != nil {
+		mux.Handle("GET /slo", s.SLO.Handler())
+	}
+	if s.Usage != nil {
+		mux.Handle("GET /usage", s.Usage.Handler())
+	}
+	mux.HandleFunc("GET /contractors/{id}/statement", s.contractorStatement)
+	if s.Periods {
+		mux.HandleFunc("GET /periods", s.listPeriods)
+		mux.HandleFunc("POST /periods/{id}/close", s.closePeriod)
+		mux.HandleFunc("POST /periods/{id}/reopen", s.reopenPeriod)
+	}
+	mux.HandleFunc("GET /feeds/{table}", s.feed)
+	mux.HandleFunc("GET /{table}", s.listLatest)
+	mux.HandleFunc("GET /{table}/{id}", s.getLatest)
+	mux.HandleFunc("PATCH /{table}/{id}", s.patch)
+	mux.HandleFunc("GET /{table}/{id}/versions", s.listVersions)
+	mux.HandleFunc("GET /{table}/{id}/timeline", s.timeline)
+	mux.HandleFunc("GET /{table}/{id}/feed", s.feed)
+	mux.HandleFunc("GET /{table}/{id}/scheduled", s.listScheduled)
+	mux.HandleFunc("DELETE /{table}/{id}/scheduled/{change}", s.cancelScheduled)
+	if s.Watcher != nil {
+		mux.HandleFunc("GET /{table}/{id}/watch", s.watch)
+	}
+	if s.Reservations {
+		mux.HandleFunc("POST /{table}/{id}/reservations", s.reserveVersion)
+		mux.HandleFunc("POST /{table}/{id}/reservations/{token}/commit", s.commitReservation)
+		mux.HandleFunc("DELETE /{table}/{id}/reservations/{token}", s.abandonReservation)
+	}
+	mux.HandleFunc("GET /{table}/{id}/versions/{version}", s.getVersion)
+	mux.HandleFunc("POST /{table}/{id}/versions/{version}/submit", s.transition(scd.SubmitDraft))
+	mux.HandleFunc("POST /{table}/{id}/versions/{version}/approve", s.transition(scd.ApproveVersion))
+	mux.HandleFunc("POST /{table}/{id}/versions/{version}/reject", s.transition(scd.RejectVersion))
+	return audit(mux)
+}
+
+// audit carries the X-Actor and X-Change-Reason request headers into the
+// request context, so the versions written for the request record them with
+// "api" as their source (see scd.WithActor). X-Period-Adjustment: true marks
+// the request's writes as adjustments of closed payroll periods (see
+// periods.WithAdjustment), and X-Service tags the repository calls it makes
+// for usage tracking (see usage.WithService). The headers are taken as
+// sent; deployments set them in the authenticating proxy in front of the
+// server.
+func audit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := scd.WithSource(r.Context(), "api")
+		if actor := r.Header.Get("X-Actor"); actor != "" {
+			ctx = scd.WithActor(ctx, actor)
+		}
+		if reason := r.Header.Get("X-Change-Reason"); reason != "" {
+			ctx = scd.WithChangeReason(ctx, reason)
+		}
+		if r.Header.Get("X-Period-Adjustment") == "true" {
+			ctx = periods.WithAdjustment(ctx)
+		}
+		if service := r.Header.Get("X-Service"); service != "" {
+			ctx = usage.WithService(ctx, service)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// listModels describes the registered models served under /{table}.
+func (s *Server) listModels(w http.ResponseWriter, r *http.Request) {
+	var out []scd.ModelDescriptor
+	for _, d := range scd.RegisteredModels() {
+		if _, ok := s.Models[d.Table]; ok {
+			out = append(out, d)
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// apiError is the body of every error response. Code and Retryable follow
+// the scderr taxonomy, so clients need not interpret the status or message.
+type apiError struct {
+	Error     string      `json:"error"`
+	Code      scderr.Code `json:"code"`
+	Retryable bool        `json:"retryable"`
+}
+
+// writeError responds with status. The code is taken from err when it is
+// classified, and derived from status otherwise.
+func writeError(w http.ResponseWriter, status int, err error) {
+	code := scderr.CodeOf(err)
+	if code == scderr.Internal && status < 500 {
+		code = scderr.FromStatus(status)
+	}
+	writeJSON(w, status, apiError{Error: err.Error(), Code: code, Retryable: code.Retryable()})
+}
+
+// writeFailure responds to a failed operation with the status its
+// classification calls for, e.g. 404 for scd.ErrNotFound or 503 for a
+// serialization failure.
+func writeFailure(w http.ResponseWriter, err error) {
+	err = scd.Translate(err)
+	writeError(w, scderr.CodeOf(err).HTTPStatus(), err)
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/yourorg/Go/statement"
+)
+
+// contractorStatement returns a contractor's statement for one month, UTC:
+// GET /contractors/{id}/statement?month=2024-01, default the current month.
+// Jobs, timesheet and line items are read in one snapshot; see
+// statement.Build.
+func (s *Server) contractorStatement(w http.ResponseWriter, r *http.Request) {
+	month := time.Now().UTC()
+	if v := r.URL.Query().Get("month"); v != "" {
+		var err error
+		if month, err = time.Parse("2006-01", v); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("month must look like 2024-01: %w", err))
+			return
+		}
+	}
+	id := r.PathValue("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, errors.New("contractor id is required"))
+		return
+	}
+	st, err := statement.Build(r.Context(), s.DB, id, month)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
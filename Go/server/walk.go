@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// snapshotCursorPrefix marks the cursors of pinned walks, which encode the
+// pinned time along with the last id.
+const snapshotCursorPrefix = "snap."
+
+// pageWalk is where a page of a listing starts: after the id after, and for
+// walks pinned with snapshot=true, as of asOf.
+type pageWalk struct {
+	after string
+	asOf  time.Time
+}
+
+// parseWalk reads the walk of r from its cursor, or starts one pinned to now
+// (or to as_of) when r asks for snapshot=true.
+//
+// The pin uses the server clock, which also stamps created_at; a version
+// whose transaction was still open at the pin can commit with an earlier
+// created_at and so show up on a later page.
+func parseWalk(r *http.Request) (pageWalk, error) {
+	query := r.URL.Query()
+	cursor := query.Get("cursor")
+	if rest, ok := strings.CutPrefix(cursor, snapshotCursorPrefix); ok {
+		return decodeSnapshotCursor(rest)
+	}
+	walk := pageWalk{after: cursor}
+	if query.Get("snapshot") != "true" {
+		return walk, nil
+	}
+	walk.asOf = time.Now().UTC()
+	if v := query.Get("as_of"); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return walk, fmt.Errorf("as_of: %w", err)
+		}
+		walk.asOf = t
+	}
+	return walk, nil
+}
+
+func (w pageWalk) pinned() bool { return !w.asOf.IsZero() }
+
+// cursor is the cursor of the page following the one ending with id.
+func (w pageWalk) cursor(id string) string {
+	if !w.pinned() {
+		return id
+	}
+	return snapshotCursorPrefix + base64.RawURLEncoding.EncodeToString([]byte(w.asOf.Format(time.RFC3339Nano)+"|"+id))
+}
+
+func decodeSnapshotCursor(s string) (pageWalk, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageWalk{}, fmt.Errorf("cursor: %w", err)
+	}
+	at, after, ok := strings.Cut(string(b), "|")
+	if !ok {
+		return pageWalk{}, errors.New("cursor: malformed snapshot cursor")
+	}
+	t, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return pageWalk{}, fmt.Errorf("cursor: %w", err)
+	}
+	return pageWalk{after: after, asOf: t}, nil
+}
@@ -0,0 +1,262 @@
+// Package slo tracks latency objectives for the SCD layer: how many version
+// creates and reads finish within their target, and how much of the error
+// budget that leaves over a rolling window. Reports are published through
+// expvar and an HTTP status handler that release gates can poll.
+package slo
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+// Operations tracked by a Tracker installed on a DB.
+const (
+	OpCreate = "create"
+	OpRead   = "read"
+)
+
+// Objective requires Percentile of operations, e.g. 0.99, to succeed within
+// Threshold. Slower and failed operations spend the error budget.
+type Objective struct {
+	Percentile float64       `json:"percentile"`
+	Threshold  time.Duration `json:"threshold"`
+}
+
+// Tracker measures operations against their objectives over a rolling Window.
+type Tracker struct {
+	Create Objective
+	Read   Objective
+	// Window is the rolling period reports cover (default 1h).
+	Window time.Duration
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// slots is the number of buckets a window is divided into; observations
+// age out one slot at a time.
+const slots = 60
+
+// latencyBounds are the upper bounds of the latency histogram, doubling from 100µs.
+var latencyBounds = func() []time.Duration {
+	bounds := make([]time.Duration, 21)
+	for i := range bounds {
+		bounds[i] = 100 * time.Microsecond << i
+	}
+	return bounds
+}()
+
+type slot struct {
+	epoch     int64
+	count     int64
+	bad       int64
+	histogram [22]int64
+}
+
+type series struct {
+	objective Objective
+	slots     [slots]slot
+}
+
+// OpReport is the state of one operation's objective over the window.
+type OpReport struct {
+	Op        string    `json:"op"`
+	Objective Objective `json:"objective"`
+	Count     int64     `json:"count"`
+	Bad       int64     `json:"bad"`
+	// Latency is the observed latency at the objective's percentile, rounded
+	// up to the histogram bucket it falls in.
+	Latency time.Duration `json:"latency"`
+	// BudgetRemaining is the unspent fraction of the error budget: 1 with no
+	// bad operations, 0 when the objective is exactly met, negative when missed.
+	BudgetRemaining float64 `json:"budget_remaining"`
+	Healthy         bool    `json:"healthy"`
+}
+
+// Report covers every tracked operation.
+type Report struct {
+	Window  time.Duration `json:"window"`
+	Healthy bool          `json:"healthy"`
+	Ops     []OpReport    `json:"ops"`
+}
+
+// Install registers callbacks on db that time creates and queries of the
+// registered versioned tables.
+func (t *Tracker) Install(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:begin_transaction").Register("slo:start", start); err != nil {
+		return err
+	}
+	if err := cb.Create().After("gorm:commit_or_rollback_transaction").Register("slo:observe", t.observer(OpCreate)); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("slo:start", start); err != nil {
+		return err
+	}
+	return cb.Query().After("gorm:after_query").Register("slo:observe", t.observer(OpRead))
+}
+
+const startKey = "slo:start"
+
+func start(tx *gorm.DB) {
+	tx.InstanceSet(startKey, time.Now())
+}
+
+func (t *Tracker) observer(op string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet(startKey)
+		if !ok || !versioned(tx.Statement) {
+			return
+		}
+		t.Observe(op, time.Since(v.(time.Time)), tx.Error != nil && tx.Error != gorm.ErrRecordNotFound)
+	}
+}
+
+func versioned(stmt *gorm.Statement) bool {
+	table := stmt.Table
+	if table == "" && stmt.Schema != nil {
+		table = stmt.Schema.Table
+	}
+	_, ok := scd.LookupModel(table)
+	return ok
+}
+
+// Observe records one operation taking d; failed operations always count as bad.
+func (t *Tracker) Observe(op string, d time.Duration, failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.seriesFor(op)
+	if s == nil {
+		return
+	}
+	epoch := time.Now().UnixNano() / int64(t.slotWidth())
+	sl := &s.slots[epoch%slots]
+	if sl.epoch != epoch {
+		*sl = slot{epoch: epoch}
+	}
+	sl.count++
+	if failed || d > s.objective.Threshold {
+		sl.bad++
+	}
+	i := 0
+	for i < len(latencyBounds) && d > latencyBounds[i] {
+		i++
+	}
+	sl.histogram[i]++
+}
+
+// Report summarizes the window ending now.
+func (t *Tracker) Report() Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r := Report{Window: t.window(), Healthy: true}
+	oldest := time.Now().UnixNano()/int64(t.slotWidth()) - slots + 1
+	for _, op := range []string{OpCreate, OpRead} {
+		s := t.seriesFor(op)
+		if s == nil {
+			continue
+		}
+		rep := OpReport{Op: op, Objective: s.objective, BudgetRemaining: 1, Healthy: true}
+		var histogram [22]int64
+		for _, sl := range s.slots {
+			if sl.epoch < oldest {
+				continue
+			}
+			rep.Count += sl.count
+			rep.Bad += sl.bad
+			for i, n := range sl.histogram {
+				histogram[i] += n
+			}
+		}
+		if rep.Count > 0 {
+			rep.Latency = percentile(histogram, rep.Count, s.objective.Percentile)
+			if allowed := (1 - s.objective.Percentile) * float64(rep.Count); allowed > 0 {
+				rep.BudgetRemaining = 1 - float64(rep.Bad)/allowed
+			} else if rep.Bad > 0 {
+				rep.BudgetRemaining = -1
+			}
+			rep.Healthy = rep.BudgetRemaining >= 0
+		}
+		r.Healthy = r.Healthy && rep.Healthy
+		r.Ops = append(r.Ops, rep)
+	}
+	return r
+}
+
+// Handler serves the Report as JSON, with status 503 while an objective is missed.
+func (t *Tracker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := t.Report()
+		status := http.StatusOK
+		if !report.Healthy {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+var publishOnce sync.Once
+
+// Publish exposes the Report through expvar under "scd_slo". Only the first
+// Tracker published in a process is exposed.
+func (t *Tracker) Publish() {
+	publishOnce.Do(func() {
+		expvar.Publish("scd_slo", expvar.Func(func() any { return t.Report() }))
+	})
+}
+
+// seriesFor returns the series of op, or nil when op has no objective.
+func (t *Tracker) seriesFor(op string) *series {
+	if s, ok := t.series[op]; ok {
+		return s
+	}
+	objective := t.Create
+	if op == OpRead {
+		objective = t.Read
+	}
+	if objective.Threshold <= 0 || objective.Percentile <= 0 || objective.Percentile > 1 {
+		return nil
+	}
+	if t.series == nil {
+		t.series = map[string]*series{}
+	}
+	s := &series{objective: objective}
+	t.series[op] = s
+	return s
+}
+
+func (t *Tracker) window() time.Duration {
+	if t.Window <= 0 {
+		return time.Hour
+	}
+	return t.Window
+}
+
+func (t *Tracker) slotWidth() time.Duration {
+	return max(t.window()/slots, time.Nanosecond)
+}
+
+// percentile returns the upper bound of the histogram bucket holding the
+// p-th fraction of count observations.
+func percentile(histogram [22]int64, count int64, p float64) time.Duration {
+	rank := int64(p * float64(count))
+	var seen int64
+	for i, n := range histogram {
+		seen += n
+		if seen >= rank && n > 0 || i == len(histogram)-1 {
+			if i < len(latencyBounds) {
+				return latencyBounds[i]
+			}
+			break
+		}
+	}
+	return latencyBounds[len(latencyBounds)-1]
+}
@@ -0,0 +1,35 @@
+package slo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yourorg/Go/slo"
+)
+
+func TestReportErrorBudget(t *testing.T) {
+	tracker := &slo.Tracker{Create: slo.Objective{Percentile: 0.9, Threshold: 10 * time.Millisecond}}
+	for range 18 {
+		tracker.Observe(slo.OpCreate, time.Millisecond, false)
+	}
+	tracker.Observe(slo.OpCreate, 50*time.Millisecond, false)
+	tracker.Observe(slo.OpRead, 50*time.Millisecond, false) // no read objective
+
+	report := tracker.Report()
+	if len(report.Ops) != 1 || report.Ops[0].Op != slo.OpCreate {
+		t.Fatalf("ops = %+v, want create only", report.Ops)
+	}
+	create := report.Ops[0]
+	if create.Count != 19 || create.Bad != 1 {
+		t.Fatalf("count, bad = %d, %d, want 19, 1", create.Count, create.Bad)
+	}
+	if !create.Healthy || create.BudgetRemaining <= 0 || create.BudgetRemaining >= 1 {
+		t.Fatalf("budget remaining = %v, want partially spent", create.BudgetRemaining)
+	}
+
+	tracker.Observe(slo.OpCreate, time.Millisecond, true)
+	tracker.Observe(slo.OpCreate, time.Millisecond, true)
+	if report := tracker.Report(); report.Healthy {
+		t.Fatalf("report healthy after budget exhausted: %+v", report.Ops[0])
+	}
+}
@@ -0,0 +1,164 @@
+// Package snapshot materializes plain, non-versioned tables from the latest
+// versions of an SCD model, for consumers that cannot handle versioned schemas
+// or for teams leaving the abstraction. Snapshots can be kept current by
+// following the scd change log.
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Cursor records how far a snapshot table has consumed the change log.
+type Cursor struct {
+	Target    string `gorm:"primaryKey;column:target"`
+	Seq       int64  `gorm:"column:seq"`
+	UpdatedAt time.Time
+}
+
+func (Cursor) TableName() string { return "scd_snapshot_cursors" }
+
+// Lookback is how many sequence numbers below the cursor Sync re-reads.
+// Sequence numbers are assigned at insert but become visible at commit, so an
+// entry committing late can land below entries already consumed; re-reading
+// the window catches it, as changefeed.Options.Lookback does. The upsert is
+// idempotent, so re-applying entries is harmless.
+const Lookback = 100
+
+// Materialize (re)builds target as a plain table holding id plus the business
+// columns of the latest version of every entity of model. The swap happens in
+// one transaction, so readers never observe a half-built table.
+func Materialize(ctx context.Context, db *gorm.DB, model any, target string) error {
+	info, err := scd.Describe(db, model)
+	if err != nil {
+		return err
+	}
+	build := target + "_build"
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&Cursor{}); err != nil {
+			return err
+		}
+		seq, err := changeLogPosition(ctx, tx)
+		if err != nil {
+			return err
+		}
+		create := fmt.Sprintf(
+			"CREATE TABLE %s AS SELECT cur.id, %s FROM %s cur JOIN (?) AS latest ON cur.id = latest.id AND cur.version = latest.max_version",
+			build, info.ColumnList("cur"), tx.Statement.Quote(info.Table))
+		stmts := []struct {
+			sql  string
+			args []any
+		}{
+			{"DROP TABLE IF EXISTS " + build, nil},
+			{create, []any{scd.LatestSubquery(ctx, tx, model)}},
+			{fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (id)", build), nil},
+			{"DROP TABLE IF EXISTS " + target, nil},
+			{fmt.Sprintf("ALTER TABLE %s RENAME TO %s", build, target), nil},
+		}
+		for _, s := range stmts {
+			if err := tx.Exec(s.sql, s.args...).Error; err != nil {
+				return fmt.Errorf("materializing %s failed: %w", target, err)
+			}
+		}
+		return saveCursor(tx, target, seq)
+	})
+}
+
+// Sync applies change log entries recorded since the last Materialize or Sync
+// to target, upserting the current latest row of every touched entity, along
+// with those of the Lookback entries before them. It consumes at most batch
+// entries and returns how many it consumed, not counting those re-read.
+func Sync(ctx context.Context, db *gorm.DB, model any, target string, batch int) (int, error) {
+	info, err := scd.Describe(db, model)
+	if err != nil {
+		return 0, err
+	}
+	db = db.WithContext(ctx)
+	var cursor Cursor
+	if err := db.Where("target = ?", target).First(&cursor).Error; err != nil {
+		return 0, fmt.Errorf("loading snapshot cursor for %s failed (run Materialize first): %w", target, err)
+	}
+	entries, err := scd.ChangesSince(ctx, db, info.Table, max(cursor.Seq-Lookback, 0), batch+Lookback)
+	if err != nil || len(entries) == 0 {
+		return 0, err
+	}
+	consumed, seq := 0, cursor.Seq
+	for _, e := range entries {
+		if e.Seq > cursor.Seq {
+			consumed++
+			seq = max(seq, e.Seq)
+		}
+	}
+
+	seen := make(map[string]bool, len(entries))
+	var ids []string
+	for _, e := range entries {
+		if !seen[e.EntityID] {
+			seen[e.EntityID] = true
+			ids = append(ids, e.EntityID)
+		}
+	}
+	updates := make([]string, len(info.Columns))
+	for i, c := range info.Columns {
+		updates[i] = fmt.Sprintf("%s = EXCLUDED.%s", c, c)
+	}
+	upsert := fmt.Sprintf(
+		`INSERT INTO %s (id, %s)
+		SELECT cur.id, %s FROM %s cur JOIN (?) AS latest ON cur.id = latest.id AND cur.version = latest.max_version
+		WHERE cur.id IN ?
+		ON CONFLICT (id) DO UPDATE SET %s`,
+		target, info.ColumnList(""), info.ColumnList("cur"), db.Statement.Quote(info.Table), strings.Join(updates, ", "))
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(upsert, scd.LatestSubquery(ctx, tx, model), ids).Error; err != nil {
+			return fmt.Errorf("syncing %s failed: %w", target, err)
+		}
+		return saveCursor(tx, target, seq)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return consumed, nil
+}
+
+// Follow calls Sync every interval until ctx is cancelled, draining the change
+// log in batches whenever it falls behind.
+func Follow(ctx context.Context, db *gorm.DB, model any, target string, interval time.Duration) error {
+	const batch = 500
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for {
+			n, err := Sync(ctx, db, model, target, batch)
+			if err != nil {
+				return err
+			}
+			if n < batch {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func changeLogPosition(ctx context.Context, tx *gorm.DB) (int64, error) {
+	if !tx.Migrator().HasTable(&scd.ChangeLogEntry{}) {
+		return 0, nil
+	}
+	return scd.LatestSeq(ctx, tx)
+}
+
+func saveCursor(tx *gorm.DB, target string, seq int64) error {
+	cursor := Cursor{Target: target, Seq: seq, UpdatedAt: time.Now()}
+	return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&cursor).Error
+}
@@ -0,0 +1,144 @@
+// Package statement assembles a contractor's monthly statement: the jobs
+// they hold with the rates in effect, the timesheet priced at the rate of
+// each piece of work, and the payment line items with their statuses, all
+// read in one snapshot so the parts agree with each other:
+//
+//	st, err := statement.Build(ctx, db, "cont1", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+//	fmt.Printf("%s: %.1fh, earned %.2f, billed %.2f\n", st.Month, st.Totals.Hours, st.Totals.Earned, st.Totals.Billed)
+package statement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/repos"
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+// Statement is one contractor's statement for one calendar month.
+type Statement struct {
+	ContractorID string `json:"contractor_id"`
+	// Month is the statement's month, e.g. "2024-01"; the statement covers
+	// [From, To).
+	Month string    `json:"month"`
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+	// Jobs are the contractor's active jobs.
+	Jobs []Job `json:"jobs"`
+	// Timesheet is the work started within the month, in time order.
+	Timesheet []TimesheetEntry `json:"timesheet"`
+	// LineItems are the payment line items for that work.
+	LineItems []LineItem `json:"line_items"`
+	Totals    Totals     `json:"totals"`
+	// GeneratedAt is when the snapshot the statement was read from was taken.
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// Job is an active job with the rate in effect at the end of the month.
+type Job struct {
+	ID        string  `json:"id"`
+	Version   int     `json:"version"`
+	Title     string  `json:"title"`
+	CompanyID string  `json:"company_id"`
+	Rate      float64 `json:"rate"`
+	// CurrentRate is the job's latest rate, which differs from Rate when it
+	// was changed after the month or takes effect later.
+	CurrentRate float64 `json:"current_rate"`
+}
+
+// TimesheetEntry is one timelog priced at the rate of its job when the work
+// started.
+type TimesheetEntry struct {
+	TimelogID string    `json:"timelog_id"`
+	TimeStart time.Time `json:"time_start"`
+	Hours     float64   `json:"hours"`
+	JobUID    string    `json:"job_uid"`
+	Rate      float64   `json:"rate"`
+	Amount    float64   `json:"amount"`
+}
+
+// LineItem is the latest version of a payment line item.
+type LineItem struct {
+	ID         string  `json:"id"`
+	Version    int     `json:"version"`
+	JobUID     string  `json:"job_uid"`
+	TimelogUID string  `json:"timelog_uid"`
+	Amount     float64 `json:"amount"`
+	Status     string  `json:"status"`
+	// CorrectsUID is set on adjustments, to the line item version they correct.
+	CorrectsUID string `json:"corrects_uid,omitempty"`
+}
+
+// Totals sums up a statement.
+type Totals struct {
+	Hours float64 `json:"hours"`
+	// Earned is the sum of the timesheet amounts.
+	Earned float64 `json:"earned"`
+	// Billed is the sum of the line item amounts, and ByStatus its split by
+	// line item status.
+	Billed   float64            `json:"billed"`
+	ByStatus map[string]float64 `json:"by_status"`
+}
+
+// Build assembles the statement of contractorID for the calendar month
+// containing month, in month's location, in one read-only snapshot (see
+// scd.WithSnapshot).
+func Build(ctx context.Context, db *gorm.DB, contractorID string, month time.Time) (Statement, error) {
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	to := from.AddDate(0, 1, 0)
+	st := Statement{ContractorID: contractorID, Month: from.Format("2006-01"), From: from, To: to,
+		Jobs: []Job{}, Timesheet: []TimesheetEntry{}, LineItems: []LineItem{}, Totals: Totals{ByStatus: map[string]float64{}}}
+	err := scd.WithSnapshot(ctx, db, func(tx *gorm.DB) error {
+		if err := tx.Raw("SELECT now()").Scan(&st.GeneratedAt).Error; err != nil {
+			return fmt.Errorf("taking statement snapshot failed: %w", err)
+		}
+		jobRepo := &repos.JobRepo{DB: tx}
+		jobs, err := jobRepo.FindActiveJobsByContractor(ctx, contractorID)
+		if err != nil {
+			return fmt.Errorf("reading jobs failed: %w", err)
+		}
+		for _, j := range jobs {
+			effective, err := jobRepo.FindJobEffectiveAt(ctx, j.ID, to.Add(-time.Nanosecond))
+			switch {
+			case errors.Is(err, scd.ErrNotFound):
+				// Jobs started after the month have no rate in it yet.
+				effective = j
+			case err != nil:
+				return fmt.Errorf("reading rate of job %s failed: %w", j.ID, err)
+			}
+			st.Jobs = append(st.Jobs, Job{ID: j.ID, Version: j.Version, Title: j.Title, CompanyID: j.CompanyID,
+				Rate: effective.Rate, CurrentRate: j.Rate})
+		}
+
+		amounts, err := (&repos.TimelogRepo{DB: tx}).FindAmountsAtWorkTime(ctx, contractorID, from, to)
+		if err != nil {
+			return fmt.Errorf("reading timesheet failed: %w", err)
+		}
+		for _, a := range amounts {
+			st.Timesheet = append(st.Timesheet, TimesheetEntry(a))
+			st.Totals.Hours += a.Hours
+			st.Totals.Earned += a.Amount
+		}
+
+		items, err := (&repos.PaymentLineItemRepo{DB: tx}).FindLineItemsByContractorAndPeriod(ctx, contractorID, from, to)
+		if err != nil {
+			return fmt.Errorf("reading line items failed: %w", err)
+		}
+		for _, li := range items {
+			st.LineItems = append(st.LineItems, lineItem(li))
+			st.Totals.Billed += li.Amount
+			st.Totals.ByStatus[li.Status] += li.Amount
+		}
+		return nil
+	})
+	return st, err
+}
+
+func lineItem(li models.PaymentLineItem) LineItem {
+	return LineItem{ID: li.ID, Version: li.Version, JobUID: li.JobUID, TimelogUID: li.TimelogUID, Amount: li.Amount, Status: li.Status,
+		CorrectsUID: li.CorrectsUID}
+}
@@ -0,0 +1,395 @@
+// Package usage records which repository methods run queries in production:
+// for which service and caller, with which filters, how often and how long.
+// A Tracker installed on a DB attributes every query to the outermost method
+// of package repos on its call stack, so finders delegating to SCDRepo are
+// counted as themselves:
+//
+//	t := &usage.Tracker{}
+//	if err := t.Install(db); err != nil { ... }
+//	t.Publish() // "scd_usage": {"methods": [{"method": "JobRepo.FindActiveJobsByCompany", "queries": 42}, ...], ...}
+//
+// The report lists every exported method of the tracked repositories, those
+// never called with zero queries, to find dead finders before refactoring,
+// and the query shapes that actually run, to target indexes at them.
+package usage
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/repos"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Tracker aggregates the queries run by repository methods.
+type Tracker struct {
+	// Repos are the repositories whose exported methods are reported, called
+	// or not (default: the job, timelog, line item, settings and generic
+	// repositories).
+	Repos []any
+	// MaxQueries bounds the distinct query shapes kept (default 1000);
+	// queries of further shapes are only counted as dropped.
+	MaxQueries int
+
+	mu      sync.Mutex
+	since   time.Time
+	queries map[string]*QueryUsage
+	dropped int64
+}
+
+// QueryUsage is one shape of query a repository method ran: the same
+// method, service, caller, table and filters.
+type QueryUsage struct {
+	Method string `json:"method"`
+	// Service is the tag of the calling service (see WithService), Caller
+	// the function outside package repos that called Method.
+	Service string `json:"service,omitempty"`
+	Caller  string `json:"caller"`
+	Table   string `json:"table"`
+	// Filters are the conditions of the query's WHERE clause without their
+	// values, e.g. "jobs.status =" or "timelogs.time_start >= ?".
+	Filters    []string      `json:"filters"`
+	Calls      int64         `json:"calls"`
+	Errors     int64         `json:"errors"`
+	TotalTime  time.Duration `json:"total_time"`
+	LastCalled time.Time     `json:"last_called"`
+}
+
+// MethodUsage sums the queries of one repository method.
+type MethodUsage struct {
+	Method     string     `json:"method"`
+	Queries    int64      `json:"queries"`
+	LastCalled *time.Time `json:"last_called,omitempty"`
+}
+
+// Report is the usage recorded since the Tracker was installed.
+type Report struct {
+	Since   time.Time     `json:"since"`
+	Methods []MethodUsage `json:"methods"`
+	Queries []QueryUsage  `json:"queries"`
+	// Dropped counts the queries of shapes beyond MaxQueries.
+	Dropped int64 `json:"dropped,omitempty"`
+}
+
+type serviceKey struct{}
+
+// WithService tags the repository calls made with ctx as made by service,
+// e.g. "billing-worker".
+func WithService(ctx context.Context, service string) context.Context {
+	return context.WithValue(ctx, serviceKey{}, service)
+}
+
+// ServiceFrom returns the service tag of ctx, or "".
+func ServiceFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	s, _ := ctx.Value(serviceKey{}).(string)
+	return s
+}
+
+const startKey = "usage:start"
+
+// Install registers callbacks on db timing every query, create, update and
+// delete, and recording those run by repository methods.
+func (t *Tracker) Install(db *gorm.DB) error {
+	t.mu.Lock()
+	t.since = time.Now().UTC()
+	t.mu.Unlock()
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Query().Before("*").Register("usage:start", start),
+		cb.Query().After("*").Register("usage:record", t.record),
+		cb.Row().Before("*").Register("usage:start", start),
+		cb.Row().After("*").Register("usage:record", t.record),
+		cb.Create().Before("*").Register("usage:start", start),
+		cb.Create().After("*").Register("usage:record", t.record),
+		cb.Update().Before("*").Register("usage:start", start),
+		cb.Update().After("*").Register("usage:record", t.record),
+		cb.Delete().Before("*").Register("usage:start", start),
+		cb.Delete().After("*").Register("usage:record", t.record),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func start(tx *gorm.DB) {
+	tx.InstanceSet(startKey, time.Now())
+}
+
+func (t *Tracker) record(tx *gorm.DB) {
+	// Subqueries are rendered by running the query callbacks dry.
+	v, ok := tx.InstanceGet(startKey)
+	if !ok || tx.DryRun {
+		return
+	}
+	method, caller := callerOf()
+	if method == "" {
+		return
+	}
+	stmt := tx.Statement
+	table := stmt.Table
+	if table == "" && stmt.Schema != nil {
+		table = stmt.Schema.Table
+	}
+	t.Observe(QueryUsage{Method: method, Service: ServiceFrom(stmt.Context), Caller: caller, Table: table,
+		Filters: filtersOf(stmt, table)}, time.Since(v.(time.Time)), tx.Error != nil && tx.Error != gorm.ErrRecordNotFound)
+}
+
+// Observe records one query of shape q taking d; Calls, Errors and the
+// times of q are ignored.
+func (t *Tracker) Observe(q QueryUsage, d time.Duration, failed bool) {
+	key := strings.Join(append([]string{q.Method, q.Service, q.Caller, q.Table}, q.Filters...), "\x00")
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	u, ok := t.queries[key]
+	if !ok {
+		if t.queries == nil {
+			t.queries = map[string]*QueryUsage{}
+		}
+		if len(t.queries) >= t.maxQueries() {
+			t.dropped++
+			return
+		}
+		u = &QueryUsage{Method: q.Method, Service: q.Service, Caller: q.Caller, Table: q.Table, Filters: q.Filters}
+		t.queries[key] = u
+	}
+	u.Calls++
+	if failed {
+		u.Errors++
+	}
+	u.TotalTime += d
+	u.LastCalled = time.Now().UTC()
+}
+
+// Report returns the usage recorded so far: every method of Repos and every
+// other method seen, by name, and the query shapes, most called first.
+func (t *Tracker) Report() Report {
+	methods := map[string]*MethodUsage{}
+	for _, m := range t.knownMethods() {
+		methods[m] = &MethodUsage{Method: m}
+	}
+	t.mu.Lock()
+	r := Report{Since: t.since, Dropped: t.dropped, Queries: make([]QueryUsage, 0, len(t.queries))}
+	for _, q := range t.queries {
+		r.Queries = append(r.Queries, *q)
+	}
+	t.mu.Unlock()
+
+	for _, q := range r.Queries {
+		m, ok := methods[q.Method]
+		if !ok {
+			m = &MethodUsage{Method: q.Method}
+			methods[q.Method] = m
+		}
+		m.Queries += q.Calls
+		if m.LastCalled == nil || q.LastCalled.After(*m.LastCalled) {
+			last := q.LastCalled
+			m.LastCalled = &last
+		}
+	}
+	for _, m := range methods {
+		r.Methods = append(r.Methods, *m)
+	}
+	slices.SortFunc(r.Methods, func(a, b MethodUsage) int { return strings.Compare(a.Method, b.Method) })
+	slices.SortFunc(r.Queries, func(a, b QueryUsage) int {
+		if c := cmp.Compare(b.Calls, a.Calls); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Method, b.Method)
+	})
+	return r
+}
+
+// WriteCSV writes the query shapes of the Report as CSV, one per row, the
+// filters joined by " AND ".
+func (t *Tracker) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"method", "service", "caller", "table", "filters", "calls", "errors", "total_ms", "last_called"})
+	for _, q := range t.Report().Queries {
+		cw.Write([]string{q.Method, q.Service, q.Caller, q.Table, strings.Join(q.Filters, " AND "),
+			strconv.FormatInt(q.Calls, 10), strconv.FormatInt(q.Errors, 10),
+			strconv.FormatFloat(float64(q.TotalTime)/float64(time.Millisecond), 'f', 3, 64), q.LastCalled.Format(time.RFC3339)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Handler serves the Report as JSON, or its query shapes as CSV with
+// ?format=csv.
+func (t *Tracker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") == "csv" {
+			w.Header().Set("Content-Type", "text/csv")
+			t.WriteCSV(w)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(t.Report())
+	})
+}
+
+var publishOnce sync.Once
+
+// Publish exposes the Report through expvar under "scd_usage". Only the
+// first Tracker published in a process is exposed.
+func (t *Tracker) Publish() {
+	publishOnce.Do(func() {
+		expvar.Publish("scd_usage", expvar.Func(func() any { return t.Report() }))
+	})
+}
+
+var reposPrefix = reflect.TypeOf(repos.JobRepo{}).PkgPath() + "."
+
+// callerOf returns the outermost method of package repos on the calling
+// goroutine's stack, and the function that called it.
+func callerOf() (method, caller string) {
+	var pcs [64]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs[:])])
+	inRepos := false
+	for {
+		f, more := frames.Next()
+		if strings.HasPrefix(f.Function, reposPrefix) {
+			method, inRepos = methodName(f.Function), true
+		} else if inRepos && !strings.HasPrefix(f.Function, "gorm.io/") {
+			caller, inRepos = trimClosure(f.Function), false
+		}
+		if !more {
+			break
+		}
+	}
+	return method, caller
+}
+
+var closureSuffix = regexp.MustCompile(`(\.func\d+|\.gowrap\d+|\.\d+)+$`)
+
+func trimClosure(fn string) string {
+	return closureSuffix.ReplaceAllString(fn, "")
+}
+
+// methodName turns the function name of a frame in package repos, e.g.
+// "github.com/yourorg/Go/repos.(*SCDRepo[...]).FindLatest.func1", into
+// "SCDRepo.FindLatest".
+func methodName(fn string) string {
+	fn = trimClosure(strings.TrimPrefix(fn, reposPrefix))
+	fn = strings.NewReplacer("(*", "", ")", "", "[...]", "").Replace(fn)
+	return fn
+}
+
+func (t *Tracker) knownMethods() []string {
+	rs := t.Repos
+	if rs == nil {
+		rs = []any{&repos.JobRepo{}, &repos.TimelogRepo{}, &repos.PaymentLineItemRepo{}, &repos.SettingsRepo{},
+			repos.NewSCDRepo[models.Job](nil)}
+	}
+	var out []string
+	for _, r := range rs {
+		typ := reflect.TypeOf(r)
+		if typ.Kind() != reflect.Pointer {
+			typ = reflect.PointerTo(typ)
+		}
+		name, _, _ := strings.Cut(typ.Elem().Name(), "[")
+		for i := range typ.NumMethod() {
+			out = append(out, name+"."+typ.Method(i).Name)
+		}
+	}
+	return out
+}
+
+// filtersOf describes the conditions of stmt's WHERE clause without their
+// values.
+func filtersOf(stmt *gorm.Statement, table string) []string {
+	c, ok := stmt.Clauses["WHERE"]
+	if !ok {
+		return nil
+	}
+	where, ok := c.Expression.(clause.Where)
+	if !ok {
+		return nil
+	}
+	out := make([]string, 0, len(where.Exprs))
+	for _, e := range where.Exprs {
+		out = append(out, describe(e, table))
+	}
+	return out
+}
+
+func describe(e clause.Expression, table string) string {
+	col := func(c any) string {
+		switch c := c.(type) {
+		case clause.Column:
+			if c.Table == clause.CurrentTable {
+				c.Table = table
+			}
+			if c.Table == "" {
+				return c.Name
+			}
+			return c.Table + "." + c.Name
+		case string:
+			return c
+		}
+		return fmt.Sprint(c)
+	}
+	group := func(exprs []clause.Expression, sep string) string {
+		parts := make([]string, len(exprs))
+		for i, e := range exprs {
+			parts[i] = describe(e, table)
+		}
+		return "(" + strings.Join(parts, sep) + ")"
+	}
+	switch e := e.(type) {
+	case clause.Expr:
+		return strings.Join(strings.Fields(e.SQL), " ")
+	case clause.NamedExpr:
+		return strings.Join(strings.Fields(e.SQL), " ")
+	case clause.Eq:
+		return col(e.Column) + " ="
+	case clause.Neq:
+		return col(e.Column) + " <>"
+	case clause.Gt:
+		return col(e.Column) + " >"
+	case clause.Gte:
+		return col(e.Column) + " >="
+	case clause.Lt:
+		return col(e.Column) + " <"
+	case clause.Lte:
+		return col(e.Column) + " <="
+	case clause.Like:
+		return col(e.Column) + " LIKE"
+	case clause.IN:
+		return col(e.Column) + " IN"
+	case clause.AndConditions:
+		return group(e.Exprs, " AND ")
+	case clause.OrConditions:
+		return group(e.Exprs, " OR ")
+	case clause.NotConditions:
+		return "NOT " + group(e.Exprs, " AND ")
+	}
+	return fmt.Sprintf("%T", e)
+}
+
+func (t *Tracker) maxQueries() int {
+	if t.MaxQueries <= 0 {
+		return 1000
+	}
+	return t.MaxQueries
+}
@@ -0,0 +1,91 @@
+package usage_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/yourorg/Go/models"
+	"github.com/yourorg/Go/repos"
+	"github.com/yourorg/Go/usage"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestTrackerAttributesQueriesToRepoMethods(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(emptyConnector{})}),
+		&gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("opening db: %v", err)
+	}
+	tracker := &usage.Tracker{}
+	if err := tracker.Install(db); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	jobs := &repos.JobRepo{DB: db}
+	ctx := usage.WithService(context.Background(), "billing")
+	for range 2 {
+		jobs.FindActiveJobsByCompany(ctx, "c1")
+	}
+	var direct []models.Job
+	db.Find(&direct) // outside the repositories: not tracked
+
+	report := tracker.Report()
+	if len(report.Queries) != 1 {
+		t.Fatalf("queries = %+v, want one shape", report.Queries)
+	}
+	q := report.Queries[0]
+	if q.Method != "JobRepo.FindActiveJobsByCompany" || q.Service != "billing" || q.Calls != 2 || q.Table != "jobs" {
+		t.Errorf("query = %+v", q)
+	}
+	if !strings.HasSuffix(q.Caller, "usage_test.TestTrackerAttributesQueriesToRepoMethods") {
+		t.Errorf("caller = %q", q.Caller)
+	}
+	if f := strings.Join(q.Filters, ", "); !strings.Contains(f, "jobs.status =") || !strings.Contains(f, "jobs.company_id =") {
+		t.Errorf("filters = %v", q.Filters)
+	}
+
+	calls := map[string]int64{}
+	for _, m := range report.Methods {
+		calls[m.Method] = m.Queries
+	}
+	if calls["JobRepo.FindActiveJobsByCompany"] != 2 {
+		t.Errorf("methods = %+v", report.Methods)
+	}
+	if n, ok := calls["JobRepo.FindActiveJobsByContractor"]; !ok || n != 0 {
+		t.Errorf("uncalled finder reported as %d, %v", n, ok)
+	}
+}
+
+// emptyConnector connects to a database answering every query with no rows.
+type emptyConnector struct{}
+
+func (emptyConnector) Connect(context.Context) (driver.Conn, error) { return emptyConn{}, nil }
+func (emptyConnector) Driver() driver.Driver                        { return nil }
+
+type emptyConn struct{}
+
+func (emptyConn) Prepare(string) (driver.Stmt, error) { return emptyStmt{}, nil }
+func (emptyConn) Close() error                        { return nil }
+func (emptyConn) Begin() (driver.Tx, error)           { return emptyTx{}, nil }
+
+type emptyTx struct{}
+
+func (emptyTx) Commit() error   { return nil }
+func (emptyTx) Rollback() error { return nil }
+
+type emptyStmt struct{}
+
+func (emptyStmt) Close() error                               { return nil }
+func (emptyStmt) NumInput() int                              { return -1 }
+func (emptyStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(0), nil }
+func (emptyStmt) Query([]driver.Value) (driver.Rows, error)  { return emptyRows{}, nil }
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return nil }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
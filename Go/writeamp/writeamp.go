@@ -0,0 +1,181 @@
+// Package writeamp measures the write amplification versioning adds: how many
+// bytes a logical update costs once the whole row is copied into a new
+// version and every index gains an entry for it. Reports help decide whether
+// a model is worth a vertical split or another storage strategy.
+package writeamp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourorg/Go/scd"
+	"gorm.io/gorm"
+)
+
+// Report describes the versions of one table written during a workload.
+type Report struct {
+	Table string `json:"table"`
+	// Versions is the number of logical updates, i.e. versions written.
+	Versions int64 `json:"versions"`
+	// ChangedBytes is the size of the values that differ from the previous
+	// version, or of the whole row for an entity's first version: the bytes a
+	// non-versioned table would have had to write.
+	ChangedBytes int64 `json:"changed_bytes"`
+	// RowBytes is the size of the version rows written.
+	RowBytes int64 `json:"row_bytes"`
+	// IndexEntries counts the index entries added, one per index per version.
+	IndexEntries int64 `json:"index_entries"`
+
+	// The fields below are only set by Measure, which observes the database
+	// while the workload runs.
+
+	// HeapBytes is the growth of the table on disk.
+	HeapBytes int64 `json:"heap_bytes,omitempty"`
+	// IndexBytes is the growth of each index on disk.
+	IndexBytes map[string]int64 `json:"index_bytes,omitempty"`
+	// WALBytes is the write-ahead log written meanwhile, by every session.
+	WALBytes int64 `json:"wal_bytes,omitempty"`
+}
+
+// Written returns the bytes the workload stored: the on-disk growth of the
+// table and its indexes when measured, the row sizes otherwise.
+func (r Report) Written() int64 {
+	if r.HeapBytes == 0 && len(r.IndexBytes) == 0 {
+		return r.RowBytes
+	}
+	n := r.HeapBytes
+	for _, b := range r.IndexBytes {
+		n += b
+	}
+	return n
+}
+
+// BytesPerUpdate returns Written divided by Versions.
+func (r Report) BytesPerUpdate() float64 {
+	if r.Versions == 0 {
+		return 0
+	}
+	return float64(r.Written()) / float64(r.Versions)
+}
+
+// Amplification returns Written divided by ChangedBytes: how many bytes are
+// stored for every byte that actually changed.
+func (r Report) Amplification() float64 {
+	if r.ChangedBytes == 0 {
+		return 0
+	}
+	return float64(r.Written()) / float64(r.ChangedBytes)
+}
+
+// Analyze reports on the versions of model created in [from, to), e.g. a
+// workload recorded in production. Sizes are those of the stored values, so
+// storage overhead such as page fill and index bloat is not included; use
+// Measure for that.
+func Analyze(ctx context.Context, db *gorm.DB, model any, from, to time.Time) (Report, error) {
+	info, err := scd.Describe(db, model)
+	if err != nil {
+		return Report{}, err
+	}
+	db = db.WithContext(ctx)
+	report := Report{Table: info.Table}
+
+	changed := make([]string, len(info.Columns))
+	for i, c := range info.Columns {
+		changed[i] = fmt.Sprintf("CASE WHEN %[1]s IS DISTINCT FROM LAG(%[1]s) OVER w THEN COALESCE(pg_column_size(%[1]s), 0) ELSE 0 END", c)
+	}
+	query := fmt.Sprintf(`SELECT COUNT(*) AS versions, COALESCE(SUM(row_bytes), 0) AS row_bytes, COALESCE(SUM(changed_bytes), 0) AS changed_bytes
+		FROM (
+			SELECT created_at, pg_column_size(t.*) AS row_bytes,
+				CASE WHEN LAG(version) OVER w IS NULL THEN pg_column_size(t.*) ELSE %[2]s END AS changed_bytes
+			FROM %[1]s t
+			WHERE t.id IN (SELECT id FROM %[1]s WHERE created_at >= @from AND created_at < @to)
+			WINDOW w AS (PARTITION BY id ORDER BY version)
+		) v
+		WHERE created_at >= @from AND created_at < @to`, info.Table, strings.Join(changed, " + "))
+	var totals struct {
+		Versions     int64
+		RowBytes     int64
+		ChangedBytes int64
+	}
+	err = db.Raw(query, map[string]any{"from": from, "to": to}).Scan(&totals).Error
+	if err != nil {
+		return report, fmt.Errorf("analyzing writes to %s failed: %w", info.Table, err)
+	}
+	report.Versions, report.RowBytes, report.ChangedBytes = totals.Versions, totals.RowBytes, totals.ChangedBytes
+	indexes, err := relationSizes(db, info.Table)
+	if err != nil {
+		return report, err
+	}
+	// relationSizes includes the table itself.
+	report.IndexEntries = report.Versions * int64(len(indexes)-1)
+	return report, nil
+}
+
+// Measure runs workload and reports on the versions of model it wrote,
+// including the growth of the table, its indexes and the write-ahead log.
+// Other writers running at the same time inflate the figures, so measure on
+// an otherwise idle database.
+func Measure(ctx context.Context, db *gorm.DB, model any, workload func(ctx context.Context) error) (Report, error) {
+	info, err := scd.Describe(db, model)
+	if err != nil {
+		return Report{}, err
+	}
+	db = db.WithContext(ctx)
+	before, err := relationSizes(db, info.Table)
+	if err != nil {
+		return Report{}, err
+	}
+	var startLSN string
+	if err := db.Raw("SELECT CAST(pg_current_wal_insert_lsn() AS text)").Scan(&startLSN).Error; err != nil {
+		return Report{}, fmt.Errorf("reading WAL position failed: %w", err)
+	}
+	start := time.Now()
+
+	if err := workload(ctx); err != nil {
+		return Report{}, err
+	}
+
+	report, err := Analyze(ctx, db, model, start, time.Now())
+	if err != nil {
+		return report, err
+	}
+	after, err := relationSizes(db, info.Table)
+	if err != nil {
+		return report, err
+	}
+	report.HeapBytes = after[info.Table] - before[info.Table]
+	report.IndexBytes = map[string]int64{}
+	for name, size := range after {
+		if name != info.Table {
+			report.IndexBytes[name] = size - before[name]
+		}
+	}
+	err = db.Raw("SELECT CAST(pg_wal_lsn_diff(pg_current_wal_insert_lsn(), CAST(? AS pg_lsn)) AS bigint)", startLSN).
+		Scan(&report.WALBytes).Error
+	if err != nil {
+		return report, fmt.Errorf("reading WAL position failed: %w", err)
+	}
+	return report, nil
+}
+
+// relationSizes returns the on-disk size of table and of each of its indexes, by name.
+func relationSizes(db *gorm.DB, table string) (map[string]int64, error) {
+	var rows []struct {
+		Name  string
+		Bytes int64
+	}
+	err := db.Raw(`SELECT c.relname AS name, pg_relation_size(c.oid) AS bytes FROM pg_class c
+		WHERE c.oid = CAST(@table AS regclass)
+		OR c.oid IN (SELECT indexrelid FROM pg_index WHERE indrelid = CAST(@table AS regclass))`,
+		map[string]any{"table": table}).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("reading sizes of %s failed: %w", table, err)
+	}
+	sizes := make(map[string]int64, len(rows))
+	for _, r := range rows {
+		sizes[r.Name] = r.Bytes
+	}
+	return sizes, nil
+}
@@ -0,0 +1,27 @@
+package writeamp_test
+
+import (
+	"testing"
+
+	"github.com/yourorg/Go/writeamp"
+)
+
+func TestReportRatios(t *testing.T) {
+	analyzed := writeamp.Report{Versions: 4, ChangedBytes: 100, RowBytes: 800}
+	if got := analyzed.BytesPerUpdate(); got != 200 {
+		t.Errorf("analyzed bytes per update = %v, want 200", got)
+	}
+	if got := analyzed.Amplification(); got != 8 {
+		t.Errorf("analyzed amplification = %v, want 8", got)
+	}
+
+	measured := analyzed
+	measured.HeapBytes = 8192
+	measured.IndexBytes = map[string]int64{"jobs_pkey": 8192, "idx_jobs_uid": 0}
+	if got := measured.Written(); got != 16384 {
+		t.Errorf("measured written = %d, want on-disk growth 16384", got)
+	}
+	if got := (writeamp.Report{}).Amplification(); got != 0 {
+		t.Errorf("empty report amplification = %v, want 0", got)
+	}
+}